@@ -48,9 +48,15 @@ func (src *Cluster) ConvertTo(dstRaw conversion.Hub) error {
 	}
 
 	dst.Spec.ControlPlaneRef = restored.Spec.ControlPlaneRef
+	dst.Spec.Topology = restored.Spec.Topology
+	dst.Spec.PropagatedLabels = restored.Spec.PropagatedLabels
+	if restored.Spec.ClusterNetwork != nil && dst.Spec.ClusterNetwork != nil {
+		dst.Spec.ClusterNetwork.AdditionalNetworks = restored.Spec.ClusterNetwork.AdditionalNetworks
+	}
 	dst.Status.ControlPlaneReady = restored.Status.ControlPlaneReady
 	dst.Status.FailureDomains = restored.Status.FailureDomains
 	dst.Spec.Paused = restored.Spec.Paused
+	dst.Status.Conditions = restored.Status.Conditions
 
 	return nil
 }
@@ -115,6 +121,9 @@ func (src *Machine) ConvertTo(dstRaw conversion.Hub) error {
 		return err
 	}
 	restoreMachineSpec(&restored.Spec, &dst.Spec)
+	dst.Status.Conditions = restored.Status.Conditions
+	dst.Status.BootstrapDataHash = restored.Status.BootstrapDataHash
+	dst.Status.LastSuccessfulReconcileTime = restored.Status.LastSuccessfulReconcileTime
 
 	return nil
 }
@@ -124,7 +133,18 @@ func restoreMachineSpec(restored *v1alpha3.MachineSpec, dst *v1alpha3.MachineSpe
 		dst.ClusterName = restored.ClusterName
 	}
 	dst.Bootstrap.DataSecretName = restored.Bootstrap.DataSecretName
+	dst.Bootstrap.Format = restored.Bootstrap.Format
+	dst.Bootstrap.ReProvisionOnChange = restored.Bootstrap.ReProvisionOnChange
+	dst.PropagatedInfrastructureLabels = restored.PropagatedInfrastructureLabels
 	dst.FailureDomain = restored.FailureDomain
+	dst.Taints = restored.Taints
+	dst.ReadinessGates = restored.ReadinessGates
+	dst.MaxUnavailableDuringUpgrade = restored.MaxUnavailableDuringUpgrade
+	dst.Paused = restored.Paused
+	dst.IgnoreNodeDrain = restored.IgnoreNodeDrain
+	dst.NodeDeletionTimeout = restored.NodeDeletionTimeout
+	dst.NodeUnresponsiveTimeout = restored.NodeUnresponsiveTimeout
+	dst.NodeName = restored.NodeName
 }
 
 func (dst *Machine) ConvertFrom(srcRaw conversion.Hub) error {
@@ -181,6 +201,10 @@ func (src *MachineSet) ConvertTo(dstRaw conversion.Hub) error {
 	if restored.Spec.ClusterName != "" {
 		dst.Spec.ClusterName = restored.Spec.ClusterName
 	}
+	dst.Spec.TopologySpreadConstraints = restored.Spec.TopologySpreadConstraints
+	dst.Spec.MachineNamingStrategy = restored.Spec.MachineNamingStrategy
+	dst.Spec.UpdateStrategy = restored.Spec.UpdateStrategy
+	dst.Status.FailedMachines = restored.Status.FailedMachines
 	restoreMachineSpec(&restored.Spec.Template.Spec, &dst.Spec.Template.Spec)
 
 	return nil
@@ -240,7 +264,15 @@ func (src *MachineDeployment) ConvertTo(dstRaw conversion.Hub) error {
 		dst.Spec.ClusterName = restored.Spec.ClusterName
 	}
 	dst.Spec.Paused = restored.Spec.Paused
+	dst.Spec.PropagatedAnnotations = restored.Spec.PropagatedAnnotations
+	dst.Spec.TopologySpreadConstraints = restored.Spec.TopologySpreadConstraints
+	if restored.Spec.Strategy != nil && restored.Spec.Strategy.RollingUpdate != nil &&
+		dst.Spec.Strategy != nil && dst.Spec.Strategy.RollingUpdate != nil {
+		dst.Spec.Strategy.RollingUpdate.CanaryReplicas = restored.Spec.Strategy.RollingUpdate.CanaryReplicas
+	}
 	dst.Status.Phase = restored.Status.Phase
+	dst.Status.Conditions = restored.Status.Conditions
+	dst.Status.GenerationLag = restored.Status.GenerationLag
 	restoreMachineSpec(&restored.Spec.Template.Spec, &dst.Spec.Template.Spec)
 
 	return nil
@@ -369,6 +401,10 @@ func Convert_v1alpha3_ClusterSpec_To_v1alpha2_ClusterSpec(in *v1alpha3.ClusterSp
 	return nil
 }
 
+func Convert_v1alpha3_ClusterNetwork_To_v1alpha2_ClusterNetwork(in *v1alpha3.ClusterNetwork, out *ClusterNetwork, s apiconversion.Scope) error {
+	return autoConvert_v1alpha3_ClusterNetwork_To_v1alpha2_ClusterNetwork(in, out, s)
+}
+
 func Convert_v1alpha3_MachineStatus_To_v1alpha2_MachineStatus(in *v1alpha3.MachineStatus, out *MachineStatus, s apiconversion.Scope) error {
 	if err := autoConvert_v1alpha3_MachineStatus_To_v1alpha2_MachineStatus(in, out, s); err != nil {
 		return err
@@ -389,6 +425,10 @@ func Convert_v1alpha3_MachineDeploymentStatus_To_v1alpha2_MachineDeploymentStatu
 	return autoConvert_v1alpha3_MachineDeploymentStatus_To_v1alpha2_MachineDeploymentStatus(in, out, s)
 }
 
+func Convert_v1alpha3_MachineRollingUpdateDeployment_To_v1alpha2_MachineRollingUpdateDeployment(in *v1alpha3.MachineRollingUpdateDeployment, out *MachineRollingUpdateDeployment, s apiconversion.Scope) error {
+	return autoConvert_v1alpha3_MachineRollingUpdateDeployment_To_v1alpha2_MachineRollingUpdateDeployment(in, out, s)
+}
+
 func Convert_v1alpha3_MachineSetSpec_To_v1alpha2_MachineSetSpec(in *v1alpha3.MachineSetSpec, out *MachineSetSpec, s apiconversion.Scope) error {
 	return autoConvert_v1alpha3_MachineSetSpec_To_v1alpha2_MachineSetSpec(in, out, s)
 }
@@ -55,10 +55,74 @@ type ClusterSpec struct {
 	// for provisioning infrastructure for a cluster in said provider.
 	// +optional
 	InfrastructureRef *corev1.ObjectReference `json:"infrastructureRef,omitempty"`
+
+	// Topology encapsulates the topology for the cluster.
+	// If set, the infrastructure and control plane objects of the Cluster
+	// are created from the templates of the referenced ClusterClass, and
+	// the control plane and the worker MachineSets are reconciled to match it.
+	// +optional
+	Topology *Topology `json:"topology,omitempty"`
+
+	// PropagatedLabels is a list of label keys that are copied from the Cluster
+	// to the MachineDeployments and MachineSets belonging to it.
+	// Propagation is additive only: removing a key from the list, or the label
+	// from the Cluster, doesn't remove the label from the objects it was copied to.
+	// +optional
+	PropagatedLabels []string `json:"propagatedLabels,omitempty"`
 }
 
 // ANCHOR_END: ClusterSpec
 
+// Topology encapsulates the information of the managed resources.
+type Topology struct {
+	// Class is the name of the ClusterClass object to create the topology from.
+	// The ClusterClass must be in the same namespace as the Cluster.
+	// If empty, the infrastructure and control plane references must be set on the Cluster.
+	// +optional
+	Class string `json:"class,omitempty"`
+
+	// ControlPlane describes the desired state of the control plane referenced by the Cluster.
+	// +optional
+	ControlPlane *TopologyClass `json:"controlPlane,omitempty"`
+
+	// Workers is the list of the worker groups of the Cluster. A MachineSet named
+	// after the Cluster and the worker group is maintained for each of them.
+	// +optional
+	Workers []TopologyWorkerClass `json:"workers,omitempty"`
+}
+
+// TopologyClass describes the desired state of the control plane of a Cluster.
+type TopologyClass struct {
+	// MachineCount is the number of control plane Machines.
+	// It is set as spec.replicas of the control plane object.
+	// +kubebuilder:validation:Minimum=0
+	MachineCount int32 `json:"machineCount"`
+
+	// Version is the Kubernetes version of the control plane.
+	// It is set as spec.version of the control plane object.
+	// +optional
+	Version string `json:"version,omitempty"`
+}
+
+// TopologyWorkerClass describes the desired state of a group of worker Machines of a Cluster.
+type TopologyWorkerClass struct {
+	// Name is the name of the worker group, it must be unique within the Cluster.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// MachineCount is the number of Machines of the worker group.
+	// +kubebuilder:validation:Minimum=0
+	MachineCount int32 `json:"machineCount"`
+
+	// MachineTemplate is the template of the Machines of the worker group.
+	MachineTemplate MachineTemplateSpec `json:"machineTemplate"`
+}
+
+// MachineSetName returns the name of the MachineSet of the worker group in the given Cluster.
+func (w *TopologyWorkerClass) MachineSetName(clusterName string) string {
+	return fmt.Sprintf("%s-%s", clusterName, w.Name)
+}
+
 // ANCHOR: ClusterNetwork
 
 // ClusterNetwork specifies the different networking
@@ -80,10 +144,35 @@ type ClusterNetwork struct {
 	// Domain name for services.
 	// +optional
 	ServiceDomain string `json:"serviceDomain,omitempty"`
+
+	// AdditionalNetworks is a list of networks configured on the cluster
+	// in addition to the primary pod and service networks, e.g. a secondary storage network.
+	// CIDR blocks must not overlap across the primary and the additional networks.
+	// +optional
+	AdditionalNetworks []ClusterNetworkSpec `json:"additionalNetworks,omitempty"`
 }
 
 // ANCHOR_END: ClusterNetwork
 
+// ANCHOR: ClusterNetworkSpec
+
+// ClusterNetworkSpec specifies an additional network of a cluster.
+type ClusterNetworkSpec struct {
+	// Name is the name of the network, it must be unique within the cluster.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// The network ranges from which Pod addresses on this network are allocated.
+	// +optional
+	Pods *NetworkRanges `json:"pods,omitempty"`
+
+	// The network ranges from which service VIPs on this network are allocated.
+	// +optional
+	Services *NetworkRanges `json:"services,omitempty"`
+}
+
+// ANCHOR_END: ClusterNetworkSpec
+
 // ANCHOR: NetworkRanges
 // NetworkRanges represents ranges of network addresses.
 type NetworkRanges struct {
@@ -126,6 +215,10 @@ type ClusterStatus struct {
 	// ControlPlaneReady defines if the control plane is ready.
 	// +optional
 	ControlPlaneReady bool `json:"controlPlaneReady,omitempty"`
+
+	// Conditions defines current service state of the cluster.
+	// +optional
+	Conditions Conditions `json:"conditions,omitempty"`
 }
 
 // ANCHOR_END: ClusterStatus
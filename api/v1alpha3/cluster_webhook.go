@@ -17,8 +17,13 @@ limitations under the License.
 package v1alpha3
 
 import (
+	"fmt"
+	"net"
+	"strings"
+
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
@@ -48,12 +53,12 @@ func (c *Cluster) Default() {
 
 // ValidateCreate implements webhook.Validator so a webhook will be registered for the type
 func (c *Cluster) ValidateCreate() error {
-	return c.validate()
+	return c.validate(true)
 }
 
 // ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
 func (c *Cluster) ValidateUpdate(old runtime.Object) error {
-	return c.validate()
+	return c.validate(false)
 }
 
 // ValidateDelete implements webhook.Validator so a webhook will be registered for the type
@@ -61,7 +66,7 @@ func (c *Cluster) ValidateDelete() error {
 	return nil
 }
 
-func (c *Cluster) validate() error {
+func (c *Cluster) validate(create bool) error {
 	var allErrs field.ErrorList
 	if c.Spec.InfrastructureRef != nil && c.Spec.InfrastructureRef.Namespace != c.Namespace {
 		allErrs = append(
@@ -87,8 +92,117 @@ func (c *Cluster) validate() error {
 
 	}
 
+	// Existing Clusters without services CIDR blocks are still allowed to be updated.
+	if create {
+		allErrs = append(allErrs, c.validateServicesCIDRBlocks()...)
+	}
+	allErrs = append(allErrs, c.validateClusterNetwork()...)
+	allErrs = append(allErrs, c.validateTopology()...)
+
 	if len(allErrs) == 0 {
 		return nil
 	}
 	return apierrors.NewInvalid(GroupVersion.WithKind("Cluster").GroupKind(), c.Name, allErrs)
 }
+
+// validateServicesCIDRBlocks checks that at least one non-blank services CIDR block
+// is set if the cluster network is configured.
+func (c *Cluster) validateServicesCIDRBlocks() field.ErrorList {
+	if c.Spec.ClusterNetwork == nil {
+		return nil
+	}
+
+	if c.Spec.ClusterNetwork.Services != nil {
+		for _, cidr := range c.Spec.ClusterNetwork.Services.CIDRBlocks {
+			if strings.TrimSpace(cidr) != "" {
+				return nil
+			}
+		}
+	}
+	return field.ErrorList{field.Required(
+		field.NewPath("spec", "clusterNetwork", "services", "cidrBlocks"),
+		"at least one services CIDR block must be set when spec.clusterNetwork is set",
+	)}
+}
+
+// validateClusterNetwork checks that the CIDR blocks of the primary and the additional
+// networks are valid and do not overlap.
+func (c *Cluster) validateClusterNetwork() field.ErrorList {
+	if c.Spec.ClusterNetwork == nil {
+		return nil
+	}
+
+	type cidrBlock struct {
+		path  *field.Path
+		value string
+		net   *net.IPNet
+	}
+
+	var (
+		allErrs field.ErrorList
+		blocks  []cidrBlock
+	)
+	addRanges := func(path *field.Path, ranges *NetworkRanges) {
+		if ranges == nil {
+			return
+		}
+		for i, cidr := range ranges.CIDRBlocks {
+			p := path.Child("cidrBlocks").Index(i)
+			_, ipNet, err := net.ParseCIDR(cidr)
+			if err != nil {
+				allErrs = append(allErrs, field.Invalid(p, cidr, "must be a valid CIDR block"))
+				continue
+			}
+			blocks = append(blocks, cidrBlock{path: p, value: cidr, net: ipNet})
+		}
+	}
+
+	networkPath := field.NewPath("spec", "clusterNetwork")
+	addRanges(networkPath.Child("pods"), c.Spec.ClusterNetwork.Pods)
+	addRanges(networkPath.Child("services"), c.Spec.ClusterNetwork.Services)
+
+	names := map[string]bool{}
+	for i, network := range c.Spec.ClusterNetwork.AdditionalNetworks {
+		path := networkPath.Child("additionalNetworks").Index(i)
+		if names[network.Name] {
+			allErrs = append(allErrs, field.Duplicate(path.Child("name"), network.Name))
+		}
+		names[network.Name] = true
+		addRanges(path.Child("pods"), network.Pods)
+		addRanges(path.Child("services"), network.Services)
+	}
+
+	for i := range blocks {
+		for j := 0; j < i; j++ {
+			if blocks[i].net.Contains(blocks[j].net.IP) || blocks[j].net.Contains(blocks[i].net.IP) {
+				allErrs = append(allErrs, field.Invalid(blocks[i].path, blocks[i].value,
+					fmt.Sprintf("overlaps with %s (%s)", blocks[j].path, blocks[j].value)))
+			}
+		}
+	}
+
+	return allErrs
+}
+
+// validateTopology checks that the worker groups of the topology have unique names
+// and that the names of their MachineSets are valid.
+func (c *Cluster) validateTopology() field.ErrorList {
+	if c.Spec.Topology == nil {
+		return nil
+	}
+
+	var allErrs field.ErrorList
+	names := map[string]bool{}
+	for i := range c.Spec.Topology.Workers {
+		worker := &c.Spec.Topology.Workers[i]
+		path := field.NewPath("spec", "topology", "workers").Index(i).Child("name")
+		if names[worker.Name] {
+			allErrs = append(allErrs, field.Duplicate(path, worker.Name))
+		}
+		names[worker.Name] = true
+		for _, msg := range validation.IsDNS1123Subdomain(worker.MachineSetName(c.Name)) {
+			allErrs = append(allErrs, field.Invalid(path, worker.Name, "the MachineSet name is invalid: "+msg))
+		}
+	}
+	return allErrs
+}
@@ -99,3 +99,234 @@ func TestClusterValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestClusterValidationNetworks(t *testing.T) {
+	clusterWithNetwork := func(network *ClusterNetwork) *Cluster {
+		return &Cluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "foo",
+			},
+			Spec: ClusterSpec{
+				ClusterNetwork: network,
+			},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		expectErr bool
+		c         *Cluster
+	}{
+		{
+			name:      "should succeed when cluster network is not set",
+			expectErr: false,
+			c:         clusterWithNetwork(nil),
+		},
+		{
+			name:      "should succeed when CIDR blocks do not overlap",
+			expectErr: false,
+			c: clusterWithNetwork(&ClusterNetwork{
+				Pods:     &NetworkRanges{CIDRBlocks: []string{"192.168.0.0/16"}},
+				Services: &NetworkRanges{CIDRBlocks: []string{"10.96.0.0/12"}},
+				AdditionalNetworks: []ClusterNetworkSpec{
+					{
+						Name: "storage",
+						Pods: &NetworkRanges{CIDRBlocks: []string{"172.16.0.0/16"}},
+					},
+				},
+			}),
+		},
+		{
+			name:      "should return error when a CIDR block is invalid",
+			expectErr: true,
+			c: clusterWithNetwork(&ClusterNetwork{
+				AdditionalNetworks: []ClusterNetworkSpec{
+					{
+						Name: "storage",
+						Pods: &NetworkRanges{CIDRBlocks: []string{"172.16.0.0"}},
+					},
+				},
+			}),
+		},
+		{
+			name:      "should return error when an additional network overlaps with the pod network",
+			expectErr: true,
+			c: clusterWithNetwork(&ClusterNetwork{
+				Pods: &NetworkRanges{CIDRBlocks: []string{"192.168.0.0/16"}},
+				AdditionalNetworks: []ClusterNetworkSpec{
+					{
+						Name: "storage",
+						Pods: &NetworkRanges{CIDRBlocks: []string{"192.168.10.0/24"}},
+					},
+				},
+			}),
+		},
+		{
+			name:      "should return error when additional networks overlap with each other",
+			expectErr: true,
+			c: clusterWithNetwork(&ClusterNetwork{
+				AdditionalNetworks: []ClusterNetworkSpec{
+					{
+						Name: "storage",
+						Pods: &NetworkRanges{CIDRBlocks: []string{"172.16.0.0/16"}},
+					},
+					{
+						Name:     "backup",
+						Services: &NetworkRanges{CIDRBlocks: []string{"172.0.0.0/8"}},
+					},
+				},
+			}),
+		},
+		{
+			name:      "should return error when additional network names are duplicated",
+			expectErr: true,
+			c: clusterWithNetwork(&ClusterNetwork{
+				AdditionalNetworks: []ClusterNetworkSpec{
+					{Name: "storage"},
+					{Name: "storage"},
+				},
+			}),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			if tt.expectErr {
+				g.Expect(tt.c.ValidateCreate()).NotTo(Succeed())
+				g.Expect(tt.c.ValidateUpdate(nil)).NotTo(Succeed())
+			} else {
+				g.Expect(tt.c.ValidateCreate()).To(Succeed())
+				g.Expect(tt.c.ValidateUpdate(nil)).To(Succeed())
+			}
+		})
+	}
+}
+
+func TestClusterValidationServicesCIDRBlocks(t *testing.T) {
+	clusterWithServices := func(services *NetworkRanges) *Cluster {
+		return &Cluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "foo",
+			},
+			Spec: ClusterSpec{
+				ClusterNetwork: &ClusterNetwork{
+					Pods:     &NetworkRanges{CIDRBlocks: []string{"192.168.0.0/16"}},
+					Services: services,
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		expectErr bool
+		c         *Cluster
+	}{
+		{
+			name:      "should succeed when services CIDR blocks are set",
+			expectErr: false,
+			c:         clusterWithServices(&NetworkRanges{CIDRBlocks: []string{"10.96.0.0/12"}}),
+		},
+		{
+			name:      "should return error when services are not set",
+			expectErr: true,
+			c:         clusterWithServices(nil),
+		},
+		{
+			name:      "should return error when services CIDR blocks are empty",
+			expectErr: true,
+			c:         clusterWithServices(&NetworkRanges{}),
+		},
+		{
+			name:      "should return error when services CIDR blocks are blank",
+			expectErr: true,
+			c:         clusterWithServices(&NetworkRanges{CIDRBlocks: []string{"", " "}}),
+		},
+		{
+			name:      "should return error when a services CIDR block is invalid",
+			expectErr: true,
+			c:         clusterWithServices(&NetworkRanges{CIDRBlocks: []string{"10.96.0.0"}}),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			if tt.expectErr {
+				g.Expect(tt.c.ValidateCreate()).NotTo(Succeed())
+			} else {
+				g.Expect(tt.c.ValidateCreate()).To(Succeed())
+			}
+		})
+	}
+}
+
+func TestClusterValidationServicesCIDRBlocksOnUpdate(t *testing.T) {
+	g := NewWithT(t)
+
+	// Clusters created without services CIDR blocks can still be updated.
+	c := &Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "foo",
+		},
+		Spec: ClusterSpec{
+			ClusterNetwork: &ClusterNetwork{
+				Pods: &NetworkRanges{CIDRBlocks: []string{"192.168.0.0/16"}},
+			},
+		},
+	}
+	g.Expect(c.ValidateUpdate(c.DeepCopy())).To(Succeed())
+}
+
+func TestClusterValidationTopology(t *testing.T) {
+	clusterWithWorkers := func(workers ...TopologyWorkerClass) *Cluster {
+		return &Cluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-cluster",
+				Namespace: "foo",
+			},
+			Spec: ClusterSpec{
+				Topology: &Topology{Workers: workers},
+			},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		expectErr bool
+		c         *Cluster
+	}{
+		{
+			name:      "should succeed when worker names are unique",
+			expectErr: false,
+			c:         clusterWithWorkers(TopologyWorkerClass{Name: "small"}, TopologyWorkerClass{Name: "large"}),
+		},
+		{
+			name:      "should return error when worker names are duplicated",
+			expectErr: true,
+			c:         clusterWithWorkers(TopologyWorkerClass{Name: "small"}, TopologyWorkerClass{Name: "small"}),
+		},
+		{
+			name:      "should return error when the MachineSet name is invalid",
+			expectErr: true,
+			c:         clusterWithWorkers(TopologyWorkerClass{Name: "Small_Workers"}),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			if tt.expectErr {
+				g.Expect(tt.c.ValidateCreate()).NotTo(Succeed())
+				g.Expect(tt.c.ValidateUpdate(nil)).NotTo(Succeed())
+			} else {
+				g.Expect(tt.c.ValidateCreate()).To(Succeed())
+				g.Expect(tt.c.ValidateUpdate(nil)).To(Succeed())
+			}
+		})
+	}
+}
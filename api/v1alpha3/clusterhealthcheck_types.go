@@ -0,0 +1,138 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha3
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	// ClusterHealthCheckFinalizer allows the ClusterHealthCheck controller to remove the result
+	// of a ClusterHealthCheck from the conditions of its Cluster before it is deleted.
+	ClusterHealthCheckFinalizer = "clusterhealthcheck.cluster.x-k8s.io"
+
+	// ClusterRemediationRequestedAnnotation is set by the ClusterHealthCheck controller on a Cluster whose
+	// ClusterHealthChecks fail, to the RFC3339 time it became unhealthy, and removed once the Cluster is healthy again.
+	// The ClusterHealthCheck controller doesn't remediate Clusters itself: this annotation is the hook for the
+	// controllers or operators that do.
+	ClusterRemediationRequestedAnnotation = "cluster.x-k8s.io/remediation-requested"
+)
+
+// ANCHOR: ClusterHealthCheckSpec
+
+// ClusterHealthCheckSpec defines the desired state of ClusterHealthCheck
+type ClusterHealthCheckSpec struct {
+	// ClusterName is the name of the Cluster this object belongs to.
+	// +kubebuilder:validation:MinLength=1
+	ClusterName string `json:"clusterName"`
+
+	// Checks contains a list of the conditions that determine whether the
+	// cluster is considered healthy. The checks are combined in a logical AND,
+	// i.e. if any of the checks fails, the cluster is unhealthy.
+	//
+	// +kubebuilder:validation:MinItems=1
+	Checks []ClusterHealthCondition `json:"checks"`
+}
+
+// ANCHOR_END: ClusterHealthCheckSpec
+
+// ANCHOR: ClusterHealthCondition
+
+// ClusterHealthCondition selects a set of resources in the workload cluster, e.g. the
+// control plane component or etcd Pods, and the minimum number of them that must be ready
+// for the cluster to be considered healthy.
+type ClusterHealthCondition struct {
+	// Name identifies the check in the status of the Cluster.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Kind is the type of resource this check selects in the workload cluster.
+	Kind ClusterHealthCheckKind `json:"kind"`
+
+	// Namespace restricts the check to resources in the given namespace.
+	// It is ignored for cluster scoped kinds such as Node.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Label selector to match the resources whose readiness will be checked.
+	Selector metav1.LabelSelector `json:"selector"`
+
+	// MinReady is the minimum number, or percentage, of the selected resources that
+	// must be ready for the check to pass. Defaults to 100%.
+	// A check that does not select any resource always fails.
+	// +optional
+	MinReady *intstr.IntOrString `json:"minReady,omitempty"`
+}
+
+// ClusterHealthCheckKind defines the kind of resource evaluated by a ClusterHealthCondition.
+// +kubebuilder:validation:Enum=Node;Pod
+type ClusterHealthCheckKind string
+
+const (
+	// ClusterHealthCheckKindNode checks the NodeReady condition of the selected Nodes.
+	ClusterHealthCheckKindNode ClusterHealthCheckKind = "Node"
+
+	// ClusterHealthCheckKindPod checks the PodReady condition of the selected Pods.
+	ClusterHealthCheckKindPod ClusterHealthCheckKind = "Pod"
+)
+
+// ANCHOR_END: ClusterHealthCondition
+
+// ANCHOR: ClusterHealthCheckStatus
+
+// ClusterHealthCheckStatus defines the observed state of ClusterHealthCheck
+type ClusterHealthCheckStatus struct {
+	// Conditions defines current service state of the ClusterHealthCheck.
+	// +optional
+	Conditions Conditions `json:"conditions,omitempty"`
+}
+
+// ANCHOR_END: ClusterHealthCheckStatus
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:path=clusterhealthchecks,shortName=chc;chcs,scope=Namespaced,categories=cluster-api
+// +kubebuilder:storageversion
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Cluster",type="string",JSONPath=".spec.clusterName",description="Cluster monitored by this health check"
+
+// ClusterHealthCheck is the Schema for the clusterhealthchecks API.
+// The results of the ClusterHealthChecks of a Cluster are merged into its Healthy condition, and the Cluster
+// is annotated with ClusterRemediationRequestedAnnotation while any of them fails.
+type ClusterHealthCheck struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Specification of cluster health check policy
+	Spec ClusterHealthCheckSpec `json:"spec,omitempty"`
+
+	// Most recently observed status of ClusterHealthCheck resource
+	Status ClusterHealthCheckStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ClusterHealthCheckList contains a list of ClusterHealthCheck
+type ClusterHealthCheckList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterHealthCheck `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterHealthCheck{}, &ClusterHealthCheckList{})
+}
@@ -26,6 +26,10 @@ const (
 	// external objects(bootstrap and infrastructure providers)
 	ClusterLabelName = "cluster.x-k8s.io/cluster-name"
 
+	// TopologyWorkerLabelName is the label set on the MachineSets, and their Machines,
+	// created for a worker group of a Cluster topology.
+	TopologyWorkerLabelName = "topology.cluster.x-k8s.io/worker-name"
+
 	// ProviderLabelName is the label set on components in the provider manifest.
 	// This label allows to easily identify all the components belonging to a provider; the clusterctl
 	// tool uses this label for implementing provider's lifecycle operations.
@@ -38,6 +42,10 @@ const (
 	// on the reconciled object.
 	PausedAnnotation = "cluster.x-k8s.io/paused"
 
+	// CleanupOrphansAnnotation is an annotation that can be applied to a Cluster to request the deletion
+	// of infrastructure objects that carry the Cluster label but are no longer referenced by the Cluster.
+	CleanupOrphansAnnotation = "cluster.x-k8s.io/cleanup-orphans"
+
 	// ClusterSecretType defines the type of secret created by core components
 	ClusterSecretType corev1.SecretType = "cluster.x-k8s.io/secret" //nolint:gosec
 )
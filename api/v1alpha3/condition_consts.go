@@ -0,0 +1,131 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha3
+
+// Conditions and condition Reasons for the Cluster object
+
+const (
+	// InfrastructureProviderInstalledCondition documents whether the API of the infrastructure provider
+	// referenced by the Cluster is served by the management cluster.
+	InfrastructureProviderInstalledCondition ConditionType = "InfrastructureProviderInstalled"
+
+	// InfrastructureProviderNotInstalledReason (Severity=Error) documents that the kind referenced by
+	// cluster.spec.infrastructureRef is not served by the API server.
+	InfrastructureProviderNotInstalledReason = "InfrastructureProviderNotInstalled"
+
+	// ClusterHealthyCondition documents whether the checks defined by the ClusterHealthChecks
+	// targeting the Cluster pass against the workload cluster.
+	ClusterHealthyCondition ConditionType = "Healthy"
+
+	// ClusterHealthCheckFailedReason (Severity=Warning) documents that at least one of the checks
+	// of a ClusterHealthCheck did not find enough ready resources in the workload cluster.
+	ClusterHealthCheckFailedReason = "ClusterHealthCheckFailed"
+
+	// ControlPlaneQuorumLostCondition documents that not enough control plane Machines of the Cluster
+	// are healthy to keep a majority, i.e. more than half of them are failed or being deleted.
+	// It is removed from the Cluster once the quorum is restored.
+	ControlPlaneQuorumLostCondition ConditionType = "ControlPlaneQuorumLost"
+)
+
+// Conditions and condition Reasons for the Machine object
+
+const (
+	// BootstrapReadyCondition reports on the state of the bootstrap data of the Machine.
+	BootstrapReadyCondition ConditionType = "BootstrapReady"
+
+	// WaitingForDataSecretReason (Severity=Info) documents a machine waiting for the bootstrap provider
+	// to generate the secret containing its bootstrap data.
+	WaitingForDataSecretReason = "WaitingForDataSecret"
+
+	// InvalidBootstrapDataReason (Severity=Error) documents a machine whose bootstrap data secret is missing
+	// the value key, or holds data not matching Spec.Bootstrap.Format.
+	InvalidBootstrapDataReason = "InvalidBootstrapData"
+
+	// InfrastructureReadyCondition reports on the state of the infrastructure object referenced by the Machine.
+	InfrastructureReadyCondition ConditionType = "InfrastructureReady"
+
+	// InfrastructureGoneReason (Severity=Error) documents the infrastructure object referenced by a machine
+	// has been deleted after being ready, outside of the machine deletion flow.
+	InfrastructureGoneReason = "InfrastructureGone"
+
+	// DeletedReason (Severity=Info) documents the infrastructure object referenced by a machine
+	// has been deleted as part of the machine deletion flow.
+	DeletedReason = "Deleted"
+
+	// ReferencedResourceFailedReason (Severity=Error) documents the bootstrap config or infrastructure object
+	// referenced by a machine reports a failure, propagated to the failure reason and message of the machine.
+	// The failure is cleared once the referenced object recovers.
+	ReferencedResourceFailedReason = "ReferencedResourceFailed"
+
+	// MachineNodeHealthyCondition provides info about the readiness of the Node referenced by the Machine.
+	MachineNodeHealthyCondition ConditionType = "NodeHealthy"
+
+	// WaitingForNodeRefReason (Severity=Info) documents a machine.status.nodeRef is not assigned yet.
+	WaitingForNodeRefReason = "WaitingForNodeRef"
+
+	// NodeNotFoundReason documents a machine's node has previously been observed but is now gone;
+	// the condition is Unknown until a Node is referenced again.
+	NodeNotFoundReason = "NodeNotFound"
+
+	// NodeNotReadyReason (Severity=Warning) documents a machine's node reports NodeReady=False.
+	NodeNotReadyReason = "NodeNotReady"
+
+	// NodeReadyUnknownReason documents a machine's node does not report NodeReady, or reports NodeReady=Unknown.
+	NodeReadyUnknownReason = "NodeReadyUnknown"
+
+	// NodeUnresponsiveReason (Severity=Warning) documents a machine's node has reported NodeReady=Unknown
+	// for longer than machine.spec.nodeUnresponsiveTimeout.
+	NodeUnresponsiveReason = "NodeUnresponsive"
+
+	// ReadinessGatesReadyCondition documents whether all the conditions listed in machine.spec.readinessGates are True.
+	ReadinessGatesReadyCondition ConditionType = "ReadinessGatesReady"
+
+	// WaitingForReadinessGatesReason (Severity=Info) documents a machine waiting for one or more of its
+	// readiness gate conditions to be True before being marked Running.
+	WaitingForReadinessGatesReason = "WaitingForReadinessGates"
+
+	// UpgradeAllowedCondition documents whether a Machine whose spec.version differs from the version
+	// reported by its Node is allowed to be upgraded, according to spec.maxUnavailableDuringUpgrade.
+	UpgradeAllowedCondition ConditionType = "UpgradeAllowed"
+
+	// MaxUnavailableReachedReason (Severity=Info) documents a machine upgrade waiting for other machines
+	// in the cluster to become available.
+	MaxUnavailableReachedReason = "MaxUnavailableReached"
+
+	// PausedCondition documents that reconciliation of a Machine is paused because machine.spec.paused is set.
+	PausedCondition ConditionType = "Paused"
+
+	// PreProvisionValidatedCondition documents whether a Machine passed the pre-provision validation
+	// of the machine controller, before its bootstrap data and infrastructure were reconciled.
+	PreProvisionValidatedCondition ConditionType = "PreProvisionValidated"
+
+	// PreProvisionValidationFailedReason (Severity=Error) documents a Machine rejected by the pre-provision
+	// validation endpoint, or for which the endpoint could not be called.
+	PreProvisionValidationFailedReason = "PreProvisionValidationFailed"
+)
+
+// Conditions and condition Reasons for the MachineDeployment object
+
+const (
+	// MachineDeploymentCanaryReadyCondition documents that the canary machines created from the new
+	// template of a MachineDeployment with Strategy.RollingUpdate.CanaryReplicas set are available.
+	MachineDeploymentCanaryReadyCondition ConditionType = "CanaryReady"
+
+	// WaitingForCanaryReason (Severity=Info) documents a MachineDeployment rollout waiting for
+	// the canary machines to become available.
+	WaitingForCanaryReason = "WaitingForCanary"
+)
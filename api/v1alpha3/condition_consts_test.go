@@ -0,0 +1,36 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha3
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+// TestMachineConditionTypes guards the values of the Machine condition types, which are persisted
+// in the status of existing Machines and relied upon by external controllers.
+func TestMachineConditionTypes(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(string(BootstrapReadyCondition)).To(Equal("BootstrapReady"))
+	g.Expect(string(InfrastructureReadyCondition)).To(Equal("InfrastructureReady"))
+	g.Expect(string(MachineNodeHealthyCondition)).To(Equal("NodeHealthy"))
+	g.Expect(string(ReadinessGatesReadyCondition)).To(Equal("ReadinessGatesReady"))
+	g.Expect(string(UpgradeAllowedCondition)).To(Equal("UpgradeAllowed"))
+	g.Expect(string(PausedCondition)).To(Equal("Paused"))
+}
@@ -19,6 +19,7 @@ package v1alpha3
 import (
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	capierrors "sigs.k8s.io/cluster-api/errors"
 )
 
@@ -32,6 +33,14 @@ const (
 	// ExcludeNodeDrainingAnnotation annotation explicitly skips node draining if set
 	ExcludeNodeDrainingAnnotation = "machine.cluster.x-k8s.io/exclude-node-draining"
 
+	// SkipInfraGCAnnotation set to "true" on a Machine prevents the machine controller from deleting the
+	// infrastructure object of the Machine when it is deleted, for providers that manage their own cleanup.
+	SkipInfraGCAnnotation = "cluster.x-k8s.io/skip-infra-gc"
+
+	// PreProvisionValidationURLAnnotation on a Machine is the URL its spec is posted to before it is provisioned,
+	// instead of the default one of the machine controller. Only the URLs allowed by the machine controller are called.
+	PreProvisionValidationURLAnnotation = "cluster.x-k8s.io/pre-provision-validation-url"
+
 	// MachineSetLabelName is the label set on machines if they're controlled by MachineSet
 	MachineSetLabelName = "cluster.x-k8s.io/set-name"
 
@@ -53,8 +62,19 @@ type MachineSpec struct {
 
 	// InfrastructureRef is a required reference to a custom resource
 	// offered by an infrastructure provider.
+	// The referenced object is in the namespace of the Machine, unless the
+	// namespace field is set to one of the namespaces the manager allows
+	// cross-namespace infrastructure references to.
 	InfrastructureRef corev1.ObjectReference `json:"infrastructureRef"`
 
+	// PropagatedInfrastructureLabels lists the keys of the labels copied from the object referenced
+	// by InfrastructureRef to the Machine once the infrastructure is ready, e.g. to list Machines
+	// by a zone label set by the infrastructure provider. Labels missing from the infrastructure
+	// object are left untouched on the Machine. Keys in the cluster.x-k8s.io domain and its
+	// subdomains, and keys of the selector of the MachineSet or MachineDeployment, are rejected.
+	// +optional
+	PropagatedInfrastructureLabels []string `json:"propagatedInfrastructureLabels,omitempty"`
+
 	// Version defines the desired Kubernetes version.
 	// This field is meant to be optionally used by bootstrap providers.
 	// +optional
@@ -73,14 +93,71 @@ type MachineSpec struct {
 	// +optional
 	ProviderID *string `json:"providerID,omitempty"`
 
+	// NodeName is the name of the Node of the machine, for infrastructure providers that don't set a provider ID.
+	// When set, the machine is associated with the Node of that name instead of being matched by provider ID,
+	// and ProviderID must not be set.
+	// +optional
+	NodeName string `json:"nodeName,omitempty"`
+
 	// FailureDomain is the failure domain the machine will be created in.
 	// Must match a key in the FailureDomains map stored on the cluster object.
 	// +optional
 	FailureDomain *string `json:"failureDomain,omitempty"`
+
+	// Taints are applied to the Node corresponding to this Machine once it is registered.
+	// Existing taints on the Node are preserved; a taint with the same key
+	// is updated to match the value and effect specified here.
+	// +optional
+	Taints []corev1.Taint `json:"taints,omitempty"`
+
+	// ReadinessGates specifies additional conditions that must be True, in addition
+	// to the Machine having a Node and ready infrastructure, before the Machine is
+	// considered Running. The conditions are expected to be set by external controllers.
+	// +optional
+	ReadinessGates []MachineReadinessGate `json:"readinessGates,omitempty"`
+
+	// MaxUnavailableDuringUpgrade is the maximum number, or percentage, of Machines in the Cluster
+	// that can be unavailable when this Machine starts moving to a new Version. While the limit
+	// is reached, the UpgradeAllowed condition of the Machine is set to False and providers
+	// are expected to wait before upgrading the Machine. If nil, the upgrade is never blocked.
+	// +optional
+	MaxUnavailableDuringUpgrade *intstr.IntOrString `json:"maxUnavailableDuringUpgrade,omitempty"`
+
+	// Paused can be used to prevent the machine controller from processing this Machine,
+	// without pausing the whole Cluster. While set, the Paused condition is set to True.
+	// +optional
+	Paused bool `json:"paused,omitempty"`
+
+	// NodeDeletionTimeout is how long the Node of a Machine being deleted can outlive its infrastructure.
+	// If the Node still exists that long after the infrastructure object is deleted, the controller deletes it
+	// before removing the Machine. Defaults to 10 minutes.
+	// +optional
+	NodeDeletionTimeout *metav1.Duration `json:"nodeDeletionTimeout,omitempty"`
+
+	// NodeUnresponsiveTimeout is how long the Node of the Machine can report NodeReady=Unknown
+	// before the NodeHealthy condition of the Machine is set to False, so it can be remediated.
+	// If nil, the Machine is never considered unresponsive.
+	// +optional
+	NodeUnresponsiveTimeout *metav1.Duration `json:"nodeUnresponsiveTimeout,omitempty"`
+
+	// IgnoreNodeDrain makes the controller skip the drain of the Node of the Machine
+	// on deletion, e.g. for Machines running only stateless workloads.
+	// It can't be changed once the infrastructure of the Machine is ready.
+	// +optional
+	IgnoreNodeDrain bool `json:"ignoreNodeDrain,omitempty"`
 }
 
 // ANCHOR_END: MachineSpec
 
+// MachineReadinessGate contains the type of a condition of the Machine that must be True
+// before the Machine is marked Running.
+type MachineReadinessGate struct {
+	// ConditionType refers to a condition in the Machine's condition list with matching type.
+	// +kubebuilder:validation:Type=string
+	// +kubebuilder:validation:MinLength=1
+	ConditionType ConditionType `json:"conditionType"`
+}
+
 // ANCHOR: MachineStatus
 
 // MachineStatus defines the observed state of Machine
@@ -93,6 +170,12 @@ type MachineStatus struct {
 	// +optional
 	LastUpdated *metav1.Time `json:"lastUpdated,omitempty"`
 
+	// LastSuccessfulReconcileTime is when the machine controller last reconciled the Machine
+	// without errors. Unlike the LastTransitionTime of the conditions, it is updated even if
+	// nothing changed, at most every 5 minutes in that case.
+	// +optional
+	LastSuccessfulReconcileTime *metav1.Time `json:"lastSuccessfulReconcileTime,omitempty"`
+
 	// Version specifies the current version of Kubernetes running
 	// on the corresponding Node. This is meant to be a means of bubbling
 	// up status from the Node to the Machine.
@@ -152,9 +235,22 @@ type MachineStatus struct {
 	// +optional
 	BootstrapReady bool `json:"bootstrapReady"`
 
+	// BootstrapDataHash is the hash of the bootstrap data stored in the secret
+	// referenced by Spec.Bootstrap.DataSecretName, as last observed by the controller.
+	// It can be used to detect drift between the data a Machine was bootstrapped with
+	// and the current content of the secret.
+	// +optional
+	BootstrapDataHash string `json:"bootstrapDataHash,omitempty"`
+
 	// InfrastructureReady is the state of the infrastructure provider.
+	// It mirrors the status.ready field of the infrastructure object, so that consumers
+	// don't have to fetch the infrastructure object to know whether it's ready.
 	// +optional
 	InfrastructureReady bool `json:"infrastructureReady"`
+
+	// Conditions defines current service state of the Machine.
+	// +optional
+	Conditions Conditions `json:"conditions,omitempty"`
 }
 
 // ANCHOR_END: MachineStatus
@@ -206,8 +302,37 @@ type Bootstrap struct {
 	// If nil, the Machine should remain in the Pending state.
 	// +optional
 	DataSecretName *string `json:"dataSecretName,omitempty"`
+
+	// Format specifies the format of the bootstrap data stored in the secret
+	// referenced by DataSecretName. Defaults to cloud-init.
+	// Ignition data must be valid JSON. Cloud-init data only needs to be present: it is not required
+	// to start with #cloud-config, as cloud-init also consumes shell scripts, jinja templates
+	// and MIME multipart archives.
+	// Data that doesn't match the format keeps the Machine from being provisioned.
+	// +optional
+	Format MachineBootstrapFormat `json:"format,omitempty"`
+
+	// ReProvisionOnChange makes the Machine controller delete the Machine, so that its MachineSet
+	// recreates it, when the content of the bootstrap data secret changes after the
+	// infrastructure has been provisioned with it. It only applies to worker Machines owned by a
+	// MachineSet, which are re-provisioned one at a time while the MachineSet is fully available.
+	// It requires the ReProvisionOnBootstrapChange feature gate to be enabled.
+	// +optional
+	ReProvisionOnChange bool `json:"reProvisionOnChange,omitempty"`
 }
 
+// MachineBootstrapFormat defines the format of the bootstrap data.
+// +kubebuilder:validation:Enum=cloud-init;ignition
+type MachineBootstrapFormat string
+
+const (
+	// MachineBootstrapFormatCloudInit is the format of bootstrap data consumed by cloud-init.
+	MachineBootstrapFormatCloudInit MachineBootstrapFormat = "cloud-init"
+
+	// MachineBootstrapFormatIgnition is the format of bootstrap data consumed by Ignition.
+	MachineBootstrapFormatIgnition MachineBootstrapFormat = "ignition"
+)
+
 // ANCHOR_END: Bootstrap
 
 // +kubebuilder:object:root=true
@@ -17,23 +17,106 @@ limitations under the License.
 package v1alpha3
 
 import (
+	"context"
 	"fmt"
+	"net/http"
 	"strings"
 
 	"github.com/blang/semver"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
 
+// machineValidatePath is the path of the validating webhook of the Machines.
+const machineValidatePath = "/validate-cluster-x-k8s-io-v1alpha3-machine"
+
 func (m *Machine) SetupWebhookWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewWebhookManagedBy(mgr).
 		For(m).
 		Complete()
 }
 
+// CrossNamespaceInfrastructureAllowlist maps a namespace to the other namespaces the Spec.InfrastructureRef
+// of the Machines in that namespace is allowed to point to.
+// +kubebuilder:object:generate=false
+type CrossNamespaceInfrastructureAllowlist map[string][]string
+
+// Allows returns true if the Machines in namespace are allowed to reference infrastructure objects in refNamespace.
+func (a CrossNamespaceInfrastructureAllowlist) Allows(namespace, refNamespace string) bool {
+	if refNamespace == "" || refNamespace == namespace {
+		return true
+	}
+	for _, allowed := range a[namespace] {
+		if allowed == refNamespace {
+			return true
+		}
+	}
+	return false
+}
+
+// MachineWebhook configures the webhooks of the Machines.
+// +kubebuilder:object:generate=false
+type MachineWebhook struct {
+	// AllowedCrossNamespaceInfrastructure lists, for each namespace, the other namespaces the Spec.InfrastructureRef
+	// of a Machine in that namespace is allowed to point to. Machines referencing any other namespace are rejected.
+	AllowedCrossNamespaceInfrastructure CrossNamespaceInfrastructureAllowlist
+}
+
+// SetupWebhookWithManager registers the webhooks of the Machines, validating them with the configuration of w.
+func (w *MachineWebhook) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	// The builder skips the validating webhook the Machine type would get, as its path is already registered.
+	mgr.GetWebhookServer().Register(machineValidatePath, &webhook.Admission{Handler: &machineValidator{
+		allowlist: w.AllowedCrossNamespaceInfrastructure,
+	}})
+	return (&Machine{}).SetupWebhookWithManager(mgr)
+}
+
+// machineValidator validates the Machines like Machine.ValidateCreate and Machine.ValidateUpdate, additionally
+// allowing the cross-namespace infrastructure references of allowlist.
+type machineValidator struct {
+	allowlist CrossNamespaceInfrastructureAllowlist
+	decoder   *admission.Decoder
+}
+
+var _ admission.DecoderInjector = &machineValidator{}
+
+// InjectDecoder injects the decoder into the machineValidator.
+func (v *machineValidator) InjectDecoder(d *admission.Decoder) error {
+	v.decoder = d
+	return nil
+}
+
+// Handle validates the Machine created or updated by the request.
+func (v *machineValidator) Handle(_ context.Context, req admission.Request) admission.Response {
+	var old *Machine
+	switch req.Operation {
+	case admissionv1beta1.Create:
+	case admissionv1beta1.Update:
+		old = &Machine{}
+		if err := v.decoder.DecodeRaw(req.OldObject, old); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+	default:
+		return admission.Allowed("")
+	}
+
+	m := &Machine{}
+	if err := v.decoder.DecodeRaw(req.Object, m); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	if err := m.validate(old, v.allowlist); err != nil {
+		return admission.Denied(err.Error())
+	}
+	return admission.Allowed("")
+}
+
 // +kubebuilder:webhook:verbs=create;update,path=/validate-cluster-x-k8s-io-v1alpha3-machine,mutating=false,failurePolicy=fail,matchPolicy=Equivalent,groups=cluster.x-k8s.io,resources=machines,versions=v1alpha3,name=validation.machine.cluster.x-k8s.io
 // +kubebuilder:webhook:verbs=create;update,path=/mutate-cluster-x-k8s-io-v1alpha3-machine,mutating=true,failurePolicy=fail,matchPolicy=Equivalent,groups=cluster.x-k8s.io,resources=machines,versions=v1alpha3,name=default.machine.cluster.x-k8s.io
 
@@ -58,7 +141,7 @@ func (m *Machine) Default() {
 
 // ValidateCreate implements webhook.Validator so a webhook will be registered for the type
 func (m *Machine) ValidateCreate() error {
-	return m.validate(nil)
+	return m.validate(nil, nil)
 }
 
 // ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
@@ -67,7 +150,7 @@ func (m *Machine) ValidateUpdate(old runtime.Object) error {
 	if !ok {
 		return apierrors.NewBadRequest(fmt.Sprintf("expected a Machine but got a %T", old))
 	}
-	return m.validate(oldM)
+	return m.validate(oldM, nil)
 }
 
 // ValidateDelete implements webhook.Validator so a webhook will be registered for the type
@@ -75,7 +158,8 @@ func (m *Machine) ValidateDelete() error {
 	return nil
 }
 
-func (m *Machine) validate(old *Machine) error {
+// validate checks the Machine, allowing the cross-namespace infrastructure references of allowlist.
+func (m *Machine) validate(old *Machine, allowlist CrossNamespaceInfrastructureAllowlist) error {
 	var allErrs field.ErrorList
 	if m.Spec.Bootstrap.ConfigRef == nil && m.Spec.Bootstrap.DataSecretName == nil {
 		allErrs = append(
@@ -87,6 +171,16 @@ func (m *Machine) validate(old *Machine) error {
 		)
 	}
 
+	if m.Spec.Bootstrap.Data != nil && m.Spec.Bootstrap.DataSecretName != nil {
+		allErrs = append(
+			allErrs,
+			field.Forbidden(
+				field.NewPath("spec", "bootstrap", "data"),
+				"at most one of spec.bootstrap.data and spec.bootstrap.dataSecretName can be populated",
+			),
+		)
+	}
+
 	if m.Spec.Bootstrap.ConfigRef != nil && m.Spec.Bootstrap.ConfigRef.Namespace != m.Namespace {
 		allErrs = append(
 			allErrs,
@@ -98,13 +192,23 @@ func (m *Machine) validate(old *Machine) error {
 		)
 	}
 
-	if m.Spec.InfrastructureRef.Namespace != m.Namespace {
+	if m.Spec.InfrastructureRef.Namespace != m.Namespace && !allowlist.Allows(m.Namespace, m.Spec.InfrastructureRef.Namespace) {
 		allErrs = append(
 			allErrs,
 			field.Invalid(
 				field.NewPath("spec", "infrastructureRef", "namespace"),
 				m.Spec.InfrastructureRef.Namespace,
-				"must match metadata.namespace",
+				"must match metadata.namespace or be one of the namespaces allowed for cross-namespace infrastructure references from it",
+			),
+		)
+	}
+
+	if m.Spec.NodeName != "" && m.Spec.ProviderID != nil {
+		allErrs = append(
+			allErrs,
+			field.Forbidden(
+				field.NewPath("spec", "nodeName"),
+				"at most one of spec.nodeName and spec.providerID can be populated",
 			),
 		)
 	}
@@ -116,14 +220,65 @@ func (m *Machine) validate(old *Machine) error {
 		)
 	}
 
+	if old != nil && old.Status.InfrastructureReady && old.Spec.IgnoreNodeDrain != m.Spec.IgnoreNodeDrain {
+		allErrs = append(
+			allErrs,
+			field.Invalid(field.NewPath("spec", "ignoreNodeDrain"), m.Spec.IgnoreNodeDrain, "field is immutable once the Machine is provisioned"),
+		)
+	}
+
+	// An unset version is allowed, Machines created by MachineSets and providers don't always have one;
+	// a blank one is rejected.
 	if m.Spec.Version != nil {
-		if _, err := semver.Parse(strings.TrimPrefix(strings.TrimSpace(*m.Spec.Version), "v")); err != nil {
+		if strings.TrimSpace(*m.Spec.Version) == "" {
+			allErrs = append(allErrs, field.Required(field.NewPath("spec", "version"), "must not be empty, unset it instead"))
+		} else if _, err := semver.Parse(strings.TrimPrefix(strings.TrimSpace(*m.Spec.Version), "v")); err != nil {
 			allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "version"), *m.Spec.Version, "must be a valid semantic version"))
 		}
 	}
 
+	if old != nil && old.Spec.Version != nil && *old.Spec.Version != "" && (m.Spec.Version == nil || *m.Spec.Version == "") {
+		allErrs = append(
+			allErrs,
+			field.Forbidden(field.NewPath("spec", "version"), "cannot be cleared once set"),
+		)
+	}
+
+	allErrs = append(allErrs, validatePropagatedInfrastructureLabels(m.Spec.PropagatedInfrastructureLabels, nil,
+		field.NewPath("spec", "propagatedInfrastructureLabels"))...)
+
 	if len(allErrs) == 0 {
 		return nil
 	}
 	return apierrors.NewInvalid(GroupVersion.WithKind("Machine").GroupKind(), m.Name, allErrs)
 }
+
+// validatePropagatedInfrastructureLabels checks that none of the label keys is reserved by Cluster API, i.e. in the
+// cluster.x-k8s.io domain or one of its subdomains, or used by the selector, if any, of the object owning the Machines:
+// propagating them could orphan the Machines from their owners.
+func validatePropagatedInfrastructureLabels(keys []string, selector *metav1.LabelSelector, fldPath *field.Path) field.ErrorList {
+	selectorKeys := sets.NewString()
+	if selector != nil {
+		for key := range selector.MatchLabels {
+			selectorKeys.Insert(key)
+		}
+		for _, requirement := range selector.MatchExpressions {
+			selectorKeys.Insert(requirement.Key)
+		}
+	}
+
+	var allErrs field.ErrorList
+	for i, key := range keys {
+		domain := ""
+		if j := strings.Index(key, "/"); j >= 0 {
+			domain = key[:j]
+		}
+		switch {
+		case domain == GroupVersion.Group || strings.HasSuffix(domain, "."+GroupVersion.Group):
+			allErrs = append(allErrs, field.Invalid(fldPath.Index(i), key, fmt.Sprintf("labels in the %s domain are reserved", GroupVersion.Group)))
+		case selectorKeys.Has(key):
+			allErrs = append(allErrs, field.Invalid(fldPath.Index(i), key, "must not be a key of spec.selector"))
+		}
+	}
+	return allErrs
+}
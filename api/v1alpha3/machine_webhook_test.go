@@ -17,13 +17,18 @@ limitations under the License.
 package v1alpha3
 
 import (
+	"context"
+	"encoding/json"
 	"testing"
 
 	. "github.com/onsi/gomega"
 
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
 
 func TestMachineDefault(t *testing.T) {
@@ -66,6 +71,11 @@ func TestMachineBootstrapValidation(t *testing.T) {
 			bootstrap: Bootstrap{ConfigRef: &corev1.ObjectReference{}, Data: nil},
 			expectErr: false,
 		},
+		{
+			name:      "should return error if both data and dataSecretName are set",
+			bootstrap: Bootstrap{Data: pointer.StringPtr("data"), DataSecretName: pointer.StringPtr("test")},
+			expectErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -143,6 +153,72 @@ func TestMachineNamespaceValidation(t *testing.T) {
 	}
 }
 
+func TestMachineCrossNamespaceInfrastructureValidation(t *testing.T) {
+	allowlist := CrossNamespaceInfrastructureAllowlist{"foobar": {"infra"}}
+
+	tests := []struct {
+		name      string
+		expectErr bool
+		bootstrap Bootstrap
+		infraRef  corev1.ObjectReference
+		namespace string
+	}{
+		{
+			name:      "should succeed if the infrastructure ref namespace is allowed for the namespace",
+			expectErr: false,
+			namespace: "foobar",
+			bootstrap: Bootstrap{ConfigRef: &corev1.ObjectReference{Namespace: "foobar"}},
+			infraRef:  corev1.ObjectReference{Namespace: "infra"},
+		},
+		{
+			name:      "should return error if the infrastructure ref namespace is allowed for another namespace",
+			expectErr: true,
+			namespace: "other",
+			bootstrap: Bootstrap{ConfigRef: &corev1.ObjectReference{Namespace: "other"}},
+			infraRef:  corev1.ObjectReference{Namespace: "infra"},
+		},
+		{
+			name:      "should return error if the bootstrap namespace is an allowed infrastructure namespace",
+			expectErr: true,
+			namespace: "foobar",
+			bootstrap: Bootstrap{ConfigRef: &corev1.ObjectReference{Namespace: "infra"}},
+			infraRef:  corev1.ObjectReference{Namespace: "foobar"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			m := &Machine{
+				ObjectMeta: metav1.ObjectMeta{Name: "machine", Namespace: tt.namespace},
+				Spec:       MachineSpec{Bootstrap: tt.bootstrap, InfrastructureRef: tt.infraRef},
+			}
+			raw, err := json.Marshal(m)
+			g.Expect(err).NotTo(HaveOccurred())
+
+			decoder, err := admission.NewDecoder(runtime.NewScheme())
+			g.Expect(err).NotTo(HaveOccurred())
+			v := &machineValidator{allowlist: allowlist}
+			g.Expect(v.InjectDecoder(decoder)).To(Succeed())
+
+			for _, op := range []admissionv1beta1.Operation{admissionv1beta1.Create, admissionv1beta1.Update} {
+				resp := v.Handle(context.Background(), admission.Request{AdmissionRequest: admissionv1beta1.AdmissionRequest{
+					Operation: op,
+					Object:    runtime.RawExtension{Raw: raw},
+					OldObject: runtime.RawExtension{Raw: raw},
+				}})
+				g.Expect(resp.Allowed).To(Equal(!tt.expectErr), string(op))
+			}
+
+			// Without the webhook configuration, no cross-namespace reference is allowed.
+			if tt.infraRef.Namespace != tt.namespace {
+				g.Expect(m.ValidateCreate()).NotTo(Succeed())
+			}
+		})
+	}
+}
+
 func TestMachineClusterNameImmutable(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -190,6 +266,63 @@ func TestMachineClusterNameImmutable(t *testing.T) {
 	}
 }
 
+func TestMachineIgnoreNodeDrainImmutable(t *testing.T) {
+	tests := []struct {
+		name                string
+		infrastructureReady bool
+		oldIgnoreNodeDrain  bool
+		newIgnoreNodeDrain  bool
+		expectErr           bool
+	}{
+		{
+			name:               "when the machine is not provisioned",
+			oldIgnoreNodeDrain: false,
+			newIgnoreNodeDrain: true,
+			expectErr:          false,
+		},
+		{
+			name:                "when the field has not changed",
+			infrastructureReady: true,
+			oldIgnoreNodeDrain:  true,
+			newIgnoreNodeDrain:  true,
+			expectErr:           false,
+		},
+		{
+			name:                "when the field has changed after provisioning",
+			infrastructureReady: true,
+			oldIgnoreNodeDrain:  false,
+			newIgnoreNodeDrain:  true,
+			expectErr:           true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			newMachine := &Machine{
+				Spec: MachineSpec{
+					Bootstrap:       Bootstrap{ConfigRef: &corev1.ObjectReference{}},
+					IgnoreNodeDrain: tt.newIgnoreNodeDrain,
+				},
+			}
+			oldMachine := &Machine{
+				Spec: MachineSpec{
+					Bootstrap:       Bootstrap{ConfigRef: &corev1.ObjectReference{}},
+					IgnoreNodeDrain: tt.oldIgnoreNodeDrain,
+				},
+				Status: MachineStatus{InfrastructureReady: tt.infrastructureReady},
+			}
+
+			if tt.expectErr {
+				g.Expect(newMachine.ValidateUpdate(oldMachine)).NotTo(Succeed())
+			} else {
+				g.Expect(newMachine.ValidateUpdate(oldMachine)).To(Succeed())
+			}
+		})
+	}
+}
+
 func TestMachineVersionValidation(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -244,3 +377,163 @@ func TestMachineVersionValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestMachineVersionRequired(t *testing.T) {
+	newMachine := func(version *string) *Machine {
+		return &Machine{
+			Spec: MachineSpec{
+				Version:   version,
+				Bootstrap: Bootstrap{DataSecretName: pointer.StringPtr("test")},
+			},
+		}
+	}
+
+	t.Run("create", func(t *testing.T) {
+		g := NewWithT(t)
+
+		g.Expect(newMachine(pointer.StringPtr("v1.17.2")).ValidateCreate()).To(Succeed())
+		// An unset version is still allowed: MachineSets and providers create Machines without a version,
+		// and rejecting them would break existing clusters. Only a blank version is rejected.
+		g.Expect(newMachine(nil).ValidateCreate()).To(Succeed())
+		g.Expect(newMachine(pointer.StringPtr("")).ValidateCreate()).NotTo(Succeed())
+		g.Expect(newMachine(pointer.StringPtr("  ")).ValidateCreate()).NotTo(Succeed())
+	})
+
+	tests := []struct {
+		name       string
+		oldVersion *string
+		newVersion *string
+		expectErr  bool
+	}{
+		{
+			name:       "should succeed when setting the version",
+			newVersion: pointer.StringPtr("v1.17.2"),
+		},
+		{
+			name:       "should succeed when changing the version",
+			oldVersion: pointer.StringPtr("v1.17.2"),
+			newVersion: pointer.StringPtr("v1.18.0"),
+		},
+		{
+			name:       "should return error when unsetting the version",
+			oldVersion: pointer.StringPtr("v1.17.2"),
+			expectErr:  true,
+		},
+		{
+			name:       "should return error when emptying the version",
+			oldVersion: pointer.StringPtr("v1.17.2"),
+			newVersion: pointer.StringPtr(""),
+			expectErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			err := newMachine(tt.newVersion).ValidateUpdate(newMachine(tt.oldVersion))
+			if tt.expectErr {
+				g.Expect(err).To(HaveOccurred())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestMachineNodeNameValidation(t *testing.T) {
+	tests := []struct {
+		name       string
+		nodeName   string
+		providerID *string
+		expectErr  bool
+	}{
+		{
+			name:      "should succeed when only the node name is set",
+			nodeName:  "node-1",
+			expectErr: false,
+		},
+		{
+			name:       "should succeed when only the provider ID is set",
+			providerID: pointer.StringPtr("aws:///id-1"),
+			expectErr:  false,
+		},
+		{
+			name:       "should return error when both the node name and the provider ID are set",
+			nodeName:   "node-1",
+			providerID: pointer.StringPtr("aws:///id-1"),
+			expectErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			m := &Machine{
+				Spec: MachineSpec{
+					NodeName:   tt.nodeName,
+					ProviderID: tt.providerID,
+					Bootstrap:  Bootstrap{ConfigRef: nil, DataSecretName: pointer.StringPtr("test")},
+				},
+			}
+
+			if tt.expectErr {
+				g.Expect(m.ValidateCreate()).NotTo(Succeed())
+				g.Expect(m.ValidateUpdate(m)).NotTo(Succeed())
+			} else {
+				g.Expect(m.ValidateCreate()).To(Succeed())
+				g.Expect(m.ValidateUpdate(m)).To(Succeed())
+			}
+		})
+	}
+}
+
+func TestMachinePropagatedInfrastructureLabelsValidation(t *testing.T) {
+	tests := []struct {
+		name      string
+		labels    []string
+		expectErr bool
+	}{
+		{
+			name:   "should succeed with provider labels",
+			labels: []string{"provider.example.com/zone", "zone"},
+		},
+		{
+			name:      "should return error with the cluster name label",
+			labels:    []string{ClusterLabelName},
+			expectErr: true,
+		},
+		{
+			name:      "should return error with the MachineSet name label",
+			labels:    []string{"provider.example.com/zone", MachineSetLabelName},
+			expectErr: true,
+		},
+		{
+			name:      "should return error with a label in a subdomain of cluster.x-k8s.io",
+			labels:    []string{TopologyWorkerLabelName},
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			m := &Machine{
+				Spec: MachineSpec{
+					Bootstrap:                      Bootstrap{ConfigRef: nil, DataSecretName: pointer.StringPtr("test")},
+					PropagatedInfrastructureLabels: tt.labels,
+				},
+			}
+
+			if tt.expectErr {
+				g.Expect(m.ValidateCreate()).NotTo(Succeed())
+				g.Expect(m.ValidateUpdate(m)).NotTo(Succeed())
+			} else {
+				g.Expect(m.ValidateCreate()).To(Succeed())
+				g.Expect(m.ValidateUpdate(m)).To(Succeed())
+			}
+		})
+	}
+}
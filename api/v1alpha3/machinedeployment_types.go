@@ -62,6 +62,12 @@ type MachineDeploymentSpec struct {
 	// Template describes the machines that will be created.
 	Template MachineTemplateSpec `json:"template"`
 
+	// PropagatedAnnotations is the list of annotation keys that are copied from
+	// Template.ObjectMeta to the metadata of the MachineSets created by this deployment.
+	// If empty, all the template annotations are propagated.
+	// +optional
+	PropagatedAnnotations []string `json:"propagatedAnnotations,omitempty"`
+
 	// The deployment strategy to use to replace existing machines with
 	// new ones.
 	// +optional
@@ -74,9 +80,15 @@ type MachineDeploymentSpec struct {
 	// +optional
 	MinReadySeconds *int32 `json:"minReadySeconds,omitempty"`
 
+	// TopologySpreadConstraints describes how the Machines are spread across the failure domains
+	// of the Cluster. They are copied to the MachineSets of this deployment.
+	// +optional
+	TopologySpreadConstraints []TopologySpreadConstraint `json:"topologySpreadConstraints,omitempty"`
+
 	// The number of old MachineSets to retain to allow rollback.
 	// This is a pointer to distinguish between explicit zero and not specified.
-	// Defaults to 1.
+	// Once a rollout is complete, the oldest MachineSets scaled to zero beyond this limit are deleted.
+	// Defaults to 10.
 	// +optional
 	RevisionHistoryLimit *int32 `json:"revisionHistoryLimit,omitempty"`
 
@@ -147,6 +159,16 @@ type MachineRollingUpdateDeployment struct {
 	// at any time during the update is at most 130% of desired machines.
 	// +optional
 	MaxSurge *intstr.IntOrString `json:"maxSurge,omitempty"`
+
+	// CanaryReplicas is the number of machines created from the new
+	// template before proceeding with the rest of the rollout.
+	// When set, the new MachineSet is scaled up to CanaryReplicas and the
+	// old MachineSets are not scaled down until the canary machines are
+	// available, as reported by the CanaryReady condition.
+	// Defaults to 0, which disables canary rollouts.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	CanaryReplicas *int32 `json:"canaryReplicas,omitempty"`
 }
 
 // ANCHOR_END: MachineRollingUpdateDeployment
@@ -192,9 +214,19 @@ type MachineDeploymentStatus struct {
 	// +optional
 	UnavailableReplicas int32 `json:"unavailableReplicas,omitempty"`
 
+	// GenerationLag is the number of generations of the MachineDeployment spec observed since
+	// the last one that was completely rolled out, i.e. with all the desired replicas updated
+	// and available. It is 0 once the rollout of the current generation completes.
+	// +optional
+	GenerationLag int64 `json:"generationLag,omitempty"`
+
 	// Phase represents the current phase of a MachineDeployment (ScalingUp, ScalingDown, Running, Failed, or Unknown).
 	// +optional
 	Phase string `json:"phase,omitempty"`
+
+	// Conditions defines current service state of the MachineDeployment.
+	// +optional
+	Conditions Conditions `json:"conditions,omitempty"`
 }
 
 // ANCHOR_END: MachineDeploymentStatus
@@ -92,6 +92,9 @@ func (m *MachineDeployment) validate(old *MachineDeployment) error {
 		)
 	}
 
+	allErrs = append(allErrs, validatePropagatedInfrastructureLabels(m.Spec.Template.Spec.PropagatedInfrastructureLabels, &m.Spec.Selector,
+		field.NewPath("spec", "template", "spec", "propagatedInfrastructureLabels"))...)
+
 	if len(allErrs) == 0 {
 		return nil
 	}
@@ -116,7 +119,7 @@ func PopulateDefaultsMachineDeployment(d *MachineDeployment) {
 	}
 
 	if d.Spec.RevisionHistoryLimit == nil {
-		d.Spec.RevisionHistoryLimit = pointer.Int32Ptr(1)
+		d.Spec.RevisionHistoryLimit = pointer.Int32Ptr(10)
 	}
 
 	if d.Spec.ProgressDeadlineSeconds == nil {
@@ -154,9 +157,12 @@ func PopulateDefaultsMachineDeployment(d *MachineDeployment) {
 		}
 	}
 
-	// If no selector has been provided, add label and selector for the
-	// MachineDeployment's name as a default way of providing uniqueness.
+	// If no selector has been provided, select the template labels, and add label and selector
+	// for the MachineDeployment's name as a default way of providing uniqueness.
 	if len(d.Spec.Selector.MatchLabels) == 0 && len(d.Spec.Selector.MatchExpressions) == 0 {
+		for k, v := range d.Spec.Template.Labels {
+			d.Spec.Selector.MatchLabels[k] = v
+		}
 		d.Spec.Selector.MatchLabels[MachineDeploymentLabelName] = d.Name
 		d.Spec.Template.Labels[MachineDeploymentLabelName] = d.Name
 	}
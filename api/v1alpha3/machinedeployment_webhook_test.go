@@ -38,7 +38,7 @@ func TestMachineDeploymentDefault(t *testing.T) {
 	g.Expect(md.Labels[ClusterLabelName]).To(Equal(md.Spec.ClusterName))
 	g.Expect(md.Spec.Replicas).To(Equal(pointer.Int32Ptr(1)))
 	g.Expect(md.Spec.MinReadySeconds).To(Equal(pointer.Int32Ptr(0)))
-	g.Expect(md.Spec.RevisionHistoryLimit).To(Equal(pointer.Int32Ptr(1)))
+	g.Expect(md.Spec.RevisionHistoryLimit).To(Equal(pointer.Int32Ptr(10)))
 	g.Expect(md.Spec.ProgressDeadlineSeconds).To(Equal(pointer.Int32Ptr(600)))
 	g.Expect(md.Spec.Strategy).ToNot(BeNil())
 	g.Expect(md.Spec.Selector.MatchLabels).To(HaveKeyWithValue(MachineDeploymentLabelName, "test-md"))
@@ -49,6 +49,50 @@ func TestMachineDeploymentDefault(t *testing.T) {
 	g.Expect(md.Spec.Strategy.RollingUpdate.MaxUnavailable.IntValue()).To(Equal(0))
 }
 
+func TestMachineDeploymentDefaultSelector(t *testing.T) {
+	t.Run("selector is defaulted from the template labels and the name", func(t *testing.T) {
+		g := NewWithT(t)
+		md := &MachineDeployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "test-md",
+			},
+			Spec: MachineDeploymentSpec{
+				ClusterName: "test-cluster",
+				Template: MachineTemplateSpec{
+					ObjectMeta: ObjectMeta{Labels: map[string]string{"pool": "workers"}},
+				},
+			},
+		}
+
+		md.Default()
+
+		g.Expect(md.Spec.Selector.MatchLabels).To(HaveKeyWithValue("pool", "workers"))
+		g.Expect(md.Spec.Selector.MatchLabels).To(HaveKeyWithValue(MachineDeploymentLabelName, "test-md"))
+		g.Expect(md.Spec.Template.Labels).To(HaveKeyWithValue(MachineDeploymentLabelName, "test-md"))
+		g.Expect(md.ValidateCreate()).To(Succeed())
+	})
+
+	t.Run("explicit selector is not modified", func(t *testing.T) {
+		g := NewWithT(t)
+		md := &MachineDeployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "test-md",
+			},
+			Spec: MachineDeploymentSpec{
+				Selector: metav1.LabelSelector{MatchLabels: map[string]string{"pool": "workers"}},
+				Template: MachineTemplateSpec{
+					ObjectMeta: ObjectMeta{Labels: map[string]string{"pool": "workers", "zone": "a"}},
+				},
+			},
+		}
+
+		md.Default()
+
+		g.Expect(md.Spec.Selector.MatchLabels).To(HaveKeyWithValue("pool", "workers"))
+		g.Expect(md.Spec.Selector.MatchLabels).NotTo(HaveKey("zone"))
+	})
+}
+
 func TestMachineDeploymentValidation(t *testing.T) {
 	tests := []struct {
 		name      string
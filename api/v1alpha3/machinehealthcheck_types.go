@@ -40,6 +40,12 @@ type MachineHealthCheckSpec struct {
 	// +kubebuilder:validation:MinItems=1
 	UnhealthyConditions []UnhealthyCondition `json:"unhealthyConditions"`
 
+	// ConditionAliases maps provider specific node condition types to the standard
+	// condition types used in UnhealthyConditions, e.g. "DiskPressureExtended" to "DiskPressure".
+	// Node conditions are evaluated under both their own type and their alias.
+	// +optional
+	ConditionAliases map[string]string `json:"conditionAliases,omitempty"`
+
 	// Any further remediation is only allowed if at most "MaxUnhealthy" machines selected by
 	// "selector" are not healthy.
 	// +optional
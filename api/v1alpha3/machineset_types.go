@@ -17,13 +17,24 @@ limitations under the License.
 package v1alpha3
 
 import (
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 	capierrors "sigs.k8s.io/cluster-api/errors"
 )
 
+const (
+	// FailedMachinesCountAnnotation is set on a MachineSet to the total number of its Machines in the Failed phase,
+	// status.failedMachines being limited to a few names.
+	FailedMachinesCountAnnotation = "machineset.cluster.x-k8s.io/failed-machines-count"
+)
+
 // ANCHOR: MachineSetSpec
 
 // MachineSetSpec defines the desired state of MachineSet
@@ -59,10 +70,95 @@ type MachineSetSpec struct {
 	// Object references to custom resources resources are treated as templates.
 	// +optional
 	Template MachineTemplateSpec `json:"template,omitempty"`
+
+	// TopologySpreadConstraints describes how the Machines of the MachineSet are spread across
+	// the failure domains of the Cluster. When set, new Machines are created in the failure domain
+	// minimizing the skew, overriding the failure domain of the template.
+	// +optional
+	TopologySpreadConstraints []TopologySpreadConstraint `json:"topologySpreadConstraints,omitempty"`
+
+	// MachineNamingStrategy defines how the Machines of the MachineSet are named.
+	// Defaults to the name of the MachineSet followed by a random suffix.
+	// +optional
+	MachineNamingStrategy *MachineNamingStrategy `json:"machineNamingStrategy,omitempty"`
+
+	// UpdateStrategy bounds the number of Machines that can be created above, or be
+	// unavailable below, the desired replicas while the Machines of the MachineSet are replaced.
+	// The Machines being deleted count towards MaxSurge until they are gone, and at most
+	// MaxSurge plus MaxUnavailable Machines are re-provisioned at once on a bootstrap data change.
+	// +optional
+	UpdateStrategy *MachineSetUpdateStrategy `json:"updateStrategy,omitempty"`
 }
 
 // ANCHOR_END: MachineSetSpec
 
+// MachineSetUpdateStrategy describes how many Machines of a MachineSet can be replaced at once.
+type MachineSetUpdateStrategy struct {
+	// The maximum number of machines that can be unavailable during the update.
+	// Value can be an absolute number (ex: 5) or a percentage of desired
+	// machines (ex: 10%), up to 100%.
+	// Absolute number is calculated from percentage by rounding down.
+	// Defaults to 0.
+	// +optional
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+
+	// The maximum number of machines that can be created above the
+	// desired number of machines.
+	// Value can be an absolute number (ex: 5) or a percentage of
+	// desired machines (ex: 10%), up to 100%.
+	// Absolute number is calculated from percentage by rounding up.
+	// Defaults to 1.
+	// +optional
+	MaxSurge *intstr.IntOrString `json:"maxSurge,omitempty"`
+}
+
+// TopologySpreadConstraint specifies how to spread the Machines of a MachineSet across failure domains.
+type TopologySpreadConstraint struct {
+	// MaxSkew is the maximum permitted difference between the number of Machines
+	// in a failure domain and the minimum number of Machines in any failure domain.
+	// +kubebuilder:validation:Minimum=1
+	MaxSkew int32 `json:"maxSkew"`
+
+	// WhenUnsatisfiable indicates how to deal with a new Machine if it can't be created
+	// in any failure domain without exceeding MaxSkew. DoNotSchedule prevents the creation
+	// of the Machine, ScheduleAnyway creates it in the failure domain minimizing the skew.
+	// +kubebuilder:validation:Enum=DoNotSchedule;ScheduleAnyway
+	WhenUnsatisfiable corev1.UnsatisfiableConstraintAction `json:"whenUnsatisfiable"`
+}
+
+const (
+	// MachineNamingTemplateMachineSet is replaced by the name of the MachineSet in a MachineNamingStrategy template.
+	MachineNamingTemplateMachineSet = "{machineSet}"
+
+	// MachineNamingTemplateIndex is replaced in a MachineNamingStrategy template by the lowest
+	// non-negative integer giving a name not used by another Machine of the MachineSet.
+	MachineNamingTemplateIndex = "{index}"
+
+	// MachineNamingTemplateRandom is replaced by a random suffix in a MachineNamingStrategy template.
+	MachineNamingTemplateRandom = "{random}"
+
+	// MachineNameRandomLength is the length of the random suffix replacing MachineNamingTemplateRandom.
+	MachineNameRandomLength = 5
+)
+
+// MachineNamingStrategy defines how the Machines of a MachineSet are named.
+type MachineNamingStrategy struct {
+	// Template is the template used to name the Machines. It supports the {machineSet}, {index}
+	// and {random} placeholders. It must contain {machineSet}, and at least one of {index} and {random},
+	// so that each Machine gets a different name, e.g. "{machineSet}-worker-{index}".
+	Template string `json:"template"`
+}
+
+// MachineName returns the name of a Machine of the MachineSet named machineSetName,
+// replacing the placeholders of the template with the given values.
+func (s *MachineNamingStrategy) MachineName(machineSetName string, index int, random string) string {
+	return strings.NewReplacer(
+		MachineNamingTemplateMachineSet, machineSetName,
+		MachineNamingTemplateIndex, strconv.Itoa(index),
+		MachineNamingTemplateRandom, random,
+	).Replace(s.Template)
+}
+
 // ANCHOR: MachineTemplateSpec
 
 // MachineTemplateSpec describes the data needed to create a Machine from a template
@@ -133,6 +229,11 @@ type MachineSetStatus struct {
 	// +optional
 	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
 
+	// FailedMachines lists the names of the Machines of the MachineSet in the Failed phase, limited
+	// to 10 entries; the total number is stored in the FailedMachinesCountAnnotation annotation.
+	// +optional
+	FailedMachines []string `json:"failedMachines,omitempty"`
+
 	// In the event that there is a terminal problem reconciling the
 	// replicas, both FailureReason and FailureMessage will be set. FailureReason
 	// will be populated with a succinct value suitable for machine
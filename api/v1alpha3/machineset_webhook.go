@@ -18,11 +18,14 @@ package v1alpha3
 
 import (
 	"fmt"
+	"strings"
 
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 	"k8s.io/utils/pointer"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -65,10 +68,26 @@ func (m *MachineSet) Default() {
 		m.Spec.Template.Labels = make(map[string]string)
 	}
 
+	// If no selector has been provided, select the template labels and the MachineSet's name,
+	// so that the MachineSet does not select the Machines of other MachineSets.
 	if len(m.Spec.Selector.MatchLabels) == 0 && len(m.Spec.Selector.MatchExpressions) == 0 {
+		for k, v := range m.Spec.Template.Labels {
+			m.Spec.Selector.MatchLabels[k] = v
+		}
 		m.Spec.Selector.MatchLabels[MachineSetLabelName] = m.Name
 		m.Spec.Template.Labels[MachineSetLabelName] = m.Name
 	}
+
+	if m.Spec.UpdateStrategy != nil {
+		if m.Spec.UpdateStrategy.MaxSurge == nil {
+			ios1 := intstr.FromInt(1)
+			m.Spec.UpdateStrategy.MaxSurge = &ios1
+		}
+		if m.Spec.UpdateStrategy.MaxUnavailable == nil {
+			ios0 := intstr.FromInt(0)
+			m.Spec.UpdateStrategy.MaxUnavailable = &ios0
+		}
+	}
 }
 
 // ValidateCreate implements webhook.Validator so a webhook will be registered for the type
@@ -116,9 +135,62 @@ func (m *MachineSet) validate(old *MachineSet) error {
 		)
 	}
 
+	allErrs = append(allErrs, validatePropagatedInfrastructureLabels(m.Spec.Template.Spec.PropagatedInfrastructureLabels, &m.Spec.Selector,
+		field.NewPath("spec", "template", "spec", "propagatedInfrastructureLabels"))...)
+
+	if m.Spec.MachineNamingStrategy != nil {
+		allErrs = append(allErrs, validateMachineNamingStrategy(m.Spec.MachineNamingStrategy, m.Name,
+			field.NewPath("spec", "machineNamingStrategy", "template"))...)
+	}
+
+	if m.Spec.UpdateStrategy != nil {
+		path := field.NewPath("spec", "updateStrategy")
+		allErrs = append(allErrs, validateIntOrPercent(m.Spec.UpdateStrategy.MaxUnavailable, path.Child("maxUnavailable"))...)
+		allErrs = append(allErrs, validateIntOrPercent(m.Spec.UpdateStrategy.MaxSurge, path.Child("maxSurge"))...)
+	}
+
 	if len(allErrs) == 0 {
 		return nil
 	}
 
 	return apierrors.NewInvalid(GroupVersion.WithKind("MachineSet").GroupKind(), m.Name, allErrs)
 }
+
+// validateIntOrPercent checks that value, if set, is a non-negative number or percentage.
+func validateIntOrPercent(value *intstr.IntOrString, fldPath *field.Path) field.ErrorList {
+	if value == nil {
+		return nil
+	}
+	v, err := intstr.GetValueFromIntOrPercent(value, 100, true)
+	if err != nil {
+		return field.ErrorList{field.Invalid(fldPath, value.String(), "must be an integer or a percentage")}
+	}
+	if v < 0 {
+		return field.ErrorList{field.Invalid(fldPath, value.String(), "must not be negative")}
+	}
+	if value.Type == intstr.String && v > 100 {
+		return field.ErrorList{field.Invalid(fldPath, value.String(), "must not be greater than 100%")}
+	}
+	return nil
+}
+
+// validateMachineNamingStrategy checks that the template of the strategy gives different names to the Machines
+// of the MachineSet named machineSetName, and of other MachineSets, and that these names are valid object names.
+func validateMachineNamingStrategy(strategy *MachineNamingStrategy, machineSetName string, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if !strings.Contains(strategy.Template, MachineNamingTemplateMachineSet) {
+		allErrs = append(allErrs, field.Invalid(fldPath, strategy.Template,
+			fmt.Sprintf("must contain %s", MachineNamingTemplateMachineSet)))
+	}
+	if !strings.Contains(strategy.Template, MachineNamingTemplateIndex) && !strings.Contains(strategy.Template, MachineNamingTemplateRandom) {
+		allErrs = append(allErrs, field.Invalid(fldPath, strategy.Template,
+			fmt.Sprintf("must contain %s or %s", MachineNamingTemplateIndex, MachineNamingTemplateRandom)))
+	}
+
+	// Check the name of a sample Machine, the random suffixes having a fixed length.
+	name := strategy.MachineName(machineSetName, 0, strings.Repeat("x", MachineNameRandomLength))
+	for _, msg := range validation.IsDNS1123Subdomain(name) {
+		allErrs = append(allErrs, field.Invalid(fldPath, strategy.Template, fmt.Sprintf("generates invalid Machine name %q: %s", name, msg)))
+	}
+	return allErrs
+}
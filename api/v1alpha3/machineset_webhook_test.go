@@ -22,6 +22,7 @@ import (
 	. "github.com/onsi/gomega"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/utils/pointer"
 )
 
@@ -42,6 +43,50 @@ func TestMachineSetDefault(t *testing.T) {
 	g.Expect(md.Spec.Template.Labels).To(HaveKeyWithValue(MachineSetLabelName, "test-ms"))
 }
 
+func TestMachineSetDefaultSelector(t *testing.T) {
+	t.Run("selector is defaulted from the template labels and the name", func(t *testing.T) {
+		g := NewWithT(t)
+		ms := &MachineSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "test-ms",
+			},
+			Spec: MachineSetSpec{
+				ClusterName: "test-cluster",
+				Template: MachineTemplateSpec{
+					ObjectMeta: ObjectMeta{Labels: map[string]string{"pool": "workers"}},
+				},
+			},
+		}
+
+		ms.Default()
+
+		g.Expect(ms.Spec.Selector.MatchLabels).To(HaveKeyWithValue("pool", "workers"))
+		g.Expect(ms.Spec.Selector.MatchLabels).To(HaveKeyWithValue(MachineSetLabelName, "test-ms"))
+		g.Expect(ms.Spec.Template.Labels).To(HaveKeyWithValue(MachineSetLabelName, "test-ms"))
+		g.Expect(ms.ValidateCreate()).To(Succeed())
+	})
+
+	t.Run("explicit selector is not modified", func(t *testing.T) {
+		g := NewWithT(t)
+		ms := &MachineSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "test-ms",
+			},
+			Spec: MachineSetSpec{
+				Selector: metav1.LabelSelector{MatchLabels: map[string]string{"pool": "workers"}},
+				Template: MachineTemplateSpec{
+					ObjectMeta: ObjectMeta{Labels: map[string]string{"pool": "workers", "zone": "a"}},
+				},
+			},
+		}
+
+		ms.Default()
+
+		g.Expect(ms.Spec.Selector.MatchLabels).To(HaveKeyWithValue("pool", "workers"))
+		g.Expect(ms.Spec.Selector.MatchLabels).NotTo(HaveKey("zone"))
+	})
+}
+
 func TestMachineSetLabelSelectorMatchValidation(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -153,3 +198,200 @@ func TestMachineSetClusterNameImmutable(t *testing.T) {
 		})
 	}
 }
+
+func TestMachineSetMachineNamingStrategyValidation(t *testing.T) {
+	tests := []struct {
+		name      string
+		template  string
+		expectErr bool
+	}{
+		{
+			name:     "template with an index",
+			template: "{machineSet}-worker-{index}",
+		},
+		{
+			name:     "template with a random suffix",
+			template: "{machineSet}-{random}",
+		},
+		{
+			name:     "template with an index and a random suffix",
+			template: "{machineSet}-{index}-{random}",
+		},
+		{
+			name:      "template without the MachineSet name",
+			template:  "worker-{index}",
+			expectErr: true,
+		},
+		{
+			name:      "template without index nor random suffix",
+			template:  "{machineSet}-worker",
+			expectErr: true,
+		},
+		{
+			name:      "template generating invalid names",
+			template:  "{machineSet}_Worker_{index}",
+			expectErr: true,
+		},
+		{
+			name:      "template with an unknown placeholder",
+			template:  "{machineSet}-{zone}-{index}",
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			ms := &MachineSet{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "md-abcde",
+				},
+				Spec: MachineSetSpec{
+					MachineNamingStrategy: &MachineNamingStrategy{Template: tt.template},
+				},
+			}
+
+			if tt.expectErr {
+				g.Expect(ms.ValidateCreate()).NotTo(Succeed())
+			} else {
+				g.Expect(ms.ValidateCreate()).To(Succeed())
+			}
+		})
+	}
+}
+
+func TestMachineSetPropagatedInfrastructureLabelsValidation(t *testing.T) {
+	tests := []struct {
+		name      string
+		labels    []string
+		expectErr bool
+	}{
+		{
+			name:   "labels not in the selector",
+			labels: []string{"provider.example.com/zone"},
+		},
+		{
+			name:      "label in the match labels of the selector",
+			labels:    []string{"pool"},
+			expectErr: true,
+		},
+		{
+			name:      "label in the match expressions of the selector",
+			labels:    []string{"tier"},
+			expectErr: true,
+		},
+		{
+			name:      "reserved label",
+			labels:    []string{MachineDeploymentLabelName},
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			ms := &MachineSet{
+				Spec: MachineSetSpec{
+					Selector: metav1.LabelSelector{
+						MatchLabels: map[string]string{"pool": "a"},
+						MatchExpressions: []metav1.LabelSelectorRequirement{
+							{Key: "tier", Operator: metav1.LabelSelectorOpExists},
+						},
+					},
+					Template: MachineTemplateSpec{
+						ObjectMeta: ObjectMeta{
+							Labels: map[string]string{"pool": "a", "tier": "worker"},
+						},
+						Spec: MachineSpec{PropagatedInfrastructureLabels: tt.labels},
+					},
+				},
+			}
+
+			if tt.expectErr {
+				g.Expect(ms.ValidateCreate()).NotTo(Succeed())
+			} else {
+				g.Expect(ms.ValidateCreate()).To(Succeed())
+			}
+		})
+	}
+}
+
+func TestMachineSetUpdateStrategy(t *testing.T) {
+	t.Run("defaults unset values", func(t *testing.T) {
+		g := NewWithT(t)
+
+		ms := &MachineSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-ms"},
+			Spec:       MachineSetSpec{UpdateStrategy: &MachineSetUpdateStrategy{}},
+		}
+		ms.Default()
+
+		g.Expect(ms.Spec.UpdateStrategy.MaxSurge.IntValue()).To(Equal(1))
+		g.Expect(ms.Spec.UpdateStrategy.MaxUnavailable.IntValue()).To(Equal(0))
+	})
+
+	tests := []struct {
+		name           string
+		maxSurge       intstr.IntOrString
+		maxUnavailable intstr.IntOrString
+		expectErr      bool
+	}{
+		{
+			name:           "absolute numbers",
+			maxSurge:       intstr.FromInt(1),
+			maxUnavailable: intstr.FromInt(0),
+		},
+		{
+			name:           "percentages",
+			maxSurge:       intstr.FromString("25%"),
+			maxUnavailable: intstr.FromString("10%"),
+		},
+		{
+			name:           "invalid percentage",
+			maxSurge:       intstr.FromString("oops"),
+			maxUnavailable: intstr.FromInt(0),
+			expectErr:      true,
+		},
+		{
+			name:           "negative number",
+			maxSurge:       intstr.FromInt(1),
+			maxUnavailable: intstr.FromInt(-1),
+			expectErr:      true,
+		},
+		{
+			name:           "100%",
+			maxSurge:       intstr.FromString("100%"),
+			maxUnavailable: intstr.FromString("100%"),
+		},
+		{
+			name:           "percentage greater than 100%",
+			maxSurge:       intstr.FromInt(1),
+			maxUnavailable: intstr.FromString("150%"),
+			expectErr:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			ms := &MachineSet{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-ms"},
+				Spec: MachineSetSpec{
+					UpdateStrategy: &MachineSetUpdateStrategy{
+						MaxSurge:       &tt.maxSurge,
+						MaxUnavailable: &tt.maxUnavailable,
+					},
+				},
+			}
+
+			if tt.expectErr {
+				g.Expect(ms.ValidateCreate()).NotTo(Succeed())
+			} else {
+				g.Expect(ms.ValidateCreate()).To(Succeed())
+			}
+		})
+	}
+}
@@ -100,6 +100,206 @@ func (in *Cluster) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterClass) DeepCopyInto(out *ClusterClass) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterClass.
+func (in *ClusterClass) DeepCopy() *ClusterClass {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterClass)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterClass) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterClassList) DeepCopyInto(out *ClusterClassList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ClusterClass, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterClassList.
+func (in *ClusterClassList) DeepCopy() *ClusterClassList {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterClassList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterClassList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterClassSpec) DeepCopyInto(out *ClusterClassSpec) {
+	*out = *in
+	in.Infrastructure.DeepCopyInto(&out.Infrastructure)
+	in.ControlPlane.DeepCopyInto(&out.ControlPlane)
+	in.Workers.DeepCopyInto(&out.Workers)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterClassSpec.
+func (in *ClusterClassSpec) DeepCopy() *ClusterClassSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterClassSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterHealthCheck) DeepCopyInto(out *ClusterHealthCheck) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterHealthCheck.
+func (in *ClusterHealthCheck) DeepCopy() *ClusterHealthCheck {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterHealthCheck)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterHealthCheck) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterHealthCheckList) DeepCopyInto(out *ClusterHealthCheckList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ClusterHealthCheck, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterHealthCheckList.
+func (in *ClusterHealthCheckList) DeepCopy() *ClusterHealthCheckList {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterHealthCheckList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterHealthCheckList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterHealthCheckSpec) DeepCopyInto(out *ClusterHealthCheckSpec) {
+	*out = *in
+	if in.Checks != nil {
+		in, out := &in.Checks, &out.Checks
+		*out = make([]ClusterHealthCondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterHealthCheckSpec.
+func (in *ClusterHealthCheckSpec) DeepCopy() *ClusterHealthCheckSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterHealthCheckSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterHealthCheckStatus) DeepCopyInto(out *ClusterHealthCheckStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterHealthCheckStatus.
+func (in *ClusterHealthCheckStatus) DeepCopy() *ClusterHealthCheckStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterHealthCheckStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterHealthCondition) DeepCopyInto(out *ClusterHealthCondition) {
+	*out = *in
+	in.Selector.DeepCopyInto(&out.Selector)
+	if in.MinReady != nil {
+		in, out := &in.MinReady, &out.MinReady
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterHealthCondition.
+func (in *ClusterHealthCondition) DeepCopy() *ClusterHealthCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterHealthCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ClusterList) DeepCopyInto(out *ClusterList) {
 	*out = *in
@@ -150,6 +350,13 @@ func (in *ClusterNetwork) DeepCopyInto(out *ClusterNetwork) {
 		*out = new(NetworkRanges)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.AdditionalNetworks != nil {
+		in, out := &in.AdditionalNetworks, &out.AdditionalNetworks
+		*out = make([]ClusterNetworkSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterNetwork.
@@ -162,6 +369,31 @@ func (in *ClusterNetwork) DeepCopy() *ClusterNetwork {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterNetworkSpec) DeepCopyInto(out *ClusterNetworkSpec) {
+	*out = *in
+	if in.Pods != nil {
+		in, out := &in.Pods, &out.Pods
+		*out = new(NetworkRanges)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Services != nil {
+		in, out := &in.Services, &out.Services
+		*out = new(NetworkRanges)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterNetworkSpec.
+func (in *ClusterNetworkSpec) DeepCopy() *ClusterNetworkSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterNetworkSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ClusterSpec) DeepCopyInto(out *ClusterSpec) {
 	*out = *in
@@ -181,6 +413,16 @@ func (in *ClusterSpec) DeepCopyInto(out *ClusterSpec) {
 		*out = new(v1.ObjectReference)
 		**out = **in
 	}
+	if in.Topology != nil {
+		in, out := &in.Topology, &out.Topology
+		*out = new(Topology)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PropagatedLabels != nil {
+		in, out := &in.PropagatedLabels, &out.PropagatedLabels
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterSpec.
@@ -213,6 +455,13 @@ func (in *ClusterStatus) DeepCopyInto(out *ClusterStatus) {
 		*out = new(string)
 		**out = **in
 	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterStatus.
@@ -225,6 +474,43 @@ func (in *ClusterStatus) DeepCopy() *ClusterStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Condition) DeepCopyInto(out *Condition) {
+	*out = *in
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Condition.
+func (in *Condition) DeepCopy() *Condition {
+	if in == nil {
+		return nil
+	}
+	out := new(Condition)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in Conditions) DeepCopyInto(out *Conditions) {
+	{
+		in := &in
+		*out = make(Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Conditions.
+func (in Conditions) DeepCopy() Conditions {
+	if in == nil {
+		return nil
+	}
+	out := new(Conditions)
+	in.DeepCopyInto(out)
+	return *out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *FailureDomainSpec) DeepCopyInto(out *FailureDomainSpec) {
 	*out = *in
@@ -268,6 +554,26 @@ func (in FailureDomains) DeepCopy() FailureDomains {
 	return *out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LocalObjectTemplate) DeepCopyInto(out *LocalObjectTemplate) {
+	*out = *in
+	if in.Ref != nil {
+		in, out := &in.Ref, &out.Ref
+		*out = new(v1.ObjectReference)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LocalObjectTemplate.
+func (in *LocalObjectTemplate) DeepCopy() *LocalObjectTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(LocalObjectTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Machine) DeepCopyInto(out *Machine) {
 	*out = *in
@@ -335,7 +641,7 @@ func (in *MachineDeployment) DeepCopyInto(out *MachineDeployment) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineDeployment.
@@ -356,6 +662,39 @@ func (in *MachineDeployment) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MachineDeploymentClass) DeepCopyInto(out *MachineDeploymentClass) {
+	*out = *in
+	in.Template.DeepCopyInto(&out.Template)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineDeploymentClass.
+func (in *MachineDeploymentClass) DeepCopy() *MachineDeploymentClass {
+	if in == nil {
+		return nil
+	}
+	out := new(MachineDeploymentClass)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MachineDeploymentClassTemplate) DeepCopyInto(out *MachineDeploymentClassTemplate) {
+	*out = *in
+	in.Bootstrap.DeepCopyInto(&out.Bootstrap)
+	in.Infrastructure.DeepCopyInto(&out.Infrastructure)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineDeploymentClassTemplate.
+func (in *MachineDeploymentClassTemplate) DeepCopy() *MachineDeploymentClassTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(MachineDeploymentClassTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MachineDeploymentList) DeepCopyInto(out *MachineDeploymentList) {
 	*out = *in
@@ -398,6 +737,11 @@ func (in *MachineDeploymentSpec) DeepCopyInto(out *MachineDeploymentSpec) {
 	}
 	in.Selector.DeepCopyInto(&out.Selector)
 	in.Template.DeepCopyInto(&out.Template)
+	if in.PropagatedAnnotations != nil {
+		in, out := &in.PropagatedAnnotations, &out.PropagatedAnnotations
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	if in.Strategy != nil {
 		in, out := &in.Strategy, &out.Strategy
 		*out = new(MachineDeploymentStrategy)
@@ -408,6 +752,11 @@ func (in *MachineDeploymentSpec) DeepCopyInto(out *MachineDeploymentSpec) {
 		*out = new(int32)
 		**out = **in
 	}
+	if in.TopologySpreadConstraints != nil {
+		in, out := &in.TopologySpreadConstraints, &out.TopologySpreadConstraints
+		*out = make([]TopologySpreadConstraint, len(*in))
+		copy(*out, *in)
+	}
 	if in.RevisionHistoryLimit != nil {
 		in, out := &in.RevisionHistoryLimit, &out.RevisionHistoryLimit
 		*out = new(int32)
@@ -433,6 +782,13 @@ func (in *MachineDeploymentSpec) DeepCopy() *MachineDeploymentSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MachineDeploymentStatus) DeepCopyInto(out *MachineDeploymentStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineDeploymentStatus.
@@ -533,6 +889,13 @@ func (in *MachineHealthCheckSpec) DeepCopyInto(out *MachineHealthCheckSpec) {
 		*out = make([]UnhealthyCondition, len(*in))
 		copy(*out, *in)
 	}
+	if in.ConditionAliases != nil {
+		in, out := &in.ConditionAliases, &out.ConditionAliases
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	if in.MaxUnhealthy != nil {
 		in, out := &in.MaxUnhealthy, &out.MaxUnhealthy
 		*out = new(intstr.IntOrString)
@@ -602,6 +965,36 @@ func (in *MachineList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MachineNamingStrategy) DeepCopyInto(out *MachineNamingStrategy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineNamingStrategy.
+func (in *MachineNamingStrategy) DeepCopy() *MachineNamingStrategy {
+	if in == nil {
+		return nil
+	}
+	out := new(MachineNamingStrategy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MachineReadinessGate) DeepCopyInto(out *MachineReadinessGate) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineReadinessGate.
+func (in *MachineReadinessGate) DeepCopy() *MachineReadinessGate {
+	if in == nil {
+		return nil
+	}
+	out := new(MachineReadinessGate)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MachineRollingUpdateDeployment) DeepCopyInto(out *MachineRollingUpdateDeployment) {
 	*out = *in
@@ -615,6 +1008,11 @@ func (in *MachineRollingUpdateDeployment) DeepCopyInto(out *MachineRollingUpdate
 		*out = new(intstr.IntOrString)
 		**out = **in
 	}
+	if in.CanaryReplicas != nil {
+		in, out := &in.CanaryReplicas, &out.CanaryReplicas
+		*out = new(int32)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineRollingUpdateDeployment.
@@ -696,6 +1094,21 @@ func (in *MachineSetSpec) DeepCopyInto(out *MachineSetSpec) {
 	}
 	in.Selector.DeepCopyInto(&out.Selector)
 	in.Template.DeepCopyInto(&out.Template)
+	if in.TopologySpreadConstraints != nil {
+		in, out := &in.TopologySpreadConstraints, &out.TopologySpreadConstraints
+		*out = make([]TopologySpreadConstraint, len(*in))
+		copy(*out, *in)
+	}
+	if in.MachineNamingStrategy != nil {
+		in, out := &in.MachineNamingStrategy, &out.MachineNamingStrategy
+		*out = new(MachineNamingStrategy)
+		**out = **in
+	}
+	if in.UpdateStrategy != nil {
+		in, out := &in.UpdateStrategy, &out.UpdateStrategy
+		*out = new(MachineSetUpdateStrategy)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineSetSpec.
@@ -711,6 +1124,11 @@ func (in *MachineSetSpec) DeepCopy() *MachineSetSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MachineSetStatus) DeepCopyInto(out *MachineSetStatus) {
 	*out = *in
+	if in.FailedMachines != nil {
+		in, out := &in.FailedMachines, &out.FailedMachines
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	if in.FailureReason != nil {
 		in, out := &in.FailureReason, &out.FailureReason
 		*out = new(errors.MachineSetStatusError)
@@ -733,11 +1151,41 @@ func (in *MachineSetStatus) DeepCopy() *MachineSetStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MachineSetUpdateStrategy) DeepCopyInto(out *MachineSetUpdateStrategy) {
+	*out = *in
+	if in.MaxUnavailable != nil {
+		in, out := &in.MaxUnavailable, &out.MaxUnavailable
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+	if in.MaxSurge != nil {
+		in, out := &in.MaxSurge, &out.MaxSurge
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineSetUpdateStrategy.
+func (in *MachineSetUpdateStrategy) DeepCopy() *MachineSetUpdateStrategy {
+	if in == nil {
+		return nil
+	}
+	out := new(MachineSetUpdateStrategy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MachineSpec) DeepCopyInto(out *MachineSpec) {
 	*out = *in
 	in.Bootstrap.DeepCopyInto(&out.Bootstrap)
 	out.InfrastructureRef = in.InfrastructureRef
+	if in.PropagatedInfrastructureLabels != nil {
+		in, out := &in.PropagatedInfrastructureLabels, &out.PropagatedInfrastructureLabels
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	if in.Version != nil {
 		in, out := &in.Version, &out.Version
 		*out = new(string)
@@ -753,6 +1201,33 @@ func (in *MachineSpec) DeepCopyInto(out *MachineSpec) {
 		*out = new(string)
 		**out = **in
 	}
+	if in.Taints != nil {
+		in, out := &in.Taints, &out.Taints
+		*out = make([]v1.Taint, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ReadinessGates != nil {
+		in, out := &in.ReadinessGates, &out.ReadinessGates
+		*out = make([]MachineReadinessGate, len(*in))
+		copy(*out, *in)
+	}
+	if in.MaxUnavailableDuringUpgrade != nil {
+		in, out := &in.MaxUnavailableDuringUpgrade, &out.MaxUnavailableDuringUpgrade
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+	if in.NodeDeletionTimeout != nil {
+		in, out := &in.NodeDeletionTimeout, &out.NodeDeletionTimeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.NodeUnresponsiveTimeout != nil {
+		in, out := &in.NodeUnresponsiveTimeout, &out.NodeUnresponsiveTimeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineSpec.
@@ -777,6 +1252,10 @@ func (in *MachineStatus) DeepCopyInto(out *MachineStatus) {
 		in, out := &in.LastUpdated, &out.LastUpdated
 		*out = (*in).DeepCopy()
 	}
+	if in.LastSuccessfulReconcileTime != nil {
+		in, out := &in.LastSuccessfulReconcileTime, &out.LastSuccessfulReconcileTime
+		*out = (*in).DeepCopy()
+	}
 	if in.Version != nil {
 		in, out := &in.Version, &out.Version
 		*out = new(string)
@@ -797,6 +1276,13 @@ func (in *MachineStatus) DeepCopyInto(out *MachineStatus) {
 		*out = make(MachineAddresses, len(*in))
 		copy(*out, *in)
 	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineStatus.
@@ -882,6 +1368,79 @@ func (in *ObjectMeta) DeepCopy() *ObjectMeta {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Topology) DeepCopyInto(out *Topology) {
+	*out = *in
+	if in.ControlPlane != nil {
+		in, out := &in.ControlPlane, &out.ControlPlane
+		*out = new(TopologyClass)
+		**out = **in
+	}
+	if in.Workers != nil {
+		in, out := &in.Workers, &out.Workers
+		*out = make([]TopologyWorkerClass, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Topology.
+func (in *Topology) DeepCopy() *Topology {
+	if in == nil {
+		return nil
+	}
+	out := new(Topology)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TopologyClass) DeepCopyInto(out *TopologyClass) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TopologyClass.
+func (in *TopologyClass) DeepCopy() *TopologyClass {
+	if in == nil {
+		return nil
+	}
+	out := new(TopologyClass)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TopologySpreadConstraint) DeepCopyInto(out *TopologySpreadConstraint) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TopologySpreadConstraint.
+func (in *TopologySpreadConstraint) DeepCopy() *TopologySpreadConstraint {
+	if in == nil {
+		return nil
+	}
+	out := new(TopologySpreadConstraint)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TopologyWorkerClass) DeepCopyInto(out *TopologyWorkerClass) {
+	*out = *in
+	in.MachineTemplate.DeepCopyInto(&out.MachineTemplate)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TopologyWorkerClass.
+func (in *TopologyWorkerClass) DeepCopy() *TopologyWorkerClass {
+	if in == nil {
+		return nil
+	}
+	out := new(TopologyWorkerClass)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *UnhealthyCondition) DeepCopyInto(out *UnhealthyCondition) {
 	*out = *in
@@ -897,3 +1456,25 @@ func (in *UnhealthyCondition) DeepCopy() *UnhealthyCondition {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WorkersClass) DeepCopyInto(out *WorkersClass) {
+	*out = *in
+	if in.MachineDeployments != nil {
+		in, out := &in.MachineDeployments, &out.MachineDeployments
+		*out = make([]MachineDeploymentClass, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkersClass.
+func (in *WorkersClass) DeepCopy() *WorkersClass {
+	if in == nil {
+		return nil
+	}
+	out := new(WorkersClass)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -1,6 +1,6 @@
 module sigs.k8s.io/cluster-api/cmd/clusterctl/test/e2e
 
-go 1.13
+go 1.15
 
 require (
 	github.com/onsi/ginkgo v1.12.0
@@ -18,23 +18,33 @@ package controllers
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"path"
 	"strings"
+	"sync"
 	"time"
 
+	jsonpatch "github.com/evanphx/json-patch"
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/clock"
 	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
 	"sigs.k8s.io/cluster-api/controllers/external"
 	"sigs.k8s.io/cluster-api/controllers/metrics"
 	capierrors "sigs.k8s.io/cluster-api/errors"
 	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/patch"
 	"sigs.k8s.io/cluster-api/util/secret"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -42,6 +52,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 )
@@ -50,6 +61,62 @@ const (
 	// deleteRequeueAfter is how long to wait before checking again to see if the cluster still has children during
 	// deletion.
 	deleteRequeueAfter = 5 * time.Second
+
+	// infrastructureProviderCheckTTL is how long the infrastructure provider of a Cluster is known to be installed
+	// before it is checked again with the discovery client.
+	infrastructureProviderCheckTTL = 10 * time.Minute
+
+	// infrastructureProviderNotInstalledWait is how long to wait before checking again if the infrastructure
+	// provider of a Cluster is installed.
+	infrastructureProviderNotInstalledWait = 30 * time.Second
+)
+
+// DefaultStatusUpdateRetryPolicy is the StatusUpdateRetryPolicy used by the ClusterReconciler if none is set:
+// a conflicting update is retried up to 3 times.
+var DefaultStatusUpdateRetryPolicy = wait.Backoff{
+	Steps:    4,
+	Duration: 10 * time.Millisecond,
+	Factor:   1.0,
+	Jitter:   0.1,
+}
+
+// Reasons of the events emitted by the cluster controller.
+const (
+	// ClusterEventReasonInfrastructureReady is emitted when the infrastructure provider of the Cluster becomes ready.
+	ClusterEventReasonInfrastructureReady = "InfrastructureReady"
+
+	// ClusterEventReasonInfrastructureNotReady is emitted when the infrastructure provider of the Cluster
+	// stops reporting ready after having been ready.
+	ClusterEventReasonInfrastructureNotReady = "InfrastructureNotReady"
+
+	// ClusterEventReasonInfrastructureProviderNotInstalled is emitted when the kind referenced by
+	// Spec.InfrastructureRef is not served by the API server.
+	ClusterEventReasonInfrastructureProviderNotInstalled = "InfrastructureProviderNotInstalled"
+
+	// ClusterEventReasonOrphanedInfrastructure is emitted when infrastructure objects labelled for the Cluster
+	// are not referenced by it.
+	ClusterEventReasonOrphanedInfrastructure = "OrphanedInfrastructure"
+
+	// ClusterEventReasonControlPlaneInitialized is emitted when the control plane of the Cluster is initialized.
+	ClusterEventReasonControlPlaneInitialized = "ControlPlaneInitialized"
+
+	// ClusterEventReasonControlPlaneReady is emitted when the control plane provider of the Cluster becomes ready.
+	ClusterEventReasonControlPlaneReady = "ControlPlaneReady"
+
+	// ClusterEventReasonDeletionStarted is emitted when the controller starts deleting the Cluster.
+	ClusterEventReasonDeletionStarted = "DeletionStarted"
+
+	// ClusterEventReasonDeleted is emitted when all the objects of the Cluster have been deleted
+	// and the finalizer is removed.
+	ClusterEventReasonDeleted = "Deleted"
+
+	// ClusterEventReasonControlPlaneQuorumLost is emitted when more than half of the control plane Machines
+	// of the Cluster are failed or being deleted.
+	ClusterEventReasonControlPlaneQuorumLost = "ControlPlaneQuorumLost"
+
+	// ClusterEventReasonStuck is emitted when the Cluster stays in a non-terminal phase without any condition
+	// transition for longer than the stuck detection interval.
+	ClusterEventReasonStuck = "Stuck"
 )
 
 // +kubebuilder:rbac:groups=core,resources=events,verbs=get;list;watch;create;patch
@@ -57,6 +124,7 @@ const (
 // +kubebuilder:rbac:groups=core,resources=nodes,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io;bootstrap.cluster.x-k8s.io;controlplane.cluster.x-k8s.io,resources=*,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=clusters;clusters/status,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=clusterclasses,verbs=get;list;watch
 // +kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions,verbs=get;list;watch
 
 // ClusterReconciler reconciles a Cluster object
@@ -64,9 +132,47 @@ type ClusterReconciler struct {
 	Client client.Client
 	Log    logr.Logger
 
+	// ConcurrentReconciles is the maximum number of Clusters reconciled in parallel.
+	// If set, it overrides MaxConcurrentReconciles in the options passed to SetupWithManager.
+	ConcurrentReconciles int
+
+	// DiscoveryClient is used to check that the infrastructure provider referenced by a Cluster is installed.
+	// If nil, a discovery client for the manager's config is created in SetupWithManager.
+	DiscoveryClient discovery.DiscoveryInterface
+
+	// DryRun makes the controller log the writes and the events it would make to the API server,
+	// without persisting them. Writes are still sent to the API server for validation.
+	DryRun bool
+
+	// StatusUpdateRetryPolicy is the backoff used to retry the update of a Cluster right away when it fails
+	// with a conflict, before the error is returned to the work queue; Steps is the total number of attempts.
+	// Before each retry, the changes made by the reconciliation are applied to the Cluster read again.
+	// Defaults to DefaultStatusUpdateRetryPolicy.
+	StatusUpdateRetryPolicy *wait.Backoff
+
+	// StuckDetectionInterval is how long a Cluster can stay in a non-terminal phase without any condition
+	// transition before a Warning event reports it as stuck. If zero, stuck Clusters are not detected.
+	StuckDetectionInterval time.Duration
+
 	scheme          *runtime.Scheme
 	recorder        record.EventRecorder
 	externalTracker external.ObjectTracker
+	clock           clock.Clock
+
+	// reportedOrphans holds, for each Cluster, the orphaned infrastructure objects last reported with an
+	// OrphanedInfrastructure event, so the event is only emitted when the set of orphans changes.
+	reportedOrphans sync.Map
+
+	// installedInfrastructureProviders holds, for each Cluster, the installedInfrastructureProvider last found
+	// by isInfrastructureProviderInstalled, so that discovery isn't called on every reconcile.
+	installedInfrastructureProviders sync.Map
+}
+
+// installedInfrastructureProvider is the kind referenced by Spec.InfrastructureRef of a Cluster, found to be
+// served by the API server, and when that expires.
+type installedInfrastructureProvider struct {
+	gvk     schema.GroupVersionKind
+	expires time.Time
 }
 
 func (r *ClusterReconciler) SetupWithManager(mgr ctrl.Manager, options controller.Options) error {
@@ -76,21 +182,60 @@ func (r *ClusterReconciler) SetupWithManager(mgr ctrl.Manager, options controlle
 			&source.Kind{Type: &clusterv1.Machine{}},
 			&handler.EnqueueRequestsFromMapFunc{ToRequests: handler.ToRequestsFunc(r.controlPlaneMachineToCluster)},
 		).
-		WithOptions(options).
+		Watches(
+			&source.Kind{Type: &clusterv1.Machine{}},
+			&handler.EnqueueRequestsFromMapFunc{ToRequests: handler.ToRequestsFunc(r.controlPlaneQuorumMachineToCluster)},
+		).
+		WithOptions(r.controllerOptions(options)).
 		Build(r)
 
 	if err != nil {
 		return errors.Wrap(err, "failed setting up with a controller manager")
 	}
 
+	if r.DiscoveryClient == nil {
+		discoveryClient, err := discovery.NewDiscoveryClientForConfig(mgr.GetConfig())
+		if err != nil {
+			return errors.Wrap(err, "failed to create discovery client")
+		}
+		r.DiscoveryClient = discoveryClient
+	}
+
 	r.recorder = mgr.GetEventRecorderFor("cluster-controller")
+	if r.DryRun {
+		r.Client = newDryRunClient(r.Client, r.Log)
+		r.recorder = newDryRunRecorder(r.Log)
+	}
 	r.scheme = mgr.GetScheme()
 	r.externalTracker = external.ObjectTracker{
 		Controller: controller,
 	}
+
+	if r.StuckDetectionInterval > 0 {
+		r.clock = clock.RealClock{}
+		if err := mgr.Add(manager.RunnableFunc(r.runStuckDetection)); err != nil {
+			return errors.Wrap(err, "failed to add the stuck Cluster detection to the manager")
+		}
+	}
 	return nil
 }
 
+// controllerOptions returns the given options with ConcurrentReconciles applied, if set.
+func (r *ClusterReconciler) controllerOptions(options controller.Options) controller.Options {
+	if r.ConcurrentReconciles > 0 {
+		options.MaxConcurrentReconciles = r.ConcurrentReconciles
+	}
+	return options
+}
+
+// statusUpdateRetryPolicy returns StatusUpdateRetryPolicy, or DefaultStatusUpdateRetryPolicy if it isn't set.
+func (r *ClusterReconciler) statusUpdateRetryPolicy() wait.Backoff {
+	if r.StatusUpdateRetryPolicy != nil {
+		return *r.StatusUpdateRetryPolicy
+	}
+	return DefaultStatusUpdateRetryPolicy
+}
+
 func (r *ClusterReconciler) Reconcile(req ctrl.Request) (_ ctrl.Result, reterr error) {
 	ctx := context.Background()
 	logger := r.Log.WithValues("cluster", req.Name, "namespace", req.Namespace)
@@ -101,6 +246,8 @@ func (r *ClusterReconciler) Reconcile(req ctrl.Request) (_ ctrl.Result, reterr e
 		if apierrors.IsNotFound(err) {
 			// Object not found, return.  Created objects are automatically garbage collected.
 			// For additional cleanup logic use finalizers.
+			r.reportedOrphans.Delete(req.NamespacedName)
+			r.installedInfrastructureProviders.Delete(req.NamespacedName)
 			return ctrl.Result{}, nil
 		}
 
@@ -115,6 +262,7 @@ func (r *ClusterReconciler) Reconcile(req ctrl.Request) (_ ctrl.Result, reterr e
 	}
 
 	// Initialize the patch helper.
+	original := cluster.DeepCopy()
 	patchHelper, err := patch.NewHelper(cluster, r.Client)
 	if err != nil {
 		return ctrl.Result{}, err
@@ -125,8 +273,9 @@ func (r *ClusterReconciler) Reconcile(req ctrl.Request) (_ ctrl.Result, reterr e
 		r.reconcilePhase(ctx, cluster)
 		r.reconcileMetrics(ctx, cluster)
 
-		// Always attempt to Patch the Cluster object and status after each reconciliation.
-		if err := patchHelper.Patch(ctx, cluster); err != nil {
+		// Always attempt to Patch the Cluster object and status after each reconciliation,
+		// retrying right away on conflicts.
+		if err := r.patchCluster(ctx, patchHelper, original, cluster); err != nil {
 			reterr = kerrors.NewAggregate([]error{reterr, err})
 		}
 	}()
@@ -140,6 +289,53 @@ func (r *ClusterReconciler) Reconcile(req ctrl.Request) (_ ctrl.Result, reterr e
 	return r.reconcile(ctx, cluster)
 }
 
+// patchCluster patches the Cluster, retrying right away with the StatusUpdateRetryPolicy if the patch fails with
+// a conflict. Before each retry, the Cluster is read again, the changes made by the reconciliation since original
+// was read are applied to it, and its phase is computed again.
+func (r *ClusterReconciler) patchCluster(ctx context.Context, patchHelper *patch.Helper, original, cluster *clusterv1.Cluster) error {
+	originalJSON, err := json.Marshal(original)
+	if err != nil {
+		return errors.Wrapf(err, "failed to serialize Cluster %q in namespace %q", cluster.Name, cluster.Namespace)
+	}
+	clusterJSON, err := json.Marshal(cluster)
+	if err != nil {
+		return errors.Wrapf(err, "failed to serialize Cluster %q in namespace %q", cluster.Name, cluster.Namespace)
+	}
+	changes, err := jsonpatch.CreateMergePatch(originalJSON, clusterJSON)
+	if err != nil {
+		return errors.Wrapf(err, "failed to compute the changes made to Cluster %q in namespace %q", cluster.Name, cluster.Namespace)
+	}
+
+	attempted := false
+	return retry.OnError(r.statusUpdateRetryPolicy(), isConflict, func() error {
+		if attempted {
+			latest := &clusterv1.Cluster{}
+			if err := r.Client.Get(ctx, util.ObjectKey(cluster), latest); err != nil {
+				return err
+			}
+			if patchHelper, err = patch.NewHelper(latest, r.Client); err != nil {
+				return err
+			}
+			latestJSON, err := json.Marshal(latest)
+			if err != nil {
+				return errors.Wrapf(err, "failed to serialize Cluster %q in namespace %q", cluster.Name, cluster.Namespace)
+			}
+			rebasedJSON, err := jsonpatch.MergePatch(latestJSON, changes)
+			if err != nil {
+				return errors.Wrapf(err, "failed to apply the changes made to Cluster %q in namespace %q", cluster.Name, cluster.Namespace)
+			}
+			rebased := &clusterv1.Cluster{}
+			if err := json.Unmarshal(rebasedJSON, rebased); err != nil {
+				return errors.Wrapf(err, "failed to deserialize Cluster %q in namespace %q", cluster.Name, cluster.Namespace)
+			}
+			r.reconcilePhase(ctx, rebased)
+			rebased.DeepCopyInto(cluster)
+		}
+		attempted = true
+		return patchHelper.Patch(ctx, cluster)
+	})
+}
+
 // reconcile handles cluster reconciliation.
 func (r *ClusterReconciler) reconcile(ctx context.Context, cluster *clusterv1.Cluster) (ctrl.Result, error) {
 	logger := r.Log.WithValues("cluster", cluster.Name, "namespace", cluster.Namespace)
@@ -149,10 +345,14 @@ func (r *ClusterReconciler) reconcile(ctx context.Context, cluster *clusterv1.Cl
 
 	// Call the inner reconciliation methods.
 	reconciliationErrors := []error{
+		r.reconcileTopology(ctx, cluster),
 		r.reconcileInfrastructure(ctx, cluster),
+		r.reconcileOrphanedInfrastructure(ctx, cluster),
 		r.reconcileControlPlane(ctx, cluster),
 		r.reconcileKubeconfig(ctx, cluster),
 		r.reconcileControlPlaneInitialized(ctx, cluster),
+		r.reconcileControlPlaneQuorum(ctx, cluster),
+		r.reconcilePropagatedLabels(ctx, cluster),
 	}
 
 	// Parse the errors, making sure we record if there is a RequeueAfterError.
@@ -206,6 +406,11 @@ func (r *ClusterReconciler) reconcileMetrics(_ context.Context, cluster *cluster
 func (r *ClusterReconciler) reconcileDelete(ctx context.Context, cluster *clusterv1.Cluster) (reconcile.Result, error) {
 	logger := r.Log.WithValues("cluster", cluster.Name, "namespace", cluster.Namespace)
 
+	// The phase is updated after the first pass of the deletion flow.
+	if cluster.Status.GetTypedPhase() != clusterv1.ClusterPhaseDeleting {
+		r.recorder.Event(cluster, corev1.EventTypeNormal, ClusterEventReasonDeletionStarted, "Deleting Cluster and its descendants")
+	}
+
 	descendants, err := r.listDescendants(ctx, cluster)
 	if err != nil {
 		logger.Error(err, "Failed to list descendants")
@@ -306,6 +511,7 @@ func (r *ClusterReconciler) reconcileDelete(ctx context.Context, cluster *cluste
 	}
 
 	controllerutil.RemoveFinalizer(cluster, clusterv1.ClusterFinalizer)
+	r.recorder.Event(cluster, corev1.EventTypeNormal, ClusterEventReasonDeleted, "Deleted all the objects of the Cluster")
 	return ctrl.Result{}, nil
 }
 
@@ -459,6 +665,8 @@ func (r *ClusterReconciler) reconcileControlPlaneInitialized(ctx context.Context
 	for _, m := range machines {
 		if util.IsControlPlaneMachine(m) && m.Status.NodeRef != nil {
 			cluster.Status.ControlPlaneInitialized = true
+			r.recorder.Eventf(cluster, corev1.EventTypeNormal, ClusterEventReasonControlPlaneInitialized,
+				"Control plane initialized, control plane Machine %q has a Node", m.Name)
 			return nil
 		}
 	}
@@ -466,6 +674,56 @@ func (r *ClusterReconciler) reconcileControlPlaneInitialized(ctx context.Context
 	return nil
 }
 
+// reconcileControlPlaneQuorum sets the ControlPlaneQuorumLost condition on the Cluster if more than half
+// of its control plane Machines are failed, and removes it once the quorum is restored.
+func (r *ClusterReconciler) reconcileControlPlaneQuorum(ctx context.Context, cluster *clusterv1.Cluster) error {
+	lost, err := util.IsControlPlaneQuorumLost(ctx, r.Client, cluster)
+	if err != nil {
+		return err
+	}
+	if lost == conditions.Has(cluster.Status.Conditions, clusterv1.ControlPlaneQuorumLostCondition) {
+		return nil
+	}
+
+	if lost {
+		r.Log.Info("Control plane quorum lost", "cluster", cluster.Name, "namespace", cluster.Namespace)
+		conditions.MarkTrue(&cluster.Status.Conditions, clusterv1.ControlPlaneQuorumLostCondition)
+		r.recorder.Eventf(cluster, corev1.EventTypeWarning, ClusterEventReasonControlPlaneQuorumLost,
+			"More than half of the control plane Machines of Cluster %q are failed or being deleted", cluster.Name)
+	} else {
+		conditions.Delete(&cluster.Status.Conditions, clusterv1.ControlPlaneQuorumLostCondition)
+	}
+	return nil
+}
+
+// controlPlaneQuorumMachineToCluster is a handler.ToRequestsFunc to be used to enqueue requests for reconciliation
+// for Cluster to update its ControlPlaneQuorumLost condition: it returns the Cluster of failed or deleting control plane
+// Machines, and of control plane Machines whose Cluster lost quorum.
+func (r *ClusterReconciler) controlPlaneQuorumMachineToCluster(o handler.MapObject) []ctrl.Request {
+	m, ok := o.Object.(*clusterv1.Machine)
+	if !ok {
+		r.Log.Error(nil, fmt.Sprintf("Expected a Machine but got a %T", o.Object))
+		return nil
+	}
+	if !util.IsControlPlaneMachine(m) {
+		return nil
+	}
+
+	cluster, err := util.GetClusterByName(context.TODO(), r.Client, m.Namespace, m.Spec.ClusterName)
+	if err != nil {
+		r.Log.Error(err, "Failed to get cluster", "machine", m.Name, "cluster", m.Spec.ClusterName, "namespace", m.Namespace)
+		return nil
+	}
+
+	if !util.IsMachineFailed(m) && m.DeletionTimestamp.IsZero() && !conditions.Has(cluster.Status.Conditions, clusterv1.ControlPlaneQuorumLostCondition) {
+		return nil
+	}
+
+	return []ctrl.Request{{
+		NamespacedName: util.ObjectKey(cluster),
+	}}
+}
+
 // controlPlaneMachineToCluster is a handler.ToRequestsFunc to be used to enqueue requests for reconciliation
 // for Cluster to update its status.controlPlaneInitialized field
 func (r *ClusterReconciler) controlPlaneMachineToCluster(o handler.MapObject) []ctrl.Request {
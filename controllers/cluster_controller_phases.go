@@ -18,21 +18,32 @@ package controllers
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/utils/pointer"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
 	"sigs.k8s.io/cluster-api/controllers/external"
 	capierrors "sigs.k8s.io/cluster-api/errors"
+	"sigs.k8s.io/cluster-api/feature"
 	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	utilconversion "sigs.k8s.io/cluster-api/util/conversion"
 	"sigs.k8s.io/cluster-api/util/kubeconfig"
 	"sigs.k8s.io/cluster-api/util/patch"
 	"sigs.k8s.io/cluster-api/util/secret"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 )
@@ -131,6 +142,106 @@ func (r *ClusterReconciler) reconcileExternal(ctx context.Context, cluster *clus
 	return external.ReconcileOutput{Result: obj}, nil
 }
 
+// reconcileTopology creates the infrastructure and control plane objects of a Cluster from the templates
+// of the ClusterClass referenced by Spec.Topology, and sets the corresponding references on the Cluster.
+// References that are already set are left untouched.
+// If the ClusterTopology feature is enabled, the control plane and the worker MachineSets are then
+// reconciled to match the topology.
+func (r *ClusterReconciler) reconcileTopology(ctx context.Context, cluster *clusterv1.Cluster) error {
+	if cluster.Spec.Topology == nil {
+		return nil
+	}
+
+	if cluster.Spec.Topology.Class != "" {
+		if err := r.reconcileTopologyClass(ctx, cluster); err != nil {
+			return err
+		}
+	}
+
+	if !feature.Gates.Enabled(feature.ClusterTopology) {
+		return nil
+	}
+	if err := r.reconcileTopologyControlPlane(ctx, cluster); err != nil {
+		return err
+	}
+	return r.reconcileTopologyWorkers(ctx, cluster)
+}
+
+// reconcileTopologyClass creates the infrastructure and control plane objects that are not referenced
+// by the Cluster yet from the templates of its ClusterClass.
+func (r *ClusterReconciler) reconcileTopologyClass(ctx context.Context, cluster *clusterv1.Cluster) error {
+	class := &clusterv1.ClusterClass{}
+	key := client.ObjectKey{Namespace: cluster.Namespace, Name: cluster.Spec.Topology.Class}
+	if err := r.Client.Get(ctx, key, class); err != nil {
+		if apierrors.IsNotFound(err) {
+			return errors.Wrapf(&capierrors.RequeueAfterError{RequeueAfter: 30 * time.Second},
+				"could not find ClusterClass %q for Cluster %q in namespace %q, requeuing",
+				key.Name, cluster.Name, cluster.Namespace)
+		}
+		return errors.Wrapf(err, "failed to retrieve ClusterClass %q for Cluster %q in namespace %q", key.Name, cluster.Name, cluster.Namespace)
+	}
+
+	if cluster.Spec.InfrastructureRef == nil && class.Spec.Infrastructure.Ref != nil {
+		ref, err := r.cloneClassTemplate(ctx, cluster, class.Spec.Infrastructure.Ref, fmt.Sprintf("%s-infrastructure", cluster.Name))
+		if err != nil {
+			return errors.Wrapf(err, "failed to create infrastructure from ClusterClass %q for Cluster %q in namespace %q",
+				class.Name, cluster.Name, cluster.Namespace)
+		}
+		cluster.Spec.InfrastructureRef = ref
+	}
+
+	if cluster.Spec.ControlPlaneRef == nil && class.Spec.ControlPlane.Ref != nil {
+		ref, err := r.cloneClassTemplate(ctx, cluster, class.Spec.ControlPlane.Ref, fmt.Sprintf("%s-control-plane", cluster.Name))
+		if err != nil {
+			return errors.Wrapf(err, "failed to create control plane from ClusterClass %q for Cluster %q in namespace %q",
+				class.Name, cluster.Name, cluster.Namespace)
+		}
+		cluster.Spec.ControlPlaneRef = ref
+	}
+
+	return nil
+}
+
+// cloneClassTemplate creates a new object owned by the Cluster with the given name from the given template reference.
+// If the object already exists and is owned by the Cluster, e.g. because the Cluster could not be patched
+// with its reference after it was created, the existing object is used.
+func (r *ClusterReconciler) cloneClassTemplate(ctx context.Context, cluster *clusterv1.Cluster, templateRef *corev1.ObjectReference, name string) (*corev1.ObjectReference, error) {
+	template, err := external.Get(ctx, r.Client, templateRef, cluster.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	to, err := external.GenerateTemplate(&external.GenerateTemplateInput{
+		Template:    template,
+		Namespace:   cluster.Namespace,
+		ClusterName: cluster.Name,
+		OwnerRef: &metav1.OwnerReference{
+			APIVersion: clusterv1.GroupVersion.String(),
+			Kind:       "Cluster",
+			Name:       cluster.Name,
+			UID:        cluster.UID,
+		},
+		Name: name,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.Client.Create(ctx, to); err != nil {
+		if !apierrors.IsAlreadyExists(err) {
+			return nil, err
+		}
+		existing, err := external.Get(ctx, r.Client, external.GetObjectReference(to), cluster.Namespace)
+		if err != nil {
+			return nil, err
+		}
+		if !util.PointsTo(existing.GetOwnerReferences(), &cluster.ObjectMeta) {
+			return nil, errors.Errorf("%s %q already exists and is not owned by the Cluster", existing.GetKind(), existing.GetName())
+		}
+	}
+
+	return external.GetObjectReference(to), nil
+}
+
 // reconcileInfrastructure reconciles the Spec.InfrastructureRef object on a Cluster.
 func (r *ClusterReconciler) reconcileInfrastructure(ctx context.Context, cluster *clusterv1.Cluster) error {
 	logger := r.Log.WithValues("cluster", cluster.Name, "namespace", cluster.Namespace)
@@ -139,6 +250,28 @@ func (r *ClusterReconciler) reconcileInfrastructure(ctx context.Context, cluster
 		return nil
 	}
 
+	// Make sure the infrastructure provider is installed before trying to reconcile the referenced object.
+	installed, err := r.isInfrastructureProviderInstalled(cluster)
+	if err != nil {
+		return err
+	}
+	if !installed {
+		ref := cluster.Spec.InfrastructureRef
+		logger.Info("Infrastructure provider is not installed", "apiVersion", ref.APIVersion, "kind", ref.Kind)
+		if !conditions.IsFalse(cluster.Status.Conditions, clusterv1.InfrastructureProviderInstalledCondition) {
+			r.recorder.Eventf(cluster, corev1.EventTypeWarning, ClusterEventReasonInfrastructureProviderNotInstalled,
+				"Kind %s in version %s referenced by the Cluster is not served by the API server", ref.Kind, ref.APIVersion)
+		}
+		conditions.MarkFalse(&cluster.Status.Conditions, clusterv1.InfrastructureProviderInstalledCondition, clusterv1.InfrastructureProviderNotInstalledReason, clusterv1.ConditionSeverityError,
+			"%s %s is not installed", ref.APIVersion, ref.Kind)
+		// Nothing notifies the controller once the provider is installed, check again later.
+		return errors.Wrapf(&capierrors.RequeueAfterError{RequeueAfter: infrastructureProviderNotInstalledWait},
+			"infrastructure provider for Cluster %q in namespace %q is not installed, requeuing", cluster.Name, cluster.Namespace)
+	}
+	if r.DiscoveryClient != nil {
+		conditions.MarkTrue(&cluster.Status.Conditions, clusterv1.InfrastructureProviderInstalledCondition)
+	}
+
 	// Call generic external reconciler.
 	infraReconcileResult, err := r.reconcileExternal(ctx, cluster, cluster.Spec.InfrastructureRef)
 	if err != nil {
@@ -155,11 +288,24 @@ func (r *ClusterReconciler) reconcileInfrastructure(ctx context.Context, cluster
 		return nil
 	}
 
+	// Propagate the additional networks of the Cluster to the infrastructure provider.
+	if err := r.reconcileAdditionalNetworks(ctx, cluster, infraConfig); err != nil {
+		return err
+	}
+
 	// Determine if the infrastructure provider is ready.
 	ready, err := external.IsReady(infraConfig)
 	if err != nil {
 		return err
 	}
+	switch {
+	case ready && !cluster.Status.InfrastructureReady:
+		r.recorder.Eventf(cluster, corev1.EventTypeNormal, ClusterEventReasonInfrastructureReady,
+			"%s %q is ready", infraConfig.GetKind(), infraConfig.GetName())
+	case !ready && cluster.Status.InfrastructureReady:
+		r.recorder.Eventf(cluster, corev1.EventTypeWarning, ClusterEventReasonInfrastructureNotReady,
+			"%s %q is no longer ready", infraConfig.GetKind(), infraConfig.GetName())
+	}
 	cluster.Status.InfrastructureReady = ready
 	if !ready {
 		logger.V(3).Info("Infrastructure provider is not ready yet")
@@ -183,6 +329,145 @@ func (r *ClusterReconciler) reconcileInfrastructure(ctx context.Context, cluster
 	return nil
 }
 
+// reconcileAdditionalNetworks sets spec.additionalNetworks on the infrastructure object
+// to the additional networks defined in the Cluster network configuration.
+// The infrastructure object is left untouched if the Cluster doesn't define any additional network,
+// so providers that don't implement the field, or manage it themselves, are not affected.
+func (r *ClusterReconciler) reconcileAdditionalNetworks(ctx context.Context, cluster *clusterv1.Cluster, infraConfig *unstructured.Unstructured) error {
+	if cluster.Spec.ClusterNetwork == nil || len(cluster.Spec.ClusterNetwork.AdditionalNetworks) == 0 {
+		return nil
+	}
+
+	patchHelper, err := patch.NewHelper(infraConfig, r.Client)
+	if err != nil {
+		return err
+	}
+
+	if err := setAdditionalNetworks(cluster, infraConfig); err != nil {
+		return errors.Wrapf(err, "failed to set Spec.AdditionalNetworks on infrastructure provider for Cluster %q in namespace %q",
+			cluster.Name, cluster.Namespace)
+	}
+
+	return patchHelper.Patch(ctx, infraConfig)
+}
+
+// setAdditionalNetworks copies the additional networks of the Cluster to spec.additionalNetworks of obj.
+func setAdditionalNetworks(cluster *clusterv1.Cluster, obj *unstructured.Unstructured) error {
+	data, err := json.Marshal(cluster.Spec.ClusterNetwork.AdditionalNetworks)
+	if err != nil {
+		return err
+	}
+	var networks []interface{}
+	if err := json.Unmarshal(data, &networks); err != nil {
+		return err
+	}
+	return unstructured.SetNestedSlice(obj.Object, networks, "spec", "additionalNetworks")
+}
+
+// isInfrastructureProviderInstalled uses the discovery client to check if the kind referenced by
+// Spec.InfrastructureRef of the Cluster is served by the API server. If no discovery client is configured,
+// the check is skipped. A kind found to be installed is cached for the Cluster for infrastructureProviderCheckTTL.
+func (r *ClusterReconciler) isInfrastructureProviderInstalled(cluster *clusterv1.Cluster) (bool, error) {
+	if r.DiscoveryClient == nil {
+		return true, nil
+	}
+
+	ref := cluster.Spec.InfrastructureRef
+	key := util.ObjectKey(cluster)
+	gvk := ref.GroupVersionKind()
+	if cached, ok := r.installedInfrastructureProviders.Load(key); ok {
+		provider := cached.(installedInfrastructureProvider)
+		if provider.gvk == gvk && time.Now().Before(provider.expires) {
+			return true, nil
+		}
+	}
+	r.installedInfrastructureProviders.Delete(key)
+
+	resources, err := r.DiscoveryClient.ServerResourcesForGroupVersion(ref.APIVersion)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, errors.Wrapf(err, "failed to discover resources for %s", ref.APIVersion)
+	}
+
+	for _, resource := range resources.APIResources {
+		if resource.Kind == ref.Kind {
+			r.installedInfrastructureProviders.Store(key, installedInfrastructureProvider{
+				gvk:     gvk,
+				expires: time.Now().Add(infrastructureProviderCheckTTL),
+			})
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// reconcileOrphanedInfrastructure looks for infrastructure objects of the same kind as Spec.InfrastructureRef
+// that carry the Cluster label but are not referenced by the Cluster anymore, e.g. because the reference has been
+// changed after creation. Orphans are reported with a Warning event whenever the set of orphans changes, and
+// deleted if the Cluster has the cleanup-orphans annotation set to "true".
+func (r *ClusterReconciler) reconcileOrphanedInfrastructure(ctx context.Context, cluster *clusterv1.Cluster) error {
+	logger := r.Log.WithValues("cluster", cluster.Name, "namespace", cluster.Namespace)
+
+	ref := cluster.Spec.InfrastructureRef
+	if ref == nil {
+		return nil
+	}
+
+	// There's nothing to look for if the infrastructure provider is not installed.
+	if conditions.IsFalse(cluster.Status.Conditions, clusterv1.InfrastructureProviderInstalledCondition) {
+		return nil
+	}
+
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(ref.GroupVersionKind().GroupVersion().WithKind(ref.Kind + "List"))
+	if err := r.Client.List(ctx, list,
+		client.InNamespace(cluster.Namespace),
+		client.MatchingLabels{clusterv1.ClusterLabelName: cluster.Name},
+	); err != nil {
+		return errors.Wrapf(err, "failed to list %s objects for Cluster %q in namespace %q", ref.Kind, cluster.Name, cluster.Namespace)
+	}
+
+	var orphans []unstructured.Unstructured
+	for _, obj := range list.Items {
+		if obj.GetName() == ref.Name || !obj.GetDeletionTimestamp().IsZero() {
+			continue
+		}
+		orphans = append(orphans, obj)
+	}
+	key := util.ObjectKey(cluster)
+	if len(orphans) == 0 {
+		r.reportedOrphans.Delete(key)
+		return nil
+	}
+
+	names := make([]string, len(orphans))
+	for i := range orphans {
+		names[i] = orphans[i].GetName()
+	}
+	sort.Strings(names)
+	message := fmt.Sprintf("Found %s objects not referenced by the Cluster: %s", ref.Kind, strings.Join(names, ", "))
+	if reported, ok := r.reportedOrphans.Load(key); !ok || reported.(string) != message {
+		r.recorder.Event(cluster, corev1.EventTypeWarning, ClusterEventReasonOrphanedInfrastructure, message)
+		r.reportedOrphans.Store(key, message)
+	}
+
+	if cluster.Annotations[clusterv1.CleanupOrphansAnnotation] != "true" {
+		return nil
+	}
+
+	var errs []error
+	for i := range orphans {
+		logger.Info("Deleting orphaned infrastructure object", "kind", ref.Kind, "name", orphans[i].GetName())
+		if err := r.Client.Delete(ctx, &orphans[i]); err != nil && !apierrors.IsNotFound(err) {
+			errs = append(errs, errors.Wrapf(err, "failed to delete orphaned %s %q for Cluster %q in namespace %q",
+				ref.Kind, orphans[i].GetName(), cluster.Name, cluster.Namespace))
+		}
+	}
+	return kerrors.NewAggregate(errs)
+}
+
 // reconcileControlPlane reconciles the Spec.ControlPlaneRef object on a Cluster.
 func (r *ClusterReconciler) reconcileControlPlane(ctx context.Context, cluster *clusterv1.Cluster) error {
 	if cluster.Spec.ControlPlaneRef == nil {
@@ -212,6 +497,10 @@ func (r *ClusterReconciler) reconcileControlPlane(ctx context.Context, cluster *
 		if err != nil {
 			return err
 		}
+		if initialized {
+			r.recorder.Eventf(cluster, corev1.EventTypeNormal, ClusterEventReasonControlPlaneInitialized,
+				"%s %q is initialized", controlPlaneConfig.GetKind(), controlPlaneConfig.GetName())
+		}
 		cluster.Status.ControlPlaneInitialized = initialized
 	}
 
@@ -220,6 +509,10 @@ func (r *ClusterReconciler) reconcileControlPlane(ctx context.Context, cluster *
 	if err != nil {
 		return err
 	}
+	if ready && !cluster.Status.ControlPlaneReady {
+		r.recorder.Eventf(cluster, corev1.EventTypeNormal, ClusterEventReasonControlPlaneReady,
+			"%s %q is ready", controlPlaneConfig.GetKind(), controlPlaneConfig.GetName())
+	}
 	cluster.Status.ControlPlaneReady = ready
 
 	return nil
@@ -254,3 +547,70 @@ func (r *ClusterReconciler) reconcileKubeconfig(ctx context.Context, cluster *cl
 
 	return nil
 }
+
+// reconcilePropagatedLabels copies the labels listed in Spec.PropagatedLabels from the Cluster
+// to the MachineDeployments and MachineSets belonging to it. Labels are never removed.
+func (r *ClusterReconciler) reconcilePropagatedLabels(ctx context.Context, cluster *clusterv1.Cluster) error {
+	labels := map[string]string{}
+	for _, key := range cluster.Spec.PropagatedLabels {
+		if value, ok := cluster.Labels[key]; ok {
+			labels[key] = value
+		}
+	}
+	if len(labels) == 0 {
+		return nil
+	}
+
+	listOptions := []client.ListOption{
+		client.InNamespace(cluster.Namespace),
+		client.MatchingLabels(map[string]string{clusterv1.ClusterLabelName: cluster.Name}),
+	}
+
+	machineDeployments := &clusterv1.MachineDeploymentList{}
+	if err := r.Client.List(ctx, machineDeployments, listOptions...); err != nil {
+		return errors.Wrapf(err, "failed to list MachineDeployments for cluster %s/%s", cluster.Namespace, cluster.Name)
+	}
+	machineSets := &clusterv1.MachineSetList{}
+	if err := r.Client.List(ctx, machineSets, listOptions...); err != nil {
+		return errors.Wrapf(err, "failed to list MachineSets for cluster %s/%s", cluster.Namespace, cluster.Name)
+	}
+
+	objs := make([]runtime.Object, 0, len(machineDeployments.Items)+len(machineSets.Items))
+	for i := range machineDeployments.Items {
+		objs = append(objs, &machineDeployments.Items[i])
+	}
+	for i := range machineSets.Items {
+		objs = append(objs, &machineSets.Items[i])
+	}
+
+	var errs []error
+	for _, obj := range objs {
+		accessor, err := meta.Accessor(obj)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		patch := client.MergeFrom(obj.DeepCopyObject())
+		objLabels := accessor.GetLabels()
+		if objLabels == nil {
+			objLabels = map[string]string{}
+		}
+		changed := false
+		for key, value := range labels {
+			if current, ok := objLabels[key]; !ok || current != value {
+				objLabels[key] = value
+				changed = true
+			}
+		}
+		if !changed {
+			continue
+		}
+		accessor.SetLabels(objLabels)
+
+		if err := r.Client.Patch(ctx, obj, patch); err != nil {
+			errs = append(errs, errors.Wrapf(err, "failed to propagate labels to %T %s/%s", obj, accessor.GetNamespace(), accessor.GetName()))
+		}
+	}
+	return kerrors.NewAggregate(errs)
+}
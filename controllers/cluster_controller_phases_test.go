@@ -22,15 +22,23 @@ import (
 	"time"
 
 	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
 
 	corev1 "k8s.io/api/core/v1"
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakediscovery "k8s.io/client-go/discovery/fake"
 	"k8s.io/client-go/kubernetes/scheme"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
 	"sigs.k8s.io/cluster-api/controllers/external"
 	capierrors "sigs.k8s.io/cluster-api/errors"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/log"
@@ -135,9 +143,10 @@ func TestClusterReconcilePhases(t *testing.T) {
 					c = fake.NewFakeClientWithScheme(scheme.Scheme, external.TestGenericInfrastructureCRD, tt.cluster)
 				}
 				r := &ClusterReconciler{
-					Client: c,
-					Log:    log.Log,
-					scheme: scheme.Scheme,
+					Client:   c,
+					Log:      log.Log,
+					scheme:   scheme.Scheme,
+					recorder: record.NewFakeRecorder(10),
 				}
 
 				err := r.reconcileInfrastructure(context.Background(), tt.cluster)
@@ -151,6 +160,399 @@ func TestClusterReconcilePhases(t *testing.T) {
 
 	})
 
+	t.Run("reconcile topology", func(t *testing.T) {
+		g := NewWithT(t)
+		g.Expect(clusterv1.AddToScheme(scheme.Scheme)).To(Succeed())
+
+		infraTemplate := &unstructured.Unstructured{Object: map[string]interface{}{
+			"kind":       "InfrastructureClusterTemplate",
+			"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
+			"metadata": map[string]interface{}{
+				"name":      "infra-template",
+				"namespace": "test-namespace",
+			},
+			"spec": map[string]interface{}{
+				"template": map[string]interface{}{
+					"spec": map[string]interface{}{
+						"size": "large",
+					},
+				},
+			},
+		}}
+		class := &clusterv1.ClusterClass{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-class",
+				Namespace: "test-namespace",
+			},
+			Spec: clusterv1.ClusterClassSpec{
+				Infrastructure: clusterv1.LocalObjectTemplate{
+					Ref: &corev1.ObjectReference{
+						APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+						Kind:       "InfrastructureClusterTemplate",
+						Name:       "infra-template",
+					},
+				},
+			},
+		}
+		cluster := &clusterv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-cluster",
+				Namespace: "test-namespace",
+			},
+			Spec: clusterv1.ClusterSpec{
+				Topology: &clusterv1.Topology{Class: "test-class"},
+			},
+		}
+
+		r := &ClusterReconciler{
+			Client: fake.NewFakeClientWithScheme(scheme.Scheme, cluster, class, infraTemplate),
+			Log:    log.Log,
+			scheme: scheme.Scheme,
+		}
+
+		g.Expect(r.reconcileTopology(context.Background(), cluster)).To(Succeed())
+		g.Expect(cluster.Spec.InfrastructureRef).NotTo(BeNil())
+		g.Expect(cluster.Spec.InfrastructureRef.Kind).To(Equal("InfrastructureCluster"))
+		g.Expect(cluster.Spec.ControlPlaneRef).To(BeNil())
+
+		infra, err := external.Get(context.Background(), r.Client, cluster.Spec.InfrastructureRef, cluster.Namespace)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(infra.GetLabels()).To(HaveKeyWithValue(clusterv1.ClusterLabelName, "test-cluster"))
+		size, _, err := unstructured.NestedString(infra.Object, "spec", "size")
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(size).To(Equal("large"))
+
+		// Reconciling again must not create a new infrastructure object.
+		ref := cluster.Spec.InfrastructureRef.DeepCopy()
+		g.Expect(r.reconcileTopology(context.Background(), cluster)).To(Succeed())
+		g.Expect(cluster.Spec.InfrastructureRef).To(Equal(ref))
+
+		// The infrastructure object created by a reconcile that failed to persist its reference is used.
+		cluster.Spec.InfrastructureRef = nil
+		g.Expect(r.reconcileTopology(context.Background(), cluster)).To(Succeed())
+		g.Expect(cluster.Spec.InfrastructureRef).To(Equal(ref))
+		g.Expect(ref.Name).To(Equal("test-cluster-infrastructure"))
+
+		// An object with the same name that isn't owned by the Cluster is not used.
+		otherCluster := cluster.DeepCopy()
+		otherCluster.UID = "other-uid"
+		otherCluster.Spec.InfrastructureRef = nil
+		g.Expect(r.reconcileTopology(context.Background(), otherCluster)).NotTo(Succeed())
+		g.Expect(otherCluster.Spec.InfrastructureRef).To(BeNil())
+
+		// A missing ClusterClass requeues.
+		cluster.Spec.Topology.Class = "missing-class"
+		err = r.reconcileTopology(context.Background(), cluster)
+		g.Expect(err).To(HaveOccurred())
+		_, ok := errors.Cause(err).(capierrors.HasRequeueAfterError)
+		g.Expect(ok).To(BeTrue())
+	})
+
+	t.Run("reconcile infrastructure provider not installed", func(t *testing.T) {
+		g := NewWithT(t)
+		g.Expect(clusterv1.AddToScheme(scheme.Scheme)).To(Succeed())
+
+		cluster := &clusterv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-cluster",
+				Namespace: "test-namespace",
+			},
+			Spec: clusterv1.ClusterSpec{
+				InfrastructureRef: &corev1.ObjectReference{
+					APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+					Kind:       "InfrastructureMachine",
+					Name:       "test",
+				},
+			},
+		}
+
+		// The group version is served, but the referenced kind is missing.
+		discoveryClient := &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{
+			Resources: []*metav1.APIResourceList{
+				{
+					GroupVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+					APIResources: []metav1.APIResource{{Name: "infrastructureclusters", Kind: "InfrastructureCluster"}},
+				},
+			},
+		}}
+
+		recorder := record.NewFakeRecorder(10)
+		r := &ClusterReconciler{
+			Client:          fake.NewFakeClientWithScheme(scheme.Scheme, cluster),
+			Log:             log.Log,
+			DiscoveryClient: discoveryClient,
+			scheme:          scheme.Scheme,
+			recorder:        recorder,
+		}
+
+		err := r.reconcileInfrastructure(context.Background(), cluster)
+		g.Expect(err).To(HaveOccurred())
+		_, ok := errors.Cause(err).(capierrors.HasRequeueAfterError)
+		g.Expect(ok).To(BeTrue())
+		g.Expect(recorder.Events).To(Receive(ContainSubstring("InfrastructureProviderNotInstalled")))
+		g.Expect(conditions.IsFalse(cluster.Status.Conditions, clusterv1.InfrastructureProviderInstalledCondition)).To(BeTrue())
+		g.Expect(conditions.Get(cluster.Status.Conditions, clusterv1.InfrastructureProviderInstalledCondition).Reason).To(Equal(clusterv1.InfrastructureProviderNotInstalledReason))
+
+		// The event is not emitted again while the provider is still not installed.
+		g.Expect(r.reconcileInfrastructure(context.Background(), cluster)).NotTo(Succeed())
+		g.Expect(recorder.Events).NotTo(Receive())
+	})
+
+	t.Run("infrastructure provider installed is cached for the cluster", func(t *testing.T) {
+		g := NewWithT(t)
+
+		cluster := &clusterv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-cluster",
+				Namespace: "test-namespace",
+			},
+			Spec: clusterv1.ClusterSpec{
+				InfrastructureRef: &corev1.ObjectReference{
+					APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+					Kind:       "InfrastructureMachine",
+					Name:       "test",
+				},
+			},
+		}
+
+		discoveryClient := &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{
+			Resources: []*metav1.APIResourceList{
+				{
+					GroupVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+					APIResources: []metav1.APIResource{{Name: "infrastructuremachines", Kind: "InfrastructureMachine"}},
+				},
+			},
+		}}
+		r := &ClusterReconciler{
+			Log:             log.Log,
+			DiscoveryClient: discoveryClient,
+		}
+
+		for i := 0; i < 3; i++ {
+			installed, err := r.isInfrastructureProviderInstalled(cluster)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(installed).To(BeTrue())
+		}
+		g.Expect(discoveryClient.Actions()).To(HaveLen(1))
+
+		// Changing the referenced kind checks discovery again.
+		cluster.Spec.InfrastructureRef.Kind = "InfrastructureCluster"
+		installed, err := r.isInfrastructureProviderInstalled(cluster)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(installed).To(BeFalse())
+		g.Expect(discoveryClient.Actions()).To(HaveLen(2))
+	})
+
+	t.Run("reconcile orphaned infrastructure", func(t *testing.T) {
+		infraObject := func(name string) *unstructured.Unstructured {
+			return &unstructured.Unstructured{Object: map[string]interface{}{
+				"kind":       "InfrastructureMachine",
+				"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
+				"metadata": map[string]interface{}{
+					"name":      name,
+					"namespace": "test-namespace",
+					"labels": map[string]interface{}{
+						clusterv1.ClusterLabelName: "test-cluster",
+					},
+				},
+			}}
+		}
+
+		tests := []struct {
+			name        string
+			annotations map[string]string
+			wantEvent   bool
+			wantDeleted bool
+		}{
+			{
+				name:      "emits a warning event for orphaned objects",
+				wantEvent: true,
+			},
+			{
+				name:        "deletes orphaned objects if the cleanup annotation is set",
+				annotations: map[string]string{clusterv1.CleanupOrphansAnnotation: "true"},
+				wantEvent:   true,
+				wantDeleted: true,
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				g := NewWithT(t)
+
+				// The fake client needs the list kind to be registered to list unstructured objects.
+				testScheme := runtime.NewScheme()
+				g.Expect(clusterv1.AddToScheme(testScheme)).To(Succeed())
+				infraGV := schema.GroupVersion{Group: "infrastructure.cluster.x-k8s.io", Version: "v1alpha3"}
+				testScheme.AddKnownTypeWithName(infraGV.WithKind("InfrastructureMachine"), &unstructured.Unstructured{})
+				testScheme.AddKnownTypeWithName(infraGV.WithKind("InfrastructureMachineList"), &unstructured.UnstructuredList{})
+
+				cluster := &clusterv1.Cluster{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:        "test-cluster",
+						Namespace:   "test-namespace",
+						Annotations: tt.annotations,
+					},
+					Spec: clusterv1.ClusterSpec{
+						InfrastructureRef: &corev1.ObjectReference{
+							APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+							Kind:       "InfrastructureMachine",
+							Name:       "current",
+						},
+					},
+				}
+
+				recorder := record.NewFakeRecorder(10)
+				r := &ClusterReconciler{
+					Client:   fake.NewFakeClientWithScheme(testScheme, cluster, infraObject("current"), infraObject("orphan")),
+					Log:      log.Log,
+					scheme:   testScheme,
+					recorder: recorder,
+				}
+
+				g.Expect(r.reconcileOrphanedInfrastructure(context.Background(), cluster)).To(Succeed())
+				if tt.wantEvent {
+					g.Expect(recorder.Events).To(Receive(ContainSubstring("orphan")))
+				}
+
+				orphan := infraObject("orphan")
+				err := r.Client.Get(context.Background(), client.ObjectKey{Namespace: "test-namespace", Name: "orphan"}, orphan)
+				if tt.wantDeleted {
+					g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+				} else {
+					g.Expect(err).NotTo(HaveOccurred())
+				}
+
+				current := infraObject("current")
+				g.Expect(r.Client.Get(context.Background(), client.ObjectKey{Namespace: "test-namespace", Name: "current"}, current)).To(Succeed())
+			})
+		}
+	})
+
+	t.Run("reports orphaned infrastructure only when the orphans change", func(t *testing.T) {
+		g := NewWithT(t)
+
+		testScheme := runtime.NewScheme()
+		g.Expect(clusterv1.AddToScheme(testScheme)).To(Succeed())
+		infraGV := schema.GroupVersion{Group: "infrastructure.cluster.x-k8s.io", Version: "v1alpha3"}
+		testScheme.AddKnownTypeWithName(infraGV.WithKind("InfrastructureMachine"), &unstructured.Unstructured{})
+		testScheme.AddKnownTypeWithName(infraGV.WithKind("InfrastructureMachineList"), &unstructured.UnstructuredList{})
+
+		infraObject := func(name string) *unstructured.Unstructured {
+			return &unstructured.Unstructured{Object: map[string]interface{}{
+				"kind":       "InfrastructureMachine",
+				"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
+				"metadata": map[string]interface{}{
+					"name":      name,
+					"namespace": "test-namespace",
+					"labels": map[string]interface{}{
+						clusterv1.ClusterLabelName: "test-cluster",
+					},
+				},
+			}}
+		}
+
+		cluster := &clusterv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-cluster",
+				Namespace: "test-namespace",
+			},
+			Spec: clusterv1.ClusterSpec{
+				InfrastructureRef: &corev1.ObjectReference{
+					APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+					Kind:       "InfrastructureMachine",
+					Name:       "current",
+				},
+			},
+		}
+
+		recorder := record.NewFakeRecorder(10)
+		r := &ClusterReconciler{
+			Client:   fake.NewFakeClientWithScheme(testScheme, cluster, infraObject("current"), infraObject("orphan")),
+			Log:      log.Log,
+			scheme:   testScheme,
+			recorder: recorder,
+		}
+
+		g.Expect(r.reconcileOrphanedInfrastructure(context.Background(), cluster)).To(Succeed())
+		g.Expect(recorder.Events).To(Receive(ContainSubstring("orphan")))
+
+		// The same orphans are not reported again.
+		g.Expect(r.reconcileOrphanedInfrastructure(context.Background(), cluster)).To(Succeed())
+		g.Expect(recorder.Events).NotTo(Receive())
+
+		// A new orphan is reported.
+		g.Expect(r.Client.Create(context.Background(), infraObject("another-orphan"))).To(Succeed())
+		g.Expect(r.reconcileOrphanedInfrastructure(context.Background(), cluster)).To(Succeed())
+		g.Expect(recorder.Events).To(Receive(ContainSubstring("another-orphan")))
+
+		// Once the orphans are gone, they're reported again if they come back.
+		g.Expect(r.Client.Delete(context.Background(), infraObject("orphan"))).To(Succeed())
+		g.Expect(r.Client.Delete(context.Background(), infraObject("another-orphan"))).To(Succeed())
+		g.Expect(r.reconcileOrphanedInfrastructure(context.Background(), cluster)).To(Succeed())
+		g.Expect(recorder.Events).NotTo(Receive())
+		g.Expect(r.Client.Create(context.Background(), infraObject("orphan"))).To(Succeed())
+		g.Expect(r.reconcileOrphanedInfrastructure(context.Background(), cluster)).To(Succeed())
+		g.Expect(recorder.Events).To(Receive(ContainSubstring("orphan")))
+	})
+
+	t.Run("reconcile propagated labels", func(t *testing.T) {
+		g := NewWithT(t)
+
+		testScheme := runtime.NewScheme()
+		g.Expect(clusterv1.AddToScheme(testScheme)).To(Succeed())
+
+		clusterLabels := map[string]string{clusterv1.ClusterLabelName: "test-cluster"}
+		cluster := &clusterv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-cluster",
+				Namespace: "test-namespace",
+				Labels: map[string]string{
+					"team":        "platform",
+					"cost-center": "1234",
+				},
+			},
+			Spec: clusterv1.ClusterSpec{
+				PropagatedLabels: []string{"team"},
+			},
+		}
+		machineSet := &clusterv1.MachineSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-ms", Namespace: "test-namespace", Labels: clusterLabels},
+		}
+		machineDeployment := &clusterv1.MachineDeployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-md", Namespace: "test-namespace", Labels: clusterLabels},
+		}
+		otherMachineSet := &clusterv1.MachineSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "other-ms", Namespace: "test-namespace"},
+		}
+
+		r := &ClusterReconciler{
+			Client: fake.NewFakeClientWithScheme(testScheme, cluster, machineSet, machineDeployment, otherMachineSet),
+			Log:    log.Log,
+			scheme: testScheme,
+		}
+		g.Expect(r.reconcilePropagatedLabels(context.Background(), cluster)).To(Succeed())
+
+		gotMS := &clusterv1.MachineSet{}
+		g.Expect(r.Client.Get(context.Background(), client.ObjectKey{Namespace: "test-namespace", Name: "test-ms"}, gotMS)).To(Succeed())
+		g.Expect(gotMS.Labels).To(HaveKeyWithValue("team", "platform"))
+		g.Expect(gotMS.Labels).NotTo(HaveKey("cost-center"))
+
+		gotMD := &clusterv1.MachineDeployment{}
+		g.Expect(r.Client.Get(context.Background(), client.ObjectKey{Namespace: "test-namespace", Name: "test-md"}, gotMD)).To(Succeed())
+		g.Expect(gotMD.Labels).To(HaveKeyWithValue("team", "platform"))
+
+		gotOther := &clusterv1.MachineSet{}
+		g.Expect(r.Client.Get(context.Background(), client.ObjectKey{Namespace: "test-namespace", Name: "other-ms"}, gotOther)).To(Succeed())
+		g.Expect(gotOther.Labels).NotTo(HaveKey("team"))
+
+		// Removing the key from the list doesn't remove the label.
+		cluster.Spec.PropagatedLabels = nil
+		g.Expect(r.reconcilePropagatedLabels(context.Background(), cluster)).To(Succeed())
+		g.Expect(r.Client.Get(context.Background(), client.ObjectKey{Namespace: "test-namespace", Name: "test-ms"}, gotMS)).To(Succeed())
+		g.Expect(gotMS.Labels).To(HaveKeyWithValue("team", "platform"))
+	})
+
 	t.Run("reconcile kubeconfig", func(t *testing.T) {
 		cluster := &clusterv1.Cluster{
 			ObjectMeta: metav1.ObjectMeta{
@@ -369,3 +771,224 @@ func TestClusterReconciler_reconcilePhase(t *testing.T) {
 		})
 	}
 }
+
+func TestSetAdditionalNetworks(t *testing.T) {
+	g := NewWithT(t)
+
+	cluster := &clusterv1.Cluster{
+		Spec: clusterv1.ClusterSpec{
+			ClusterNetwork: &clusterv1.ClusterNetwork{
+				AdditionalNetworks: []clusterv1.ClusterNetworkSpec{
+					{
+						Name: "storage",
+						Pods: &clusterv1.NetworkRanges{CIDRBlocks: []string{"172.16.0.0/16"}},
+					},
+				},
+			},
+		},
+	}
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{}}
+
+	g.Expect(setAdditionalNetworks(cluster, obj)).To(Succeed())
+	networks, found, err := unstructured.NestedSlice(obj.Object, "spec", "additionalNetworks")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(found).To(BeTrue())
+	g.Expect(networks).To(Equal([]interface{}{
+		map[string]interface{}{
+			"name": "storage",
+			"pods": map[string]interface{}{
+				"cidrBlocks": []interface{}{"172.16.0.0/16"},
+			},
+		},
+	}))
+
+}
+
+func TestReconcileAdditionalNetworksWithoutAdditionalNetworks(t *testing.T) {
+	g := NewWithT(t)
+
+	// The field set by the provider is not removed when the Cluster doesn't define any additional network.
+	cluster := &clusterv1.Cluster{}
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"additionalNetworks": []interface{}{
+				map[string]interface{}{"name": "provider-managed"},
+			},
+		},
+	}}
+	expected := obj.DeepCopy()
+
+	r := &ClusterReconciler{}
+	g.Expect(r.reconcileAdditionalNetworks(context.Background(), cluster, obj)).To(Succeed())
+	g.Expect(obj).To(Equal(expected))
+}
+
+func TestClusterEventReasons(t *testing.T) {
+	controlPlaneCRD := external.TestGenericInfrastructureCRD.DeepCopy()
+	controlPlaneCRD.Name = "genericcontrolplanes.controlplane.cluster.x-k8s.io"
+	controlPlaneCRD.Spec.Group = "controlplane.cluster.x-k8s.io"
+	controlPlaneCRD.Spec.Names = apiextensionsv1.CustomResourceDefinitionNames{
+		Kind:   "GenericControlPlane",
+		Plural: "genericcontrolplanes",
+	}
+
+	newCluster := func() *clusterv1.Cluster {
+		return &clusterv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-cluster",
+				Namespace: "test-namespace",
+			},
+			Spec: clusterv1.ClusterSpec{
+				ControlPlaneEndpoint: clusterv1.APIEndpoint{Host: "1.2.3.4", Port: 8443},
+				InfrastructureRef: &corev1.ObjectReference{
+					APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+					Kind:       "InfrastructureMachine",
+					Name:       "test",
+				},
+				ControlPlaneRef: &corev1.ObjectReference{
+					APIVersion: "controlplane.cluster.x-k8s.io/v1alpha3",
+					Kind:       "GenericControlPlane",
+					Name:       "test",
+				},
+			},
+		}
+	}
+	newExternal := func(apiVersion, kind string, status map[string]interface{}) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"kind":       kind,
+			"apiVersion": apiVersion,
+			"metadata": map[string]interface{}{
+				"name":      "test",
+				"namespace": "test-namespace",
+			},
+			"spec":   map[string]interface{}{},
+			"status": status,
+		}}
+	}
+
+	tests := []struct {
+		name          string
+		cluster       func() *clusterv1.Cluster
+		objs          []runtime.Object
+		reconcile     func(r *ClusterReconciler, cluster *clusterv1.Cluster) error
+		expectReasons []string
+	}{
+		{
+			name:    "infrastructure becomes ready",
+			cluster: newCluster,
+			objs: []runtime.Object{
+				newExternal("infrastructure.cluster.x-k8s.io/v1alpha3", "InfrastructureMachine", map[string]interface{}{"ready": true}),
+			},
+			reconcile: func(r *ClusterReconciler, cluster *clusterv1.Cluster) error {
+				return r.reconcileInfrastructure(context.Background(), cluster)
+			},
+			expectReasons: []string{ClusterEventReasonInfrastructureReady},
+		},
+		{
+			name: "infrastructure is no longer ready",
+			cluster: func() *clusterv1.Cluster {
+				cluster := newCluster()
+				cluster.Status.InfrastructureReady = true
+				return cluster
+			},
+			objs: []runtime.Object{
+				newExternal("infrastructure.cluster.x-k8s.io/v1alpha3", "InfrastructureMachine", map[string]interface{}{"ready": false}),
+			},
+			reconcile: func(r *ClusterReconciler, cluster *clusterv1.Cluster) error {
+				return r.reconcileInfrastructure(context.Background(), cluster)
+			},
+			expectReasons: []string{ClusterEventReasonInfrastructureNotReady},
+		},
+		{
+			name: "infrastructure stays ready",
+			cluster: func() *clusterv1.Cluster {
+				cluster := newCluster()
+				cluster.Status.InfrastructureReady = true
+				return cluster
+			},
+			objs: []runtime.Object{
+				newExternal("infrastructure.cluster.x-k8s.io/v1alpha3", "InfrastructureMachine", map[string]interface{}{"ready": true}),
+			},
+			reconcile: func(r *ClusterReconciler, cluster *clusterv1.Cluster) error {
+				return r.reconcileInfrastructure(context.Background(), cluster)
+			},
+		},
+		{
+			name:    "control plane becomes initialized and ready",
+			cluster: newCluster,
+			objs: []runtime.Object{
+				newExternal("controlplane.cluster.x-k8s.io/v1alpha3", "GenericControlPlane", map[string]interface{}{"initialized": true, "ready": true}),
+			},
+			reconcile: func(r *ClusterReconciler, cluster *clusterv1.Cluster) error {
+				return r.reconcileControlPlane(context.Background(), cluster)
+			},
+			expectReasons: []string{ClusterEventReasonControlPlaneInitialized, ClusterEventReasonControlPlaneReady},
+		},
+		{
+			name: "control plane machine gets a Node",
+			cluster: func() *clusterv1.Cluster {
+				cluster := newCluster()
+				cluster.Spec.ControlPlaneRef = nil
+				return cluster
+			},
+			objs: []runtime.Object{
+				&clusterv1.Machine{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "control-plane",
+						Namespace: "test-namespace",
+						Labels: map[string]string{
+							clusterv1.ClusterLabelName:             "test-cluster",
+							clusterv1.MachineControlPlaneLabelName: "",
+						},
+					},
+					Status: clusterv1.MachineStatus{NodeRef: &corev1.ObjectReference{Kind: "Node", Name: "node-1"}},
+				},
+			},
+			reconcile: func(r *ClusterReconciler, cluster *clusterv1.Cluster) error {
+				return r.reconcileControlPlaneInitialized(context.Background(), cluster)
+			},
+			expectReasons: []string{ClusterEventReasonControlPlaneInitialized},
+		},
+		{
+			name: "cluster without descendants is deleted",
+			cluster: func() *clusterv1.Cluster {
+				cluster := newCluster()
+				cluster.Spec.InfrastructureRef = nil
+				cluster.Spec.ControlPlaneRef = nil
+				return cluster
+			},
+			reconcile: func(r *ClusterReconciler, cluster *clusterv1.Cluster) error {
+				_, err := r.reconcileDelete(context.Background(), cluster)
+				return err
+			},
+			expectReasons: []string{ClusterEventReasonDeletionStarted, ClusterEventReasonDeleted},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(clusterv1.AddToScheme(scheme.Scheme)).To(Succeed())
+			g.Expect(apiextensionsv1.AddToScheme(scheme.Scheme)).To(Succeed())
+
+			cluster := tt.cluster()
+			objs := append([]runtime.Object{external.TestGenericInfrastructureCRD, controlPlaneCRD, cluster}, tt.objs...)
+			recorder := record.NewFakeRecorder(10)
+			r := &ClusterReconciler{
+				Client:   fake.NewFakeClientWithScheme(scheme.Scheme, objs...),
+				Log:      log.Log,
+				scheme:   scheme.Scheme,
+				recorder: recorder,
+			}
+
+			g.Expect(tt.reconcile(r, cluster)).To(Succeed())
+
+			for _, reason := range tt.expectReasons {
+				var event string
+				g.Expect(recorder.Events).To(Receive(&event))
+				g.Expect(event).To(ContainSubstring(" " + reason + " "))
+			}
+			g.Expect(recorder.Events).NotTo(Receive())
+		})
+	}
+}
@@ -24,22 +24,31 @@ import (
 	. "github.com/onsi/gomega"
 
 	"github.com/gogo/protobuf/proto"
+	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/utils/pointer"
 	"sigs.k8s.io/cluster-api/util"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
 	capierrors "sigs.k8s.io/cluster-api/errors"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/kubeconfig"
 	"sigs.k8s.io/cluster-api/util/patch"
 )
@@ -798,3 +807,302 @@ func TestReconcileControlPlaneInitializedControlPlaneRef(t *testing.T) {
 	g.Expect(r.reconcileControlPlaneInitialized(context.Background(), c)).To(Succeed())
 	g.Expect(c.Status.ControlPlaneInitialized).To(BeFalse())
 }
+
+func TestClusterReconcilerReconcileControlPlaneQuorum(t *testing.T) {
+	g := NewWithT(t)
+
+	cluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-cluster",
+			Namespace: "default",
+		},
+	}
+	controlPlaneMachine := func(name string, failed, deleting bool) *clusterv1.Machine {
+		m := &clusterv1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Labels: map[string]string{
+					clusterv1.ClusterLabelName:             cluster.Name,
+					clusterv1.MachineControlPlaneLabelName: "",
+				},
+			},
+			Spec: clusterv1.MachineSpec{ClusterName: cluster.Name},
+		}
+		if failed {
+			m.Status.FailureMessage = pointer.StringPtr("failed")
+		}
+		if deleting {
+			now := metav1.Now()
+			m.DeletionTimestamp = &now
+		}
+		return m
+	}
+
+	healthy1 := controlPlaneMachine("healthy-1", false, false)
+	healthy2 := controlPlaneMachine("healthy-2", false, false)
+	failed := controlPlaneMachine("failed", true, false)
+	deleting := controlPlaneMachine("deleting", false, true)
+
+	recorder := record.NewFakeRecorder(10)
+	r := &ClusterReconciler{
+		Client:   fake.NewFakeClientWithScheme(scheme.Scheme, cluster, healthy1, healthy2, failed),
+		Log:      log.Log,
+		recorder: recorder,
+	}
+
+	// Two of the three Machines are healthy.
+	g.Expect(r.reconcileControlPlaneQuorum(context.Background(), cluster)).To(Succeed())
+	g.Expect(conditions.Has(cluster.Status.Conditions, clusterv1.ControlPlaneQuorumLostCondition)).To(BeFalse())
+	g.Expect(recorder.Events).To(BeEmpty())
+
+	// A Machine being deleted counts as lost, the event is only emitted when the quorum is lost, not on every reconcile.
+	g.Expect(r.Client.Create(context.Background(), deleting)).To(Succeed())
+	g.Expect(r.reconcileControlPlaneQuorum(context.Background(), cluster)).To(Succeed())
+	g.Expect(r.reconcileControlPlaneQuorum(context.Background(), cluster)).To(Succeed())
+	g.Expect(conditions.IsTrue(cluster.Status.Conditions, clusterv1.ControlPlaneQuorumLostCondition)).To(BeTrue())
+	g.Expect(recorder.Events).To(HaveLen(1))
+	g.Expect(recorder.Events).To(Receive(ContainSubstring(ClusterEventReasonControlPlaneQuorumLost)))
+
+	// The condition is removed once the quorum is recovered.
+	g.Expect(r.Client.Delete(context.Background(), deleting)).To(Succeed())
+	g.Expect(r.reconcileControlPlaneQuorum(context.Background(), cluster)).To(Succeed())
+	g.Expect(conditions.Has(cluster.Status.Conditions, clusterv1.ControlPlaneQuorumLostCondition)).To(BeFalse())
+}
+
+func TestClusterReconcilerControlPlaneQuorumMachineToCluster(t *testing.T) {
+	g := NewWithT(t)
+
+	cluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-cluster",
+			Namespace: "default",
+		},
+	}
+	controlPlane := &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "control-plane",
+			Namespace: "default",
+			Labels: map[string]string{
+				clusterv1.ClusterLabelName:             cluster.Name,
+				clusterv1.MachineControlPlaneLabelName: "",
+			},
+		},
+		Spec: clusterv1.MachineSpec{ClusterName: cluster.Name},
+	}
+	failedControlPlane := controlPlane.DeepCopy()
+	failedControlPlane.Status.FailureMessage = pointer.StringPtr("failed")
+	deletingControlPlane := controlPlane.DeepCopy()
+	now := metav1.Now()
+	deletingControlPlane.DeletionTimestamp = &now
+	failedWorker := failedControlPlane.DeepCopy()
+	delete(failedWorker.Labels, clusterv1.MachineControlPlaneLabelName)
+
+	r := &ClusterReconciler{
+		Client: fake.NewFakeClientWithScheme(scheme.Scheme, cluster),
+		Log:    log.Log,
+	}
+	expected := []ctrl.Request{{NamespacedName: util.ObjectKey(cluster)}}
+
+	g.Expect(r.controlPlaneQuorumMachineToCluster(handler.MapObject{Object: controlPlane})).To(BeEmpty())
+	g.Expect(r.controlPlaneQuorumMachineToCluster(handler.MapObject{Object: failedWorker})).To(BeEmpty())
+	g.Expect(r.controlPlaneQuorumMachineToCluster(handler.MapObject{Object: failedControlPlane})).To(Equal(expected))
+	g.Expect(r.controlPlaneQuorumMachineToCluster(handler.MapObject{Object: deletingControlPlane})).To(Equal(expected))
+
+	// Once the quorum is lost, any control plane Machine can recover it.
+	conditions.MarkTrue(&cluster.Status.Conditions, clusterv1.ControlPlaneQuorumLostCondition)
+	g.Expect(r.Client.Status().Update(context.Background(), cluster)).To(Succeed())
+	g.Expect(r.controlPlaneQuorumMachineToCluster(handler.MapObject{Object: controlPlane})).To(Equal(expected))
+}
+
+func TestClusterReconcilerControllerOptions(t *testing.T) {
+	g := NewWithT(t)
+
+	r := &ClusterReconciler{ConcurrentReconciles: 10}
+	g.Expect(r.controllerOptions(controller.Options{MaxConcurrentReconciles: 1}).MaxConcurrentReconciles).To(Equal(10))
+
+	r = &ClusterReconciler{}
+	g.Expect(r.controllerOptions(controller.Options{MaxConcurrentReconciles: 1}).MaxConcurrentReconciles).To(Equal(1))
+}
+
+var _ = Describe("Cluster Reconciler with a cache restricted to a list of namespaces", func() {
+	It("Should only read the Clusters in the watched namespaces", func() {
+		// The manager is configured like the one started with --watch-namespace.
+		watched := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{GenerateName: "watched-"}}
+		unwatched := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{GenerateName: "unwatched-"}}
+		Expect(k8sClient.Create(ctx, watched)).To(Succeed())
+		Expect(k8sClient.Create(ctx, unwatched)).To(Succeed())
+
+		namespacedMgr, err := manager.New(cfg, manager.Options{
+			Scheme:             scheme.Scheme,
+			MetricsBindAddress: "0",
+			NewCache:           cache.MultiNamespacedCacheBuilder([]string{watched.Name}),
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		stop := make(chan struct{})
+		defer close(stop)
+		go func() {
+			defer GinkgoRecover()
+			Expect(namespacedMgr.Start(stop)).To(Succeed())
+		}()
+		Expect(namespacedMgr.GetCache().WaitForCacheSync(stop)).To(BeTrue())
+
+		watchedCluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: watched.Name}}
+		unwatchedCluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: unwatched.Name}}
+		Expect(k8sClient.Create(ctx, watchedCluster)).To(Succeed())
+		Expect(k8sClient.Create(ctx, unwatchedCluster)).To(Succeed())
+		defer func() {
+			Expect(k8sClient.Delete(ctx, watchedCluster)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, unwatchedCluster)).To(Succeed())
+		}()
+
+		// Only the Cluster in the watched namespace is read, and thus reconciled, by the controllers
+		// running in the restricted manager.
+		c := namespacedMgr.GetClient()
+		Eventually(func() error {
+			return c.Get(ctx, util.ObjectKey(watchedCluster), &clusterv1.Cluster{})
+		}, timeout).Should(Succeed())
+		Expect(c.Get(ctx, util.ObjectKey(unwatchedCluster), &clusterv1.Cluster{})).NotTo(Succeed())
+
+		clusters := &clusterv1.ClusterList{}
+		Expect(c.List(ctx, clusters)).To(Succeed())
+		for _, cluster := range clusters.Items {
+			Expect(cluster.Namespace).To(Equal(watched.Name))
+		}
+	})
+})
+
+// conflictingClient fails the first patches, up to conflicts, and the status patches following them
+// with a conflict error. If set, onConflict is called before each patch fails.
+type conflictingClient struct {
+	client.Client
+	conflicts  int
+	patches    int
+	onConflict func()
+}
+
+func (c *conflictingClient) Patch(ctx context.Context, obj runtime.Object, patch client.Patch, opts ...client.PatchOption) error {
+	c.patches++
+	if c.patches <= c.conflicts {
+		if c.onConflict != nil {
+			c.onConflict()
+		}
+		return apierrors.NewConflict(clusterv1.GroupVersion.WithResource("clusters").GroupResource(), "test-cluster", errors.New("conflict"))
+	}
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func (c *conflictingClient) Status() client.StatusWriter {
+	return &conflictingStatusWriter{StatusWriter: c.Client.Status(), client: c}
+}
+
+type conflictingStatusWriter struct {
+	client.StatusWriter
+	client *conflictingClient
+}
+
+func (w *conflictingStatusWriter) Patch(ctx context.Context, obj runtime.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if w.client.patches <= w.client.conflicts {
+		return apierrors.NewConflict(clusterv1.GroupVersion.WithResource("clusters").GroupResource(), "test-cluster", errors.New("conflict"))
+	}
+	return w.StatusWriter.Patch(ctx, obj, patch, opts...)
+}
+
+func TestClusterReconcilerStatusUpdateRetryPolicy(t *testing.T) {
+	tests := []struct {
+		name          string
+		policy        *wait.Backoff
+		conflicts     int
+		expectErr     bool
+		expectPatches int
+	}{
+		{
+			name:          "conflicting update is retried with the default policy",
+			conflicts:     1,
+			expectPatches: 2,
+		},
+		{
+			name:          "error is returned once the default policy is exhausted",
+			conflicts:     10,
+			expectErr:     true,
+			expectPatches: 4,
+		},
+		{
+			name:          "conflicting update isn't retried with a single step policy",
+			policy:        &wait.Backoff{Steps: 1},
+			conflicts:     1,
+			expectErr:     true,
+			expectPatches: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			cluster := &clusterv1.Cluster{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+			}
+			c := &conflictingClient{
+				Client:    fake.NewFakeClientWithScheme(scheme.Scheme, cluster),
+				conflicts: tt.conflicts,
+			}
+			r := &ClusterReconciler{
+				Client:                  c,
+				Log:                     log.Log,
+				scheme:                  scheme.Scheme,
+				recorder:                record.NewFakeRecorder(10),
+				StatusUpdateRetryPolicy: tt.policy,
+			}
+
+			_, err := r.Reconcile(reconcile.Request{NamespacedName: util.ObjectKey(cluster)})
+			g.Expect(c.patches).To(Equal(tt.expectPatches))
+			if tt.expectErr {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+
+			got := &clusterv1.Cluster{}
+			g.Expect(c.Get(ctx, util.ObjectKey(cluster), got)).To(Succeed())
+			g.Expect(got.Status.Phase).NotTo(BeEmpty())
+		})
+	}
+}
+
+func TestClusterReconcilerStatusUpdateRetryRebasesChanges(t *testing.T) {
+	g := NewWithT(t)
+
+	cluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+	}
+	c := &conflictingClient{
+		Client:    fake.NewFakeClientWithScheme(scheme.Scheme, cluster),
+		conflicts: 1,
+	}
+	// Another controller updates the Cluster while it is reconciled.
+	c.onConflict = func() {
+		latest := &clusterv1.Cluster{}
+		g.Expect(c.Client.Get(ctx, util.ObjectKey(cluster), latest)).To(Succeed())
+		latest.Annotations = map[string]string{"other": "value"}
+		g.Expect(c.Client.Update(ctx, latest)).To(Succeed())
+	}
+	r := &ClusterReconciler{
+		Client:   c,
+		Log:      log.Log,
+		scheme:   scheme.Scheme,
+		recorder: record.NewFakeRecorder(10),
+	}
+
+	_, err := r.Reconcile(reconcile.Request{NamespacedName: util.ObjectKey(cluster)})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(c.patches).To(Equal(2))
+
+	// Both the changes made by the other controller and by the reconciliation are kept.
+	got := &clusterv1.Cluster{}
+	g.Expect(c.Get(ctx, util.ObjectKey(cluster), got)).To(Succeed())
+	g.Expect(got.Annotations).To(HaveKeyWithValue("other", "value"))
+	g.Expect(got.Finalizers).To(ContainElement(clusterv1.ClusterFinalizer))
+	g.Expect(got.Status.Phase).To(Equal(string(clusterv1.ClusterPhasePending)))
+}
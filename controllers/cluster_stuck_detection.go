@@ -0,0 +1,84 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/controllers/metrics"
+)
+
+// stuckDetectionScanInterval is the interval between two scans of the Clusters looking for stuck ones.
+var stuckDetectionScanInterval = 5 * time.Minute
+
+// runStuckDetection scans the Clusters every stuckDetectionScanInterval until stop is closed.
+// It is run by the manager, on the leader only.
+func (r *ClusterReconciler) runStuckDetection(stop <-chan struct{}) error {
+	wait.Until(func() {
+		if err := r.detectStuckClusters(context.Background()); err != nil {
+			r.Log.Error(err, "Failed to detect stuck Clusters")
+		}
+	}, stuckDetectionScanInterval, stop)
+	return nil
+}
+
+// detectStuckClusters emits a Warning event, and increments the capi_cluster_stuck_total metric, for each Cluster
+// that is not in a terminal phase and has no condition that transitioned in the last StuckDetectionInterval.
+func (r *ClusterReconciler) detectStuckClusters(ctx context.Context) error {
+	clusters := &clusterv1.ClusterList{}
+	if err := r.Client.List(ctx, clusters); err != nil {
+		return errors.Wrap(err, "failed to list Clusters")
+	}
+
+	now := r.clock.Now()
+	for i := range clusters.Items {
+		cluster := &clusters.Items[i]
+		if isTerminalClusterPhase(cluster.Status.GetTypedPhase()) {
+			continue
+		}
+
+		lastTransition := lastClusterTransitionTime(cluster)
+		if stuckFor := now.Sub(lastTransition); stuckFor > r.StuckDetectionInterval {
+			r.recorder.Eventf(cluster, corev1.EventTypeWarning, ClusterEventReasonStuck,
+				"Cluster has been in phase %q without any condition transition for %s", cluster.Status.Phase, stuckFor.Round(time.Second))
+			metrics.ClusterStuckTotal.WithLabelValues(cluster.Name, cluster.Namespace).Inc()
+		}
+	}
+	return nil
+}
+
+// isTerminalClusterPhase returns true if a Cluster in the given phase isn't expected to transition anymore.
+func isTerminalClusterPhase(phase clusterv1.ClusterPhase) bool {
+	return phase == clusterv1.ClusterPhaseProvisioned || phase == clusterv1.ClusterPhaseFailed
+}
+
+// lastClusterTransitionTime returns the most recent LastTransitionTime of the conditions of the Cluster,
+// or its creation time if it has no conditions.
+func lastClusterTransitionTime(cluster *clusterv1.Cluster) time.Time {
+	last := cluster.CreationTimestamp.Time
+	for _, condition := range cluster.Status.Conditions {
+		if condition.LastTransitionTime.After(last) {
+			last = condition.LastTransitionTime.Time
+		}
+	}
+	return last
+}
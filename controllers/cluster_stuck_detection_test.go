@@ -0,0 +1,135 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	dto "github.com/prometheus/client_model/go"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/controllers/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestDetectStuckClusters(t *testing.T) {
+	g := NewWithT(t)
+
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	newCluster := func(name string, phase clusterv1.ClusterPhase, lastTransition time.Duration) *clusterv1.Cluster {
+		cluster := &clusterv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				Namespace:         "stuck-detection",
+				CreationTimestamp: metav1.NewTime(now.Add(-2 * time.Hour)),
+			},
+		}
+		cluster.Status.SetTypedPhase(phase)
+		if lastTransition > 0 {
+			cluster.Status.Conditions = clusterv1.Conditions{
+				{
+					Type:               clusterv1.InfrastructureReadyCondition,
+					Status:             corev1.ConditionFalse,
+					LastTransitionTime: metav1.NewTime(now.Add(-2 * time.Hour)),
+				},
+				{
+					Type:               clusterv1.ClusterHealthyCondition,
+					Status:             corev1.ConditionFalse,
+					LastTransitionTime: metav1.NewTime(now.Add(-lastTransition)),
+				},
+			}
+		}
+		return cluster
+	}
+
+	tests := []struct {
+		cluster     *clusterv1.Cluster
+		expectStuck bool
+	}{
+		{
+			cluster:     newCluster("provisioning-without-transition", clusterv1.ClusterPhaseProvisioning, time.Hour),
+			expectStuck: true,
+		},
+		{
+			cluster:     newCluster("provisioning-with-recent-transition", clusterv1.ClusterPhaseProvisioning, 10*time.Minute),
+			expectStuck: false,
+		},
+		{
+			cluster:     newCluster("pending-without-conditions", clusterv1.ClusterPhasePending, 0),
+			expectStuck: true,
+		},
+		{
+			cluster:     newCluster("deleting-without-transition", clusterv1.ClusterPhaseDeleting, time.Hour),
+			expectStuck: true,
+		},
+		{
+			cluster:     newCluster("provisioned", clusterv1.ClusterPhaseProvisioned, time.Hour),
+			expectStuck: false,
+		},
+		{
+			cluster:     newCluster("failed", clusterv1.ClusterPhaseFailed, time.Hour),
+			expectStuck: false,
+		},
+	}
+
+	objs := []runtime.Object{}
+	for _, tt := range tests {
+		objs = append(objs, tt.cluster)
+	}
+	recorder := record.NewFakeRecorder(10)
+	r := &ClusterReconciler{
+		Client:                 fake.NewFakeClientWithScheme(scheme.Scheme, objs...),
+		Log:                    log.Log,
+		StuckDetectionInterval: 30 * time.Minute,
+		recorder:               recorder,
+		clock:                  clock.NewFakeClock(now),
+	}
+
+	g.Expect(r.detectStuckClusters(context.Background())).To(Succeed())
+
+	// One event is emitted for each stuck Cluster.
+	stuck := 0
+	for _, tt := range tests {
+		if tt.expectStuck {
+			stuck++
+		}
+	}
+	g.Expect(recorder.Events).To(HaveLen(stuck))
+	for i := 0; i < stuck; i++ {
+		g.Expect(<-recorder.Events).To(HavePrefix("Warning Stuck"))
+	}
+
+	for _, tt := range tests {
+		metric := &dto.Metric{}
+		g.Expect(metrics.ClusterStuckTotal.WithLabelValues(tt.cluster.Name, tt.cluster.Namespace).Write(metric)).To(Succeed())
+		if tt.expectStuck {
+			g.Expect(metric.GetCounter().GetValue()).To(Equal(1.0), "%s should be detected as stuck", tt.cluster.Name)
+		} else {
+			g.Expect(metric.GetCounter().GetValue()).To(BeZero(), "%s should not be detected as stuck", tt.cluster.Name)
+		}
+	}
+}
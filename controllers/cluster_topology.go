@@ -0,0 +1,151 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/controllers/external"
+	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// reconcileTopologyControlPlane sets the number of replicas and the version of the control plane
+// object referenced by the Cluster according to Spec.Topology.ControlPlane.
+func (r *ClusterReconciler) reconcileTopologyControlPlane(ctx context.Context, cluster *clusterv1.Cluster) error {
+	desired := cluster.Spec.Topology.ControlPlane
+	if desired == nil || cluster.Spec.ControlPlaneRef == nil {
+		return nil
+	}
+
+	controlPlane, err := external.Get(ctx, r.Client, cluster.Spec.ControlPlaneRef, cluster.Namespace)
+	if err != nil {
+		return errors.Wrapf(err, "failed to retrieve the control plane of Cluster %q in namespace %q", cluster.Name, cluster.Namespace)
+	}
+	original := controlPlane.DeepCopy()
+
+	if err := unstructured.SetNestedField(controlPlane.Object, int64(desired.MachineCount), "spec", "replicas"); err != nil {
+		return errors.Wrapf(err, "failed to set the replicas of %s %q", controlPlane.GetKind(), controlPlane.GetName())
+	}
+	if desired.Version != "" {
+		if err := unstructured.SetNestedField(controlPlane.Object, desired.Version, "spec", "version"); err != nil {
+			return errors.Wrapf(err, "failed to set the version of %s %q", controlPlane.GetKind(), controlPlane.GetName())
+		}
+	}
+
+	if _, err := util.PatchIfChanged(ctx, r.Client, original, controlPlane); err != nil {
+		return errors.Wrapf(err, "failed to patch %s %q for Cluster %q in namespace %q",
+			controlPlane.GetKind(), controlPlane.GetName(), cluster.Name, cluster.Namespace)
+	}
+	return nil
+}
+
+// reconcileTopologyWorkers creates or updates a MachineSet for each worker group of Spec.Topology,
+// and deletes the MachineSets of the worker groups that were removed from it.
+func (r *ClusterReconciler) reconcileTopologyWorkers(ctx context.Context, cluster *clusterv1.Cluster) error {
+	desired := map[string]bool{}
+	for i := range cluster.Spec.Topology.Workers {
+		worker := &cluster.Spec.Topology.Workers[i]
+		desired[worker.MachineSetName(cluster.Name)] = true
+		if err := r.reconcileTopologyWorker(ctx, cluster, worker); err != nil {
+			return err
+		}
+	}
+
+	machineSets := &clusterv1.MachineSetList{}
+	if err := r.Client.List(ctx, machineSets,
+		client.InNamespace(cluster.Namespace),
+		client.MatchingLabels{clusterv1.ClusterLabelName: cluster.Name},
+		client.HasLabels{clusterv1.TopologyWorkerLabelName},
+	); err != nil {
+		return errors.Wrapf(err, "failed to list the worker MachineSets of Cluster %q in namespace %q", cluster.Name, cluster.Namespace)
+	}
+	for i := range machineSets.Items {
+		ms := &machineSets.Items[i]
+		if desired[ms.Name] || !ms.DeletionTimestamp.IsZero() || !util.PointsTo(ms.OwnerReferences, &cluster.ObjectMeta) {
+			continue
+		}
+		if err := r.Client.Delete(ctx, ms); err != nil && !apierrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to delete MachineSet %q of Cluster %q in namespace %q", ms.Name, cluster.Name, cluster.Namespace)
+		}
+	}
+	return nil
+}
+
+// reconcileTopologyWorker creates the MachineSet of the given worker group, or updates its replicas
+// and Machine template if it already exists.
+func (r *ClusterReconciler) reconcileTopologyWorker(ctx context.Context, cluster *clusterv1.Cluster, worker *clusterv1.TopologyWorkerClass) error {
+	labels := map[string]string{
+		clusterv1.ClusterLabelName:        cluster.Name,
+		clusterv1.TopologyWorkerLabelName: worker.Name,
+	}
+
+	template := *worker.MachineTemplate.DeepCopy()
+	template.Spec.ClusterName = cluster.Name
+	if template.Labels == nil {
+		template.Labels = map[string]string{}
+	}
+	for k, v := range labels {
+		template.Labels[k] = v
+	}
+
+	ms := &clusterv1.MachineSet{}
+	key := client.ObjectKey{Namespace: cluster.Namespace, Name: worker.MachineSetName(cluster.Name)}
+	err := r.Client.Get(ctx, key, ms)
+	switch {
+	case apierrors.IsNotFound(err):
+		ms = &clusterv1.MachineSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      key.Name,
+				Namespace: key.Namespace,
+				Labels:    labels,
+				OwnerReferences: []metav1.OwnerReference{{
+					APIVersion: clusterv1.GroupVersion.String(),
+					Kind:       "Cluster",
+					Name:       cluster.Name,
+					UID:        cluster.UID,
+				}},
+			},
+			Spec: clusterv1.MachineSetSpec{
+				ClusterName: cluster.Name,
+				Replicas:    pointer.Int32Ptr(worker.MachineCount),
+				Selector:    metav1.LabelSelector{MatchLabels: labels},
+				Template:    template,
+			},
+		}
+		if err := r.Client.Create(ctx, ms); err != nil {
+			return errors.Wrapf(err, "failed to create MachineSet %q for Cluster %q in namespace %q", key.Name, cluster.Name, cluster.Namespace)
+		}
+		return nil
+	case err != nil:
+		return errors.Wrapf(err, "failed to retrieve MachineSet %q for Cluster %q in namespace %q", key.Name, cluster.Name, cluster.Namespace)
+	}
+
+	original := ms.DeepCopy()
+	ms.Spec.Replicas = pointer.Int32Ptr(worker.MachineCount)
+	ms.Spec.Template = template
+	if _, err := util.PatchIfChanged(ctx, r.Client, original, ms); err != nil {
+		return errors.Wrapf(err, "failed to patch MachineSet %q for Cluster %q in namespace %q", key.Name, cluster.Name, cluster.Namespace)
+	}
+	return nil
+}
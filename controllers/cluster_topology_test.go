@@ -0,0 +1,199 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/controllers/external"
+	"sigs.k8s.io/cluster-api/feature"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestReconcileTopology(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(clusterv1.AddToScheme(scheme.Scheme)).To(Succeed())
+
+	newCluster := func() *clusterv1.Cluster {
+		return &clusterv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-cluster",
+				Namespace: "test-namespace",
+				UID:       "test-cluster-uid",
+			},
+			Spec: clusterv1.ClusterSpec{
+				ControlPlaneRef: &corev1.ObjectReference{
+					APIVersion: "controlplane.cluster.x-k8s.io/v1alpha3",
+					Kind:       "ControlPlane",
+					Name:       "test-control-plane",
+					Namespace:  "test-namespace",
+				},
+				Topology: &clusterv1.Topology{
+					ControlPlane: &clusterv1.TopologyClass{MachineCount: 3, Version: "v1.17.3"},
+					Workers: []clusterv1.TopologyWorkerClass{{
+						Name:         "small",
+						MachineCount: 2,
+						MachineTemplate: clusterv1.MachineTemplateSpec{
+							Spec: clusterv1.MachineSpec{
+								Version: pointer.StringPtr("v1.17.3"),
+							},
+						},
+					}},
+				},
+			},
+		}
+	}
+	newControlPlane := func() *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"kind":       "ControlPlane",
+			"apiVersion": "controlplane.cluster.x-k8s.io/v1alpha3",
+			"metadata": map[string]interface{}{
+				"name":      "test-control-plane",
+				"namespace": "test-namespace",
+			},
+			"spec": map[string]interface{}{
+				"replicas": int64(1),
+				"version":  "v1.16.3",
+			},
+		}}
+	}
+
+	t.Run("does nothing when the ClusterTopology feature is disabled", func(t *testing.T) {
+		g := NewWithT(t)
+
+		cluster := newCluster()
+		r := &ClusterReconciler{
+			Client: fake.NewFakeClientWithScheme(scheme.Scheme, cluster, newControlPlane()),
+			Log:    log.Log,
+			scheme: scheme.Scheme,
+		}
+		g.Expect(r.reconcileTopology(context.Background(), cluster)).To(Succeed())
+
+		ms := &clusterv1.MachineSet{}
+		err := r.Client.Get(context.Background(), client.ObjectKey{Namespace: "test-namespace", Name: "test-cluster-small"}, ms)
+		g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	t.Run("reconciles the control plane and the workers", func(t *testing.T) {
+		g := NewWithT(t)
+		g.Expect(feature.MutableGates.Set(fmt.Sprintf("%s=true", feature.ClusterTopology))).To(Succeed())
+		defer func() {
+			g.Expect(feature.MutableGates.Set(fmt.Sprintf("%s=false", feature.ClusterTopology))).To(Succeed())
+		}()
+
+		cluster := newCluster()
+		r := &ClusterReconciler{
+			Client: fake.NewFakeClientWithScheme(scheme.Scheme, cluster, newControlPlane()),
+			Log:    log.Log,
+			scheme: scheme.Scheme,
+		}
+		g.Expect(r.reconcileTopology(context.Background(), cluster)).To(Succeed())
+
+		controlPlane, err := external.Get(context.Background(), r.Client, cluster.Spec.ControlPlaneRef, cluster.Namespace)
+		g.Expect(err).NotTo(HaveOccurred())
+		replicas, _, err := unstructured.NestedInt64(controlPlane.Object, "spec", "replicas")
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(replicas).To(Equal(int64(3)))
+		version, _, err := unstructured.NestedString(controlPlane.Object, "spec", "version")
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(version).To(Equal("v1.17.3"))
+
+		key := client.ObjectKey{Namespace: "test-namespace", Name: "test-cluster-small"}
+		ms := &clusterv1.MachineSet{}
+		g.Expect(r.Client.Get(context.Background(), key, ms)).To(Succeed())
+		g.Expect(*ms.Spec.Replicas).To(Equal(int32(2)))
+		g.Expect(ms.Spec.ClusterName).To(Equal("test-cluster"))
+		g.Expect(ms.Spec.Template.Spec.ClusterName).To(Equal("test-cluster"))
+		g.Expect(ms.Spec.Selector.MatchLabels).To(HaveKeyWithValue(clusterv1.TopologyWorkerLabelName, "small"))
+		g.Expect(ms.Spec.Template.Labels).To(HaveKeyWithValue(clusterv1.TopologyWorkerLabelName, "small"))
+		g.Expect(ms.OwnerReferences).To(HaveLen(1))
+		g.Expect(ms.OwnerReferences[0].UID).To(Equal(cluster.UID))
+
+		// Scaling the worker group updates the existing MachineSet.
+		cluster.Spec.Topology.Workers[0].MachineCount = 5
+		g.Expect(r.reconcileTopology(context.Background(), cluster)).To(Succeed())
+		g.Expect(r.Client.Get(context.Background(), key, ms)).To(Succeed())
+		g.Expect(*ms.Spec.Replicas).To(Equal(int32(5)))
+
+		// Removing the worker group deletes its MachineSet.
+		cluster.Spec.Topology.Workers = nil
+		g.Expect(r.reconcileTopology(context.Background(), cluster)).To(Succeed())
+		err = r.Client.Get(context.Background(), key, ms)
+		g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	t.Run("updates the control plane replicas when the topology changes", func(t *testing.T) {
+		g := NewWithT(t)
+		g.Expect(feature.MutableGates.Set(fmt.Sprintf("%s=true", feature.ClusterTopology))).To(Succeed())
+		defer func() {
+			g.Expect(feature.MutableGates.Set(fmt.Sprintf("%s=false", feature.ClusterTopology))).To(Succeed())
+		}()
+
+		cluster := newCluster()
+		r := &ClusterReconciler{
+			Client: fake.NewFakeClientWithScheme(scheme.Scheme, cluster, newControlPlane()),
+			Log:    log.Log,
+			scheme: scheme.Scheme,
+		}
+
+		getReplicas := func() int64 {
+			controlPlane, err := external.Get(context.Background(), r.Client, cluster.Spec.ControlPlaneRef, cluster.Namespace)
+			g.Expect(err).NotTo(HaveOccurred())
+			replicas, _, err := unstructured.NestedInt64(controlPlane.Object, "spec", "replicas")
+			g.Expect(err).NotTo(HaveOccurred())
+			return replicas
+		}
+
+		g.Expect(r.reconcileTopology(context.Background(), cluster)).To(Succeed())
+		g.Expect(getReplicas()).To(Equal(int64(3)))
+
+		cluster.Spec.Topology.ControlPlane.MachineCount = 5
+		g.Expect(r.reconcileTopology(context.Background(), cluster)).To(Succeed())
+		g.Expect(getReplicas()).To(Equal(int64(5)))
+	})
+
+	t.Run("skips the control plane when the Cluster doesn't reference one", func(t *testing.T) {
+		g := NewWithT(t)
+		g.Expect(feature.MutableGates.Set(fmt.Sprintf("%s=true", feature.ClusterTopology))).To(Succeed())
+		defer func() {
+			g.Expect(feature.MutableGates.Set(fmt.Sprintf("%s=false", feature.ClusterTopology))).To(Succeed())
+		}()
+
+		cluster := newCluster()
+		cluster.Spec.ControlPlaneRef = nil
+		cluster.Spec.Topology.Workers = nil
+		r := &ClusterReconciler{
+			Client: fake.NewFakeClientWithScheme(scheme.Scheme, cluster),
+			Log:    log.Log,
+			scheme: scheme.Scheme,
+		}
+		g.Expect(r.reconcileTopology(context.Background(), cluster)).To(Succeed())
+	})
+}
@@ -0,0 +1,420 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/controllers/remote"
+	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/patch"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+const (
+	// clusterHealthCheckInterval is the interval at which the checks are evaluated again;
+	// the workload cluster resources are not watched, so the health of the cluster is polled.
+	clusterHealthCheckInterval = 1 * time.Minute
+
+	// EventClusterUnhealthy is emitted when one or more checks of a ClusterHealthCheck fail.
+	EventClusterUnhealthy string = "ClusterUnhealthy"
+
+	// EventClusterRemediationRequested is emitted when the remediation of an unhealthy Cluster is requested
+	// with the ClusterRemediationRequestedAnnotation.
+	EventClusterRemediationRequested string = "RemediationRequested"
+)
+
+// +kubebuilder:rbac:groups=core,resources=events,verbs=get;list;watch;create;patch
+// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=clusters;clusters/status,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=clusterhealthchecks;clusterhealthchecks/status,verbs=get;list;watch;update;patch
+
+// ClusterHealthCheckReconciler reconciles a ClusterHealthCheck object.
+//
+// On each reconcile the checks of the ClusterHealthCheck are evaluated against the workload
+// cluster, and the result is reported on the Healthy condition of the ClusterHealthCheck.
+// The results of all the ClusterHealthChecks of the target Cluster are merged into its Healthy condition.
+// While that condition is false, the Cluster has the ClusterRemediationRequestedAnnotation; the remediation
+// itself is left to the controllers watching that annotation.
+type ClusterHealthCheckReconciler struct {
+	Client client.Client
+	Log    logr.Logger
+
+	recorder record.EventRecorder
+	scheme   *runtime.Scheme
+}
+
+func (r *ClusterHealthCheckReconciler) SetupWithManager(mgr ctrl.Manager, options controller.Options) error {
+	err := ctrl.NewControllerManagedBy(mgr).
+		For(&clusterv1.ClusterHealthCheck{}).
+		Watches(
+			&source.Kind{Type: &clusterv1.Cluster{}},
+			&handler.EnqueueRequestsFromMapFunc{ToRequests: handler.ToRequestsFunc(r.clusterToClusterHealthCheck)},
+		).
+		WithOptions(options).
+		Complete(r)
+
+	if err != nil {
+		return errors.Wrap(err, "failed setting up with a controller manager")
+	}
+
+	r.recorder = mgr.GetEventRecorderFor("clusterhealthcheck-controller")
+	r.scheme = mgr.GetScheme()
+	return nil
+}
+
+func (r *ClusterHealthCheckReconciler) Reconcile(req ctrl.Request) (_ ctrl.Result, reterr error) {
+	ctx := context.Background()
+	logger := r.Log.WithValues("clusterhealthcheck", req.Name, "namespace", req.Namespace)
+
+	// Fetch the ClusterHealthCheck instance
+	c := &clusterv1.ClusterHealthCheck{}
+	if err := r.Client.Get(ctx, req.NamespacedName, c); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+
+		// Error reading the object - requeue the request.
+		logger.Error(err, "Failed to fetch ClusterHealthCheck")
+		return ctrl.Result{}, err
+	}
+
+	cluster, err := util.GetClusterByName(ctx, r.Client, c.Namespace, c.Spec.ClusterName)
+	if apierrors.IsNotFound(err) && !c.DeletionTimestamp.IsZero() {
+		// There is no condition to clean up once the Cluster is gone.
+		return ctrl.Result{}, r.removeFinalizer(ctx, c)
+	}
+	if err != nil {
+		logger.Error(err, "Failed to fetch Cluster for ClusterHealthCheck")
+		return ctrl.Result{}, errors.Wrapf(err, "failed to get Cluster %q for ClusterHealthCheck %q in namespace %q",
+			c.Spec.ClusterName, c.Name, c.Namespace)
+	}
+
+	// Return early if the object or Cluster is paused.
+	if util.IsPaused(cluster, c) {
+		logger.Info("Reconciliation is paused for this object")
+		return ctrl.Result{}, nil
+	}
+
+	// Initialize the patch helpers
+	patchHelper, err := patch.NewHelper(c, r.Client)
+	if err != nil {
+		logger.Error(err, "Failed to build patch helper")
+		return ctrl.Result{}, err
+	}
+	clusterPatchHelper, err := patch.NewHelper(cluster, r.Client)
+	if err != nil {
+		logger.Error(err, "Failed to build patch helper")
+		return ctrl.Result{}, err
+	}
+
+	defer func() {
+		// Always attempt to patch the objects and status after each reconciliation.
+		if err := patchHelper.Patch(ctx, c); err != nil {
+			reterr = kerrors.NewAggregate([]error{reterr, err})
+		}
+		if err := clusterPatchHelper.Patch(ctx, cluster); err != nil {
+			reterr = kerrors.NewAggregate([]error{reterr, err})
+		}
+	}()
+
+	// Handle deletion reconciliation loop.
+	if !c.DeletionTimestamp.IsZero() {
+		if err := r.reconcileClusterHealthyCondition(ctx, cluster, c); err != nil {
+			return ctrl.Result{}, err
+		}
+		controllerutil.RemoveFinalizer(c, clusterv1.ClusterHealthCheckFinalizer)
+		return ctrl.Result{}, nil
+	}
+
+	// Reconcile labels.
+	if c.Labels == nil {
+		c.Labels = make(map[string]string)
+	}
+	c.Labels[clusterv1.ClusterLabelName] = c.Spec.ClusterName
+
+	// Make sure the result of the ClusterHealthCheck is removed from the Cluster when it is deleted.
+	controllerutil.AddFinalizer(c, clusterv1.ClusterHealthCheckFinalizer)
+
+	result, err := r.reconcile(ctx, cluster, c)
+	if err != nil {
+		logger.Error(err, "Failed to reconcile ClusterHealthCheck")
+		r.recorder.Eventf(c, corev1.EventTypeWarning, "ReconcileError", "%v", err)
+		return ctrl.Result{}, err
+	}
+
+	return result, nil
+}
+
+func (r *ClusterHealthCheckReconciler) reconcile(ctx context.Context, cluster *clusterv1.Cluster, c *clusterv1.ClusterHealthCheck) (ctrl.Result, error) {
+	// Ensure the ClusterHealthCheck is owned by the Cluster it belongs to
+	c.OwnerReferences = util.EnsureOwnerRef(c.OwnerReferences, metav1.OwnerReference{
+		APIVersion: clusterv1.GroupVersion.String(),
+		Kind:       "Cluster",
+		Name:       cluster.Name,
+		UID:        cluster.UID,
+	})
+
+	logger := r.Log.WithValues("clusterhealthcheck", c.Name, "namespace", c.Namespace, "cluster", cluster.Name)
+
+	// The workload cluster cannot be reached before the control plane is initialized.
+	if !cluster.Status.ControlPlaneInitialized {
+		logger.V(3).Info("Waiting for the control plane to be initialized")
+		return ctrl.Result{}, nil
+	}
+
+	// Create client for target cluster
+	clusterClient, err := remote.NewClusterClient(ctx, r.Client, util.ObjectKey(cluster), r.scheme)
+	if err != nil {
+		logger.Error(err, "Error building target cluster client")
+		return ctrl.Result{}, err
+	}
+
+	failed, err := evaluateClusterHealthChecks(ctx, clusterClient, c.Spec.Checks)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if len(failed) > 0 {
+		logger.V(3).Info("Cluster is unhealthy", "failed checks", failed)
+	}
+	r.setHealthyCondition(cluster, c, failed)
+
+	if err := r.reconcileClusterHealthyCondition(ctx, cluster, c); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: clusterHealthCheckInterval}, nil
+}
+
+// setHealthyCondition sets the Healthy condition of the ClusterHealthCheck from the descriptions of its failed checks.
+// A warning event is emitted on the Cluster when the ClusterHealthCheck starts failing.
+func (r *ClusterHealthCheckReconciler) setHealthyCondition(cluster *clusterv1.Cluster, c *clusterv1.ClusterHealthCheck, failed []string) {
+	if len(failed) == 0 {
+		conditions.MarkTrue(&c.Status.Conditions, clusterv1.ClusterHealthyCondition)
+		return
+	}
+
+	msg := fmt.Sprintf("ClusterHealthCheck %s failed: %s", c.Name, strings.Join(failed, "; "))
+	if !conditions.IsFalse(c.Status.Conditions, clusterv1.ClusterHealthyCondition) {
+		r.recorder.Event(cluster, corev1.EventTypeWarning, EventClusterUnhealthy, msg)
+	}
+	conditions.MarkFalse(&c.Status.Conditions, clusterv1.ClusterHealthyCondition, clusterv1.ClusterHealthCheckFailedReason, clusterv1.ConditionSeverityWarning, "%s", msg)
+}
+
+// reconcileClusterHealthyCondition sets the Healthy condition of the Cluster from the Healthy conditions of all
+// its ClusterHealthChecks, taking the current one from memory: the Cluster is healthy if all of them pass.
+// The condition is removed from the Cluster if none of its ClusterHealthChecks has been evaluated,
+// ClusterHealthChecks being deleted being ignored.
+func (r *ClusterHealthCheckReconciler) reconcileClusterHealthyCondition(ctx context.Context, cluster *clusterv1.Cluster, current *clusterv1.ClusterHealthCheck) error {
+	chcList := &clusterv1.ClusterHealthCheckList{}
+	if err := r.Client.List(ctx, chcList, client.InNamespace(cluster.Namespace)); err != nil {
+		return errors.Wrapf(err, "failed to list the ClusterHealthChecks of Cluster %q in namespace %q", cluster.Name, cluster.Namespace)
+	}
+
+	chcs := []*clusterv1.ClusterHealthCheck{current}
+	for i := range chcList.Items {
+		if chcList.Items[i].Spec.ClusterName == cluster.Name && chcList.Items[i].Name != current.Name {
+			chcs = append(chcs, &chcList.Items[i])
+		}
+	}
+
+	evaluated := 0
+	var failed []string
+	for _, chc := range chcs {
+		if !chc.DeletionTimestamp.IsZero() {
+			continue
+		}
+		condition := conditions.Get(chc.Status.Conditions, clusterv1.ClusterHealthyCondition)
+		if condition == nil {
+			continue
+		}
+		evaluated++
+		if condition.Status == corev1.ConditionFalse {
+			failed = append(failed, condition.Message)
+		}
+	}
+	// Keep the message stable whatever the order the ClusterHealthChecks are listed in.
+	sort.Strings(failed)
+
+	switch {
+	case evaluated == 0:
+		conditions.Delete(&cluster.Status.Conditions, clusterv1.ClusterHealthyCondition)
+	case len(failed) == 0:
+		conditions.MarkTrue(&cluster.Status.Conditions, clusterv1.ClusterHealthyCondition)
+	default:
+		conditions.MarkFalse(&cluster.Status.Conditions, clusterv1.ClusterHealthyCondition, clusterv1.ClusterHealthCheckFailedReason, clusterv1.ConditionSeverityWarning,
+			"%s", strings.Join(failed, "; "))
+	}
+	r.reconcileRemediationRequest(cluster, failed)
+	return nil
+}
+
+// reconcileRemediationRequest sets the ClusterRemediationRequestedAnnotation on the Cluster when it becomes unhealthy,
+// emitting an event, and removes it once none of its ClusterHealthChecks fails.
+func (r *ClusterHealthCheckReconciler) reconcileRemediationRequest(cluster *clusterv1.Cluster, failed []string) {
+	_, requested := cluster.Annotations[clusterv1.ClusterRemediationRequestedAnnotation]
+	switch {
+	case len(failed) == 0 && requested:
+		delete(cluster.Annotations, clusterv1.ClusterRemediationRequestedAnnotation)
+	case len(failed) > 0 && !requested:
+		if cluster.Annotations == nil {
+			cluster.Annotations = map[string]string{}
+		}
+		cluster.Annotations[clusterv1.ClusterRemediationRequestedAnnotation] = time.Now().UTC().Format(time.RFC3339)
+		r.recorder.Eventf(cluster, corev1.EventTypeWarning, EventClusterRemediationRequested,
+			"Requested the remediation of the Cluster: %s", strings.Join(failed, "; "))
+	}
+}
+
+// removeFinalizer removes the finalizer of a ClusterHealthCheck whose Cluster doesn't exist anymore.
+func (r *ClusterHealthCheckReconciler) removeFinalizer(ctx context.Context, c *clusterv1.ClusterHealthCheck) error {
+	patchHelper, err := patch.NewHelper(c, r.Client)
+	if err != nil {
+		return err
+	}
+	controllerutil.RemoveFinalizer(c, clusterv1.ClusterHealthCheckFinalizer)
+	return patchHelper.Patch(ctx, c)
+}
+
+// evaluateClusterHealthChecks evaluates the given checks against the workload cluster
+// and returns a description of each check that did not pass.
+func evaluateClusterHealthChecks(ctx context.Context, c client.Client, checks []clusterv1.ClusterHealthCondition) ([]string, error) {
+	failed := []string{}
+	for _, check := range checks {
+		total, ready, err := countReadyResources(ctx, c, check)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to evaluate check %q", check.Name)
+		}
+
+		minReady := intstr.FromString("100%")
+		if check.MinReady != nil {
+			minReady = *check.MinReady
+		}
+		required, err := intstr.GetValueFromIntOrPercent(&minReady, total, true)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid minReady for check %q", check.Name)
+		}
+
+		if total == 0 || ready < required {
+			failed = append(failed, fmt.Sprintf("%s: %d of %d %ss ready, %d required", check.Name, ready, total, check.Kind, required))
+		}
+	}
+	return failed, nil
+}
+
+// countReadyResources returns the number of resources selected by the check and how many of them are ready.
+func countReadyResources(ctx context.Context, c client.Client, check clusterv1.ClusterHealthCondition) (int, int, error) {
+	selector, err := metav1.LabelSelectorAsSelector(&check.Selector)
+	if err != nil {
+		return 0, 0, errors.Wrapf(err, "failed to build selector")
+	}
+
+	switch check.Kind {
+	case clusterv1.ClusterHealthCheckKindNode:
+		nodes := &corev1.NodeList{}
+		if err := c.List(ctx, nodes, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+			return 0, 0, errors.Wrapf(err, "failed to list Nodes")
+		}
+		ready := 0
+		for i := range nodes.Items {
+			if nodeIsReady(&nodes.Items[i]) {
+				ready++
+			}
+		}
+		return len(nodes.Items), ready, nil
+	case clusterv1.ClusterHealthCheckKindPod:
+		pods := &corev1.PodList{}
+		if err := c.List(ctx, pods, client.InNamespace(check.Namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+			return 0, 0, errors.Wrapf(err, "failed to list Pods")
+		}
+		ready := 0
+		for i := range pods.Items {
+			if podIsReady(&pods.Items[i]) {
+				ready++
+			}
+		}
+		return len(pods.Items), ready, nil
+	default:
+		return 0, 0, errors.Errorf("unsupported kind %q", check.Kind)
+	}
+}
+
+func nodeIsReady(node *corev1.Node) bool {
+	for _, c := range node.Status.Conditions {
+		if c.Type == corev1.NodeReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+func podIsReady(pod *corev1.Pod) bool {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// clusterToClusterHealthCheck maps events from Cluster objects to
+// ClusterHealthCheck objects that belong to the Cluster
+func (r *ClusterHealthCheckReconciler) clusterToClusterHealthCheck(o handler.MapObject) []reconcile.Request {
+	c, ok := o.Object.(*clusterv1.Cluster)
+	if !ok {
+		r.Log.Error(errors.New("incorrect type"), "expected a Cluster", "type", fmt.Sprintf("%T", o))
+		return nil
+	}
+
+	chcList := &clusterv1.ClusterHealthCheckList{}
+	if err := r.Client.List(context.TODO(), chcList, client.InNamespace(c.Namespace)); err != nil {
+		r.Log.Error(err, "Unable to list ClusterHealthChecks", "cluster", c.Name, "namespace", c.Namespace)
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+	for _, chc := range chcList.Items {
+		if chc.Spec.ClusterName != c.Name {
+			continue
+		}
+		key := types.NamespacedName{Namespace: chc.Namespace, Name: chc.Name}
+		requests = append(requests, reconcile.Request{NamespacedName: key})
+	}
+	return requests
+}
@@ -0,0 +1,352 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestEvaluateClusterHealthChecks(t *testing.T) {
+	newPod := func(name string, labels map[string]string, ready corev1.ConditionStatus) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: metav1.NamespaceSystem, Labels: labels},
+			Status: corev1.PodStatus{
+				Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: ready}},
+			},
+		}
+	}
+	newNode := func(name string, ready corev1.ConditionStatus) *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"node-role.kubernetes.io/master": ""}},
+			Status: corev1.NodeStatus{
+				Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: ready}},
+			},
+		}
+	}
+
+	etcdLabels := map[string]string{"component": "etcd"}
+	etcdCheck := clusterv1.ClusterHealthCondition{
+		Name:      "etcd",
+		Kind:      clusterv1.ClusterHealthCheckKindPod,
+		Namespace: metav1.NamespaceSystem,
+		Selector:  metav1.LabelSelector{MatchLabels: etcdLabels},
+	}
+	quorum := intstr.FromString("51%")
+	etcdQuorumCheck := etcdCheck
+	etcdQuorumCheck.MinReady = &quorum
+	controlPlaneNodesCheck := clusterv1.ClusterHealthCondition{
+		Name: "control-plane-nodes",
+		Kind: clusterv1.ClusterHealthCheckKindNode,
+		Selector: metav1.LabelSelector{
+			MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: "node-role.kubernetes.io/master", Operator: metav1.LabelSelectorOpExists},
+			},
+		},
+	}
+
+	testCases := []struct {
+		name       string
+		objs       []runtime.Object
+		checks     []clusterv1.ClusterHealthCondition
+		wantFailed int
+	}{
+		{
+			name: "all selected pods are ready",
+			objs: []runtime.Object{
+				newPod("etcd-1", etcdLabels, corev1.ConditionTrue),
+				newPod("etcd-2", etcdLabels, corev1.ConditionTrue),
+				newPod("other", map[string]string{"component": "other"}, corev1.ConditionFalse),
+			},
+			checks:     []clusterv1.ClusterHealthCondition{etcdCheck},
+			wantFailed: 0,
+		},
+		{
+			name: "fails when a pod is not ready and minReady defaults to 100%",
+			objs: []runtime.Object{
+				newPod("etcd-1", etcdLabels, corev1.ConditionTrue),
+				newPod("etcd-2", etcdLabels, corev1.ConditionFalse),
+			},
+			checks:     []clusterv1.ClusterHealthCondition{etcdCheck},
+			wantFailed: 1,
+		},
+		{
+			name: "passes when enough pods are ready to meet minReady",
+			objs: []runtime.Object{
+				newPod("etcd-1", etcdLabels, corev1.ConditionTrue),
+				newPod("etcd-2", etcdLabels, corev1.ConditionTrue),
+				newPod("etcd-3", etcdLabels, corev1.ConditionFalse),
+			},
+			checks:     []clusterv1.ClusterHealthCondition{etcdQuorumCheck},
+			wantFailed: 0,
+		},
+		{
+			name:       "fails when no resource is selected",
+			checks:     []clusterv1.ClusterHealthCondition{etcdCheck},
+			wantFailed: 1,
+		},
+		{
+			name: "reports each failed check",
+			objs: []runtime.Object{
+				newPod("etcd-1", etcdLabels, corev1.ConditionFalse),
+				newNode("node-1", corev1.ConditionTrue),
+				newNode("node-2", corev1.ConditionUnknown),
+			},
+			checks:     []clusterv1.ClusterHealthCondition{etcdCheck, controlPlaneNodesCheck},
+			wantFailed: 2,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			c := fake.NewFakeClientWithScheme(scheme.Scheme, tc.objs...)
+			failed, err := evaluateClusterHealthChecks(context.Background(), c, tc.checks)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(failed).To(HaveLen(tc.wantFailed))
+		})
+	}
+
+	t.Run("returns an error for an invalid minReady", func(t *testing.T) {
+		g := NewWithT(t)
+
+		invalid := intstr.FromString("bad")
+		check := etcdCheck
+		check.MinReady = &invalid
+
+		c := fake.NewFakeClientWithScheme(scheme.Scheme, newPod("etcd-1", etcdLabels, corev1.ConditionTrue))
+		_, err := evaluateClusterHealthChecks(context.Background(), c, []clusterv1.ClusterHealthCondition{check})
+		g.Expect(err).To(HaveOccurred())
+	})
+}
+
+func TestClusterHealthCheckSetHealthyCondition(t *testing.T) {
+	g := NewWithT(t)
+
+	cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "default"}}
+	chc := &clusterv1.ClusterHealthCheck{ObjectMeta: metav1.ObjectMeta{Name: "etcd", Namespace: "default"}}
+	recorder := record.NewFakeRecorder(10)
+	r := &ClusterHealthCheckReconciler{recorder: recorder}
+
+	// The event is only emitted when the ClusterHealthCheck starts failing.
+	r.setHealthyCondition(cluster, chc, []string{"etcd: 1 of 3 Pods ready, 3 required"})
+	r.setHealthyCondition(cluster, chc, []string{"etcd: 2 of 3 Pods ready, 3 required"})
+	g.Expect(conditions.IsFalse(chc.Status.Conditions, clusterv1.ClusterHealthyCondition)).To(BeTrue())
+	g.Expect(conditions.Get(chc.Status.Conditions, clusterv1.ClusterHealthyCondition).Message).To(Equal(
+		"ClusterHealthCheck etcd failed: etcd: 2 of 3 Pods ready, 3 required"))
+	g.Expect(recorder.Events).To(HaveLen(1))
+
+	r.setHealthyCondition(cluster, chc, nil)
+	g.Expect(conditions.IsTrue(chc.Status.Conditions, clusterv1.ClusterHealthyCondition)).To(BeTrue())
+	r.setHealthyCondition(cluster, chc, []string{"etcd: 1 of 3 Pods ready, 3 required"})
+	g.Expect(recorder.Events).To(HaveLen(2))
+}
+
+func TestClusterHealthCheckReconcileClusterHealthyCondition(t *testing.T) {
+	newClusterHealthCheck := func(name, clusterName string, healthy *bool) *clusterv1.ClusterHealthCheck {
+		chc := &clusterv1.ClusterHealthCheck{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       clusterv1.ClusterHealthCheckSpec{ClusterName: clusterName},
+		}
+		switch {
+		case healthy == nil:
+		case *healthy:
+			conditions.MarkTrue(&chc.Status.Conditions, clusterv1.ClusterHealthyCondition)
+		default:
+			conditions.MarkFalse(&chc.Status.Conditions, clusterv1.ClusterHealthyCondition, clusterv1.ClusterHealthCheckFailedReason,
+				clusterv1.ConditionSeverityWarning, "ClusterHealthCheck %s failed", name)
+		}
+		return chc
+	}
+	healthy, unhealthy := pointer.BoolPtr(true), pointer.BoolPtr(false)
+
+	testCases := []struct {
+		name          string
+		current       *clusterv1.ClusterHealthCheck
+		others        []runtime.Object
+		expectNoCond  bool
+		expectStatus  corev1.ConditionStatus
+		expectMessage string
+	}{
+		{
+			name:         "healthy if all the ClusterHealthChecks pass",
+			current:      newClusterHealthCheck("a", "test-cluster", healthy),
+			others:       []runtime.Object{newClusterHealthCheck("b", "test-cluster", healthy)},
+			expectStatus: corev1.ConditionTrue,
+		},
+		{
+			name:    "merges the failed ClusterHealthChecks",
+			current: newClusterHealthCheck("b", "test-cluster", unhealthy),
+			others: []runtime.Object{
+				newClusterHealthCheck("a", "test-cluster", unhealthy),
+				newClusterHealthCheck("c", "test-cluster", healthy),
+			},
+			expectStatus:  corev1.ConditionFalse,
+			expectMessage: "ClusterHealthCheck a failed; ClusterHealthCheck b failed",
+		},
+		{
+			name:    "ignores the ClusterHealthChecks of other Clusters and not evaluated yet",
+			current: newClusterHealthCheck("a", "test-cluster", healthy),
+			others: []runtime.Object{
+				newClusterHealthCheck("b", "other-cluster", unhealthy),
+				newClusterHealthCheck("c", "test-cluster", nil),
+			},
+			expectStatus: corev1.ConditionTrue,
+		},
+		{
+			name: "removes the condition when the last ClusterHealthCheck is deleted",
+			current: func() *clusterv1.ClusterHealthCheck {
+				chc := newClusterHealthCheck("a", "test-cluster", unhealthy)
+				now := metav1.Now()
+				chc.DeletionTimestamp = &now
+				return chc
+			}(),
+			others:       []runtime.Object{newClusterHealthCheck("b", "other-cluster", healthy)},
+			expectNoCond: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "default"}}
+			conditions.MarkFalse(&cluster.Status.Conditions, clusterv1.ClusterHealthyCondition, clusterv1.ClusterHealthCheckFailedReason,
+				clusterv1.ConditionSeverityWarning, "stale")
+			r := &ClusterHealthCheckReconciler{
+				Client:   fake.NewFakeClientWithScheme(scheme.Scheme, append(tc.others, tc.current)...),
+				recorder: record.NewFakeRecorder(10),
+			}
+
+			g.Expect(r.reconcileClusterHealthyCondition(context.Background(), cluster, tc.current)).To(Succeed())
+			if tc.expectStatus == corev1.ConditionFalse {
+				g.Expect(cluster.Annotations).To(HaveKey(clusterv1.ClusterRemediationRequestedAnnotation))
+			} else {
+				g.Expect(cluster.Annotations).NotTo(HaveKey(clusterv1.ClusterRemediationRequestedAnnotation))
+			}
+			c := conditions.Get(cluster.Status.Conditions, clusterv1.ClusterHealthyCondition)
+			if tc.expectNoCond {
+				g.Expect(c).To(BeNil())
+				return
+			}
+			g.Expect(c).NotTo(BeNil())
+			g.Expect(c.Status).To(Equal(tc.expectStatus))
+			g.Expect(c.Message).To(Equal(tc.expectMessage))
+		})
+	}
+}
+
+func TestClusterHealthCheckReconcileRemediationRequest(t *testing.T) {
+	g := NewWithT(t)
+
+	cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "default"}}
+	recorder := record.NewFakeRecorder(10)
+	r := &ClusterHealthCheckReconciler{recorder: recorder}
+
+	// The remediation is requested once when the Cluster becomes unhealthy.
+	r.reconcileRemediationRequest(cluster, []string{"ClusterHealthCheck etcd failed"})
+	g.Expect(cluster.Annotations).To(HaveKey(clusterv1.ClusterRemediationRequestedAnnotation))
+	requestedAt := cluster.Annotations[clusterv1.ClusterRemediationRequestedAnnotation]
+	_, err := time.Parse(time.RFC3339, requestedAt)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(recorder.Events).To(Receive(ContainSubstring(EventClusterRemediationRequested)))
+
+	r.reconcileRemediationRequest(cluster, []string{"ClusterHealthCheck etcd failed", "ClusterHealthCheck nodes failed"})
+	g.Expect(cluster.Annotations).To(HaveKeyWithValue(clusterv1.ClusterRemediationRequestedAnnotation, requestedAt))
+	g.Expect(recorder.Events).NotTo(Receive())
+
+	// The request is withdrawn once the Cluster is healthy again.
+	r.reconcileRemediationRequest(cluster, nil)
+	g.Expect(cluster.Annotations).NotTo(HaveKey(clusterv1.ClusterRemediationRequestedAnnotation))
+	g.Expect(recorder.Events).NotTo(Receive())
+}
+
+func TestClusterHealthCheckReconcileDelete(t *testing.T) {
+	newDeletingClusterHealthCheck := func() *clusterv1.ClusterHealthCheck {
+		now := metav1.Now()
+		chc := &clusterv1.ClusterHealthCheck{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "etcd",
+				Namespace:         "default",
+				DeletionTimestamp: &now,
+				Finalizers:        []string{clusterv1.ClusterHealthCheckFinalizer},
+			},
+			Spec: clusterv1.ClusterHealthCheckSpec{ClusterName: "test-cluster"},
+		}
+		conditions.MarkFalse(&chc.Status.Conditions, clusterv1.ClusterHealthyCondition, clusterv1.ClusterHealthCheckFailedReason,
+			clusterv1.ConditionSeverityWarning, "ClusterHealthCheck etcd failed")
+		return chc
+	}
+	req := ctrl.Request{NamespacedName: client.ObjectKey{Namespace: "default", Name: "etcd"}}
+
+	t.Run("removes the result from the Cluster", func(t *testing.T) {
+		g := NewWithT(t)
+
+		cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "default"}}
+		conditions.MarkFalse(&cluster.Status.Conditions, clusterv1.ClusterHealthyCondition, clusterv1.ClusterHealthCheckFailedReason,
+			clusterv1.ConditionSeverityWarning, "ClusterHealthCheck etcd failed")
+		r := &ClusterHealthCheckReconciler{
+			Client: fake.NewFakeClientWithScheme(scheme.Scheme, cluster, newDeletingClusterHealthCheck()),
+			Log:    log.Log,
+		}
+
+		_, err := r.Reconcile(req)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		updated := &clusterv1.Cluster{}
+		g.Expect(r.Client.Get(context.Background(), util.ObjectKey(cluster), updated)).To(Succeed())
+		g.Expect(conditions.Has(updated.Status.Conditions, clusterv1.ClusterHealthyCondition)).To(BeFalse())
+		chc := &clusterv1.ClusterHealthCheck{}
+		g.Expect(r.Client.Get(context.Background(), req.NamespacedName, chc)).To(Succeed())
+		g.Expect(chc.Finalizers).To(BeEmpty())
+	})
+
+	t.Run("removes the finalizer if the Cluster is gone", func(t *testing.T) {
+		g := NewWithT(t)
+
+		r := &ClusterHealthCheckReconciler{
+			Client: fake.NewFakeClientWithScheme(scheme.Scheme, newDeletingClusterHealthCheck()),
+			Log:    log.Log,
+		}
+
+		_, err := r.Reconcile(req)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		chc := &clusterv1.ClusterHealthCheck{}
+		g.Expect(r.Client.Get(context.Background(), req.NamespacedName, chc)).To(Succeed())
+		g.Expect(chc.Finalizers).To(BeEmpty())
+	})
+}
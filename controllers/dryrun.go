@@ -0,0 +1,144 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// dryRunClient is a client that sends all the writes to the API server with the "dry run" option set to "all",
+// so they are validated without being persisted, and logs the changes that would have been made.
+type dryRunClient struct {
+	client.Client
+	log logr.Logger
+}
+
+// newDryRunClient returns a client wrapping c that doesn't persist any write.
+func newDryRunClient(c client.Client, log logr.Logger) client.Client {
+	return &dryRunClient{Client: c, log: log.WithValues("dryRun", true)}
+}
+
+func (c *dryRunClient) Create(ctx context.Context, obj runtime.Object, opts ...client.CreateOption) error {
+	c.logWrite("create", obj)
+	return c.Client.Create(ctx, obj, append(opts, client.DryRunAll)...)
+}
+
+func (c *dryRunClient) Update(ctx context.Context, obj runtime.Object, opts ...client.UpdateOption) error {
+	c.logWrite("update", obj)
+	return c.Client.Update(ctx, obj, append(opts, client.DryRunAll)...)
+}
+
+func (c *dryRunClient) Patch(ctx context.Context, obj runtime.Object, patch client.Patch, opts ...client.PatchOption) error {
+	c.logPatch("patch", obj, patch)
+	return c.Client.Patch(ctx, obj, patch, append(opts, client.DryRunAll)...)
+}
+
+func (c *dryRunClient) Delete(ctx context.Context, obj runtime.Object, opts ...client.DeleteOption) error {
+	c.logWrite("delete", obj)
+	return c.Client.Delete(ctx, obj, append(opts, client.DryRunAll)...)
+}
+
+func (c *dryRunClient) DeleteAllOf(ctx context.Context, obj runtime.Object, opts ...client.DeleteAllOfOption) error {
+	c.logWrite("delete all of", obj)
+	return c.Client.DeleteAllOf(ctx, obj, append(opts, deleteAllOfDryRunAll{})...)
+}
+
+// deleteAllOfDryRunAll sets the "dry run" option to "all" for DeleteAllOf requests,
+// which client.DryRunAll doesn't apply to.
+type deleteAllOfDryRunAll struct{}
+
+func (deleteAllOfDryRunAll) ApplyToDeleteAllOf(opts *client.DeleteAllOfOptions) {
+	opts.DryRun = []string{metav1.DryRunAll}
+}
+
+func (c *dryRunClient) Status() client.StatusWriter {
+	return &dryRunStatusWriter{StatusWriter: c.Client.Status(), client: c}
+}
+
+func (c *dryRunClient) logWrite(verb string, obj runtime.Object) {
+	c.log.Info("Would "+verb+" object", objectLogValues(obj)...)
+}
+
+func (c *dryRunClient) logPatch(verb string, obj runtime.Object, patch client.Patch) {
+	data, err := patch.Data(obj)
+	if err != nil {
+		c.log.Error(err, "Failed to compute the patch", objectLogValues(obj)...)
+		return
+	}
+	c.log.Info("Would "+verb+" object", append(objectLogValues(obj), "patch", string(data))...)
+}
+
+// dryRunStatusWriter is the StatusWriter of a dryRunClient.
+type dryRunStatusWriter struct {
+	client.StatusWriter
+	client *dryRunClient
+}
+
+func (w *dryRunStatusWriter) Update(ctx context.Context, obj runtime.Object, opts ...client.UpdateOption) error {
+	w.client.logWrite("update status of", obj)
+	return w.StatusWriter.Update(ctx, obj, append(opts, client.DryRunAll)...)
+}
+
+func (w *dryRunStatusWriter) Patch(ctx context.Context, obj runtime.Object, patch client.Patch, opts ...client.PatchOption) error {
+	w.client.logPatch("patch status of", obj, patch)
+	return w.StatusWriter.Patch(ctx, obj, patch, append(opts, client.DryRunAll)...)
+}
+
+// dryRunRecorder is an EventRecorder logging the events instead of emitting them.
+type dryRunRecorder struct {
+	log logr.Logger
+}
+
+var _ record.EventRecorder = &dryRunRecorder{}
+
+// newDryRunRecorder returns an EventRecorder that doesn't emit any event.
+func newDryRunRecorder(log logr.Logger) record.EventRecorder {
+	return &dryRunRecorder{log: log.WithValues("dryRun", true)}
+}
+
+func (r *dryRunRecorder) Event(obj runtime.Object, eventtype, reason, message string) {
+	r.log.Info("Would emit event", append(objectLogValues(obj), "type", eventtype, "reason", reason, "message", message)...)
+}
+
+func (r *dryRunRecorder) Eventf(obj runtime.Object, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.Event(obj, eventtype, reason, fmt.Sprintf(messageFmt, args...))
+}
+
+func (r *dryRunRecorder) PastEventf(obj runtime.Object, _ metav1.Time, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.Eventf(obj, eventtype, reason, messageFmt, args...)
+}
+
+func (r *dryRunRecorder) AnnotatedEventf(obj runtime.Object, _ map[string]string, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.Eventf(obj, eventtype, reason, messageFmt, args...)
+}
+
+// objectLogValues returns the key/value pairs identifying obj in the logs.
+func objectLogValues(obj runtime.Object) []interface{} {
+	values := []interface{}{"kind", obj.GetObjectKind().GroupVersionKind().Kind}
+	if accessor, err := meta.Accessor(obj); err == nil {
+		values = append(values, "namespace", accessor.GetNamespace(), "name", accessor.GetName())
+	}
+	return values
+}
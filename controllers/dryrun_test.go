@@ -0,0 +1,145 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// recordedWrite is a write received by a recordingClient.
+type recordedWrite struct {
+	verb   string
+	dryRun []string
+}
+
+// recordingClient records the writes it receives, together with their "dry run" option.
+type recordingClient struct {
+	client.Client
+	writes []recordedWrite
+}
+
+func (c *recordingClient) Create(ctx context.Context, obj runtime.Object, opts ...client.CreateOption) error {
+	c.writes = append(c.writes, recordedWrite{verb: "create", dryRun: (&client.CreateOptions{}).ApplyOptions(opts).DryRun})
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func (c *recordingClient) Update(ctx context.Context, obj runtime.Object, opts ...client.UpdateOption) error {
+	c.writes = append(c.writes, recordedWrite{verb: "update", dryRun: (&client.UpdateOptions{}).ApplyOptions(opts).DryRun})
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func (c *recordingClient) Patch(ctx context.Context, obj runtime.Object, patch client.Patch, opts ...client.PatchOption) error {
+	c.writes = append(c.writes, recordedWrite{verb: "patch", dryRun: (&client.PatchOptions{}).ApplyOptions(opts).DryRun})
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func (c *recordingClient) Delete(ctx context.Context, obj runtime.Object, opts ...client.DeleteOption) error {
+	c.writes = append(c.writes, recordedWrite{verb: "delete", dryRun: (&client.DeleteOptions{}).ApplyOptions(opts).DryRun})
+	return c.Client.Delete(ctx, obj, opts...)
+}
+
+func (c *recordingClient) DeleteAllOf(ctx context.Context, obj runtime.Object, opts ...client.DeleteAllOfOption) error {
+	c.writes = append(c.writes, recordedWrite{verb: "deleteAllOf", dryRun: (&client.DeleteAllOfOptions{}).ApplyOptions(opts).DryRun})
+	return c.Client.DeleteAllOf(ctx, obj, opts...)
+}
+
+func (c *recordingClient) Status() client.StatusWriter {
+	return &recordingStatusWriter{StatusWriter: c.Client.Status(), client: c}
+}
+
+type recordingStatusWriter struct {
+	client.StatusWriter
+	client *recordingClient
+}
+
+func (w *recordingStatusWriter) Update(ctx context.Context, obj runtime.Object, opts ...client.UpdateOption) error {
+	w.client.writes = append(w.client.writes, recordedWrite{verb: "updateStatus", dryRun: (&client.UpdateOptions{}).ApplyOptions(opts).DryRun})
+	return w.StatusWriter.Update(ctx, obj, opts...)
+}
+
+func (w *recordingStatusWriter) Patch(ctx context.Context, obj runtime.Object, patch client.Patch, opts ...client.PatchOption) error {
+	w.client.writes = append(w.client.writes, recordedWrite{verb: "patchStatus", dryRun: (&client.PatchOptions{}).ApplyOptions(opts).DryRun})
+	return w.StatusWriter.Patch(ctx, obj, patch, opts...)
+}
+
+func TestDryRunClient(t *testing.T) {
+	g := NewWithT(t)
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test"}}
+	recorder := &recordingClient{Client: fake.NewFakeClientWithScheme(scheme.Scheme)}
+	c := newDryRunClient(recorder, log.Log)
+
+	// The fake client doesn't validate dry run writes, only the options they are sent with are checked.
+	_ = c.Create(ctx, cm.DeepCopy())
+	_ = c.Update(ctx, cm.DeepCopy())
+	_ = c.Patch(ctx, cm.DeepCopy(), client.MergeFrom(cm))
+	_ = c.Status().Update(ctx, cm.DeepCopy())
+	_ = c.Status().Patch(ctx, cm.DeepCopy(), client.MergeFrom(cm))
+	_ = c.Delete(ctx, cm.DeepCopy())
+	_ = c.DeleteAllOf(ctx, &corev1.ConfigMap{}, client.InNamespace("default"))
+
+	g.Expect(recorder.writes).To(HaveLen(7))
+	for _, w := range recorder.writes {
+		g.Expect(w.dryRun).To(Equal([]string{metav1.DryRunAll}), "%s is not a dry run", w.verb)
+	}
+
+	// The dry run writes didn't persist the object.
+	g.Expect(recorder.Get(ctx, util.ObjectKey(cm), &corev1.ConfigMap{})).NotTo(Succeed())
+}
+
+func TestClusterReconcilerDryRun(t *testing.T) {
+	g := NewWithT(t)
+
+	cluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+	}
+	recorder := &recordingClient{Client: fake.NewFakeClientWithScheme(scheme.Scheme, cluster)}
+	r := &ClusterReconciler{
+		Client:   newDryRunClient(recorder, log.Log),
+		Log:      log.Log,
+		scheme:   scheme.Scheme,
+		recorder: newDryRunRecorder(log.Log),
+	}
+
+	_, err := r.Reconcile(reconcile.Request{NamespacedName: util.ObjectKey(cluster)})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// The reconciler computed a finalizer and a phase, but the writes were dry runs only.
+	g.Expect(recorder.writes).NotTo(BeEmpty())
+	for _, w := range recorder.writes {
+		g.Expect(w.dryRun).To(Equal([]string{metav1.DryRunAll}), "%s is not a dry run", w.verb)
+	}
+
+	got := &clusterv1.Cluster{}
+	g.Expect(recorder.Get(ctx, util.ObjectKey(cluster), got)).To(Succeed())
+	g.Expect(got.Finalizers).To(BeEmpty())
+	g.Expect(got.Status.Phase).To(BeEmpty())
+}
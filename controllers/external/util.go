@@ -18,6 +18,7 @@ package external
 
 import (
 	"context"
+	"reflect"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -28,6 +29,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/util"
 )
 
 const (
@@ -122,6 +124,10 @@ type GenerateTemplateInput struct {
 	// Labels is an optional map of labels to be added to the object.
 	// +optional
 	Labels map[string]string
+
+	// Name is an optional name for the object, a name is generated from the template name if empty.
+	// +optional
+	Name string
 }
 
 func GenerateTemplate(in *GenerateTemplateInput) (*unstructured.Unstructured, error) {
@@ -138,7 +144,11 @@ func GenerateTemplate(in *GenerateTemplateInput) (*unstructured.Unstructured, er
 	to.SetFinalizers(nil)
 	to.SetUID("")
 	to.SetSelfLink("")
-	to.SetName(names.SimpleNameGenerator.GenerateName(in.Template.GetName() + "-"))
+	if in.Name != "" {
+		to.SetName(in.Name)
+	} else {
+		to.SetName(names.SimpleNameGenerator.GenerateName(in.Template.GetName() + "-"))
+	}
 	to.SetNamespace(in.Namespace)
 
 	// Set labels.
@@ -214,3 +224,34 @@ func IsInitialized(obj *unstructured.Unstructured) (bool, error) {
 	}
 	return initialized && found, nil
 }
+
+// ExtractMachineStatusFromInfrastructure copies well-known fields from the status of an infrastructure object
+// to the Machine: status.providerID is used to set Spec.ProviderID if it's not already set and the Machine
+// is not associated with its Node by name, and status.addresses is copied to Status.Addresses. It returns true if the Machine has been changed.
+func ExtractMachineStatusFromInfrastructure(infra *unstructured.Unstructured, machine *clusterv1.Machine) (bool, error) {
+	changed := false
+
+	providerID, found, err := unstructured.NestedString(infra.Object, "status", "providerID")
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to retrieve status.providerID from %v %q",
+			infra.GroupVersionKind(), infra.GetName())
+	}
+	if found && providerID != "" && machine.Spec.ProviderID == nil && machine.Spec.NodeName == "" {
+		machine.Spec.ProviderID = &providerID
+		changed = true
+	}
+
+	var addresses clusterv1.MachineAddresses
+	err = util.UnstructuredUnmarshalField(infra, &addresses, "status", "addresses")
+	switch {
+	case err == util.ErrUnstructuredFieldNotFound: // no-op
+	case err != nil:
+		return false, errors.Wrapf(err, "failed to retrieve status.addresses from %v %q",
+			infra.GroupVersionKind(), infra.GetName())
+	case !reflect.DeepEqual(machine.Status.Addresses, addresses):
+		machine.Status.Addresses = addresses
+		changed = true
+	}
+
+	return changed, nil
+}
@@ -28,6 +28,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/pointer"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
@@ -301,3 +302,104 @@ func TestCloneTemplateMissingSpecTemplate(t *testing.T) {
 	})
 	g.Expect(err).To(HaveOccurred())
 }
+
+func TestExtractMachineStatusFromInfrastructure(t *testing.T) {
+	addresses := []interface{}{
+		map[string]interface{}{"type": "InternalIP", "address": "10.0.0.1"},
+	}
+
+	testCases := []struct {
+		name              string
+		status            map[string]interface{}
+		machine           *clusterv1.Machine
+		expectChanged     bool
+		expectProviderID  *string
+		expectedAddresses clusterv1.MachineAddresses
+	}{
+		{
+			name:    "no status",
+			machine: &clusterv1.Machine{},
+		},
+		{
+			name: "providerID and addresses are set",
+			status: map[string]interface{}{
+				"providerID": "aws:////id-1",
+				"addresses":  addresses,
+			},
+			machine:           &clusterv1.Machine{},
+			expectChanged:     true,
+			expectProviderID:  pointer.StringPtr("aws:////id-1"),
+			expectedAddresses: clusterv1.MachineAddresses{{Type: clusterv1.MachineInternalIP, Address: "10.0.0.1"}},
+		},
+		{
+			name: "only addresses are set",
+			status: map[string]interface{}{
+				"addresses": addresses,
+			},
+			machine:           &clusterv1.Machine{},
+			expectChanged:     true,
+			expectedAddresses: clusterv1.MachineAddresses{{Type: clusterv1.MachineInternalIP, Address: "10.0.0.1"}},
+		},
+		{
+			name: "providerID does not override the machine providerID",
+			status: map[string]interface{}{
+				"providerID": "aws:////id-2",
+			},
+			machine: &clusterv1.Machine{
+				Spec: clusterv1.MachineSpec{ProviderID: pointer.StringPtr("aws:////id-1")},
+			},
+			expectProviderID: pointer.StringPtr("aws:////id-1"),
+		},
+		{
+			name: "providerID is not set on a machine associated with its node by name",
+			status: map[string]interface{}{
+				"providerID": "aws:////id-1",
+				"addresses":  addresses,
+			},
+			machine: &clusterv1.Machine{
+				Spec: clusterv1.MachineSpec{NodeName: "node-1"},
+			},
+			expectChanged:     true,
+			expectedAddresses: clusterv1.MachineAddresses{{Type: clusterv1.MachineInternalIP, Address: "10.0.0.1"}},
+		},
+		{
+			name: "status already up to date",
+			status: map[string]interface{}{
+				"providerID": "aws:////id-1",
+				"addresses":  addresses,
+			},
+			machine: &clusterv1.Machine{
+				Spec: clusterv1.MachineSpec{ProviderID: pointer.StringPtr("aws:////id-1")},
+				Status: clusterv1.MachineStatus{
+					Addresses: clusterv1.MachineAddresses{{Type: clusterv1.MachineInternalIP, Address: "10.0.0.1"}},
+				},
+			},
+			expectProviderID:  pointer.StringPtr("aws:////id-1"),
+			expectedAddresses: clusterv1.MachineAddresses{{Type: clusterv1.MachineInternalIP, Address: "10.0.0.1"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			infra := &unstructured.Unstructured{Object: map[string]interface{}{
+				"kind":       "InfrastructureMachine",
+				"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
+				"metadata": map[string]interface{}{
+					"name":      "infra-config1",
+					"namespace": "default",
+				},
+			}}
+			if tc.status != nil {
+				infra.Object["status"] = tc.status
+			}
+
+			changed, err := ExtractMachineStatusFromInfrastructure(infra, tc.machine)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(changed).To(Equal(tc.expectChanged))
+			g.Expect(tc.machine.Spec.ProviderID).To(Equal(tc.expectProviderID))
+			g.Expect(tc.machine.Status.Addresses).To(Equal(tc.expectedAddresses))
+		})
+	}
+}
@@ -18,21 +18,33 @@ package controllers
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"hash/fnv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
 	kerrors "k8s.io/apimachinery/pkg/util/errors"
-	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
 	"k8s.io/klog"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
 	"sigs.k8s.io/cluster-api/controllers/external"
@@ -40,14 +52,18 @@ import (
 	"sigs.k8s.io/cluster-api/controllers/noderefutil"
 	"sigs.k8s.io/cluster-api/controllers/remote"
 	capierrors "sigs.k8s.io/cluster-api/errors"
+	"sigs.k8s.io/cluster-api/feature"
 	kubedrain "sigs.k8s.io/cluster-api/third_party/kubernetes-drain"
 	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/patch"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
@@ -58,6 +74,61 @@ var (
 	errClusterIsBeingDeleted = errors.New("cluster is being deleted")
 )
 
+// MachineManagedFields is the field manager used by the machine controller when the status of a Machine
+// is updated with server-side apply.
+const MachineManagedFields = "capi-machine-controller"
+
+const (
+	// defaultNodeDeletionTimeout is used when machine.spec.nodeDeletionTimeout is not set.
+	defaultNodeDeletionTimeout = 10 * time.Minute
+
+	// reProvisionRetryPeriod is how often a Machine waiting for other Machines of its MachineSet
+	// to be re-provisioned is requeued.
+	reProvisionRetryPeriod = 30 * time.Second
+
+	// nodeDeletionRetryPeriod is how often the deletion of the Node of a Machine is retried.
+	nodeDeletionRetryPeriod = 10 * time.Second
+
+	// statusRefreshPeriod is how often status.lastSuccessfulReconcileTime is updated
+	// when nothing else changed in the status of a Machine.
+	statusRefreshPeriod = 5 * time.Minute
+
+	// updatedByOthersRequeuePeriod is how long the reconciliation of a Machine only updated by other
+	// controllers is deferred, so that consecutive updates are reconciled once.
+	updatedByOthersRequeuePeriod = 5 * time.Second
+)
+
+// tracerName is the name of the tracer recording the spans of the Machine controller.
+const tracerName = "sigs.k8s.io/cluster-api/controllers/machine"
+
+// Keys of the attributes of the spans recorded by the Machine controller.
+const (
+	spanAttributeMachine   = "machine.name"
+	spanAttributeNamespace = "machine.namespace"
+	spanAttributePhase     = "machine.phase"
+)
+
+// Keys of the structured log fields used by the Machine controller.
+const (
+	logFieldMachine    = "machine"
+	logFieldNamespace  = "namespace"
+	logFieldCluster    = "cluster"
+	logFieldPhase      = "phase"
+	logFieldNode       = "node"
+	logFieldProviderID = "providerID"
+)
+
+// LogFields returns the key/value pairs identifying a Machine in the logs,
+// to be passed to logr.Logger.WithValues.
+func LogFields(machine *clusterv1.Machine) []interface{} {
+	return []interface{}{
+		logFieldMachine, machine.Name,
+		logFieldNamespace, machine.Namespace,
+		logFieldCluster, machine.Spec.ClusterName,
+		logFieldPhase, machine.Status.Phase,
+	}
+}
+
 // +kubebuilder:rbac:groups=core,resources=events,verbs=get;list;watch;create;patch
 // +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch
 // +kubebuilder:rbac:groups=core,resources=nodes,verbs=get;list;watch;create;update;patch;delete
@@ -70,16 +141,74 @@ type MachineReconciler struct {
 	Client client.Client
 	Log    logr.Logger
 
-	config          *rest.Config
-	scheme          *runtime.Scheme
+	// ConcurrentReconciles is the maximum number of Machines reconciled in parallel.
+	// If set, it overrides MaxConcurrentReconciles in the options passed to SetupWithManager.
+	ConcurrentReconciles int
+
+	// NoDrainTaints is a list of taints, matched on their key and effect; if the Node of a Machine
+	// being deleted carries any of them, the drain step is skipped.
+	NoDrainTaints []corev1.Taint
+
+	// NodeLabelPrefix is the prefix of the Machine labels copied to the Node once the NodeRef is set.
+	// Defaults to DefaultNodeLabelPrefix.
+	NodeLabelPrefix string
+
+	// AllowedCrossNamespaceInfrastructure lists, for each namespace, the other namespaces the Spec.InfrastructureRef
+	// of a Machine in that namespace can point to. Machines referencing any other namespace are failed.
+	AllowedCrossNamespaceInfrastructure clusterv1.CrossNamespaceInfrastructureAllowlist
+
+	// PreProvisionValidationURL is the URL the spec of each new Machine is posted to before the Machine
+	// is provisioned, when the PreProvisionValidation feature gate is enabled, unless the Machine has
+	// a PreProvisionValidationURLAnnotation.
+	PreProvisionValidationURL string
+
+	// AllowedPreProvisionValidationURLs lists the URLs, in addition to PreProvisionValidationURL, the
+	// PreProvisionValidationURLAnnotation of a Machine can be set to. The controller doesn't call any other URL,
+	// so that the users creating Machines can't make it send requests to arbitrary endpoints.
+	AllowedPreProvisionValidationURLs []string
+
+	// LifecycleRecorder, if set, records the lifecycle events of the Machines for audit purposes.
+	LifecycleRecorder *MachineEventRecorder
+
+	// TracerProvider provides the tracer recording a span for each reconciliation and its main steps.
+	// Defaults to the global OpenTelemetry tracer provider, which is a no-op unless otel.SetTracerProvider
+	// is called. The manager built from main.go configures neither: tracing is a hook for programs embedding
+	// the MachineReconciler, which set up the provider and its exporter.
+	TracerProvider trace.TracerProvider
+
+	config *rest.Config
+	scheme *runtime.Scheme
+
+	// apiReader reads the objects the controller doesn't watch, like the bootstrap data secrets, directly
+	// from the API server, so that no informer is started for them; defaults to Client.
+	apiReader client.Reader
+
 	recorder        record.EventRecorder
 	externalTracker external.ObjectTracker
+	clock           clock.Clock
+
+	// crossNamespaceTracker watches the infrastructure objects referenced from another namespace, which
+	// can't be owned by their Machine.
+	crossNamespaceTracker external.ObjectTracker
+
+	// remoteClientGetter returns the client used to look up and delete the Node of a Machine;
+	// defaults to remote.NewClusterClient.
+	remoteClientGetter remote.ClusterClientGetter
+
+	// observedMachines stores, for each Machine, the machineObservation recorded by the last
+	// reconciliation.
+	observedMachines sync.Map
+
+	// machineStatusCache stores, for each Machine, the hash of the status last written by this
+	// controller, without status.lastSuccessfulReconcileTime.
+	machineStatusCache sync.Map
 }
 
 func (r *MachineReconciler) SetupWithManager(mgr ctrl.Manager, options controller.Options) error {
 	controller, err := ctrl.NewControllerManagedBy(mgr).
 		For(&clusterv1.Machine{}).
-		WithOptions(options).
+		WithOptions(r.controllerOptions(options)).
+		WithEventFilter(ignoreLastSuccessfulReconcileTimeUpdates).
 		Build(r)
 
 	if err != nil {
@@ -95,15 +224,63 @@ func (r *MachineReconciler) SetupWithManager(mgr ctrl.Manager, options controlle
 		return err
 	}
 
+	// Add index to Machine for util.GetMachineByProviderID
+	if err := mgr.GetCache().IndexField(&clusterv1.Machine{},
+		util.MachineProviderIDIndex,
+		util.IndexMachineByProviderID,
+	); err != nil {
+		return errors.Wrap(err, "error setting index fields")
+	}
+
 	r.recorder = mgr.GetEventRecorderFor("machine-controller")
 	r.config = mgr.GetConfig()
 	r.scheme = mgr.GetScheme()
+	r.apiReader = mgr.GetAPIReader()
+	r.clock = clock.RealClock{}
 	r.externalTracker = external.ObjectTracker{
 		Controller: controller,
 	}
+	r.crossNamespaceTracker = external.ObjectTracker{
+		Controller: controller,
+	}
 	return nil
 }
 
+// ignoreLastSuccessfulReconcileTimeUpdates filters out the updates of a Machine only changing
+// status.lastSuccessfulReconcileTime, so that recording it doesn't trigger a new reconciliation.
+var ignoreLastSuccessfulReconcileTimeUpdates = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldMachine, ok := e.ObjectOld.(*clusterv1.Machine)
+		if !ok {
+			return true
+		}
+		newMachine, ok := e.ObjectNew.(*clusterv1.Machine)
+		if !ok {
+			return true
+		}
+		// Resyncs don't change the resourceVersion.
+		if oldMachine.ResourceVersion == newMachine.ResourceVersion {
+			return true
+		}
+
+		oldMachine, newMachine = oldMachine.DeepCopy(), newMachine.DeepCopy()
+		for _, m := range []*clusterv1.Machine{oldMachine, newMachine} {
+			m.ResourceVersion = ""
+			m.ManagedFields = nil
+			m.Status.LastSuccessfulReconcileTime = nil
+		}
+		return !equality.Semantic.DeepEqual(oldMachine, newMachine)
+	},
+}
+
+// controllerOptions returns the given options with ConcurrentReconciles applied, if set.
+func (r *MachineReconciler) controllerOptions(options controller.Options) controller.Options {
+	if r.ConcurrentReconciles > 0 {
+		options.MaxConcurrentReconciles = r.ConcurrentReconciles
+	}
+	return options
+}
+
 func (r *MachineReconciler) clusterToActiveMachines(a handler.MapObject) []reconcile.Request {
 	requests := []reconcile.Request{}
 	machines, err := getActiveMachinesInCluster(context.TODO(), r.Client, a.Meta.GetNamespace(), a.Meta.GetName())
@@ -119,9 +296,41 @@ func (r *MachineReconciler) clusterToActiveMachines(a handler.MapObject) []recon
 	return requests
 }
 
+// crossNamespaceInfrastructureToMachines maps an infrastructure object to the Machines referencing it from
+// the other namespaces allowed by AllowedCrossNamespaceInfrastructure.
+func (r *MachineReconciler) crossNamespaceInfrastructureToMachines(o handler.MapObject) []reconcile.Request {
+	requests := []reconcile.Request{}
+	gk := o.Object.GetObjectKind().GroupVersionKind().GroupKind()
+	for namespace := range r.AllowedCrossNamespaceInfrastructure {
+		if namespace == o.Meta.GetNamespace() || !r.AllowedCrossNamespaceInfrastructure.Allows(namespace, o.Meta.GetNamespace()) {
+			continue
+		}
+
+		machines := &clusterv1.MachineList{}
+		if err := r.Client.List(context.TODO(), machines, client.InNamespace(namespace)); err != nil {
+			r.Log.Error(err, "Failed to list Machines referencing an infrastructure object from another namespace",
+				"namespace", namespace, "infrastructure", o.Meta.GetName(), "infrastructureNamespace", o.Meta.GetNamespace())
+			continue
+		}
+		for i := range machines.Items {
+			ref := machines.Items[i].Spec.InfrastructureRef
+			if ref.Namespace == o.Meta.GetNamespace() && ref.Name == o.Meta.GetName() && ref.GroupVersionKind().GroupKind() == gk {
+				requests = append(requests, reconcile.Request{NamespacedName: util.ObjectKey(&machines.Items[i])})
+			}
+		}
+	}
+	return requests
+}
+
 func (r *MachineReconciler) Reconcile(req ctrl.Request) (_ ctrl.Result, reterr error) {
-	ctx := context.Background()
-	logger := r.Log.WithValues("machine", req.Name, "namespace", req.Namespace)
+	ctx, span := r.tracer().Start(context.Background(), "machine.reconcile", trace.WithAttributes(
+		attribute.String(spanAttributeMachine, req.Name),
+		attribute.String(spanAttributeNamespace, req.Namespace),
+	))
+	defer func() {
+		endSpan(span, reterr)
+	}()
+	logger := r.Log.WithValues(logFieldMachine, req.Name, logFieldNamespace, req.Namespace)
 
 	// Fetch the Machine instance
 	m := &clusterv1.Machine{}
@@ -129,6 +338,8 @@ func (r *MachineReconciler) Reconcile(req ctrl.Request) (_ ctrl.Result, reterr e
 		if apierrors.IsNotFound(err) {
 			// Object not found, return.  Created objects are automatically garbage collected.
 			// For additional cleanup logic use finalizers.
+			r.observedMachines.Delete(req.NamespacedName)
+			r.machineStatusCache.Delete(req.NamespacedName)
 			return ctrl.Result{}, nil
 		}
 
@@ -136,6 +347,17 @@ func (r *MachineReconciler) Reconcile(req ctrl.Request) (_ ctrl.Result, reterr e
 		return ctrl.Result{}, err
 	}
 
+	// Defer the reconciliation if the Machine was only updated by another controller since the last
+	// reconciliation, without changing its spec or metadata. The request can have been triggered by a
+	// change of one of its dependencies too, so it is requeued rather than dropped; it is deferred at most
+	// once in a row, so that a Machine updated continuously by other controllers is still reconciled.
+	if r.isUpdatedByOthers(req.NamespacedName, m) {
+		logger.V(4).Info("Deferring reconciliation of a Machine only updated by another controller")
+		r.observeMachine(m, false, true)
+		return ctrl.Result{RequeueAfter: updatedByOthersRequeuePeriod}, nil
+	}
+	original := m.DeepCopy()
+
 	cluster, err := util.GetClusterByName(ctx, r.Client, m.ObjectMeta.Namespace, m.Spec.ClusterName)
 	if err != nil {
 		return ctrl.Result{}, errors.Wrapf(err, "failed to get cluster %q for machine %q in namespace %q",
@@ -154,14 +376,41 @@ func (r *MachineReconciler) Reconcile(req ctrl.Request) (_ ctrl.Result, reterr e
 		return ctrl.Result{}, err
 	}
 
+	// Return early if the Machine is paused, only recording it in the Paused condition.
+	if m.Spec.Paused {
+		logger.Info("Reconciliation is paused for this Machine")
+		conditions.MarkTrue(&m.Status.Conditions, clusterv1.PausedCondition)
+		return ctrl.Result{}, r.patchMachine(ctx, patchHelper, original, m)
+	}
+	conditions.Delete(&m.Status.Conditions, clusterv1.PausedCondition)
+
 	defer func() {
 		r.reconcilePhase(ctx, m)
 		r.reconcileMetrics(ctx, m)
+		span.SetAttributes(attribute.String(spanAttributePhase, m.Status.Phase))
+
+		// Skip the status update if only status.lastSuccessfulReconcileTime would change,
+		// unless it is older than statusRefreshPeriod.
+		if reterr == nil && !r.isStatusCached(req.NamespacedName, m) {
+			// Timestamps are serialized with a precision of one second; truncate it so the Machine
+			// isn't considered modified if the patch is a no-op.
+			now := metav1.NewTime(r.now().Truncate(time.Second))
+			m.Status.LastSuccessfulReconcileTime = &now
+		}
 
 		// Always attempt to patch the object and status after each reconciliation.
-		if err := patchHelper.Patch(ctx, m); err != nil {
+		if err := r.traceStep(ctx, "machine.reconcile.status", m, func(ctx context.Context) error {
+			return r.patchMachine(ctx, patchHelper, original, m)
+		}); err != nil {
+			r.observedMachines.Delete(req.NamespacedName)
 			reterr = kerrors.NewAggregate([]error{reterr, err})
+			return
+		}
+		r.observeMachine(original, !equality.Semantic.DeepEqual(original, m), false)
+		if hash, err := machineStatusHash(m); err == nil {
+			r.machineStatusCache.Store(req.NamespacedName, hash)
 		}
+		r.LifecycleRecorder.RecordTransitions(original, m)
 	}()
 
 	// Reconcile labels.
@@ -179,28 +428,347 @@ func (r *MachineReconciler) Reconcile(req ctrl.Request) (_ ctrl.Result, reterr e
 	return r.reconcile(ctx, cluster, m)
 }
 
+// patchMachine patches the Machine.
+func (r *MachineReconciler) patchMachine(ctx context.Context, patchHelper *patch.Helper, original, m *clusterv1.Machine) error {
+	// The Machine could be gone as soon as its finalizer is removed, so the finalizer is removed last,
+	// with a dedicated patch.
+	obj := m
+	removeFinalizer := hasMachineFinalizer(original) && !hasMachineFinalizer(m)
+	if removeFinalizer {
+		obj = m.DeepCopy()
+		obj.Finalizers = original.Finalizers
+	}
+
+	if feature.Gates.Enabled(feature.MachineStatusServerSideApply) {
+		// Apply the status, the patch helper doesn't use server-side apply.
+		if !equality.Semantic.DeepEqual(original.Status, obj.Status) {
+			if err := r.applyMachineStatus(ctx, original, obj); err != nil {
+				return err
+			}
+		}
+		// Let the patch helper only patch the metadata, the spec and the conditions.
+		withoutStatus := obj.DeepCopy()
+		original.Status.DeepCopyInto(&withoutStatus.Status)
+		withoutStatus.Status.Conditions = obj.Status.Conditions
+		if err := patchHelper.Patch(ctx, withoutStatus); err != nil {
+			return err
+		}
+	} else if err := patchHelper.Patch(ctx, obj); err != nil {
+		return err
+	}
+
+	if removeFinalizer {
+		if err := r.removeMachineFinalizer(ctx, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyMachineStatus updates the status of the Machine, except its conditions, with server-side apply, as the
+// MachineManagedFields field manager. Only the fields already applied by MachineManagedFields, or changed by the
+// controller since the Machine was read, are sent, and the ownership of the fields is not forced: the fields set by
+// other managers are preserved, and changing one of them fails with a conflict.
+func (r *MachineReconciler) applyMachineStatus(ctx context.Context, original, m *clusterv1.Machine) error {
+	obj, err := machineStatusApplyConfiguration(original, m)
+	if err != nil {
+		return err
+	}
+	if err := r.Client.Status().Patch(ctx, obj, client.Apply, client.FieldOwner(MachineManagedFields)); err != nil {
+		return errors.Wrapf(err, "failed to apply the status of Machine %q in namespace %q", m.Name, m.Namespace)
+	}
+	return nil
+}
+
+// machineStatusApplyConfiguration returns the object to apply to update the status of the Machine from original to m.
+// The conditions are not included: they are an atomic list, applying them would conflict with every other writer.
+func machineStatusApplyConfiguration(original, m *clusterv1.Machine) (*unstructured.Unstructured, error) {
+	originalStatus, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&original.Status)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to convert the status of Machine %q in namespace %q", m.Name, m.Namespace)
+	}
+	status, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&m.Status)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to convert the status of Machine %q in namespace %q", m.Name, m.Namespace)
+	}
+	owned, err := machineStatusAppliedFields(original)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read the managed fields of Machine %q in namespace %q", m.Name, m.Namespace)
+	}
+
+	applied := map[string]interface{}{}
+	for field, value := range status {
+		if field == "conditions" {
+			continue
+		}
+		if owned[field] || !equality.Semantic.DeepEqual(originalStatus[field], value) {
+			applied[field] = value
+		}
+	}
+
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{"status": applied}}
+	obj.SetAPIVersion(clusterv1.GroupVersion.String())
+	obj.SetKind("Machine")
+	obj.SetNamespace(m.Namespace)
+	obj.SetName(m.Name)
+	return obj, nil
+}
+
+// machineStatusAppliedFields returns the status fields of the Machine applied by the MachineManagedFields field manager.
+// They must be sent with every apply: a field missing from an apply is removed from the Machine.
+func machineStatusAppliedFields(m *clusterv1.Machine) (map[string]bool, error) {
+	fields := map[string]bool{}
+	for _, entry := range m.ManagedFields {
+		if entry.Manager != MachineManagedFields || entry.Operation != metav1.ManagedFieldsOperationApply || entry.FieldsV1 == nil {
+			continue
+		}
+		managed := struct {
+			Status map[string]json.RawMessage `json:"f:status"`
+		}{}
+		if err := json.Unmarshal(entry.FieldsV1.Raw, &managed); err != nil {
+			return nil, err
+		}
+		for key := range managed.Status {
+			if strings.HasPrefix(key, "f:") {
+				fields[strings.TrimPrefix(key, "f:")] = true
+			}
+		}
+	}
+	return fields, nil
+}
+
+// machineObservation records the Machine read by a reconciliation.
+type machineObservation struct {
+	// resourceVersion is the resourceVersion of the Machine read.
+	resourceVersion string
+	// specHash is the hash of the generation and metadata of the Machine read.
+	specHash uint32
+	// updated is true if the reconciliation updated the Machine; the next resourceVersion can
+	// then be the result of that update.
+	updated bool
+	// deferred is true if the reconciliation was deferred.
+	deferred bool
+}
+
+// observeMachine records the Machine read by a reconciliation, whether the reconciliation updated it,
+// and whether it was deferred.
+func (r *MachineReconciler) observeMachine(m *clusterv1.Machine, updated, deferred bool) {
+	hash, err := machineSpecHash(m)
+	if err != nil || m.ResourceVersion == "" {
+		r.observedMachines.Delete(util.ObjectKey(m))
+		return
+	}
+	r.observedMachines.Store(util.ObjectKey(m), machineObservation{
+		resourceVersion: m.ResourceVersion,
+		specHash:        hash,
+		updated:         updated,
+		deferred:        deferred,
+	})
+}
+
+// isUpdatedByOthers returns true if the Machine changed since the last reconciliation, that reconciliation
+// wasn't deferred and didn't update the Machine, and the generation and metadata of the Machine are unchanged:
+// the Machine was only updated by another controller, and only in its status.
+func (r *MachineReconciler) isUpdatedByOthers(key types.NamespacedName, m *clusterv1.Machine) bool {
+	value, ok := r.observedMachines.Load(key)
+	if !ok {
+		return false
+	}
+	observed := value.(machineObservation)
+	if observed.updated || observed.deferred || observed.resourceVersion == m.ResourceVersion {
+		return false
+	}
+	hash, err := machineSpecHash(m)
+	return err == nil && hash == observed.specHash
+}
+
+// machineSpecHash returns a hash of the generation and metadata of the Machine, without its resourceVersion
+// and managed fields.
+func machineSpecHash(m *clusterv1.Machine) (uint32, error) {
+	meta := m.ObjectMeta.DeepCopy()
+	meta.ResourceVersion = ""
+	meta.ManagedFields = nil
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to serialize the metadata of Machine %q in namespace %q", m.Name, m.Namespace)
+	}
+	hasher := fnv.New32a()
+	_, _ = hasher.Write(data)
+	return hasher.Sum32(), nil
+}
+
+// isStatusCached returns true if the status of the Machine, without status.lastSuccessfulReconcileTime, is the
+// one last written by this controller and status.lastSuccessfulReconcileTime is more recent than statusRefreshPeriod.
+func (r *MachineReconciler) isStatusCached(key types.NamespacedName, m *clusterv1.Machine) bool {
+	cached, ok := r.machineStatusCache.Load(key)
+	if !ok {
+		return false
+	}
+	hash, err := machineStatusHash(m)
+	if err != nil || hash != cached.(uint32) {
+		return false
+	}
+	last := m.Status.LastSuccessfulReconcileTime
+	return last != nil && r.now().Sub(last.Time) < statusRefreshPeriod
+}
+
+// machineStatusHash returns the hash of the status of the Machine, without status.lastSuccessfulReconcileTime.
+// The status is hashed in its serialized form, so that timestamps are compared with the precision they are stored with.
+func machineStatusHash(m *clusterv1.Machine) (uint32, error) {
+	status := m.Status.DeepCopy()
+	status.LastSuccessfulReconcileTime = nil
+	data, err := json.Marshal(status)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to serialize the status of Machine %q in namespace %q", m.Name, m.Namespace)
+	}
+	hasher := fnv.New32a()
+	_, _ = hasher.Write(data)
+	return hasher.Sum32(), nil
+}
+
+// now returns the current time from the clock of the reconciler, or from the system if it has none.
+func (r *MachineReconciler) now() time.Time {
+	if r.clock == nil {
+		return time.Now()
+	}
+	return r.clock.Now()
+}
+
+// removeMachineFinalizer removes the Machine finalizer with a merge patch that includes the resourceVersion,
+// so it fails with a conflict instead of overwriting the finalizers set concurrently by other controllers.
+// Conflicts are retried on the latest version of the Machine; nothing is patched if the finalizer is already gone.
+func (r *MachineReconciler) removeMachineFinalizer(ctx context.Context, m *clusterv1.Machine) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		latest := &clusterv1.Machine{}
+		if err := r.Client.Get(ctx, util.ObjectKey(m), latest); err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil
+			}
+			return err
+		}
+		if !hasMachineFinalizer(latest) {
+			return nil
+		}
+
+		base := latest.DeepCopy()
+		base.ResourceVersion = ""
+		controllerutil.RemoveFinalizer(latest, clusterv1.MachineFinalizer)
+		if err := r.Client.Patch(ctx, latest, client.MergeFrom(base)); err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil
+			}
+			return err
+		}
+		return nil
+	})
+}
+
+// hasMachineFinalizer returns true if the Machine has the Machine finalizer.
+func hasMachineFinalizer(m *clusterv1.Machine) bool {
+	return sets.NewString(m.Finalizers...).Has(clusterv1.MachineFinalizer)
+}
+
+// tracer returns the tracer of the Machine controller, from the tracer provider of the reconciler
+// or from the global one if it has none.
+func (r *MachineReconciler) tracer() trace.Tracer {
+	if r.TracerProvider == nil {
+		return otel.Tracer(tracerName)
+	}
+	return r.TracerProvider.Tracer(tracerName)
+}
+
+// traceStep runs a step of the reconciliation of the Machine in a child span of the one in ctx.
+func (r *MachineReconciler) traceStep(ctx context.Context, name string, m *clusterv1.Machine, step func(ctx context.Context) error) (err error) {
+	ctx, span := r.tracer().Start(ctx, name, trace.WithAttributes(
+		attribute.String(spanAttributeMachine, m.Name),
+		attribute.String(spanAttributeNamespace, m.Namespace),
+		attribute.String(spanAttributePhase, m.Status.Phase),
+	))
+	defer func() {
+		endSpan(span, err)
+	}()
+	return step(ctx)
+}
+
+// endSpan records the error, if any, on the span and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// isConflict returns true if the error, or any of the errors aggregated in it, is a conflict error.
+func isConflict(err error) bool {
+	if agg, ok := err.(kerrors.Aggregate); ok {
+		for _, e := range agg.Errors() {
+			if isConflict(e) {
+				return true
+			}
+		}
+		return false
+	}
+	return apierrors.IsConflict(errors.Cause(err))
+}
+
 func (r *MachineReconciler) reconcile(ctx context.Context, cluster *clusterv1.Cluster, m *clusterv1.Machine) (ctrl.Result, error) {
-	logger := r.Log.WithValues("machine", m.Name, "namespace", m.Namespace)
-	logger = logger.WithValues("cluster", cluster.Name)
+	logger := r.Log.WithValues(LogFields(m)...)
 
 	// If the Machine belongs to a cluster, add an owner reference.
 	if r.shouldAdopt(m) {
-		m.OwnerReferences = util.EnsureOwnerRef(m.OwnerReferences, metav1.OwnerReference{
+		m.OwnerReferences = util.EnsureOwnerRefWithOptions(m.OwnerReferences, metav1.OwnerReference{
 			APIVersion: clusterv1.GroupVersion.String(),
 			Kind:       "Cluster",
 			Name:       cluster.Name,
 			UID:        cluster.UID,
-		})
+		}, false, true)
 	}
 
 	// If the Machine doesn't have a finalizer, add one.
 	controllerutil.AddFinalizer(m, clusterv1.MachineFinalizer)
 
+	// Fail the Machine without reading its infrastructure if it references a namespace it isn't allowed to.
+	if !r.isInfrastructureRefNamespaceAllowed(m) {
+		message := fmt.Sprintf("Spec.InfrastructureRef references namespace %q, which is not allowed for Machines in namespace %q",
+			m.Spec.InfrastructureRef.Namespace, m.Namespace)
+		if m.Status.FailureMessage == nil || *m.Status.FailureMessage != message {
+			r.recorder.Event(m, corev1.EventTypeWarning, "InvalidInfrastructureRef", message)
+		}
+		failureReason := capierrors.InvalidConfigurationMachineError
+		m.Status.FailureReason = &failureReason
+		m.Status.FailureMessage = &message
+		return ctrl.Result{}, nil
+	}
+
+	// Re-provision the Machine if it was provisioned with bootstrap data that changed since.
+	// If it has to wait for other Machines, it is requeued once reconciled.
+	reProvisioned, reProvisionErr := r.reconcileReProvision(ctx, m)
+	if reProvisioned || (reProvisionErr != nil && !capierrors.IsRequeueAfter(reProvisionErr)) {
+		return ctrl.Result{}, reProvisionErr
+	}
+
+	// Validate the Machine against the external policy, if any, before it is provisioned.
+	if feature.Gates.Enabled(feature.PreProvisionValidation) && !r.reconcilePreProvisionValidation(ctx, m) {
+		return ctrl.Result{RequeueAfter: preProvisionValidationRetryPeriod}, nil
+	}
+
 	// Call the inner reconciliation methods.
 	reconciliationErrors := []error{
-		r.reconcileBootstrap(ctx, cluster, m),
-		r.reconcileInfrastructure(ctx, cluster, m),
-		r.reconcileNodeRef(ctx, cluster, m),
+		reProvisionErr,
+		r.traceStep(ctx, "machine.reconcile.bootstrap", m, func(ctx context.Context) error {
+			return r.reconcileBootstrap(ctx, cluster, m)
+		}),
+		r.traceStep(ctx, "machine.reconcile.infrastructure", m, func(ctx context.Context) error {
+			return r.reconcileInfrastructure(ctx, cluster, m)
+		}),
+		r.traceStep(ctx, "machine.reconcile.noderef", m, func(ctx context.Context) error {
+			return r.reconcileNodeRef(ctx, cluster, m)
+		}),
+		r.reconcileNodeHealthy(ctx, cluster, m),
+		r.reconcileUpgrade(ctx, cluster, m),
+		r.reconcileNodeTaints(ctx, cluster, m),
+		r.reconcileNodeLabels(ctx, cluster, m),
 	}
 
 	// Parse the errors, making sure we record if there is a RequeueAfterError.
@@ -222,6 +790,12 @@ func (r *MachineReconciler) reconcile(ctx context.Context, cluster *clusterv1.Cl
 	return res, kerrors.NewAggregate(errs)
 }
 
+// isInfrastructureRefNamespaceAllowed returns true if the Spec.InfrastructureRef of the Machine is in the namespace
+// of the Machine, or in one of the namespaces AllowedCrossNamespaceInfrastructure allows for it.
+func (r *MachineReconciler) isInfrastructureRefNamespaceAllowed(m *clusterv1.Machine) bool {
+	return r.AllowedCrossNamespaceInfrastructure.Allows(m.Namespace, m.Spec.InfrastructureRef.Namespace)
+}
+
 func (r *MachineReconciler) reconcileMetrics(_ context.Context, m *clusterv1.Machine) {
 	if m.Status.BootstrapReady {
 		metrics.MachineBootstrapReady.WithLabelValues(m.Name, m.Namespace, m.Spec.ClusterName).Set(1)
@@ -241,15 +815,14 @@ func (r *MachineReconciler) reconcileMetrics(_ context.Context, m *clusterv1.Mac
 }
 
 func (r *MachineReconciler) reconcileDelete(ctx context.Context, cluster *clusterv1.Cluster, m *clusterv1.Machine) (ctrl.Result, error) {
-	logger := r.Log.WithValues("machine", m.Name, "namespace", m.Namespace)
-	logger = logger.WithValues("cluster", cluster.Name)
+	logger := r.Log.WithValues(LogFields(m)...)
 
 	err := r.isDeleteNodeAllowed(ctx, cluster, m)
 	isDeleteNodeAllowed := err == nil
 	if err != nil {
 		switch err {
 		case errNoControlPlaneNodes, errLastControlPlaneNode, errNilNodeRef, errClusterIsBeingDeleted:
-			logger.Info("Deleting Kubernetes Node associated with Machine is not allowed", "node", m.Status.NodeRef, "cause", err)
+			logger.Info("Deleting Kubernetes Node associated with Machine is not allowed", logFieldNode, m.Status.NodeRef, "cause", err)
 		default:
 			return ctrl.Result{}, errors.Wrapf(err, "failed to check if Kubernetes Node deletion is allowed")
 		}
@@ -257,8 +830,9 @@ func (r *MachineReconciler) reconcileDelete(ctx context.Context, cluster *cluste
 
 	if isDeleteNodeAllowed {
 		// Drain node before deletion.
-		if _, exists := m.ObjectMeta.Annotations[clusterv1.ExcludeNodeDrainingAnnotation]; !exists {
-			logger.Info("Draining node", "node", m.Status.NodeRef.Name)
+		if !isNodeDrainSkipped(m) && !r.hasNoDrainTaint(ctx, cluster, m.Status.NodeRef.Name) {
+			logger.Info("Draining node", logFieldNode, m.Status.NodeRef.Name)
+			r.LifecycleRecorder.Record(m, MachineDrainStartedEvent, "Draining Node %q", m.Status.NodeRef.Name)
 			if err := r.drainNode(ctx, cluster, m.Status.NodeRef.Name, m.Name); err != nil {
 				r.recorder.Eventf(m, corev1.EventTypeWarning, "FailedDrainNode", "error draining Machine's node %q: %v", m.Status.NodeRef.Name, err)
 				return ctrl.Result{}, err
@@ -273,21 +847,33 @@ func (r *MachineReconciler) reconcileDelete(ctx context.Context, cluster *cluste
 		return ctrl.Result{}, err
 	}
 
-	// We only delete the node after the underlying infrastructure is gone.
+	// Record that the infrastructure has been deleted as part of the deletion of the Machine;
+	// the transition time of the condition is when the node deletion timeout starts.
+	if c := conditions.Get(m.Status.Conditions, clusterv1.InfrastructureReadyCondition); c == nil || c.Reason != clusterv1.DeletedReason {
+		conditions.MarkFalse(&m.Status.Conditions, clusterv1.InfrastructureReadyCondition, clusterv1.DeletedReason, clusterv1.ConditionSeverityInfo, "")
+		conditions.Get(m.Status.Conditions, clusterv1.InfrastructureReadyCondition).LastTransitionTime = metav1.NewTime(r.now())
+	}
+
+	// We only delete the node after the underlying infrastructure is gone, and only if it still exists
+	// machine.spec.nodeDeletionTimeout later.
 	// https://github.com/kubernetes-sigs/cluster-api/issues/2565
 	if isDeleteNodeAllowed {
-		logger.Info("Deleting node", "node", m.Status.NodeRef.Name)
-
-		var deleteNodeErr error
-		waitErr := wait.PollImmediate(2*time.Second, 10*time.Second, func() (bool, error) {
-			if deleteNodeErr = r.deleteNode(ctx, cluster, m.Status.NodeRef.Name); deleteNodeErr != nil && !apierrors.IsNotFound(deleteNodeErr) {
-				return false, nil
+		if remaining := r.nodeDeletionTimeRemaining(m); remaining > 0 {
+			exists, err := r.nodeExists(ctx, cluster, m.Status.NodeRef.Name)
+			if err != nil {
+				logger.Error(err, "Failed to check if node still exists", logFieldNode, m.Status.NodeRef.Name)
+			}
+			if exists || err != nil {
+				logger.Info("Waiting for the node deletion timeout to pass before deleting the node", logFieldNode, m.Status.NodeRef.Name, "remaining", remaining)
+				return ctrl.Result{RequeueAfter: remaining}, nil
+			}
+		} else {
+			logger.Info("Deleting node", logFieldNode, m.Status.NodeRef.Name)
+			if err := r.deleteNode(ctx, cluster, m.Status.NodeRef.Name); err != nil && !apierrors.IsNotFound(err) {
+				logger.Error(err, "Failed to delete node, retrying", logFieldNode, m.Status.NodeRef.Name)
+				r.recorder.Eventf(m, corev1.EventTypeWarning, "FailedDeleteNode", "error deleting Machine's node: %v", err)
+				return ctrl.Result{RequeueAfter: nodeDeletionRetryPeriod}, nil
 			}
-			return true, nil
-		})
-		if waitErr != nil {
-			logger.Error(deleteNodeErr, "Timed out deleting node, moving on", "node", m.Status.NodeRef.Name)
-			r.recorder.Eventf(m, corev1.EventTypeWarning, "FailedDeleteNode", "error deleting Machine's node: %v", deleteNodeErr)
 		}
 	}
 
@@ -295,6 +881,36 @@ func (r *MachineReconciler) reconcileDelete(ctx context.Context, cluster *cluste
 	return ctrl.Result{}, nil
 }
 
+// nodeDeletionTimeRemaining returns how long the controller has to wait before deleting the Node of a Machine
+// being deleted, according to machine.spec.nodeDeletionTimeout counted from the deletion of its infrastructure.
+func (r *MachineReconciler) nodeDeletionTimeRemaining(machine *clusterv1.Machine) time.Duration {
+	timeout := defaultNodeDeletionTimeout
+	if machine.Spec.NodeDeletionTimeout != nil {
+		timeout = machine.Spec.NodeDeletionTimeout.Duration
+	}
+	c := conditions.Get(machine.Status.Conditions, clusterv1.InfrastructureReadyCondition)
+	if c == nil || c.Reason != clusterv1.DeletedReason {
+		return timeout
+	}
+	return c.LastTransitionTime.Add(timeout).Sub(r.now())
+}
+
+// nodeExists returns true if the Node with the given name exists in the workload cluster.
+func (r *MachineReconciler) nodeExists(ctx context.Context, cluster *clusterv1.Cluster, name string) (bool, error) {
+	c, err := r.clusterClient(ctx, cluster)
+	if err != nil {
+		return false, err
+	}
+
+	if err := c.Get(ctx, client.ObjectKey{Name: name}, &corev1.Node{}); err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, errors.Wrapf(err, "error getting node %s", name)
+	}
+	return true, nil
+}
+
 // isDeleteNodeAllowed returns nil only if the Machine's NodeRef is not nil
 // and if the Machine is not the last control plane node in the cluster.
 func (r *MachineReconciler) isDeleteNodeAllowed(ctx context.Context, cluster *clusterv1.Cluster, machine *clusterv1.Machine) error {
@@ -332,8 +948,17 @@ func (r *MachineReconciler) isDeleteNodeAllowed(ctx context.Context, cluster *cl
 	}
 }
 
+// isNodeDrainSkipped returns true if the Node of the Machine must not be drained on deletion,
+// because of either the ExcludeNodeDrainingAnnotation or Spec.IgnoreNodeDrain.
+func isNodeDrainSkipped(m *clusterv1.Machine) bool {
+	if _, exists := m.ObjectMeta.Annotations[clusterv1.ExcludeNodeDrainingAnnotation]; exists {
+		return true
+	}
+	return m.Spec.IgnoreNodeDrain
+}
+
 func (r *MachineReconciler) drainNode(ctx context.Context, cluster *clusterv1.Cluster, nodeName string, machineName string) error {
-	logger := r.Log.WithValues("machine", machineName, "node", nodeName, "cluster", cluster.Name, "namespace", cluster.Namespace)
+	logger := r.Log.WithValues(logFieldMachine, machineName, logFieldNode, nodeName, logFieldCluster, cluster.Name, logFieldNamespace, cluster.Namespace)
 
 	restConfig, err := remote.RESTConfig(ctx, r.Client, util.ObjectKey(cluster))
 	if err != nil {
@@ -399,11 +1024,52 @@ func (r *MachineReconciler) drainNode(ctx context.Context, cluster *clusterv1.Cl
 	return nil
 }
 
+// hasNoDrainTaint returns true if the Node carries a taint matching one of NoDrainTaints on its key and effect.
+// If the Node can't be read, the Node is drained as usual.
+func (r *MachineReconciler) hasNoDrainTaint(ctx context.Context, cluster *clusterv1.Cluster, nodeName string) bool {
+	if len(r.NoDrainTaints) == 0 {
+		return false
+	}
+	logger := r.Log.WithValues(logFieldNode, nodeName, logFieldCluster, cluster.Name, logFieldNamespace, cluster.Namespace)
+
+	c, err := r.clusterClient(ctx, cluster)
+	if err != nil {
+		logger.Error(err, "Error creating a remote client to check the taints of the Node")
+		return false
+	}
+	node := &corev1.Node{}
+	if err := c.Get(ctx, client.ObjectKey{Name: nodeName}, node); err != nil {
+		if !apierrors.IsNotFound(err) {
+			logger.Error(err, "Error getting the Node to check its taints")
+		}
+		return false
+	}
+
+	for i := range node.Spec.Taints {
+		for j := range r.NoDrainTaints {
+			if node.Spec.Taints[i].MatchTaint(&r.NoDrainTaints[j]) {
+				logger.Info("Node carries a no-drain taint, skipping drain", "taint", node.Spec.Taints[i].ToString())
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// clusterClient returns a client for the workload cluster, created with remoteClientGetter.
+func (r *MachineReconciler) clusterClient(ctx context.Context, cluster *clusterv1.Cluster) (client.Client, error) {
+	remoteClientGetter := r.remoteClientGetter
+	if remoteClientGetter == nil {
+		remoteClientGetter = remote.NewClusterClient
+	}
+	return remoteClientGetter(ctx, r.Client, util.ObjectKey(cluster), r.scheme)
+}
+
 func (r *MachineReconciler) deleteNode(ctx context.Context, cluster *clusterv1.Cluster, name string) error {
-	logger := r.Log.WithValues("machine", name, "cluster", cluster.Name, "namespace", cluster.Namespace)
+	logger := r.Log.WithValues(logFieldNode, name, logFieldCluster, cluster.Name, logFieldNamespace, cluster.Namespace)
 
 	// Create a remote client to delete the node
-	c, err := remote.NewClusterClient(ctx, r.Client, util.ObjectKey(cluster), r.scheme)
+	c, err := r.clusterClient(ctx, cluster)
 	if err != nil {
 		logger.Error(err, "Error creating a remote client for cluster while deleting Machine, won't retry")
 		return nil
@@ -422,11 +1088,14 @@ func (r *MachineReconciler) deleteNode(ctx context.Context, cluster *clusterv1.C
 }
 
 // reconcileDeleteExternal tries to delete external references, returning true if it cannot find any.
+// The infrastructure object is left to its provider if the Machine has the skip-infra-gc annotation.
 func (r *MachineReconciler) reconcileDeleteExternal(ctx context.Context, m *clusterv1.Machine) (bool, error) {
 	objects := []*unstructured.Unstructured{}
 	references := []*corev1.ObjectReference{
 		m.Spec.Bootstrap.ConfigRef,
-		&m.Spec.InfrastructureRef,
+	}
+	if m.Annotations[clusterv1.SkipInfraGCAnnotation] != "true" {
+		references = append(references, &m.Spec.InfrastructureRef)
 	}
 
 	// Loop over the references and try to retrieve it with the client.
@@ -435,7 +1104,7 @@ func (r *MachineReconciler) reconcileDeleteExternal(ctx context.Context, m *clus
 			continue
 		}
 
-		obj, err := external.Get(ctx, r.Client, ref, m.Namespace)
+		obj, err := external.Get(ctx, r.Client, ref, externalRefNamespace(m, ref))
 		if err != nil && !apierrors.IsNotFound(errors.Cause(err)) {
 			return false, errors.Wrapf(err, "failed to get %s %q for Machine %q in namespace %q",
 				ref.GroupVersionKind(), ref.Name, m.Name, m.Namespace)
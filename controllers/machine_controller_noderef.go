@@ -18,15 +18,19 @@ package controllers
 
 import (
 	"context"
+	"encoding/json"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
 	apicorev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
 	"sigs.k8s.io/cluster-api/controllers/noderefutil"
-	"sigs.k8s.io/cluster-api/controllers/remote"
 	capierrors "sigs.k8s.io/cluster-api/errors"
-	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -34,8 +38,12 @@ var (
 	ErrNodeNotFound = errors.New("cannot find node with matching ProviderID")
 )
 
+// DefaultNodeLabelPrefix is the prefix of the Machine labels copied to the Node
+// when MachineReconciler.NodeLabelPrefix is not set.
+const DefaultNodeLabelPrefix = "node.cluster.x-k8s.io/"
+
 func (r *MachineReconciler) reconcileNodeRef(ctx context.Context, cluster *clusterv1.Cluster, machine *clusterv1.Machine) error {
-	logger := r.Log.WithValues("machine", machine.Name, "namespace", machine.Namespace)
+	logger := r.Log.WithValues(LogFields(machine)...)
 	// Check that the Machine hasn't been deleted or in the process.
 	if !machine.DeletionTimestamp.IsZero() {
 		return nil
@@ -46,26 +54,34 @@ func (r *MachineReconciler) reconcileNodeRef(ctx context.Context, cluster *clust
 		return nil
 	}
 
-	logger = logger.WithValues("cluster", cluster.Name)
-
-	// Check that the Machine has a valid ProviderID.
-	if machine.Spec.ProviderID == nil || *machine.Spec.ProviderID == "" {
-		logger.Info("Machine doesn't have a valid ProviderID yet")
-		return nil
-	}
+	// Machines with a NodeName are associated with the Node of that name, the others by ProviderID.
+	var providerID *noderefutil.ProviderID
+	if machine.Spec.NodeName == "" {
+		// Check that the Machine has a valid ProviderID.
+		if machine.Spec.ProviderID == nil || *machine.Spec.ProviderID == "" {
+			logger.Info("Machine doesn't have a valid ProviderID yet")
+			return nil
+		}
 
-	providerID, err := noderefutil.NewProviderID(*machine.Spec.ProviderID)
-	if err != nil {
-		return err
+		var err error
+		providerID, err = noderefutil.NewProviderID(*machine.Spec.ProviderID)
+		if err != nil {
+			return err
+		}
 	}
 
-	clusterClient, err := remote.NewClusterClient(ctx, r.Client, util.ObjectKey(cluster), r.scheme)
+	clusterClient, err := r.clusterClient(ctx, cluster)
 	if err != nil {
 		return err
 	}
 
 	// Get the Node reference.
-	nodeRef, err := r.getNodeReference(clusterClient, providerID)
+	var nodeRef *apicorev1.ObjectReference
+	if providerID != nil {
+		nodeRef, err = r.getNodeReference(clusterClient, providerID)
+	} else {
+		nodeRef, err = r.getNodeReferenceByName(ctx, clusterClient, machine.Spec.NodeName)
+	}
 	if err != nil {
 		if err == ErrNodeNotFound {
 			return errors.Wrapf(&capierrors.RequeueAfterError{RequeueAfter: 10 * time.Second},
@@ -83,8 +99,25 @@ func (r *MachineReconciler) reconcileNodeRef(ctx context.Context, cluster *clust
 	return nil
 }
 
+// getNodeReferenceByName returns a reference to the Node with the given name, or ErrNodeNotFound if it doesn't exist.
+func (r *MachineReconciler) getNodeReferenceByName(ctx context.Context, c client.Client, name string) (*apicorev1.ObjectReference, error) {
+	node := &apicorev1.Node{}
+	if err := c.Get(ctx, client.ObjectKey{Name: name}, node); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, ErrNodeNotFound
+		}
+		return nil, err
+	}
+	return &apicorev1.ObjectReference{
+		Kind:       node.Kind,
+		APIVersion: node.APIVersion,
+		Name:       node.Name,
+		UID:        node.UID,
+	}, nil
+}
+
 func (r *MachineReconciler) getNodeReference(c client.Client, providerID *noderefutil.ProviderID) (*apicorev1.ObjectReference, error) {
-	logger := r.Log.WithValues("providerID", providerID)
+	logger := r.Log.WithValues(logFieldProviderID, providerID)
 
 	nodeList := apicorev1.NodeList{}
 	for {
@@ -95,7 +128,7 @@ func (r *MachineReconciler) getNodeReference(c client.Client, providerID *nodere
 		for _, node := range nodeList.Items {
 			nodeProviderID, err := noderefutil.NewProviderID(node.Spec.ProviderID)
 			if err != nil {
-				logger.Error(err, "Failed to parse ProviderID", "node", node.Name)
+				logger.Error(err, "Failed to parse ProviderID", logFieldNode, node.Name)
 				continue
 			}
 
@@ -116,3 +149,257 @@ func (r *MachineReconciler) getNodeReference(c client.Client, providerID *nodere
 
 	return nil, ErrNodeNotFound
 }
+
+// reconcileNodeHealthy updates the MachineNodeHealthyCondition according to the NodeReady condition
+// reported by the Node referenced by the Machine.
+func (r *MachineReconciler) reconcileNodeHealthy(ctx context.Context, cluster *clusterv1.Cluster, machine *clusterv1.Machine) error {
+	// Check that the Machine hasn't been deleted or in the process.
+	if !machine.DeletionTimestamp.IsZero() {
+		return nil
+	}
+
+	// Check that the Machine has a NodeRef.
+	if machine.Status.NodeRef == nil {
+		conditions.MarkFalse(&machine.Status.Conditions, clusterv1.MachineNodeHealthyCondition, clusterv1.WaitingForNodeRefReason, clusterv1.ConditionSeverityInfo, "")
+		return nil
+	}
+
+	clusterClient, err := r.clusterClient(ctx, cluster)
+	if err != nil {
+		r.Log.WithValues(LogFields(machine)...).Error(err, "Error creating a remote client for cluster while checking Node health, won't retry")
+		return nil
+	}
+
+	return r.setNodeHealthyCondition(ctx, clusterClient, machine)
+}
+
+// setNodeHealthyCondition fetches the Node referenced by the Machine using the given client
+// and sets the MachineNodeHealthyCondition accordingly.
+// If the Node doesn't exist anymore, e.g. because it was deleted externally, the stale NodeRef is cleared
+// so it can be assigned again; the Machine is left for the health checks to remediate.
+func (r *MachineReconciler) setNodeHealthyCondition(ctx context.Context, c client.Client, machine *clusterv1.Machine) error {
+	node := &apicorev1.Node{}
+	if err := c.Get(ctx, client.ObjectKey{Name: machine.Status.NodeRef.Name}, node); err != nil {
+		if apierrors.IsNotFound(err) {
+			nodeName := machine.Status.NodeRef.Name
+			r.Log.WithValues(LogFields(machine)...).Info("Node referenced by the Machine does not exist, clearing NodeRef", logFieldNode, nodeName)
+			r.recorder.Eventf(machine, apicorev1.EventTypeWarning, "NodeNotFound", "Node %q referenced by the Machine does not exist", nodeName)
+			conditions.MarkUnknown(&machine.Status.Conditions, clusterv1.MachineNodeHealthyCondition, clusterv1.NodeNotFoundReason,
+				"Node %q referenced by the Machine does not exist", nodeName)
+			machine.Status.NodeRef = nil
+			return nil
+		}
+		return errors.Wrapf(err, "failed to get Node %q for Machine %q in namespace %q", machine.Status.NodeRef.Name, machine.Name, machine.Namespace)
+	}
+
+	// The message of the condition must not depend on the current time: its transition time,
+	// used by the MachineHealthChecks, is reset whenever the message changes.
+	condition := nodeHealthyCondition(node)
+	var requeueAfter time.Duration
+	if timeout := machine.Spec.NodeUnresponsiveTimeout; timeout != nil {
+		if unresponsiveFor := nodeUnresponsiveDuration(node, r.now()); unresponsiveFor > timeout.Duration {
+			condition = conditions.FalseCondition(clusterv1.MachineNodeHealthyCondition, clusterv1.NodeUnresponsiveReason, clusterv1.ConditionSeverityWarning,
+				"Node %q has reported NodeReady=Unknown for longer than %s", node.Name, timeout.Duration)
+		} else if unresponsiveFor > 0 {
+			// Nothing else triggers a reconciliation when the timeout passes.
+			requeueAfter = timeout.Duration - unresponsiveFor
+		}
+	}
+	conditions.Set(&machine.Status.Conditions, condition)
+	if node.Status.NodeInfo.KubeletVersion != "" {
+		version := node.Status.NodeInfo.KubeletVersion
+		machine.Status.Version = &version
+	}
+	if requeueAfter > 0 {
+		return &capierrors.RequeueAfterError{RequeueAfter: requeueAfter}
+	}
+	return nil
+}
+
+// nodeHealthyCondition returns the MachineNodeHealthyCondition derived from the NodeReady condition of the given Node.
+func nodeHealthyCondition(node *apicorev1.Node) *clusterv1.Condition {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type != apicorev1.NodeReady {
+			continue
+		}
+		switch condition.Status {
+		case apicorev1.ConditionTrue:
+			return conditions.TrueCondition(clusterv1.MachineNodeHealthyCondition)
+		case apicorev1.ConditionFalse:
+			return conditions.FalseCondition(clusterv1.MachineNodeHealthyCondition, clusterv1.NodeNotReadyReason, clusterv1.ConditionSeverityWarning, "%s", condition.Message)
+		default:
+			return conditions.UnknownCondition(clusterv1.MachineNodeHealthyCondition, clusterv1.NodeReadyUnknownReason, "%s", condition.Message)
+		}
+	}
+	return conditions.UnknownCondition(clusterv1.MachineNodeHealthyCondition, clusterv1.NodeReadyUnknownReason, "Node %q does not report the NodeReady condition", node.Name)
+}
+
+// nodeUnresponsiveDuration returns for how long the given Node has reported NodeReady=Unknown,
+// or zero if it reports another status or doesn't report the NodeReady condition.
+func nodeUnresponsiveDuration(node *apicorev1.Node, now time.Time) time.Duration {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == apicorev1.NodeReady && condition.Status == apicorev1.ConditionUnknown {
+			return now.Sub(condition.LastTransitionTime.Time)
+		}
+	}
+	return 0
+}
+
+// reconcileNodeTaints applies the taints specified in Spec.Taints to the Node referenced by the Machine.
+func (r *MachineReconciler) reconcileNodeTaints(ctx context.Context, cluster *clusterv1.Cluster, machine *clusterv1.Machine) error {
+	// Check that the Machine hasn't been deleted or in the process.
+	if !machine.DeletionTimestamp.IsZero() {
+		return nil
+	}
+
+	// Check that there is something to apply and the Machine has a NodeRef.
+	if len(machine.Spec.Taints) == 0 || machine.Status.NodeRef == nil {
+		return nil
+	}
+
+	clusterClient, err := r.clusterClient(ctx, cluster)
+	if err != nil {
+		r.Log.WithValues(LogFields(machine)...).Error(err, "Error creating a remote client for cluster while applying Node taints, won't retry")
+		return nil
+	}
+
+	return r.applyNodeTaints(ctx, clusterClient, machine)
+}
+
+// applyNodeTaints patches the Node referenced by the Machine using the given client so that it carries
+// all the taints in Spec.Taints. Taints are matched by key; other taints are left untouched.
+// Node taints have no merge key, so the strategic merge patch replaces the whole list: it includes the
+// resourceVersion to fail with a conflict instead of dropping the taints set concurrently by other
+// controllers, and conflicts are retried on the latest version of the Node.
+func (r *MachineReconciler) applyNodeTaints(ctx context.Context, c client.Client, machine *clusterv1.Machine) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		node := &apicorev1.Node{}
+		if err := c.Get(ctx, client.ObjectKey{Name: machine.Status.NodeRef.Name}, node); err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil
+			}
+			return errors.Wrapf(err, "failed to get Node %q for Machine %q in namespace %q", machine.Status.NodeRef.Name, machine.Name, machine.Namespace)
+		}
+
+		changed := false
+		for _, taint := range machine.Spec.Taints {
+			if mergeTaint(&node.Spec.Taints, taint) {
+				changed = true
+			}
+		}
+		if !changed {
+			return nil
+		}
+
+		data, err := json.Marshal(map[string]interface{}{
+			"metadata": map[string]interface{}{"resourceVersion": node.ResourceVersion},
+			"spec":     map[string]interface{}{"taints": node.Spec.Taints},
+		})
+		if err != nil {
+			return errors.Wrapf(err, "failed to create taints patch for Node %q", node.Name)
+		}
+
+		if err := c.Patch(ctx, node, client.RawPatch(types.StrategicMergePatchType, data)); err != nil {
+			if apierrors.IsConflict(err) {
+				return err
+			}
+			return errors.Wrapf(err, "failed to apply taints to Node %q for Machine %q in namespace %q", node.Name, machine.Name, machine.Namespace)
+		}
+		return nil
+	})
+}
+
+// mergeTaint adds the taint to the list, or updates the value and effect of an existing taint with the same key.
+// It returns true if the list has been modified.
+func mergeTaint(taints *[]apicorev1.Taint, taint apicorev1.Taint) bool {
+	for i := range *taints {
+		existing := &(*taints)[i]
+		if existing.Key != taint.Key {
+			continue
+		}
+		if existing.Value == taint.Value && existing.Effect == taint.Effect {
+			return false
+		}
+		existing.Value = taint.Value
+		existing.Effect = taint.Effect
+		return true
+	}
+	*taints = append(*taints, taint)
+	return true
+}
+
+// reconcileNodeLabels copies the Machine labels matching the configured prefix to the Node referenced by the Machine.
+func (r *MachineReconciler) reconcileNodeLabels(ctx context.Context, cluster *clusterv1.Cluster, machine *clusterv1.Machine) error {
+	// Check that the Machine hasn't been deleted or in the process.
+	if !machine.DeletionTimestamp.IsZero() {
+		return nil
+	}
+
+	// Check that the Machine has a NodeRef.
+	if machine.Status.NodeRef == nil {
+		return nil
+	}
+
+	prefix := r.NodeLabelPrefix
+	if prefix == "" {
+		prefix = DefaultNodeLabelPrefix
+	}
+	if !hasLabelWithPrefix(machine.Labels, prefix) {
+		return nil
+	}
+
+	clusterClient, err := r.clusterClient(ctx, cluster)
+	if err != nil {
+		r.Log.WithValues(LogFields(machine)...).Error(err, "Error creating a remote client for cluster while applying Node labels, won't retry")
+		return nil
+	}
+
+	node := &apicorev1.Node{}
+	if err := clusterClient.Get(ctx, client.ObjectKey{Name: machine.Status.NodeRef.Name}, node); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return errors.Wrapf(err, "failed to get Node %q for Machine %q in namespace %q", machine.Status.NodeRef.Name, machine.Name, machine.Namespace)
+	}
+
+	_, err = SyncMachineLabelsToNode(ctx, clusterClient, machine, node, prefix)
+	return err
+}
+
+// SyncMachineLabelsToNode patches the Node using the given client so that it carries all the labels
+// of the Machine whose key starts with prefix. Other labels on the Node are left untouched.
+// It returns true if the Node has been patched.
+func SyncMachineLabelsToNode(ctx context.Context, c client.Client, machine *clusterv1.Machine, node *apicorev1.Node, prefix string) (bool, error) {
+	patch := client.MergeFrom(node.DeepCopy())
+	changed := false
+	for key, value := range machine.Labels {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if existing, ok := node.Labels[key]; ok && existing == value {
+			continue
+		}
+		if node.Labels == nil {
+			node.Labels = map[string]string{}
+		}
+		node.Labels[key] = value
+		changed = true
+	}
+	if !changed {
+		return false, nil
+	}
+
+	if err := c.Patch(ctx, node, patch); err != nil {
+		return false, errors.Wrapf(err, "failed to apply labels to Node %q for Machine %q in namespace %q", node.Name, machine.Name, machine.Namespace)
+	}
+	return true, nil
+}
+
+func hasLabelWithPrefix(labels map[string]string, prefix string) bool {
+	for key := range labels {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
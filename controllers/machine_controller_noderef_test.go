@@ -17,20 +17,31 @@ limitations under the License.
 package controllers
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	. "github.com/onsi/gomega"
 
+	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
 	"sigs.k8s.io/cluster-api/controllers/noderefutil"
+	fakeremote "sigs.k8s.io/cluster-api/controllers/remote/fake"
+	capierrors "sigs.k8s.io/cluster-api/errors"
+	"sigs.k8s.io/cluster-api/util/conditions"
 )
 
 func TestGetNodeReference(t *testing.T) {
@@ -126,3 +137,547 @@ func TestGetNodeReference(t *testing.T) {
 
 	}
 }
+
+func TestReconcileNodeRefByNodeName(t *testing.T) {
+	cluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+	}
+	// The Node doesn't have a provider ID.
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "bare-metal-1"},
+	}
+
+	tests := []struct {
+		name              string
+		nodeName          string
+		expectNodeRef     string
+		expectRequeueErr  bool
+		expectNoReconcile bool
+	}{
+		{
+			name:          "node is found by name",
+			nodeName:      "bare-metal-1",
+			expectNodeRef: "bare-metal-1",
+		},
+		{
+			name:             "node doesn't exist yet",
+			nodeName:         "bare-metal-2",
+			expectRequeueErr: true,
+		},
+		{
+			name:              "no node name and no provider ID",
+			expectNoReconcile: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			machine := &clusterv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "machine"},
+				Spec: clusterv1.MachineSpec{
+					ClusterName: cluster.Name,
+					NodeName:    tt.nodeName,
+				},
+			}
+			r := &MachineReconciler{
+				Client:             fake.NewFakeClientWithScheme(scheme.Scheme, cluster, node),
+				Log:                log.Log,
+				scheme:             scheme.Scheme,
+				recorder:           record.NewFakeRecorder(32),
+				remoteClientGetter: fakeremote.NewClusterClient,
+			}
+
+			err := r.reconcileNodeRef(context.Background(), cluster, machine)
+			switch {
+			case tt.expectRequeueErr:
+				_, ok := errors.Cause(err).(capierrors.HasRequeueAfterError)
+				g.Expect(ok).To(BeTrue())
+				g.Expect(machine.Status.NodeRef).To(BeNil())
+			case tt.expectNoReconcile:
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(machine.Status.NodeRef).To(BeNil())
+			default:
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(machine.Status.NodeRef).NotTo(BeNil())
+				g.Expect(machine.Status.NodeRef.Name).To(Equal(tt.expectNodeRef))
+			}
+		})
+	}
+}
+
+func TestSetNodeHealthyCondition(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(clusterv1.AddToScheme(scheme.Scheme)).To(Succeed())
+
+	nodeWithReady := func(name string, status corev1.ConditionStatus) *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status: corev1.NodeStatus{
+				Conditions: []corev1.NodeCondition{
+					{Type: corev1.NodeMemoryPressure, Status: corev1.ConditionFalse},
+					{Type: corev1.NodeReady, Status: status, Message: "kubelet says " + string(status)},
+				},
+			},
+		}
+	}
+
+	client := fake.NewFakeClientWithScheme(scheme.Scheme,
+		nodeWithReady("node-ready", corev1.ConditionTrue),
+		nodeWithReady("node-not-ready", corev1.ConditionFalse),
+		nodeWithReady("node-unknown", corev1.ConditionUnknown),
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-no-conditions"}},
+	)
+
+	r := &MachineReconciler{
+		Client:   client,
+		Log:      log.Log,
+		recorder: record.NewFakeRecorder(32),
+	}
+
+	testCases := []struct {
+		name     string
+		nodeName string
+		status   corev1.ConditionStatus
+		reason   string
+		severity clusterv1.ConditionSeverity
+	}{
+		{
+			name:     "node reports NodeReady=True",
+			nodeName: "node-ready",
+			status:   corev1.ConditionTrue,
+		},
+		{
+			name:     "node reports NodeReady=False",
+			nodeName: "node-not-ready",
+			status:   corev1.ConditionFalse,
+			reason:   clusterv1.NodeNotReadyReason,
+			severity: clusterv1.ConditionSeverityWarning,
+		},
+		{
+			name:     "node reports NodeReady=Unknown",
+			nodeName: "node-unknown",
+			status:   corev1.ConditionUnknown,
+			reason:   clusterv1.NodeReadyUnknownReason,
+		},
+		{
+			name:     "node does not report NodeReady",
+			nodeName: "node-no-conditions",
+			status:   corev1.ConditionUnknown,
+			reason:   clusterv1.NodeReadyUnknownReason,
+		},
+		{
+			name:     "node does not exist",
+			nodeName: "node-missing",
+			status:   corev1.ConditionUnknown,
+			reason:   clusterv1.NodeNotFoundReason,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			machine := &clusterv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{Name: "machine-test", Namespace: "default"},
+				Status: clusterv1.MachineStatus{
+					NodeRef: &corev1.ObjectReference{Name: tc.nodeName},
+				},
+			}
+
+			g.Expect(r.setNodeHealthyCondition(context.Background(), client, machine)).To(Succeed())
+
+			g.Expect(machine.Status.Conditions).To(HaveLen(1))
+			condition := machine.Status.Conditions[0]
+			g.Expect(condition.Type).To(Equal(clusterv1.MachineNodeHealthyCondition))
+			g.Expect(condition.Status).To(Equal(tc.status))
+			g.Expect(condition.Reason).To(Equal(tc.reason))
+			g.Expect(condition.Severity).To(Equal(tc.severity))
+			g.Expect(condition.LastTransitionTime.IsZero()).To(BeFalse())
+		})
+	}
+
+	t.Run("machine without a NodeRef is waiting for it", func(t *testing.T) {
+		g := NewWithT(t)
+
+		machine := &clusterv1.Machine{
+			ObjectMeta: metav1.ObjectMeta{Name: "machine-test", Namespace: "default"},
+		}
+
+		g.Expect(r.reconcileNodeHealthy(context.Background(), &clusterv1.Cluster{}, machine)).To(Succeed())
+
+		g.Expect(machine.Status.Conditions).To(HaveLen(1))
+		g.Expect(machine.Status.Conditions[0].Status).To(Equal(corev1.ConditionFalse))
+		g.Expect(machine.Status.Conditions[0].Reason).To(Equal(clusterv1.WaitingForNodeRefReason))
+	})
+}
+
+func TestSetNodeHealthyConditionUnresponsive(t *testing.T) {
+	now := time.Date(2020, time.June, 1, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name         string
+		status       corev1.ConditionStatus
+		since        time.Duration
+		timeout      *metav1.Duration
+		expected     corev1.ConditionStatus
+		reason       string
+		requeueAfter time.Duration
+	}{
+		{
+			name:     "node reports NodeReady=Unknown for longer than the timeout",
+			status:   corev1.ConditionUnknown,
+			since:    10 * time.Minute,
+			timeout:  &metav1.Duration{Duration: 5 * time.Minute},
+			expected: corev1.ConditionFalse,
+			reason:   clusterv1.NodeUnresponsiveReason,
+		},
+		{
+			name:         "node reports NodeReady=Unknown for less than the timeout",
+			status:       corev1.ConditionUnknown,
+			since:        time.Minute,
+			timeout:      &metav1.Duration{Duration: 5 * time.Minute},
+			expected:     corev1.ConditionUnknown,
+			reason:       clusterv1.NodeReadyUnknownReason,
+			requeueAfter: 4 * time.Minute,
+		},
+		{
+			name:     "node reports NodeReady=Unknown without a timeout",
+			status:   corev1.ConditionUnknown,
+			since:    time.Hour,
+			expected: corev1.ConditionUnknown,
+			reason:   clusterv1.NodeReadyUnknownReason,
+		},
+		{
+			name:     "node reports NodeReady=True",
+			status:   corev1.ConditionTrue,
+			since:    time.Hour,
+			timeout:  &metav1.Duration{Duration: 5 * time.Minute},
+			expected: corev1.ConditionTrue,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			node := &corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "node"},
+				Status: corev1.NodeStatus{
+					Conditions: []corev1.NodeCondition{{
+						Type:               corev1.NodeReady,
+						Status:             tc.status,
+						LastTransitionTime: metav1.NewTime(now.Add(-tc.since)),
+					}},
+				},
+			}
+			client := fake.NewFakeClientWithScheme(scheme.Scheme, node)
+			r := &MachineReconciler{
+				Client:   client,
+				Log:      log.Log,
+				recorder: record.NewFakeRecorder(32),
+				clock:    clock.NewFakeClock(now),
+			}
+
+			machine := &clusterv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{Name: "machine-test", Namespace: "default"},
+				Spec:       clusterv1.MachineSpec{NodeUnresponsiveTimeout: tc.timeout},
+				Status: clusterv1.MachineStatus{
+					NodeRef: &corev1.ObjectReference{Name: "node"},
+				},
+			}
+
+			err := r.setNodeHealthyCondition(context.Background(), client, machine)
+			if tc.requeueAfter > 0 {
+				// The Machine is reconciled again when the timeout passes.
+				g.Expect(err).To(HaveOccurred())
+				requeueErr, ok := errors.Cause(err).(capierrors.HasRequeueAfterError)
+				g.Expect(ok).To(BeTrue())
+				g.Expect(requeueErr.GetRequeueAfter()).To(Equal(tc.requeueAfter))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+
+			condition := conditions.Get(machine.Status.Conditions, clusterv1.MachineNodeHealthyCondition)
+			g.Expect(condition).NotTo(BeNil())
+			g.Expect(condition.Status).To(Equal(tc.expected))
+			g.Expect(condition.Reason).To(Equal(tc.reason))
+		})
+	}
+}
+
+func TestSetNodeHealthyConditionUnresponsiveIsStable(t *testing.T) {
+	g := NewWithT(t)
+
+	now := time.Date(2020, time.June, 1, 12, 0, 0, 0, time.UTC)
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node"},
+		Status: corev1.NodeStatus{
+			Conditions: []corev1.NodeCondition{{
+				Type:               corev1.NodeReady,
+				Status:             corev1.ConditionUnknown,
+				LastTransitionTime: metav1.NewTime(now.Add(-10 * time.Minute)),
+			}},
+		},
+	}
+	client := fake.NewFakeClientWithScheme(scheme.Scheme, node)
+	fakeClock := clock.NewFakeClock(now)
+	r := &MachineReconciler{
+		Client:   client,
+		Log:      log.Log,
+		recorder: record.NewFakeRecorder(32),
+		clock:    fakeClock,
+	}
+	machine := &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{Name: "machine-test", Namespace: "default"},
+		Spec:       clusterv1.MachineSpec{NodeUnresponsiveTimeout: &metav1.Duration{Duration: 5 * time.Minute}},
+		Status: clusterv1.MachineStatus{
+			NodeRef: &corev1.ObjectReference{Name: "node"},
+		},
+	}
+
+	g.Expect(r.setNodeHealthyCondition(context.Background(), client, machine)).To(Succeed())
+	first := conditions.Get(machine.Status.Conditions, clusterv1.MachineNodeHealthyCondition).DeepCopy()
+
+	// Reconciling the Machine later doesn't change the condition, nor its transition time.
+	fakeClock.Step(time.Minute)
+	g.Expect(r.setNodeHealthyCondition(context.Background(), client, machine)).To(Succeed())
+	g.Expect(conditions.Get(machine.Status.Conditions, clusterv1.MachineNodeHealthyCondition)).To(Equal(first))
+}
+
+func TestApplyNodeTaints(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(clusterv1.AddToScheme(scheme.Scheme)).To(Succeed())
+
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+		Spec: corev1.NodeSpec{
+			Taints: []corev1.Taint{
+				{Key: "existing", Value: "keep", Effect: corev1.TaintEffectNoSchedule},
+				{Key: "dedicated", Value: "old", Effect: corev1.TaintEffectPreferNoSchedule},
+			},
+		},
+	}
+	c := fake.NewFakeClientWithScheme(scheme.Scheme, node)
+
+	r := &MachineReconciler{
+		Client: c,
+		Log:    log.Log,
+	}
+
+	machine := &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{Name: "machine-test", Namespace: "default"},
+		Spec: clusterv1.MachineSpec{
+			Taints: []corev1.Taint{
+				{Key: "dedicated", Value: "gpu", Effect: corev1.TaintEffectNoSchedule},
+				{Key: "new", Value: "added", Effect: corev1.TaintEffectNoExecute},
+			},
+		},
+		Status: clusterv1.MachineStatus{
+			NodeRef: &corev1.ObjectReference{Name: "node-1"},
+		},
+	}
+
+	expected := []corev1.Taint{
+		{Key: "existing", Value: "keep", Effect: corev1.TaintEffectNoSchedule},
+		{Key: "dedicated", Value: "gpu", Effect: corev1.TaintEffectNoSchedule},
+		{Key: "new", Value: "added", Effect: corev1.TaintEffectNoExecute},
+	}
+
+	g.Expect(r.applyNodeTaints(context.TODO(), c, machine)).To(Succeed())
+
+	updated := &corev1.Node{}
+	g.Expect(c.Get(context.TODO(), client.ObjectKey{Name: "node-1"}, updated)).To(Succeed())
+	g.Expect(updated.Spec.Taints).To(Equal(expected))
+
+	// Reconciling again must not change the Node.
+	resourceVersion := updated.ResourceVersion
+	g.Expect(r.applyNodeTaints(context.TODO(), c, machine)).To(Succeed())
+
+	g.Expect(c.Get(context.TODO(), client.ObjectKey{Name: "node-1"}, updated)).To(Succeed())
+	g.Expect(updated.Spec.Taints).To(Equal(expected))
+	g.Expect(updated.ResourceVersion).To(Equal(resourceVersion))
+}
+
+// nodeConflictClient fails the first Patch with a conflict, as if the Node was modified concurrently,
+// and records the type of the patches it receives.
+type nodeConflictClient struct {
+	client.Client
+	conflicts  int
+	patchTypes []types.PatchType
+}
+
+func (c *nodeConflictClient) Patch(ctx context.Context, obj runtime.Object, patch client.Patch, opts ...client.PatchOption) error {
+	c.patchTypes = append(c.patchTypes, patch.Type())
+	if c.conflicts > 0 {
+		c.conflicts--
+		node := &corev1.Node{}
+		if err := c.Client.Get(ctx, client.ObjectKey{Name: "node-1"}, node); err != nil {
+			return err
+		}
+		node.Spec.Taints = append(node.Spec.Taints, corev1.Taint{Key: "concurrent", Value: "set", Effect: corev1.TaintEffectNoSchedule})
+		if err := c.Client.Update(ctx, node); err != nil {
+			return err
+		}
+		return apierrors.NewConflict(corev1.Resource("nodes"), "node-1", errors.New("the object has been modified"))
+	}
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func TestReconcileNodeTaintsConflict(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(clusterv1.AddToScheme(scheme.Scheme)).To(Succeed())
+
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+		Spec: corev1.NodeSpec{
+			Taints: []corev1.Taint{
+				{Key: "existing", Value: "keep", Effect: corev1.TaintEffectNoSchedule},
+			},
+		},
+	}
+	c := &nodeConflictClient{Client: fake.NewFakeClientWithScheme(scheme.Scheme, node), conflicts: 1}
+
+	r := &MachineReconciler{
+		Client: c,
+		Log:    log.Log,
+		scheme: scheme.Scheme,
+		remoteClientGetter: func(_ context.Context, _ client.Client, _ client.ObjectKey, _ *runtime.Scheme) (client.Client, error) {
+			return c, nil
+		},
+	}
+
+	machine := &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{Name: "machine-test", Namespace: "default"},
+		Spec: clusterv1.MachineSpec{
+			Taints: []corev1.Taint{
+				{Key: "dedicated", Value: "gpu", Effect: corev1.TaintEffectNoSchedule},
+			},
+		},
+		Status: clusterv1.MachineStatus{
+			NodeRef: &corev1.ObjectReference{Name: "node-1"},
+		},
+	}
+
+	g.Expect(r.reconcileNodeTaints(context.TODO(), &clusterv1.Cluster{}, machine)).To(Succeed())
+
+	// The conflict is retried on the latest Node, so the taint set concurrently is kept.
+	g.Expect(c.patchTypes).To(Equal([]types.PatchType{types.StrategicMergePatchType, types.StrategicMergePatchType}))
+	updated := &corev1.Node{}
+	g.Expect(c.Get(context.TODO(), client.ObjectKey{Name: "node-1"}, updated)).To(Succeed())
+	g.Expect(updated.Spec.Taints).To(Equal([]corev1.Taint{
+		{Key: "existing", Value: "keep", Effect: corev1.TaintEffectNoSchedule},
+		{Key: "concurrent", Value: "set", Effect: corev1.TaintEffectNoSchedule},
+		{Key: "dedicated", Value: "gpu", Effect: corev1.TaintEffectNoSchedule},
+	}))
+}
+
+func TestSyncMachineLabelsToNode(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(clusterv1.AddToScheme(scheme.Scheme)).To(Succeed())
+
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "node-1",
+			Labels: map[string]string{
+				"existing":                    "keep",
+				"node.cluster.x-k8s.io/stale": "old",
+			},
+		},
+	}
+	c := fake.NewFakeClientWithScheme(scheme.Scheme, node)
+
+	machine := &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "machine-test",
+			Namespace: "default",
+			Labels: map[string]string{
+				"node.cluster.x-k8s.io/pool":  "gpu",
+				"node.cluster.x-k8s.io/stale": "new",
+				"topology.kubernetes.io/zone": "us-east-1a",
+				clusterv1.ClusterLabelName:    "test-cluster",
+			},
+		},
+		Status: clusterv1.MachineStatus{
+			NodeRef: &corev1.ObjectReference{Name: "node-1"},
+		},
+	}
+
+	expected := map[string]string{
+		"existing":                    "keep",
+		"node.cluster.x-k8s.io/pool":  "gpu",
+		"node.cluster.x-k8s.io/stale": "new",
+	}
+
+	changed, err := SyncMachineLabelsToNode(context.TODO(), c, machine, node, DefaultNodeLabelPrefix)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(changed).To(BeTrue())
+
+	updated := &corev1.Node{}
+	g.Expect(c.Get(context.TODO(), client.ObjectKey{Name: "node-1"}, updated)).To(Succeed())
+	g.Expect(updated.Labels).To(Equal(expected))
+
+	// Syncing again must not change the Node.
+	changed, err = SyncMachineLabelsToNode(context.TODO(), c, machine, updated, DefaultNodeLabelPrefix)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(changed).To(BeFalse())
+
+	// A different prefix selects a different set of labels.
+	changed, err = SyncMachineLabelsToNode(context.TODO(), c, machine, updated, "topology.kubernetes.io/")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(changed).To(BeTrue())
+	g.Expect(c.Get(context.TODO(), client.ObjectKey{Name: "node-1"}, updated)).To(Succeed())
+	g.Expect(updated.Labels).To(HaveKeyWithValue("topology.kubernetes.io/zone", "us-east-1a"))
+	g.Expect(updated.Labels).NotTo(HaveKey(clusterv1.ClusterLabelName))
+}
+
+func TestReconcileDeletedNode(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(clusterv1.AddToScheme(scheme.Scheme)).To(Succeed())
+
+	cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"}}
+	machine := &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "machine"},
+		Spec: clusterv1.MachineSpec{
+			ClusterName: cluster.Name,
+			ProviderID:  pointer.StringPtr("aws:///id-node-1"),
+		},
+		Status: clusterv1.MachineStatus{
+			NodeRef: &corev1.ObjectReference{Name: "deleted-node"},
+		},
+	}
+	recorder := record.NewFakeRecorder(32)
+	r := &MachineReconciler{
+		Client:             fake.NewFakeClientWithScheme(scheme.Scheme, cluster),
+		Log:                log.Log,
+		scheme:             scheme.Scheme,
+		recorder:           recorder,
+		remoteClientGetter: fakeremote.NewClusterClient,
+	}
+
+	// The Node was deleted: the stale NodeRef is cleared, but the Machine is kept.
+	g.Expect(r.setNodeHealthyCondition(context.Background(), r.Client, machine)).To(Succeed())
+	g.Expect(machine.Status.NodeRef).To(BeNil())
+	g.Expect(machine.DeletionTimestamp).To(BeNil())
+	condition := conditions.Get(machine.Status.Conditions, clusterv1.MachineNodeHealthyCondition)
+	g.Expect(condition).NotTo(BeNil())
+	g.Expect(condition.Status).To(Equal(corev1.ConditionUnknown))
+	g.Expect(condition.Reason).To(Equal(clusterv1.NodeNotFoundReason))
+	g.Expect(recorder.Events).To(Receive(ContainSubstring("NodeNotFound")))
+
+	// A new Node with the ProviderID of the Machine is referenced on the next reconcile.
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "new-node"},
+		Spec:       corev1.NodeSpec{ProviderID: "aws:///id-node-1"},
+	}
+	g.Expect(r.Client.Create(context.Background(), node)).To(Succeed())
+	g.Expect(r.reconcileNodeRef(context.Background(), cluster, machine)).To(Succeed())
+	g.Expect(machine.Status.NodeRef).NotTo(BeNil())
+	g.Expect(machine.Status.NodeRef.Name).To(Equal("new-node"))
+}
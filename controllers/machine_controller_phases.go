@@ -18,6 +18,7 @@ package controllers
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"strings"
 	"time"
@@ -27,15 +28,20 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/utils/pointer"
 	utilconversion "sigs.k8s.io/cluster-api/util/conversion"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
 	"sigs.k8s.io/cluster-api/controllers/external"
+	"sigs.k8s.io/cluster-api/controllers/mdutil"
 	capierrors "sigs.k8s.io/cluster-api/errors"
+	"sigs.k8s.io/cluster-api/feature"
 	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/patch"
 )
 
@@ -61,8 +67,9 @@ func (r *MachineReconciler) reconcilePhase(_ context.Context, m *clusterv1.Machi
 		m.Status.SetTypedPhase(clusterv1.MachinePhaseProvisioned)
 	}
 
-	// Set the phase to "running" if there is a NodeRef field and infrastructure is ready.
-	if m.Status.NodeRef != nil && m.Status.InfrastructureReady {
+	// Set the phase to "running" if there is a NodeRef field, infrastructure is ready
+	// and all the readiness gates pass.
+	if m.Status.NodeRef != nil && m.Status.InfrastructureReady && reconcileReadinessGates(m) {
 		m.Status.SetTypedPhase(clusterv1.MachinePhaseRunning)
 	}
 
@@ -83,20 +90,120 @@ func (r *MachineReconciler) reconcilePhase(_ context.Context, m *clusterv1.Machi
 	}
 }
 
+// reconcileReadinessGates returns true if all the conditions listed in Spec.ReadinessGates are True,
+// and reports the result on the ReadinessGatesReady condition of the Machine.
+func reconcileReadinessGates(m *clusterv1.Machine) bool {
+	if len(m.Spec.ReadinessGates) == 0 {
+		return true
+	}
+
+	pending := []string{}
+	for _, gate := range m.Spec.ReadinessGates {
+		if !conditions.IsTrue(m.Status.Conditions, gate.ConditionType) {
+			pending = append(pending, string(gate.ConditionType))
+		}
+	}
+	if len(pending) > 0 {
+		conditions.MarkFalse(&m.Status.Conditions, clusterv1.ReadinessGatesReadyCondition, clusterv1.WaitingForReadinessGatesReason, clusterv1.ConditionSeverityInfo,
+			"Waiting for readiness gates: %s", strings.Join(pending, ", "))
+		return false
+	}
+
+	conditions.MarkTrue(&m.Status.Conditions, clusterv1.ReadinessGatesReadyCondition)
+	return true
+}
+
+// reconcileUpgrade gates the upgrade of a Machine whose Spec.Version differs from the version reported by its Node,
+// so that no more than Spec.MaxUnavailableDuringUpgrade Machines in the Cluster are unavailable at the same time.
+// The result is reported on the UpgradeAllowed condition of the Machine.
+func (r *MachineReconciler) reconcileUpgrade(ctx context.Context, cluster *clusterv1.Cluster, m *clusterv1.Machine) error {
+	if m.Spec.MaxUnavailableDuringUpgrade == nil || !m.DeletionTimestamp.IsZero() {
+		return nil
+	}
+
+	// Reset the gate once the Node reports the desired version, so the next upgrade is gated again.
+	if !isUpgrading(m) {
+		conditions.Delete(&m.Status.Conditions, clusterv1.UpgradeAllowedCondition)
+		return nil
+	}
+
+	// Machines that have been allowed to upgrade are not gated again.
+	if conditions.IsTrue(m.Status.Conditions, clusterv1.UpgradeAllowedCondition) {
+		return nil
+	}
+
+	machines, err := util.GetMachinesForCluster(ctx, r.Client, cluster)
+	if err != nil {
+		return errors.Wrapf(err, "failed to list Machines for Cluster %q in namespace %q", cluster.Name, cluster.Namespace)
+	}
+
+	maxUnavailable, err := intstr.GetValueFromIntOrPercent(m.Spec.MaxUnavailableDuringUpgrade, len(machines.Items), false)
+	if err != nil {
+		return errors.Wrapf(err, "invalid MaxUnavailableDuringUpgrade for Machine %q in namespace %q", m.Name, m.Namespace)
+	}
+
+	unavailable := 0
+	for i := range machines.Items {
+		if machines.Items[i].Name != m.Name && isUnavailable(&machines.Items[i]) {
+			unavailable++
+		}
+	}
+
+	// Upgrading this Machine makes it unavailable too.
+	if unavailable+1 > maxUnavailable {
+		conditions.MarkFalse(&m.Status.Conditions, clusterv1.UpgradeAllowedCondition, clusterv1.MaxUnavailableReachedReason, clusterv1.ConditionSeverityInfo,
+			"%d of %d Machines in the Cluster are unavailable, maxUnavailable is %d", unavailable, len(machines.Items), maxUnavailable)
+		return errors.Wrapf(&capierrors.RequeueAfterError{RequeueAfter: 30 * time.Second},
+			"upgrade of Machine %q in namespace %q is blocked by maxUnavailableDuringUpgrade, requeuing", m.Name, m.Namespace)
+	}
+
+	conditions.MarkTrue(&m.Status.Conditions, clusterv1.UpgradeAllowedCondition)
+	return nil
+}
+
+// isUpgrading returns true if the Machine has a Node reporting a version other than Spec.Version.
+// The versions are compared as semantic versions, so that e.g. "1.17.3" and "v1.17.3" are the same version;
+// they are compared as strings if either of them can't be parsed.
+func isUpgrading(m *clusterv1.Machine) bool {
+	if m.Spec.Version == nil || m.Status.Version == nil {
+		return false
+	}
+	desired, err := util.ParseMajorMinorPatchWithMetadata(*m.Spec.Version)
+	if err != nil {
+		return *m.Spec.Version != *m.Status.Version
+	}
+	current, err := util.ParseMajorMinorPatchWithMetadata(*m.Status.Version)
+	if err != nil {
+		return *m.Spec.Version != *m.Status.Version
+	}
+	return !desired.Equals(current)
+}
+
+// isUnavailable returns true if the Machine is not running with a healthy Node, is being deleted,
+// or has been allowed to upgrade and has not completed the upgrade yet.
+func isUnavailable(m *clusterv1.Machine) bool {
+	if !m.DeletionTimestamp.IsZero() {
+		return true
+	}
+	if m.Status.GetTypedPhase() != clusterv1.MachinePhaseRunning || !conditions.IsTrue(m.Status.Conditions, clusterv1.MachineNodeHealthyCondition) {
+		return true
+	}
+	return isUpgrading(m) && conditions.IsTrue(m.Status.Conditions, clusterv1.UpgradeAllowedCondition)
+}
+
 // reconcileExternal handles generic unstructured objects referenced by a Machine.
-func (r *MachineReconciler) reconcileExternal(ctx context.Context, cluster *clusterv1.Cluster, m *clusterv1.Machine, ref *corev1.ObjectReference) (external.ReconcileOutput, error) {
-	logger := r.Log.WithValues("machine", m.Name, "namespace", m.Namespace)
+func (r *MachineReconciler) reconcileExternal(ctx context.Context, cluster *clusterv1.Cluster, m *clusterv1.Machine, ref *corev1.ObjectReference, readyCondition clusterv1.ConditionType) (external.ReconcileOutput, error) {
+	logger := r.Log.WithValues(LogFields(m)...)
 
 	if err := utilconversion.ConvertReferenceAPIContract(ctx, r.Client, ref); err != nil {
 		return external.ReconcileOutput{}, err
 	}
 
-	obj, err := external.Get(ctx, r.Client, ref, m.Namespace)
+	obj, err := external.Get(ctx, r.Client, ref, externalRefNamespace(m, ref))
 	if err != nil {
 		if apierrors.IsNotFound(errors.Cause(err)) {
-			return external.ReconcileOutput{}, errors.Wrapf(&capierrors.RequeueAfterError{RequeueAfter: externalReadyWait},
-				"could not find %v %q for Machine %q in namespace %q, requeuing",
-				ref.GroupVersionKind(), ref.Name, m.Name, m.Namespace)
+			return external.ReconcileOutput{}, errors.Wrapf(err, "could not find %v %q in namespace %q for Machine %q in namespace %q",
+				ref.GroupVersionKind(), ref.Name, externalRefNamespace(m, ref), m.Name, m.Namespace)
 		}
 		return external.ReconcileOutput{}, err
 	}
@@ -114,8 +221,12 @@ func (r *MachineReconciler) reconcileExternal(ctx context.Context, cluster *clus
 	}
 
 	// Set external object ControllerReference to the Machine.
-	if err := controllerutil.SetControllerReference(m, obj, r.scheme); err != nil {
-		return external.ReconcileOutput{}, err
+	// Owner references can't cross namespaces, so objects in another namespace are deleted
+	// by reconcileDeleteExternal only, and watched through crossNamespaceTracker.
+	if obj.GetNamespace() == m.Namespace {
+		if err := controllerutil.SetControllerReference(m, obj, r.scheme); err != nil {
+			return external.ReconcileOutput{}, err
+		}
 	}
 
 	// Set the Cluster label.
@@ -135,6 +246,14 @@ func (r *MachineReconciler) reconcileExternal(ctx context.Context, cluster *clus
 	if err := r.externalTracker.Watch(logger, obj, &handler.EnqueueRequestForOwner{OwnerType: &clusterv1.Machine{}}); err != nil {
 		return external.ReconcileOutput{}, err
 	}
+	// Objects in another namespace aren't owned by the Machine, map them back to the Machines referencing them.
+	if obj.GetNamespace() != m.Namespace {
+		if err := r.crossNamespaceTracker.Watch(logger, obj, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(r.crossNamespaceInfrastructureToMachines),
+		}); err != nil {
+			return external.ReconcileOutput{}, err
+		}
+	}
 
 	// Set failure reason and message, if any.
 	failureReason, failureMessage, err := external.FailuresFrom(obj)
@@ -151,6 +270,15 @@ func (r *MachineReconciler) reconcileExternal(ctx context.Context, cluster *clus
 				obj.GroupVersionKind(), obj.GetName(), failureMessage),
 		)
 	}
+	if failureReason != "" || failureMessage != "" {
+		conditions.MarkFalse(&m.Status.Conditions, readyCondition, clusterv1.ReferencedResourceFailedReason, clusterv1.ConditionSeverityError,
+			"%s %q reports a failure", obj.GetKind(), obj.GetName())
+	} else if c := conditions.Get(m.Status.Conditions, readyCondition); c != nil && c.Reason == clusterv1.ReferencedResourceFailedReason {
+		// The referenced resource recovered, clear the failure propagated from it.
+		m.Status.FailureReason = nil
+		m.Status.FailureMessage = nil
+		conditions.Delete(&m.Status.Conditions, readyCondition)
+	}
 
 	return external.ReconcileOutput{Result: obj}, nil
 }
@@ -158,23 +286,32 @@ func (r *MachineReconciler) reconcileExternal(ctx context.Context, cluster *clus
 // reconcileBootstrap reconciles the Spec.Bootstrap.ConfigRef object on a Machine.
 func (r *MachineReconciler) reconcileBootstrap(ctx context.Context, cluster *clusterv1.Cluster, m *clusterv1.Machine) error {
 	if m.Spec.Bootstrap.ConfigRef == nil {
+		// The bootstrap data is read from an existing secret referenced by name, if any.
+		// DataSecretName takes precedence over the deprecated inline Data.
+		if m.Spec.Bootstrap.DataSecretName != nil {
+			return r.reconcileBootstrapData(ctx, m)
+		}
 		return nil
 	}
 
 	// Call generic external reconciler if we have an external reference.
-	externalResult, err := r.reconcileExternal(ctx, cluster, m, m.Spec.Bootstrap.ConfigRef)
+	externalResult, err := r.reconcileExternal(ctx, cluster, m, m.Spec.Bootstrap.ConfigRef, clusterv1.BootstrapReadyCondition)
 	if err != nil {
-		return err
+		return requeueIfNotFound(err)
 	}
 	if externalResult.Paused {
 		return nil
 	}
 	bootstrapConfig := externalResult.Result
 
+	// Leave the BootstrapReady condition as is while the bootstrap config reports a failure.
+	if c := conditions.Get(m.Status.Conditions, clusterv1.BootstrapReadyCondition); c != nil && c.Reason == clusterv1.ReferencedResourceFailedReason {
+		return nil
+	}
+
 	// If the bootstrap data is populated, set ready and return.
 	if m.Spec.Bootstrap.DataSecretName != nil {
-		m.Status.BootstrapReady = true
-		return nil
+		return r.reconcileBootstrapData(ctx, m)
 	}
 
 	// If the bootstrap config is being deleted, return early.
@@ -187,6 +324,7 @@ func (r *MachineReconciler) reconcileBootstrap(ctx context.Context, cluster *clu
 	if err != nil {
 		return err
 	} else if !ready {
+		conditions.MarkFalse(&m.Status.Conditions, clusterv1.BootstrapReadyCondition, clusterv1.WaitingForDataSecretReason, clusterv1.ConditionSeverityInfo, "")
 		return errors.Wrapf(&capierrors.RequeueAfterError{RequeueAfter: externalReadyWait},
 			"Bootstrap provider for Machine %q in namespace %q is not ready, requeuing", m.Name, m.Namespace)
 	}
@@ -201,23 +339,236 @@ func (r *MachineReconciler) reconcileBootstrap(ctx context.Context, cluster *clu
 
 	m.Spec.Bootstrap.Data = nil
 	m.Spec.Bootstrap.DataSecretName = pointer.StringPtr(secretName)
+	return r.reconcileBootstrapData(ctx, m)
+}
+
+// reconcileBootstrapData validates the content of the bootstrap data secret against Spec.Bootstrap.Format
+// and sets Status.BootstrapDataHash and the BootstrapReady condition accordingly. Status.BootstrapReady is
+// only set once the data has been read and validated.
+func (r *MachineReconciler) reconcileBootstrapData(ctx context.Context, m *clusterv1.Machine) error {
+	logger := r.Log.WithValues(LogFields(m)...)
+
+	secret := &corev1.Secret{}
+	key := client.ObjectKey{Namespace: m.Namespace, Name: *m.Spec.Bootstrap.DataSecretName}
+	if err := r.bootstrapDataReader().Get(ctx, key, secret); err != nil {
+		if apierrors.IsNotFound(err) {
+			// The secret might not have been created yet, it is read again on a later reconcile.
+			logger.V(3).Info("Bootstrap data secret not found", "secret", key.Name)
+			conditions.MarkFalse(&m.Status.Conditions, clusterv1.BootstrapReadyCondition, clusterv1.WaitingForDataSecretReason, clusterv1.ConditionSeverityInfo,
+				"Bootstrap data secret %q not found", key.Name)
+			return errors.Wrapf(&capierrors.RequeueAfterError{RequeueAfter: externalReadyWait},
+				"Bootstrap data secret for Machine %q in namespace %q not found, requeuing", m.Name, m.Namespace)
+		}
+		return errors.Wrapf(err, "failed to retrieve bootstrap data secret for Machine %q in namespace %q", m.Name, m.Namespace)
+	}
+
+	value, ok := secret.Data["value"]
+	if !ok {
+		return r.markInvalidBootstrapData(m, fmt.Sprintf("Bootstrap data secret %q is missing the value key", key.Name))
+	}
+
+	if err := validateBootstrapData(m.Spec.Bootstrap.Format, value); err != nil {
+		return r.markInvalidBootstrapData(m, fmt.Sprintf("Bootstrap data secret %q is invalid: %v", key.Name, err))
+	}
+
+	// Keep the hash of the data the infrastructure was provisioned with while the Machine is to be re-provisioned,
+	// so that the change is still detected if reconcileReProvision deferred it.
+	hash := util.BootstrapDataHash(value)
+	if m.Status.BootstrapDataHash == "" || !isReProvisionCandidate(m) {
+		m.Status.BootstrapDataHash = hash
+	}
 	m.Status.BootstrapReady = true
+	conditions.MarkTrue(&m.Status.Conditions, clusterv1.BootstrapReadyCondition)
 	return nil
 }
 
+// bootstrapDataReader returns the reader of the bootstrap data secrets. Secrets are read uncached,
+// so that the Machine controller doesn't start a Secret informer.
+func (r *MachineReconciler) bootstrapDataReader() client.Reader {
+	if r.apiReader == nil {
+		return r.Client
+	}
+	return r.apiReader
+}
+
+// markInvalidBootstrapData sets Status.BootstrapReady and the BootstrapReady condition to false with
+// InvalidBootstrapDataReason, and returns the error to stop the provisioning of the Machine.
+// The Warning event is only emitted when the condition changes, not on every reconcile of the same secret.
+func (r *MachineReconciler) markInvalidBootstrapData(m *clusterv1.Machine, message string) error {
+	if c := conditions.Get(m.Status.Conditions, clusterv1.BootstrapReadyCondition); c == nil ||
+		c.Reason != clusterv1.InvalidBootstrapDataReason || c.Message != message {
+		r.recorder.Event(m, corev1.EventTypeWarning, clusterv1.InvalidBootstrapDataReason, message)
+	}
+	m.Status.BootstrapReady = false
+	conditions.MarkFalse(&m.Status.Conditions, clusterv1.BootstrapReadyCondition, clusterv1.InvalidBootstrapDataReason, clusterv1.ConditionSeverityError, "%s", message)
+	return errors.Errorf("invalid bootstrap data for Machine %q in namespace %q: %s", m.Name, m.Namespace, message)
+}
+
+// propagateInfrastructureLabels copies the labels listed in Spec.PropagatedInfrastructureLabels
+// from the infrastructure object to the Machine.
+func propagateInfrastructureLabels(infraConfig *unstructured.Unstructured, m *clusterv1.Machine) {
+	infraLabels := infraConfig.GetLabels()
+	for _, key := range m.Spec.PropagatedInfrastructureLabels {
+		value, ok := infraLabels[key]
+		if !ok {
+			continue
+		}
+		if m.Labels == nil {
+			m.Labels = make(map[string]string)
+		}
+		m.Labels[key] = value
+	}
+}
+
+// reconcileReProvision deletes the Machine, so that its MachineSet recreates it, if Spec.Bootstrap.ReProvisionOnChange
+// is set and the bootstrap data changed after the infrastructure was provisioned with it.
+// Only the worker Machines owned by a MachineSet are re-provisioned, one at a time per MachineSet: a Machine waits,
+// with a RequeueAfterError, while another Machine of its MachineSet is being deleted or is not available.
+// It returns true if the Machine has been deleted.
+func (r *MachineReconciler) reconcileReProvision(ctx context.Context, m *clusterv1.Machine) (bool, error) {
+	if !isReProvisionCandidate(m) {
+		return false, nil
+	}
+
+	changed, err := util.BootstrapDataChanged(ctx, r.bootstrapDataReader(), m)
+	if err != nil {
+		if apierrors.IsNotFound(errors.Cause(err)) {
+			// The secret might have been deleted, reconcileBootstrapData waits for it.
+			return false, nil
+		}
+		return false, err
+	}
+	if !changed {
+		return false, nil
+	}
+
+	ms, err := util.GetOwnerMachineSet(ctx, r.Client, m.ObjectMeta)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, errors.Wrapf(err, "failed to get the MachineSet of Machine %q in namespace %q", m.Name, m.Namespace)
+	}
+	if !ms.DeletionTimestamp.IsZero() {
+		return false, nil
+	}
+	busy, err := r.isMachineSetReProvisioning(ctx, ms, m)
+	if err != nil {
+		return false, err
+	}
+	if busy {
+		return false, &capierrors.RequeueAfterError{RequeueAfter: reProvisionRetryPeriod}
+	}
+
+	r.recorder.Eventf(m, corev1.EventTypeNormal, "ReProvisioning",
+		"Bootstrap data secret %q changed, deleting the Machine to re-provision it", *m.Spec.Bootstrap.DataSecretName)
+	if err := r.Client.Delete(ctx, m); err != nil && !apierrors.IsNotFound(err) {
+		return false, errors.Wrapf(err, "failed to delete Machine %q in namespace %q to re-provision it", m.Name, m.Namespace)
+	}
+	return true, nil
+}
+
+// isReProvisionCandidate returns true if the Machine is re-provisioned when its bootstrap data changes.
+// Standalone Machines wouldn't be recreated, and control plane Machines are rolled out by their control plane provider.
+func isReProvisionCandidate(m *clusterv1.Machine) bool {
+	return feature.Gates.Enabled(feature.ReProvisionOnBootstrapChange) &&
+		m.Spec.Bootstrap.ReProvisionOnChange && m.Status.InfrastructureReady &&
+		!util.IsControlPlaneMachine(m) && util.IsMachineOwnedByMachineSet(m)
+}
+
+// isMachineSetReProvisioning returns true if the MachineSet can't replace one more of its Machines:
+// the number of Machines of the MachineSet, other than m, being deleted, or the number of missing available
+// replicas if higher, must stay below the sum of the MaxSurge and MaxUnavailable of its update strategy.
+// The strategy defaults to replacing the Machines one at a time while all the others are available.
+func (r *MachineReconciler) isMachineSetReProvisioning(ctx context.Context, ms *clusterv1.MachineSet, m *clusterv1.Machine) (bool, error) {
+	var replicas int32
+	if ms.Spec.Replicas != nil {
+		replicas = *ms.Spec.Replicas
+	}
+	strategy := clusterv1.MachineSetUpdateStrategy{}
+	if ms.Spec.UpdateStrategy != nil {
+		strategy = *ms.Spec.UpdateStrategy
+	}
+	surge, unavailable, err := mdutil.ComputeDesiredReplicas(replicas, strategy)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to resolve the update strategy of MachineSet %q in namespace %q", ms.Name, ms.Namespace)
+	}
+
+	machines := &clusterv1.MachineList{}
+	if err := r.Client.List(ctx, machines, client.InNamespace(ms.Namespace), client.MatchingLabels{clusterv1.ClusterLabelName: ms.Spec.ClusterName}); err != nil {
+		return false, errors.Wrapf(err, "failed to list the Machines of MachineSet %q in namespace %q", ms.Name, ms.Namespace)
+	}
+	var replacing int32
+	for i := range machines.Items {
+		sibling := &machines.Items[i]
+		if sibling.Name != m.Name && metav1.IsControlledBy(sibling, ms) && !sibling.DeletionTimestamp.IsZero() {
+			replacing++
+		}
+	}
+	if missing := replicas - ms.Status.AvailableReplicas; missing > replacing {
+		replacing = missing
+	}
+	return replacing >= surge+unavailable, nil
+}
+
+// validateBootstrapData returns an error if data doesn't match the given bootstrap format.
+// An empty format is treated as cloud-init, which accepts many kinds of user data (cloud-config,
+// shell scripts, MIME multipart archives, include files, ...), so only its presence is checked.
+func validateBootstrapData(format clusterv1.MachineBootstrapFormat, data []byte) error {
+	switch format {
+	case "", clusterv1.MachineBootstrapFormatCloudInit:
+		if len(data) == 0 {
+			return errors.Errorf("data in %s format must not be empty", clusterv1.MachineBootstrapFormatCloudInit)
+		}
+	case clusterv1.MachineBootstrapFormatIgnition:
+		if !json.Valid(data) {
+			return errors.Errorf("data in %s format must be valid JSON", clusterv1.MachineBootstrapFormatIgnition)
+		}
+	default:
+		return errors.Errorf("unsupported bootstrap format %q", format)
+	}
+	return nil
+}
+
+// externalRefNamespace returns the namespace of the object referenced by ref, which defaults
+// to the namespace of the Machine.
+func externalRefNamespace(m *clusterv1.Machine, ref *corev1.ObjectReference) string {
+	if ref.Namespace != "" {
+		return ref.Namespace
+	}
+	return m.Namespace
+}
+
+// requeueIfNotFound turns an error caused by an external object not being found into a RequeueAfterError,
+// so that the Machine waits for the object to be created.
+func requeueIfNotFound(err error) error {
+	if apierrors.IsNotFound(errors.Cause(err)) {
+		return errors.Wrapf(&capierrors.RequeueAfterError{RequeueAfter: externalReadyWait}, "%v, requeuing", err)
+	}
+	return err
+}
+
 // reconcileInfrastructure reconciles the Spec.InfrastructureRef object on a Machine.
 func (r *MachineReconciler) reconcileInfrastructure(ctx context.Context, cluster *clusterv1.Cluster, m *clusterv1.Machine) error {
 	// Call generic external reconciler.
-	infraReconcileResult, err := r.reconcileExternal(ctx, cluster, m, &m.Spec.InfrastructureRef)
+	infraReconcileResult, err := r.reconcileExternal(ctx, cluster, m, &m.Spec.InfrastructureRef, clusterv1.InfrastructureReadyCondition)
 	if err != nil {
-		if m.Status.InfrastructureReady && strings.Contains(err.Error(), "could not find") {
+		if m.Status.InfrastructureReady && m.DeletionTimestamp.IsZero() && apierrors.IsNotFound(errors.Cause(err)) {
 			// Infra object went missing after the machine was up and running
-			r.Log.Error(err, "Machine infrastructure reference has been deleted after being ready, setting failure state")
+			r.Log.WithValues(LogFields(m)...).Error(err, "Machine infrastructure reference has been deleted after being ready, setting failure state")
 			m.Status.FailureReason = capierrors.MachineStatusErrorPtr(capierrors.InvalidConfigurationMachineError)
 			m.Status.FailureMessage = pointer.StringPtr(fmt.Sprintf("Machine infrastructure resource %v with name %q has been deleted after being ready",
 				m.Spec.InfrastructureRef.GroupVersionKind(), m.Spec.InfrastructureRef.Name))
+			// Only report the missing infrastructure once, not on every reconcile of the failed Machine.
+			if c := conditions.Get(m.Status.Conditions, clusterv1.InfrastructureReadyCondition); c == nil || c.Reason != clusterv1.InfrastructureGoneReason {
+				r.recorder.Event(m, corev1.EventTypeWarning, clusterv1.InfrastructureGoneReason, *m.Status.FailureMessage)
+			}
+			conditions.MarkFalse(&m.Status.Conditions, clusterv1.InfrastructureReadyCondition, clusterv1.InfrastructureGoneReason, clusterv1.ConditionSeverityError,
+				"%s", *m.Status.FailureMessage)
+			// The Machine is now in a terminal failed state, there is no point in retrying.
+			return nil
 		}
-		return err
+		return requeueIfNotFound(err)
 	}
 	// if the external object is paused, return without any further processing
 	if infraReconcileResult.Paused {
@@ -229,6 +580,18 @@ func (r *MachineReconciler) reconcileInfrastructure(ctx context.Context, cluster
 		return nil
 	}
 
+	// Wait for the infrastructure provider to observe the current spec of the infrastructure object,
+	// so that its status isn't acted on while it still describes a previous generation. This only applies
+	// until the infrastructure is ready: the generation of objects without a status subresource is
+	// bumped by each status update, so it can keep lagging once the Machine is provisioned.
+	if generation, ok := util.GetObjectGeneration(infraConfig); ok && !m.Status.InfrastructureReady {
+		if observedGeneration, ok := util.GetObservedGeneration(infraConfig); ok && observedGeneration < generation {
+			return errors.Wrapf(&capierrors.RequeueAfterError{RequeueAfter: externalReadyWait},
+				"Infrastructure provider for Machine %q in namespace %q has not observed generation %d yet, requeuing", m.Name, m.Namespace, generation,
+			)
+		}
+	}
+
 	// Determine if the infrastructure provider is ready.
 	ready, err := external.IsReady(infraConfig)
 	if err != nil {
@@ -241,18 +604,22 @@ func (r *MachineReconciler) reconcileInfrastructure(ctx context.Context, cluster
 		)
 	}
 
-	// Get Spec.ProviderID from the infrastructure provider.
+	// Copy the requested labels of the infrastructure object to the Machine.
+	propagateInfrastructureLabels(infraConfig, m)
+
+	// Get Spec.ProviderID from the infrastructure provider, unless the Machine is associated with its Node by name.
 	var providerID string
-	if err := util.UnstructuredUnmarshalField(infraConfig, &providerID, "spec", "providerID"); err != nil {
-		return errors.Wrapf(err, "failed to retrieve Spec.ProviderID from infrastructure provider for Machine %q in namespace %q", m.Name, m.Namespace)
-	} else if providerID == "" {
-		return errors.Errorf("retrieved empty Spec.ProviderID from infrastructure provider for Machine %q in namespace %q", m.Name, m.Namespace)
+	if m.Spec.NodeName == "" {
+		if err := util.UnstructuredUnmarshalField(infraConfig, &providerID, "spec", "providerID"); err != nil {
+			return errors.Wrapf(err, "failed to retrieve Spec.ProviderID from infrastructure provider for Machine %q in namespace %q", m.Name, m.Namespace)
+		} else if providerID == "" {
+			return errors.Errorf("retrieved empty Spec.ProviderID from infrastructure provider for Machine %q in namespace %q", m.Name, m.Namespace)
+		}
 	}
 
 	// Get and set Status.Addresses from the infrastructure provider.
-	err = util.UnstructuredUnmarshalField(infraConfig, &m.Status.Addresses, "status", "addresses")
-	if err != nil && err != util.ErrUnstructuredFieldNotFound {
-		return errors.Wrapf(err, "failed to retrieve addresses from infrastructure provider for Machine %q in namespace %q", m.Name, m.Namespace)
+	if _, err := external.ExtractMachineStatusFromInfrastructure(infraConfig, m); err != nil {
+		return errors.Wrapf(err, "failed to retrieve status from infrastructure provider for Machine %q in namespace %q", m.Name, m.Namespace)
 	}
 
 	// Get and set the failure domain from the infrastructure provider.
@@ -266,6 +633,8 @@ func (r *MachineReconciler) reconcileInfrastructure(ctx context.Context, cluster
 		m.Spec.FailureDomain = pointer.StringPtr(failureDomain)
 	}
 
-	m.Spec.ProviderID = pointer.StringPtr(providerID)
+	if providerID != "" {
+		m.Spec.ProviderID = pointer.StringPtr(providerID)
+	}
 	return nil
 }
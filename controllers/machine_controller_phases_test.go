@@ -18,21 +18,31 @@ package controllers
 
 import (
 	"context"
+	"fmt"
 	"testing"
 	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
 
 	dto "github.com/prometheus/client_model/go"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/utils/pointer"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
 	"sigs.k8s.io/cluster-api/controllers/external"
+	capierrors "sigs.k8s.io/cluster-api/errors"
+	"sigs.k8s.io/cluster-api/feature"
+	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/kubeconfig"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/log"
@@ -46,6 +56,15 @@ var _ = Describe("Reconcile Machine Phases", func() {
 	deletionTimestamp := metav1.Now()
 
 	var defaultKubeconfigSecret *corev1.Secret
+	defaultBootstrapSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "secret-data",
+			Namespace: "default",
+		},
+		Data: map[string][]byte{
+			"value": []byte("#cloud-config\n... data"),
+		},
+	}
 	defaultCluster := &clusterv1.Cluster{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "test-cluster",
@@ -117,6 +136,7 @@ var _ = Describe("Reconcile Machine Phases", func() {
 			Client: fake.NewFakeClientWithScheme(scheme.Scheme,
 				defaultCluster,
 				defaultKubeconfigSecret,
+				defaultBootstrapSecret,
 				machine,
 				external.TestGenericBootstrapCRD,
 				external.TestGenericInfrastructureCRD,
@@ -153,6 +173,7 @@ var _ = Describe("Reconcile Machine Phases", func() {
 			Client: fake.NewFakeClientWithScheme(scheme.Scheme,
 				defaultCluster,
 				defaultKubeconfigSecret,
+				defaultBootstrapSecret,
 				machine,
 				external.TestGenericBootstrapCRD,
 				external.TestGenericInfrastructureCRD,
@@ -194,6 +215,7 @@ var _ = Describe("Reconcile Machine Phases", func() {
 			Client: fake.NewFakeClientWithScheme(scheme.Scheme,
 				defaultCluster,
 				defaultKubeconfigSecret,
+				defaultBootstrapSecret,
 				machine,
 				external.TestGenericBootstrapCRD,
 				external.TestGenericInfrastructureCRD,
@@ -261,6 +283,7 @@ var _ = Describe("Reconcile Machine Phases", func() {
 			Client: fake.NewFakeClientWithScheme(scheme.Scheme,
 				defaultCluster,
 				defaultKubeconfigSecret,
+				defaultBootstrapSecret,
 				machine,
 				external.TestGenericBootstrapCRD,
 				external.TestGenericInfrastructureCRD,
@@ -315,6 +338,7 @@ var _ = Describe("Reconcile Machine Phases", func() {
 			Client: fake.NewFakeClientWithScheme(scheme.Scheme,
 				defaultCluster,
 				defaultKubeconfigSecret,
+				defaultBootstrapSecret,
 				machine,
 				external.TestGenericBootstrapCRD,
 				external.TestGenericInfrastructureCRD,
@@ -380,6 +404,7 @@ var _ = Describe("Reconcile Machine Phases", func() {
 			Client: fake.NewFakeClientWithScheme(scheme.Scheme,
 				defaultCluster,
 				defaultKubeconfigSecret,
+				defaultBootstrapSecret,
 				machine,
 				external.TestGenericBootstrapCRD,
 				external.TestGenericInfrastructureCRD,
@@ -425,6 +450,7 @@ var _ = Describe("Reconcile Machine Phases", func() {
 			Client: fake.NewFakeClientWithScheme(scheme.Scheme,
 				defaultCluster,
 				defaultKubeconfigSecret,
+				defaultBootstrapSecret,
 				machine,
 				external.TestGenericBootstrapCRD,
 				external.TestGenericInfrastructureCRD,
@@ -492,6 +518,7 @@ var _ = Describe("Reconcile Machine Phases", func() {
 			Client: fake.NewFakeClientWithScheme(scheme.Scheme,
 				defaultCluster,
 				defaultKubeconfigSecret,
+				defaultBootstrapSecret,
 				machine,
 				external.TestGenericBootstrapCRD,
 				external.TestGenericInfrastructureCRD,
@@ -542,10 +569,21 @@ func TestReconcileBootstrap(t *testing.T) {
 		},
 	}
 
+	bootstrapSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "secret-data",
+			Namespace: "default",
+		},
+		Data: map[string][]byte{
+			"value": []byte("#!/bin/bash ... data"),
+		},
+	}
+
 	testCases := []struct {
 		name            string
 		bootstrapConfig map[string]interface{}
 		machine         *clusterv1.Machine
+		secretData      string
 		expectError     bool
 		expected        func(g *WithT, m *clusterv1.Machine)
 	}{
@@ -569,6 +607,118 @@ func TestReconcileBootstrap(t *testing.T) {
 				g.Expect(m.Status.BootstrapReady).To(BeTrue())
 				g.Expect(m.Spec.Bootstrap.DataSecretName).ToNot(BeNil())
 				g.Expect(*m.Spec.Bootstrap.DataSecretName).To(ContainSubstring("secret-data"))
+				g.Expect(m.Status.BootstrapDataHash).To(Equal(util.BootstrapDataHash([]byte("#!/bin/bash ... data"))))
+				g.Expect(conditions.IsTrue(m.Status.Conditions, clusterv1.BootstrapReadyCondition)).To(BeTrue())
+			},
+		},
+		{
+			name: "new machine, bootstrap config ready with data not matching the format",
+			bootstrapConfig: map[string]interface{}{
+				"kind":       "BootstrapMachine",
+				"apiVersion": "bootstrap.cluster.x-k8s.io/v1alpha3",
+				"metadata": map[string]interface{}{
+					"name":      "bootstrap-config1",
+					"namespace": "default",
+				},
+				"spec": map[string]interface{}{},
+				"status": map[string]interface{}{
+					"ready":          true,
+					"dataSecretName": "secret-data",
+				},
+			},
+			machine: &clusterv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "bootstrap-test-ignition",
+					Namespace: "default",
+				},
+				Spec: clusterv1.MachineSpec{
+					Bootstrap: clusterv1.Bootstrap{
+						ConfigRef: &corev1.ObjectReference{
+							APIVersion: "bootstrap.cluster.x-k8s.io/v1alpha3",
+							Kind:       "BootstrapMachine",
+							Name:       "bootstrap-config1",
+						},
+						Format: clusterv1.MachineBootstrapFormatIgnition,
+					},
+				},
+				Status: clusterv1.MachineStatus{
+					BootstrapReady: true,
+				},
+			},
+			expectError: true,
+			expected: func(g *WithT, m *clusterv1.Machine) {
+				g.Expect(m.Status.BootstrapReady).To(BeFalse())
+				g.Expect(m.Status.BootstrapDataHash).To(BeEmpty())
+				g.Expect(conditions.IsFalse(m.Status.Conditions, clusterv1.BootstrapReadyCondition)).To(BeTrue())
+				g.Expect(conditions.Get(m.Status.Conditions, clusterv1.BootstrapReadyCondition).Reason).To(Equal(clusterv1.InvalidBootstrapDataReason))
+			},
+		},
+		{
+			name: "new machine, bootstrap config ready with a cloud-init jinja template",
+			bootstrapConfig: map[string]interface{}{
+				"kind":       "BootstrapMachine",
+				"apiVersion": "bootstrap.cluster.x-k8s.io/v1alpha3",
+				"metadata": map[string]interface{}{
+					"name":      "bootstrap-config1",
+					"namespace": "default",
+				},
+				"spec": map[string]interface{}{},
+				"status": map[string]interface{}{
+					"ready":          true,
+					"dataSecretName": "secret-data",
+				},
+			},
+			secretData:  "## template: jinja\n#cloud-config\nruncmd: []",
+			expectError: false,
+			expected: func(g *WithT, m *clusterv1.Machine) {
+				g.Expect(m.Status.BootstrapReady).To(BeTrue())
+				g.Expect(m.Status.BootstrapDataHash).To(Equal(util.BootstrapDataHash([]byte("## template: jinja\n#cloud-config\nruncmd: []"))))
+				g.Expect(conditions.IsTrue(m.Status.Conditions, clusterv1.BootstrapReadyCondition)).To(BeTrue())
+			},
+		},
+		{
+			name: "new machine, bootstrap config ready with cloud-init MIME multipart user data",
+			bootstrapConfig: map[string]interface{}{
+				"kind":       "BootstrapMachine",
+				"apiVersion": "bootstrap.cluster.x-k8s.io/v1alpha3",
+				"metadata": map[string]interface{}{
+					"name":      "bootstrap-config1",
+					"namespace": "default",
+				},
+				"spec": map[string]interface{}{},
+				"status": map[string]interface{}{
+					"ready":          true,
+					"dataSecretName": "secret-data",
+				},
+			},
+			secretData:  "Content-Type: multipart/mixed; boundary=\"==BOUNDARY==\"\nMIME-Version: 1.0\n\n--==BOUNDARY==--",
+			expectError: false,
+			expected: func(g *WithT, m *clusterv1.Machine) {
+				g.Expect(m.Status.BootstrapReady).To(BeTrue())
+				g.Expect(conditions.IsTrue(m.Status.Conditions, clusterv1.BootstrapReadyCondition)).To(BeTrue())
+			},
+		},
+		{
+			name: "new machine, bootstrap config ready with data, secret not found",
+			bootstrapConfig: map[string]interface{}{
+				"kind":       "BootstrapMachine",
+				"apiVersion": "bootstrap.cluster.x-k8s.io/v1alpha3",
+				"metadata": map[string]interface{}{
+					"name":      "bootstrap-config1",
+					"namespace": "default",
+				},
+				"spec": map[string]interface{}{},
+				"status": map[string]interface{}{
+					"ready":          true,
+					"dataSecretName": "secret-data-missing",
+				},
+			},
+			expectError: true,
+			expected: func(g *WithT, m *clusterv1.Machine) {
+				g.Expect(m.Status.BootstrapReady).To(BeFalse())
+				g.Expect(m.Status.BootstrapDataHash).To(BeEmpty())
+				g.Expect(conditions.IsFalse(m.Status.Conditions, clusterv1.BootstrapReadyCondition)).To(BeTrue())
+				g.Expect(conditions.Get(m.Status.Conditions, clusterv1.BootstrapReadyCondition).Reason).To(Equal(clusterv1.WaitingForDataSecretReason))
 			},
 		},
 		{
@@ -606,6 +756,31 @@ func TestReconcileBootstrap(t *testing.T) {
 			expectError: true,
 			expected: func(g *WithT, m *clusterv1.Machine) {
 				g.Expect(m.Status.BootstrapReady).To(BeFalse())
+				g.Expect(conditions.IsFalse(m.Status.Conditions, clusterv1.BootstrapReadyCondition)).To(BeTrue())
+				g.Expect(conditions.Get(m.Status.Conditions, clusterv1.BootstrapReadyCondition).Reason).To(Equal(clusterv1.WaitingForDataSecretReason))
+			},
+		},
+		{
+			name: "new machine, bootstrap config reports a failure",
+			bootstrapConfig: map[string]interface{}{
+				"kind":       "BootstrapMachine",
+				"apiVersion": "bootstrap.cluster.x-k8s.io/v1alpha3",
+				"metadata": map[string]interface{}{
+					"name":      "bootstrap-config1",
+					"namespace": "default",
+				},
+				"spec": map[string]interface{}{},
+				"status": map[string]interface{}{
+					"failureReason":  "InvalidConfiguration",
+					"failureMessage": "failed to render",
+				},
+			},
+			expectError: false,
+			expected: func(g *WithT, m *clusterv1.Machine) {
+				g.Expect(m.Status.BootstrapReady).To(BeFalse())
+				g.Expect(m.Status.FailureReason).To(Equal(capierrors.MachineStatusErrorPtr(capierrors.InvalidConfigurationMachineError)))
+				g.Expect(m.Status.FailureMessage).NotTo(BeNil())
+				g.Expect(conditions.Get(m.Status.Conditions, clusterv1.BootstrapReadyCondition).Reason).To(Equal(clusterv1.ReferencedResourceFailedReason))
 			},
 		},
 		{
@@ -714,6 +889,33 @@ func TestReconcileBootstrap(t *testing.T) {
 			},
 			expectError: true,
 		},
+		{
+			name: "new machine, bootstrap data from an existing secret referenced by name",
+			bootstrapConfig: map[string]interface{}{
+				"kind":       "BootstrapMachine",
+				"apiVersion": "bootstrap.cluster.x-k8s.io/v1alpha3",
+				"metadata": map[string]interface{}{
+					"name":      "bootstrap-config1",
+					"namespace": "default",
+				},
+			},
+			machine: &clusterv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "bootstrap-test-secret-name",
+					Namespace: "default",
+				},
+				Spec: clusterv1.MachineSpec{
+					Bootstrap: clusterv1.Bootstrap{
+						DataSecretName: pointer.StringPtr("secret-data"),
+					},
+				},
+			},
+			expectError: false,
+			expected: func(g *WithT, m *clusterv1.Machine) {
+				g.Expect(m.Status.BootstrapReady).To(BeTrue())
+				g.Expect(m.Status.BootstrapDataHash).To(Equal(util.BootstrapDataHash([]byte("#!/bin/bash ... data"))))
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -727,15 +929,21 @@ func TestReconcileBootstrap(t *testing.T) {
 			}
 
 			bootstrapConfig := &unstructured.Unstructured{Object: tc.bootstrapConfig}
+			secret := bootstrapSecret.DeepCopy()
+			if tc.secretData != "" {
+				secret.Data["value"] = []byte(tc.secretData)
+			}
 			r := &MachineReconciler{
 				Client: fake.NewFakeClientWithScheme(scheme.Scheme,
 					tc.machine,
 					external.TestGenericBootstrapCRD,
 					external.TestGenericInfrastructureCRD,
 					bootstrapConfig,
+					secret,
 				),
-				Log:    log.Log,
-				scheme: scheme.Scheme,
+				Log:      log.Log,
+				scheme:   scheme.Scheme,
+				recorder: record.NewFakeRecorder(10),
 			}
 
 			err := r.reconcileBootstrap(context.Background(), defaultCluster, tc.machine)
@@ -752,85 +960,732 @@ func TestReconcileBootstrap(t *testing.T) {
 	}
 }
 
-func TestReconcileInfrastructure(t *testing.T) {
-	defaultMachine := clusterv1.Machine{
+func TestReconcileBootstrapDataInvalidSecret(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(clusterv1.AddToScheme(scheme.Scheme)).To(Succeed())
+
+	machine := &clusterv1.Machine{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      "machine-test",
+			Name:      "bootstrap-test-invalid-secret",
 			Namespace: "default",
-			Labels: map[string]string{
-				clusterv1.ClusterLabelName: "test-cluster",
-			},
 		},
 		Spec: clusterv1.MachineSpec{
 			Bootstrap: clusterv1.Bootstrap{
-				ConfigRef: &corev1.ObjectReference{
-					APIVersion: "bootstrap.cluster.x-k8s.io/v1alpha3",
-					Kind:       "BootstrapMachine",
-					Name:       "bootstrap-config1",
-				},
-			},
-			InfrastructureRef: corev1.ObjectReference{
-				APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
-				Kind:       "InfrastructureMachine",
-				Name:       "infra-config1",
+				DataSecretName: pointer.StringPtr("secret-no-value"),
 			},
 		},
 	}
-
-	defaultCluster := &clusterv1.Cluster{
+	secret := &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      "test-cluster",
+			Name:      "secret-no-value",
 			Namespace: "default",
 		},
+		Data: map[string][]byte{
+			"format": []byte("cloud-config"),
+		},
+	}
+
+	apiReader := fake.NewFakeClientWithScheme(scheme.Scheme, secret)
+	recorder := record.NewFakeRecorder(10)
+	r := &MachineReconciler{
+		// The secret is only known to the API reader.
+		Client:    fake.NewFakeClientWithScheme(scheme.Scheme, machine),
+		apiReader: apiReader,
+		Log:       log.Log,
+		scheme:    scheme.Scheme,
+		recorder:  recorder,
 	}
 
+	g.Expect(r.reconcileBootstrap(context.Background(), &clusterv1.Cluster{}, machine)).NotTo(Succeed())
+	g.Expect(machine.Status.BootstrapReady).To(BeFalse())
+	g.Expect(machine.Status.BootstrapDataHash).To(BeEmpty())
+	g.Expect(conditions.IsFalse(machine.Status.Conditions, clusterv1.BootstrapReadyCondition)).To(BeTrue())
+	g.Expect(conditions.Get(machine.Status.Conditions, clusterv1.BootstrapReadyCondition).Reason).To(Equal(clusterv1.InvalidBootstrapDataReason))
+	g.Expect(recorder.Events).To(Receive(ContainSubstring("missing the value key")))
+
+	// The same invalid secret is not reported again.
+	g.Expect(r.reconcileBootstrap(context.Background(), &clusterv1.Cluster{}, machine)).NotTo(Succeed())
+	g.Expect(recorder.Events).NotTo(Receive())
+
+	// Once the secret is fixed, the condition is set back to true.
+	secret.Data["value"] = []byte("#cloud-config\n... data")
+	g.Expect(apiReader.Update(context.Background(), secret)).To(Succeed())
+	g.Expect(r.reconcileBootstrap(context.Background(), &clusterv1.Cluster{}, machine)).To(Succeed())
+	g.Expect(machine.Status.BootstrapReady).To(BeTrue())
+	g.Expect(machine.Status.BootstrapDataHash).To(Equal(util.BootstrapDataHash([]byte("#cloud-config\n... data"))))
+	g.Expect(conditions.IsTrue(machine.Status.Conditions, clusterv1.BootstrapReadyCondition)).To(BeTrue())
+}
+
+func TestValidateBootstrapData(t *testing.T) {
 	testCases := []struct {
-		name               string
-		bootstrapConfig    map[string]interface{}
-		infraConfig        map[string]interface{}
-		machine            *clusterv1.Machine
-		expectError        bool
-		expectChanged      bool
-		expectRequeueAfter bool
-		expected           func(g *WithT, m *clusterv1.Machine)
+		name        string
+		format      clusterv1.MachineBootstrapFormat
+		data        string
+		expectError bool
 	}{
 		{
-			name: "new machine, infrastructure config ready",
-			infraConfig: map[string]interface{}{
-				"kind":       "InfrastructureMachine",
-				"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
-				"metadata": map[string]interface{}{
-					"name":      "infra-config1",
-					"namespace": "default",
-				},
-				"spec": map[string]interface{}{
-					"providerID": "test://id-1",
-				},
-				"status": map[string]interface{}{
-					"ready": true,
-					"addresses": []interface{}{
-						map[string]interface{}{
-							"type":    "InternalIP",
-							"address": "10.0.0.1",
-						},
-						map[string]interface{}{
-							"type":    "InternalIP",
-							"address": "10.0.0.2",
-						},
-					},
-				},
+			name:   "cloud-init is the default format",
+			format: "",
+			data:   "#cloud-config\nruncmd: []",
+		},
+		{
+			name:   "cloud-init with a jinja template header",
+			format: clusterv1.MachineBootstrapFormatCloudInit,
+			data:   "## template: jinja\n#cloud-config\nruncmd: []",
+		},
+		{
+			name:   "cloud-init with a shell script",
+			format: clusterv1.MachineBootstrapFormatCloudInit,
+			data:   "#!/bin/bash\necho hello",
+		},
+		{
+			name:   "cloud-init with a MIME multipart archive",
+			format: clusterv1.MachineBootstrapFormatCloudInit,
+			data:   "Content-Type: multipart/mixed; boundary=\"==BOUNDARY==\"\nMIME-Version: 1.0\n\n--==BOUNDARY==--",
+		},
+		{
+			name:   "cloud-init with an include file",
+			format: clusterv1.MachineBootstrapFormatCloudInit,
+			data:   "#include\nhttps://example.com/user-data",
+		},
+		{
+			name:        "cloud-init without data",
+			format:      clusterv1.MachineBootstrapFormatCloudInit,
+			data:        "",
+			expectError: true,
+		},
+		{
+			name:   "ignition with valid JSON",
+			format: clusterv1.MachineBootstrapFormatIgnition,
+			data:   `{"ignition":{"version":"2.2.0"}}`,
+		},
+		{
+			name:        "ignition with invalid JSON",
+			format:      clusterv1.MachineBootstrapFormatIgnition,
+			data:        "#cloud-config\nruncmd: []",
+			expectError: true,
+		},
+		{
+			name:        "unsupported format",
+			format:      "unknown",
+			data:        "data",
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			err := validateBootstrapData(tc.format, []byte(tc.data))
+			if tc.expectError {
+				g.Expect(err).To(HaveOccurred())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestReconcileReProvision(t *testing.T) {
+	bootstrapSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "secret-data",
+			Namespace: "default",
+		},
+		Data: map[string][]byte{
+			"value": []byte("#cloud-config\n... new data"),
+		},
+	}
+	oldHash := util.BootstrapDataHash([]byte("#cloud-config\n... old data"))
+	newMachineSet := func() *clusterv1.MachineSet {
+		return &clusterv1.MachineSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "ms",
+				Namespace: "default",
+				UID:       "ms-uid",
 			},
-			expectError:   false,
-			expectChanged: true,
-			expected: func(g *WithT, m *clusterv1.Machine) {
-				g.Expect(m.Status.InfrastructureReady).To(BeTrue())
+			Spec: clusterv1.MachineSetSpec{
+				ClusterName: "test-cluster",
+				Replicas:    pointer.Int32Ptr(2),
 			},
+			Status: clusterv1.MachineSetStatus{AvailableReplicas: 2},
+		}
+	}
+	machineSetOwnerRef := metav1.OwnerReference{
+		APIVersion: clusterv1.GroupVersion.String(),
+		Kind:       "MachineSet",
+		Name:       "ms",
+		UID:        "ms-uid",
+		Controller: pointer.BoolPtr(true),
+	}
+
+	testCases := []struct {
+		name                string
+		featureEnabled      bool
+		reProvisionOnChange bool
+		infrastructureReady bool
+		hash                string
+		standalone          bool
+		controlPlane        bool
+		machineSet          func(ms *clusterv1.MachineSet)
+		siblingDeleting     bool
+		expectReProvision   bool
+		expectRequeue       bool
+	}{
+		{
+			name:                "bootstrap data changed",
+			featureEnabled:      true,
+			reProvisionOnChange: true,
+			infrastructureReady: true,
+			hash:                oldHash,
+			expectReProvision:   true,
 		},
 		{
-			name: "ready bootstrap, infra, and nodeRef, machine is running, infra object is deleted, expect failed",
-			machine: &clusterv1.Machine{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:      "machine-test",
+			name:                "bootstrap data changed, feature gate disabled",
+			reProvisionOnChange: true,
+			infrastructureReady: true,
+			hash:                oldHash,
+		},
+		{
+			name:                "bootstrap data changed, re-provisioning not requested",
+			featureEnabled:      true,
+			infrastructureReady: true,
+			hash:                oldHash,
+		},
+		{
+			name:                "bootstrap data changed, infrastructure not provisioned yet",
+			featureEnabled:      true,
+			reProvisionOnChange: true,
+			hash:                oldHash,
+		},
+		{
+			name:                "bootstrap data unchanged",
+			featureEnabled:      true,
+			reProvisionOnChange: true,
+			infrastructureReady: true,
+			hash:                util.BootstrapDataHash([]byte("#cloud-config\n... new data")),
+		},
+		{
+			name:                "bootstrap data changed, standalone Machine",
+			featureEnabled:      true,
+			reProvisionOnChange: true,
+			infrastructureReady: true,
+			hash:                oldHash,
+			standalone:          true,
+		},
+		{
+			name:                "bootstrap data changed, control plane Machine",
+			featureEnabled:      true,
+			reProvisionOnChange: true,
+			infrastructureReady: true,
+			hash:                oldHash,
+			controlPlane:        true,
+		},
+		{
+			name:                "bootstrap data changed, MachineSet not fully available",
+			featureEnabled:      true,
+			reProvisionOnChange: true,
+			infrastructureReady: true,
+			hash:                oldHash,
+			machineSet: func(ms *clusterv1.MachineSet) {
+				ms.Status.AvailableReplicas = 1
+			},
+			expectRequeue: true,
+		},
+		{
+			name:                "bootstrap data changed, another Machine of the MachineSet being deleted",
+			featureEnabled:      true,
+			reProvisionOnChange: true,
+			infrastructureReady: true,
+			hash:                oldHash,
+			siblingDeleting:     true,
+			expectRequeue:       true,
+		},
+		{
+			name:                "bootstrap data changed, another Machine of the MachineSet being deleted within maxUnavailable",
+			featureEnabled:      true,
+			reProvisionOnChange: true,
+			infrastructureReady: true,
+			hash:                oldHash,
+			machineSet: func(ms *clusterv1.MachineSet) {
+				ms.Status.AvailableReplicas = 1
+				maxUnavailable := intstr.FromInt(1)
+				ms.Spec.UpdateStrategy = &clusterv1.MachineSetUpdateStrategy{MaxUnavailable: &maxUnavailable}
+			},
+			siblingDeleting:   true,
+			expectReProvision: true,
+		},
+		{
+			name:                "bootstrap data changed, another Machine of the MachineSet being deleted without surge",
+			featureEnabled:      true,
+			reProvisionOnChange: true,
+			infrastructureReady: true,
+			hash:                oldHash,
+			machineSet: func(ms *clusterv1.MachineSet) {
+				maxSurge, maxUnavailable := intstr.FromInt(0), intstr.FromString("50%")
+				ms.Spec.UpdateStrategy = &clusterv1.MachineSetUpdateStrategy{MaxSurge: &maxSurge, MaxUnavailable: &maxUnavailable}
+			},
+			siblingDeleting: true,
+			expectRequeue:   true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			g.Expect(feature.MutableGates.Set(fmt.Sprintf("%s=%t", feature.ReProvisionOnBootstrapChange, tc.featureEnabled))).To(Succeed())
+			defer func() {
+				g.Expect(feature.MutableGates.Set(fmt.Sprintf("%s=false", feature.ReProvisionOnBootstrapChange))).To(Succeed())
+			}()
+
+			machine := &clusterv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:            "machine-test",
+					Namespace:       "default",
+					Labels:          map[string]string{clusterv1.ClusterLabelName: "test-cluster"},
+					OwnerReferences: []metav1.OwnerReference{machineSetOwnerRef},
+				},
+				Spec: clusterv1.MachineSpec{
+					ClusterName: "test-cluster",
+					Bootstrap: clusterv1.Bootstrap{
+						DataSecretName:      pointer.StringPtr("secret-data"),
+						ReProvisionOnChange: tc.reProvisionOnChange,
+					},
+				},
+				Status: clusterv1.MachineStatus{
+					InfrastructureReady: tc.infrastructureReady,
+					BootstrapDataHash:   tc.hash,
+				},
+			}
+			if tc.standalone {
+				machine.OwnerReferences = nil
+			}
+			if tc.controlPlane {
+				machine.Labels[clusterv1.MachineControlPlaneLabelName] = ""
+			}
+			ms := newMachineSet()
+			if tc.machineSet != nil {
+				tc.machineSet(ms)
+			}
+			sibling := &clusterv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:            "sibling",
+					Namespace:       "default",
+					Labels:          map[string]string{clusterv1.ClusterLabelName: "test-cluster"},
+					OwnerReferences: []metav1.OwnerReference{machineSetOwnerRef},
+				},
+				Spec: clusterv1.MachineSpec{ClusterName: "test-cluster"},
+			}
+			if tc.siblingDeleting {
+				now := metav1.Now()
+				sibling.DeletionTimestamp = &now
+			}
+
+			r := &MachineReconciler{
+				Client:   fake.NewFakeClientWithScheme(scheme.Scheme, machine.DeepCopy(), sibling, ms, bootstrapSecret),
+				Log:      log.Log,
+				scheme:   scheme.Scheme,
+				recorder: record.NewFakeRecorder(10),
+			}
+
+			reProvisioned, err := r.reconcileReProvision(context.Background(), machine)
+			if tc.expectRequeue {
+				g.Expect(capierrors.IsRequeueAfter(err)).To(BeTrue())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+			g.Expect(reProvisioned).To(Equal(tc.expectReProvision))
+
+			err = r.Client.Get(context.Background(), util.ObjectKey(machine), &clusterv1.Machine{})
+			if tc.expectReProvision {
+				g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestReconcileReProvisionDeferred(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(feature.MutableGates.Set(fmt.Sprintf("%s=true", feature.ReProvisionOnBootstrapChange))).To(Succeed())
+	defer func() {
+		g.Expect(feature.MutableGates.Set(fmt.Sprintf("%s=false", feature.ReProvisionOnBootstrapChange))).To(Succeed())
+	}()
+
+	oldHash := util.BootstrapDataHash([]byte("#cloud-config\n... old data"))
+	cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "default"}}
+	ms := &clusterv1.MachineSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "ms", Namespace: "default", UID: "ms-uid"},
+		Spec: clusterv1.MachineSetSpec{
+			ClusterName: "test-cluster",
+			Replicas:    pointer.Int32Ptr(2),
+		},
+		Status: clusterv1.MachineSetStatus{AvailableReplicas: 2},
+	}
+	machineSetOwnerRef := metav1.OwnerReference{
+		APIVersion: clusterv1.GroupVersion.String(),
+		Kind:       "MachineSet",
+		Name:       "ms",
+		UID:        "ms-uid",
+		Controller: pointer.BoolPtr(true),
+	}
+	machine := &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "machine-test",
+			Namespace:       "default",
+			Labels:          map[string]string{clusterv1.ClusterLabelName: "test-cluster"},
+			OwnerReferences: []metav1.OwnerReference{machineSetOwnerRef},
+		},
+		Spec: clusterv1.MachineSpec{
+			ClusterName: "test-cluster",
+			Bootstrap: clusterv1.Bootstrap{
+				DataSecretName:      pointer.StringPtr("secret-data"),
+				ReProvisionOnChange: true,
+			},
+			InfrastructureRef: corev1.ObjectReference{
+				APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+				Kind:       "InfrastructureMachine",
+				Name:       "infra-config1",
+			},
+		},
+		Status: clusterv1.MachineStatus{
+			InfrastructureReady: true,
+			BootstrapDataHash:   oldHash,
+		},
+	}
+	now := metav1.Now()
+	sibling := &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "sibling",
+			Namespace:         "default",
+			Labels:            map[string]string{clusterv1.ClusterLabelName: "test-cluster"},
+			OwnerReferences:   []metav1.OwnerReference{machineSetOwnerRef},
+			DeletionTimestamp: &now,
+		},
+		Spec: clusterv1.MachineSpec{ClusterName: "test-cluster"},
+	}
+	// The secret is only readable uncached.
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "secret-data", Namespace: "default"},
+		Data:       map[string][]byte{"value": []byte("#cloud-config\n... new data")},
+	}
+
+	r := &MachineReconciler{
+		Client:    fake.NewFakeClientWithScheme(scheme.Scheme, cluster, ms, machine.DeepCopy(), sibling),
+		Log:       log.Log,
+		apiReader: fake.NewFakeClientWithScheme(scheme.Scheme, secret),
+		scheme:    scheme.Scheme,
+		recorder:  record.NewFakeRecorder(10),
+	}
+
+	// The re-provisioning waits for the sibling being deleted, without recording the new bootstrap data.
+	res, _ := r.reconcile(context.Background(), cluster, machine)
+	g.Expect(res.RequeueAfter).To(BeNumerically(">", 0))
+	g.Expect(machine.Status.BootstrapDataHash).To(Equal(oldHash))
+	g.Expect(r.Client.Get(context.Background(), util.ObjectKey(machine), &clusterv1.Machine{})).To(Succeed())
+
+	// Once the sibling is gone, the pending change re-provisions the Machine.
+	g.Expect(r.Client.Delete(context.Background(), sibling)).To(Succeed())
+	_, err := r.reconcile(context.Background(), cluster, machine)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(apierrors.IsNotFound(r.Client.Get(context.Background(), util.ObjectKey(machine), &clusterv1.Machine{}))).To(BeTrue())
+}
+
+func TestReconcileInfrastructure(t *testing.T) {
+	defaultMachine := clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "machine-test",
+			Namespace: "default",
+			Labels: map[string]string{
+				clusterv1.ClusterLabelName: "test-cluster",
+			},
+		},
+		Spec: clusterv1.MachineSpec{
+			Bootstrap: clusterv1.Bootstrap{
+				ConfigRef: &corev1.ObjectReference{
+					APIVersion: "bootstrap.cluster.x-k8s.io/v1alpha3",
+					Kind:       "BootstrapMachine",
+					Name:       "bootstrap-config1",
+				},
+			},
+			InfrastructureRef: corev1.ObjectReference{
+				APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+				Kind:       "InfrastructureMachine",
+				Name:       "infra-config1",
+			},
+		},
+	}
+
+	defaultCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-cluster",
+			Namespace: "default",
+		},
+	}
+
+	testCases := []struct {
+		name               string
+		bootstrapConfig    map[string]interface{}
+		infraConfig        map[string]interface{}
+		machine            *clusterv1.Machine
+		expectError        bool
+		expectChanged      bool
+		expectRequeueAfter bool
+		expected           func(g *WithT, m *clusterv1.Machine)
+	}{
+		{
+			name: "new machine, infrastructure config ready",
+			infraConfig: map[string]interface{}{
+				"kind":       "InfrastructureMachine",
+				"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
+				"metadata": map[string]interface{}{
+					"name":      "infra-config1",
+					"namespace": "default",
+				},
+				"spec": map[string]interface{}{
+					"providerID": "test://id-1",
+				},
+				"status": map[string]interface{}{
+					"ready": true,
+					"addresses": []interface{}{
+						map[string]interface{}{
+							"type":    "InternalIP",
+							"address": "10.0.0.1",
+						},
+						map[string]interface{}{
+							"type":    "InternalIP",
+							"address": "10.0.0.2",
+						},
+					},
+				},
+			},
+			expectError:   false,
+			expectChanged: true,
+			expected: func(g *WithT, m *clusterv1.Machine) {
+				g.Expect(m.Status.InfrastructureReady).To(BeTrue())
+			},
+		},
+		{
+			name: "new machine, infrastructure config ready but not reconciled at its current generation",
+			infraConfig: map[string]interface{}{
+				"kind":       "InfrastructureMachine",
+				"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
+				"metadata": map[string]interface{}{
+					"name":       "infra-config1",
+					"namespace":  "default",
+					"generation": int64(2),
+				},
+				"spec": map[string]interface{}{
+					"providerID": "test://id-1",
+				},
+				"status": map[string]interface{}{
+					"ready":              true,
+					"observedGeneration": int64(1),
+				},
+			},
+			expectError:        true,
+			expectRequeueAfter: true,
+			expected: func(g *WithT, m *clusterv1.Machine) {
+				g.Expect(m.Status.InfrastructureReady).To(BeFalse())
+				g.Expect(m.Spec.ProviderID).To(BeNil())
+			},
+		},
+		{
+			name: "new machine, infrastructure config ready and reconciled at its current generation",
+			infraConfig: map[string]interface{}{
+				"kind":       "InfrastructureMachine",
+				"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
+				"metadata": map[string]interface{}{
+					"name":       "infra-config1",
+					"namespace":  "default",
+					"generation": int64(2),
+				},
+				"spec": map[string]interface{}{
+					"providerID": "test://id-1",
+				},
+				"status": map[string]interface{}{
+					"ready":              true,
+					"observedGeneration": int64(2),
+				},
+			},
+			expectError:   false,
+			expectChanged: true,
+			expected: func(g *WithT, m *clusterv1.Machine) {
+				g.Expect(m.Status.InfrastructureReady).To(BeTrue())
+			},
+		},
+		{
+			name: "ready machine, infrastructure config not reconciled at its current generation",
+			machine: &clusterv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "machine-test",
+					Namespace: "default",
+				},
+				Spec: clusterv1.MachineSpec{
+					InfrastructureRef: corev1.ObjectReference{
+						APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+						Kind:       "InfrastructureMachine",
+						Name:       "infra-config1",
+					},
+					ProviderID: pointer.StringPtr("test://id-1"),
+				},
+				Status: clusterv1.MachineStatus{
+					InfrastructureReady: true,
+				},
+			},
+			infraConfig: map[string]interface{}{
+				"kind":       "InfrastructureMachine",
+				"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
+				"metadata": map[string]interface{}{
+					"name":       "infra-config1",
+					"namespace":  "default",
+					"generation": int64(3),
+				},
+				"spec": map[string]interface{}{
+					"providerID": "test://id-1",
+				},
+				"status": map[string]interface{}{
+					"ready":              true,
+					"observedGeneration": int64(2),
+				},
+			},
+			expectError: false,
+			expected: func(g *WithT, m *clusterv1.Machine) {
+				g.Expect(m.Status.InfrastructureReady).To(BeTrue())
+				g.Expect(m.Spec.ProviderID).To(Equal(pointer.StringPtr("test://id-1")))
+			},
+		},
+		{
+			name: "new machine with a node name, infrastructure config ready without provider ID",
+			machine: &clusterv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "machine-test",
+					Namespace: "default",
+				},
+				Spec: clusterv1.MachineSpec{
+					InfrastructureRef: corev1.ObjectReference{
+						APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+						Kind:       "InfrastructureMachine",
+						Name:       "infra-config1",
+					},
+					NodeName: "bare-metal-1",
+				},
+			},
+			infraConfig: map[string]interface{}{
+				"kind":       "InfrastructureMachine",
+				"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
+				"metadata": map[string]interface{}{
+					"name":      "infra-config1",
+					"namespace": "default",
+				},
+				"status": map[string]interface{}{
+					"ready": true,
+				},
+			},
+			expectError:   false,
+			expectChanged: true,
+			expected: func(g *WithT, m *clusterv1.Machine) {
+				g.Expect(m.Status.InfrastructureReady).To(BeTrue())
+				g.Expect(m.Spec.ProviderID).To(BeNil())
+			},
+		},
+		{
+			name: "new machine, infrastructure config ready with labels to propagate",
+			machine: &clusterv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "machine-test",
+					Namespace: "default",
+					Labels: map[string]string{
+						clusterv1.ClusterLabelName: "test-cluster",
+						"role":                     "worker",
+					},
+				},
+				Spec: clusterv1.MachineSpec{
+					InfrastructureRef: corev1.ObjectReference{
+						APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+						Kind:       "InfrastructureMachine",
+						Name:       "infra-config1",
+					},
+					PropagatedInfrastructureLabels: []string{"provider.example.com/zone", "provider.example.com/missing"},
+				},
+			},
+			infraConfig: map[string]interface{}{
+				"kind":       "InfrastructureMachine",
+				"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
+				"metadata": map[string]interface{}{
+					"name":      "infra-config1",
+					"namespace": "default",
+					"labels": map[string]interface{}{
+						"provider.example.com/zone": "us-east-1a",
+						"provider.example.com/rack": "rack-1",
+						"role":                      "infra",
+					},
+				},
+				"spec": map[string]interface{}{
+					"providerID": "test://id-1",
+				},
+				"status": map[string]interface{}{
+					"ready": true,
+				},
+			},
+			expectError:   false,
+			expectChanged: true,
+			expected: func(g *WithT, m *clusterv1.Machine) {
+				g.Expect(m.Status.InfrastructureReady).To(BeTrue())
+				g.Expect(m.Labels).To(Equal(map[string]string{
+					clusterv1.ClusterLabelName:  "test-cluster",
+					"role":                      "worker",
+					"provider.example.com/zone": "us-east-1a",
+				}))
+			},
+		},
+		{
+			name: "new machine, infrastructure config ready in another namespace",
+			machine: &clusterv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "machine-test",
+					Namespace: "default",
+				},
+				Spec: clusterv1.MachineSpec{
+					InfrastructureRef: corev1.ObjectReference{
+						APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+						Kind:       "InfrastructureMachine",
+						Name:       "infra-config1",
+						Namespace:  "infra",
+					},
+				},
+			},
+			infraConfig: map[string]interface{}{
+				"kind":       "InfrastructureMachine",
+				"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
+				"metadata": map[string]interface{}{
+					"name":      "infra-config1",
+					"namespace": "infra",
+				},
+				"spec": map[string]interface{}{
+					"providerID": "test://id-1",
+				},
+				"status": map[string]interface{}{
+					"ready": true,
+				},
+			},
+			expectError:   false,
+			expectChanged: true,
+			expected: func(g *WithT, m *clusterv1.Machine) {
+				g.Expect(m.Status.InfrastructureReady).To(BeTrue())
+				g.Expect(m.Spec.ProviderID).To(Equal(pointer.StringPtr("test://id-1")))
+			},
+		},
+		{
+			name: "ready bootstrap, infra, and nodeRef, machine is running, infra object is deleted, expect failed",
+			machine: &clusterv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "machine-test",
 					Namespace: "default",
 				},
 				Spec: clusterv1.MachineSpec{
@@ -871,13 +1726,15 @@ func TestReconcileInfrastructure(t *testing.T) {
 				"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
 				"metadata":   map[string]interface{}{},
 			},
-			expectError:        true,
-			expectRequeueAfter: true,
+			expectError:        false,
+			expectRequeueAfter: false,
 			expected: func(g *WithT, m *clusterv1.Machine) {
 				g.Expect(m.Status.InfrastructureReady).To(BeTrue())
 				g.Expect(m.Status.FailureMessage).ToNot(BeNil())
 				g.Expect(m.Status.FailureReason).ToNot(BeNil())
 				g.Expect(m.Status.GetTypedPhase()).To(Equal(clusterv1.MachinePhaseFailed))
+				g.Expect(conditions.IsFalse(m.Status.Conditions, clusterv1.InfrastructureReadyCondition)).To(BeTrue())
+				g.Expect(conditions.Get(m.Status.Conditions, clusterv1.InfrastructureReadyCondition).Reason).To(Equal(clusterv1.InfrastructureGoneReason))
 			},
 		},
 		{
@@ -915,6 +1772,110 @@ func TestReconcileInfrastructure(t *testing.T) {
 				g.Expect(m.Status.InfrastructureReady).To(BeFalse())
 			},
 		},
+		{
+			name: "infrastructure ref reports a failure, expect failure propagated",
+			infraConfig: map[string]interface{}{
+				"kind":       "InfrastructureMachine",
+				"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
+				"metadata": map[string]interface{}{
+					"name":      "infra-config1",
+					"namespace": "default",
+				},
+				"status": map[string]interface{}{
+					"failureReason":  "CreateError",
+					"failureMessage": "failed to create instance",
+				},
+			},
+			expectError:        true,
+			expectRequeueAfter: true,
+			expected: func(g *WithT, m *clusterv1.Machine) {
+				g.Expect(m.Status.FailureReason).To(Equal(capierrors.MachineStatusErrorPtr(capierrors.CreateMachineError)))
+				g.Expect(m.Status.FailureMessage).To(Equal(pointer.StringPtr(
+					"Failure detected from referenced resource infrastructure.cluster.x-k8s.io/v1alpha3, Kind=InfrastructureMachine with name \"infra-config1\": failed to create instance")))
+				g.Expect(m.Status.GetTypedPhase()).To(Equal(clusterv1.MachinePhaseFailed))
+				g.Expect(conditions.Get(m.Status.Conditions, clusterv1.InfrastructureReadyCondition).Reason).To(Equal(clusterv1.ReferencedResourceFailedReason))
+			},
+		},
+		{
+			name: "infrastructure ref recovered from a failure, expect failure cleared",
+			machine: func() *clusterv1.Machine {
+				m := defaultMachine.DeepCopy()
+				m.Status.FailureReason = capierrors.MachineStatusErrorPtr(capierrors.CreateMachineError)
+				m.Status.FailureMessage = pointer.StringPtr("Failure detected from referenced resource infrastructure.cluster.x-k8s.io/v1alpha3, Kind=InfrastructureMachine with name \"infra-config1\": failed to create instance")
+				conditions.MarkFalse(&m.Status.Conditions, clusterv1.InfrastructureReadyCondition, clusterv1.ReferencedResourceFailedReason, clusterv1.ConditionSeverityError, "")
+				return m
+			}(),
+			infraConfig: map[string]interface{}{
+				"kind":       "InfrastructureMachine",
+				"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
+				"metadata": map[string]interface{}{
+					"name":      "infra-config1",
+					"namespace": "default",
+				},
+				"status": map[string]interface{}{},
+			},
+			expectError:        true,
+			expectRequeueAfter: true,
+			expected: func(g *WithT, m *clusterv1.Machine) {
+				g.Expect(m.Status.FailureReason).To(BeNil())
+				g.Expect(m.Status.FailureMessage).To(BeNil())
+				g.Expect(m.Status.GetTypedPhase()).ToNot(Equal(clusterv1.MachinePhaseFailed))
+				g.Expect(conditions.Has(m.Status.Conditions, clusterv1.InfrastructureReadyCondition)).To(BeFalse())
+			},
+		},
+		{
+			name: "infrastructure config no longer ready, expect infrastructure ready unset",
+			machine: func() *clusterv1.Machine {
+				m := defaultMachine.DeepCopy()
+				m.Status.InfrastructureReady = true
+				return m
+			}(),
+			infraConfig: map[string]interface{}{
+				"kind":       "InfrastructureMachine",
+				"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
+				"metadata": map[string]interface{}{
+					"name":      "infra-config1",
+					"namespace": "default",
+				},
+				"spec": map[string]interface{}{
+					"providerID": "test://id-1",
+				},
+				"status": map[string]interface{}{
+					"ready": false,
+				},
+			},
+			expectError:        true,
+			expectRequeueAfter: true,
+			expected: func(g *WithT, m *clusterv1.Machine) {
+				g.Expect(m.Status.InfrastructureReady).To(BeFalse())
+			},
+		},
+		{
+			name: "infrastructure ref has no failure, expect failure from another source preserved",
+			machine: func() *clusterv1.Machine {
+				m := defaultMachine.DeepCopy()
+				m.Status.FailureReason = capierrors.MachineStatusErrorPtr(capierrors.CreateMachineError)
+				m.Status.FailureMessage = pointer.StringPtr("Failure detected from referenced resource bootstrap.cluster.x-k8s.io/v1alpha3, Kind=BootstrapMachine with name \"bootstrap-config1\": failed to render")
+				conditions.MarkFalse(&m.Status.Conditions, clusterv1.BootstrapReadyCondition, clusterv1.ReferencedResourceFailedReason, clusterv1.ConditionSeverityError, "")
+				return m
+			}(),
+			infraConfig: map[string]interface{}{
+				"kind":       "InfrastructureMachine",
+				"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
+				"metadata": map[string]interface{}{
+					"name":      "infra-config1",
+					"namespace": "default",
+				},
+				"status": map[string]interface{}{},
+			},
+			expectError:        true,
+			expectRequeueAfter: true,
+			expected: func(g *WithT, m *clusterv1.Machine) {
+				g.Expect(m.Status.FailureReason).ToNot(BeNil())
+				g.Expect(m.Status.FailureMessage).ToNot(BeNil())
+				g.Expect(m.Status.GetTypedPhase()).To(Equal(clusterv1.MachinePhaseFailed))
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -935,8 +1896,9 @@ func TestReconcileInfrastructure(t *testing.T) {
 					external.TestGenericInfrastructureCRD,
 					infraConfig,
 				),
-				Log:    log.Log,
-				scheme: scheme.Scheme,
+				Log:      log.Log,
+				scheme:   scheme.Scheme,
+				recorder: record.NewFakeRecorder(10),
 			}
 
 			err := r.reconcileInfrastructure(context.Background(), defaultCluster, tc.machine)
@@ -962,3 +1924,306 @@ func getMetricFamily(list []*dto.MetricFamily, metricName string) *dto.MetricFam
 	}
 	return nil
 }
+
+func TestReconcileInfrastructureGoneEvent(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(clusterv1.AddToScheme(scheme.Scheme)).To(Succeed())
+
+	machine := &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "machine-test",
+			Namespace: "default",
+		},
+		Spec: clusterv1.MachineSpec{
+			InfrastructureRef: corev1.ObjectReference{
+				APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+				Kind:       "InfrastructureMachine",
+				Name:       "infra-config1",
+			},
+		},
+		Status: clusterv1.MachineStatus{
+			InfrastructureReady: true,
+		},
+	}
+
+	recorder := record.NewFakeRecorder(10)
+	r := &MachineReconciler{
+		Client:   fake.NewFakeClientWithScheme(scheme.Scheme, machine, external.TestGenericInfrastructureCRD),
+		Log:      log.Log,
+		scheme:   scheme.Scheme,
+		recorder: recorder,
+	}
+	cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "default"}}
+
+	g.Expect(r.reconcileInfrastructure(context.Background(), cluster, machine)).To(Succeed())
+	g.Expect(conditions.Get(machine.Status.Conditions, clusterv1.InfrastructureReadyCondition).Reason).To(Equal(clusterv1.InfrastructureGoneReason))
+	g.Expect(recorder.Events).To(Receive(ContainSubstring(clusterv1.InfrastructureGoneReason)))
+
+	// The missing infrastructure is not reported again.
+	g.Expect(r.reconcileInfrastructure(context.Background(), cluster, machine)).To(Succeed())
+	g.Expect(recorder.Events).NotTo(Receive())
+}
+
+func TestReconcilePhaseReadinessGates(t *testing.T) {
+	const (
+		gateA clusterv1.ConditionType = "GateA"
+		gateB clusterv1.ConditionType = "GateB"
+	)
+
+	newMachine := func() *clusterv1.Machine {
+		return &clusterv1.Machine{
+			ObjectMeta: metav1.ObjectMeta{Name: "machine-test", Namespace: "default"},
+			Spec: clusterv1.MachineSpec{
+				ReadinessGates: []clusterv1.MachineReadinessGate{
+					{ConditionType: gateA},
+					{ConditionType: gateB},
+				},
+			},
+			Status: clusterv1.MachineStatus{
+				BootstrapReady:      true,
+				InfrastructureReady: true,
+				NodeRef:             &corev1.ObjectReference{Kind: "Node", Name: "machine-test-node"},
+			},
+		}
+	}
+
+	t.Run("should stay Provisioned while a readiness gate is False", func(t *testing.T) {
+		g := NewWithT(t)
+
+		machine := newMachine()
+		conditions.MarkTrue(&machine.Status.Conditions, gateA)
+		conditions.MarkFalse(&machine.Status.Conditions, gateB, "NotYet", clusterv1.ConditionSeverityInfo, "")
+
+		r := &MachineReconciler{Log: log.Log}
+		r.reconcilePhase(context.Background(), machine)
+
+		g.Expect(machine.Status.GetTypedPhase()).To(Equal(clusterv1.MachinePhaseProvisioned))
+		c := conditions.Get(machine.Status.Conditions, clusterv1.ReadinessGatesReadyCondition)
+		g.Expect(c).ToNot(BeNil())
+		g.Expect(c.Status).To(Equal(corev1.ConditionFalse))
+		g.Expect(c.Reason).To(Equal(clusterv1.WaitingForReadinessGatesReason))
+		g.Expect(c.Message).To(ContainSubstring(string(gateB)))
+		g.Expect(c.Message).ToNot(ContainSubstring(string(gateA)))
+	})
+
+	t.Run("should stay Provisioned while a readiness gate is missing", func(t *testing.T) {
+		g := NewWithT(t)
+
+		machine := newMachine()
+		conditions.MarkTrue(&machine.Status.Conditions, gateA)
+
+		r := &MachineReconciler{Log: log.Log}
+		r.reconcilePhase(context.Background(), machine)
+
+		g.Expect(machine.Status.GetTypedPhase()).To(Equal(clusterv1.MachinePhaseProvisioned))
+		g.Expect(conditions.IsFalse(machine.Status.Conditions, clusterv1.ReadinessGatesReadyCondition)).To(BeTrue())
+	})
+
+	t.Run("should be Running when all readiness gates are True", func(t *testing.T) {
+		g := NewWithT(t)
+
+		machine := newMachine()
+		conditions.MarkTrue(&machine.Status.Conditions, gateA)
+		conditions.MarkTrue(&machine.Status.Conditions, gateB)
+
+		r := &MachineReconciler{Log: log.Log}
+		r.reconcilePhase(context.Background(), machine)
+
+		g.Expect(machine.Status.GetTypedPhase()).To(Equal(clusterv1.MachinePhaseRunning))
+		g.Expect(conditions.IsTrue(machine.Status.Conditions, clusterv1.ReadinessGatesReadyCondition)).To(BeTrue())
+	})
+}
+
+func TestReconcileUpgrade(t *testing.T) {
+	cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "default"}}
+
+	newMachine := func(name, specVersion, nodeVersion string, available bool) *clusterv1.Machine {
+		m := &clusterv1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Labels:    map[string]string{clusterv1.ClusterLabelName: cluster.Name},
+			},
+			Spec: clusterv1.MachineSpec{
+				ClusterName: cluster.Name,
+				Version:     pointer.StringPtr(specVersion),
+			},
+			Status: clusterv1.MachineStatus{
+				Version: pointer.StringPtr(nodeVersion),
+			},
+		}
+		if available {
+			m.Status.SetTypedPhase(clusterv1.MachinePhaseRunning)
+			conditions.MarkTrue(&m.Status.Conditions, clusterv1.MachineNodeHealthyCondition)
+		} else {
+			m.Status.SetTypedPhase(clusterv1.MachinePhaseProvisioned)
+		}
+		return m
+	}
+	maxUnavailable := func(v intstr.IntOrString) *intstr.IntOrString { return &v }
+
+	testCases := []struct {
+		name             string
+		machine          *clusterv1.Machine
+		others           []runtime.Object
+		maxUnavailable   *intstr.IntOrString
+		expectError      bool
+		expectNoCond     bool
+		expectCondStatus corev1.ConditionStatus
+	}{
+		{
+			name:         "does nothing without maxUnavailableDuringUpgrade",
+			machine:      newMachine("machine", "v1.19.1", "v1.18.1", true),
+			others:       []runtime.Object{newMachine("other", "v1.19.1", "v1.18.1", false)},
+			expectNoCond: true,
+		},
+		{
+			name:           "does nothing if the Machine is not upgrading",
+			machine:        newMachine("machine", "v1.19.1", "v1.19.1", true),
+			others:         []runtime.Object{newMachine("other", "v1.19.1", "v1.18.1", false)},
+			maxUnavailable: maxUnavailable(intstr.FromInt(1)),
+			expectNoCond:   true,
+		},
+		{
+			name:             "allows the upgrade if all the other Machines are available",
+			machine:          newMachine("machine", "v1.19.1", "v1.18.1", true),
+			others:           []runtime.Object{newMachine("other", "v1.19.1", "v1.18.1", true)},
+			maxUnavailable:   maxUnavailable(intstr.FromInt(1)),
+			expectCondStatus: corev1.ConditionTrue,
+		},
+		{
+			name:             "blocks the upgrade if too many Machines are unavailable",
+			machine:          newMachine("machine", "v1.19.1", "v1.18.1", true),
+			others:           []runtime.Object{newMachine("other", "v1.18.1", "v1.18.1", false)},
+			maxUnavailable:   maxUnavailable(intstr.FromInt(1)),
+			expectError:      true,
+			expectCondStatus: corev1.ConditionFalse,
+		},
+		{
+			name:    "counts Machines allowed to upgrade as unavailable",
+			machine: newMachine("machine", "v1.19.1", "v1.18.1", true),
+			others: []runtime.Object{
+				func() *clusterv1.Machine {
+					m := newMachine("upgrading", "v1.19.1", "v1.18.1", true)
+					conditions.MarkTrue(&m.Status.Conditions, clusterv1.UpgradeAllowedCondition)
+					return m
+				}(),
+				newMachine("waiting", "v1.19.1", "v1.18.1", true),
+				newMachine("other", "v1.19.1", "v1.19.1", true),
+			},
+			maxUnavailable:   maxUnavailable(intstr.FromString("25%")),
+			expectError:      true,
+			expectCondStatus: corev1.ConditionFalse,
+		},
+		{
+			name:    "allows the upgrade within a percentage of the Machines",
+			machine: newMachine("machine", "v1.19.1", "v1.18.1", true),
+			others: []runtime.Object{
+				func() *clusterv1.Machine {
+					m := newMachine("upgrading", "v1.19.1", "v1.18.1", true)
+					conditions.MarkTrue(&m.Status.Conditions, clusterv1.UpgradeAllowedCondition)
+					return m
+				}(),
+				newMachine("waiting", "v1.19.1", "v1.18.1", true),
+				newMachine("other", "v1.19.1", "v1.19.1", true),
+			},
+			maxUnavailable:   maxUnavailable(intstr.FromString("50%")),
+			expectCondStatus: corev1.ConditionTrue,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			tc.machine.Spec.MaxUnavailableDuringUpgrade = tc.maxUnavailable
+			objs := append([]runtime.Object{cluster, tc.machine}, tc.others...)
+			r := &MachineReconciler{
+				Client: fake.NewFakeClientWithScheme(scheme.Scheme, objs...),
+				Log:    log.Log,
+			}
+
+			err := r.reconcileUpgrade(context.Background(), cluster, tc.machine)
+			if tc.expectError {
+				g.Expect(err).To(HaveOccurred())
+				_, ok := errors.Cause(err).(capierrors.HasRequeueAfterError)
+				g.Expect(ok).To(BeTrue())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+
+			c := conditions.Get(tc.machine.Status.Conditions, clusterv1.UpgradeAllowedCondition)
+			if tc.expectNoCond {
+				g.Expect(c).To(BeNil())
+				return
+			}
+			g.Expect(c).NotTo(BeNil())
+			g.Expect(c.Status).To(Equal(tc.expectCondStatus))
+		})
+	}
+}
+
+func TestIsUpgrading(t *testing.T) {
+	testCases := []struct {
+		name          string
+		specVersion   *string
+		statusVersion *string
+		expected      bool
+	}{
+		{
+			name:          "without a node version",
+			specVersion:   pointer.StringPtr("v1.19.1"),
+			statusVersion: nil,
+			expected:      false,
+		},
+		{
+			name:          "with the same version",
+			specVersion:   pointer.StringPtr("v1.19.1"),
+			statusVersion: pointer.StringPtr("v1.19.1"),
+			expected:      false,
+		},
+		{
+			name:          "with the same version without the leading v",
+			specVersion:   pointer.StringPtr("1.19.1"),
+			statusVersion: pointer.StringPtr("v1.19.1"),
+			expected:      false,
+		},
+		{
+			name:          "with the same version and build metadata",
+			specVersion:   pointer.StringPtr("v1.19.1"),
+			statusVersion: pointer.StringPtr("v1.19.1+vmware.1"),
+			expected:      false,
+		},
+		{
+			name:          "with a different version",
+			specVersion:   pointer.StringPtr("v1.19.1"),
+			statusVersion: pointer.StringPtr("v1.18.1"),
+			expected:      true,
+		},
+		{
+			name:          "with a different pre-release",
+			specVersion:   pointer.StringPtr("v1.19.1"),
+			statusVersion: pointer.StringPtr("v1.19.1-rc.1"),
+			expected:      true,
+		},
+		{
+			name:          "with an invalid version",
+			specVersion:   pointer.StringPtr("latest"),
+			statusVersion: pointer.StringPtr("v1.19.1"),
+			expected:      true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			m := &clusterv1.Machine{
+				Spec:   clusterv1.MachineSpec{Version: tc.specVersion},
+				Status: clusterv1.MachineStatus{Version: tc.statusVersion},
+			}
+			g.Expect(isUpgrading(m)).To(Equal(tc.expected))
+		})
+	}
+}
@@ -17,24 +17,49 @@ limitations under the License.
 package controllers
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
+	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/clock"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
 	"k8s.io/utils/pointer"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
 	"sigs.k8s.io/cluster-api/controllers/external"
+	fakeremote "sigs.k8s.io/cluster-api/controllers/remote/fake"
+	capierrors "sigs.k8s.io/cluster-api/errors"
+	"sigs.k8s.io/cluster-api/feature"
 	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/patch"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	"sigs.k8s.io/controller-runtime/pkg/metrics"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
@@ -221,10 +246,12 @@ func TestMachineOwnerReference(t *testing.T) {
 			m: machineValidCluster,
 			expectedOR: []metav1.OwnerReference{
 				{
-					APIVersion: testCluster.APIVersion,
-					Kind:       testCluster.Kind,
-					Name:       testCluster.Name,
-					UID:        testCluster.UID,
+					APIVersion:         testCluster.APIVersion,
+					Kind:               testCluster.Kind,
+					Name:               testCluster.Name,
+					UID:                testCluster.UID,
+					Controller:         pointer.BoolPtr(false),
+					BlockOwnerDeletion: pointer.BoolPtr(true),
 				},
 			},
 		},
@@ -444,6 +471,598 @@ func TestReconcileRequest(t *testing.T) {
 	}
 }
 
+func TestReconcilePausedMachine(t *testing.T) {
+	g := NewWithT(t)
+
+	bootstrapConfig := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"kind":       "BootstrapMachine",
+			"apiVersion": "bootstrap.cluster.x-k8s.io/v1alpha3",
+			"metadata": map[string]interface{}{
+				"name":      "bootstrap-config1",
+				"namespace": "default",
+			},
+			"status": map[string]interface{}{
+				"ready":          true,
+				"dataSecretName": "secret-data",
+			},
+		},
+	}
+	infraConfig := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"kind":       "InfrastructureMachine",
+			"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
+			"metadata": map[string]interface{}{
+				"name":      "infra-config1",
+				"namespace": "default",
+			},
+		},
+	}
+	testCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-cluster",
+			Namespace: "default",
+		},
+	}
+	machine := &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "paused",
+			Namespace: "default",
+		},
+		Spec: clusterv1.MachineSpec{
+			ClusterName: "test-cluster",
+			Bootstrap: clusterv1.Bootstrap{
+				ConfigRef: &corev1.ObjectReference{
+					APIVersion: "bootstrap.cluster.x-k8s.io/v1alpha3",
+					Kind:       "BootstrapMachine",
+					Name:       "bootstrap-config1",
+				},
+			},
+			InfrastructureRef: corev1.ObjectReference{
+				APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+				Kind:       "InfrastructureMachine",
+				Name:       "infra-config1",
+			},
+			Paused: true,
+		},
+	}
+
+	c := fake.NewFakeClientWithScheme(
+		scheme.Scheme,
+		testCluster,
+		machine,
+		external.TestGenericBootstrapCRD,
+		external.TestGenericInfrastructureCRD,
+		bootstrapConfig,
+		infraConfig,
+	)
+	r := &MachineReconciler{
+		Client: c,
+		Log:    log.Log,
+		scheme: scheme.Scheme,
+	}
+
+	// Change the bootstrap config between reconciles; the paused Machine must not pick it up.
+	for _, secretName := range []string{"secret-data", "secret-data-updated"} {
+		g.Expect(unstructured.SetNestedField(bootstrapConfig.Object, secretName, "status", "dataSecretName")).To(Succeed())
+		g.Expect(c.Update(context.Background(), bootstrapConfig)).To(Succeed())
+
+		result, err := r.Reconcile(reconcile.Request{NamespacedName: util.ObjectKey(machine)})
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(result).To(Equal(reconcile.Result{}))
+
+		paused := &clusterv1.Machine{}
+		g.Expect(c.Get(context.Background(), util.ObjectKey(machine), paused)).To(Succeed())
+		g.Expect(paused.Finalizers).To(BeEmpty())
+		g.Expect(paused.Labels).NotTo(HaveKey(clusterv1.ClusterLabelName))
+		g.Expect(paused.Spec.Bootstrap.DataSecretName).To(BeNil())
+		g.Expect(paused.Status.BootstrapReady).To(BeFalse())
+		g.Expect(paused.Status.Phase).To(BeEmpty())
+		g.Expect(conditions.IsTrue(paused.Status.Conditions, clusterv1.PausedCondition)).To(BeTrue())
+	}
+
+	// Once unpaused, the Machine is reconciled again and the Paused condition is removed.
+	unpaused := &clusterv1.Machine{}
+	g.Expect(c.Get(context.Background(), util.ObjectKey(machine), unpaused)).To(Succeed())
+	unpaused.Spec.Paused = false
+	g.Expect(c.Update(context.Background(), unpaused)).To(Succeed())
+
+	_, err := r.Reconcile(reconcile.Request{NamespacedName: util.ObjectKey(machine)})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(c.Get(context.Background(), util.ObjectKey(machine), unpaused)).To(Succeed())
+	g.Expect(unpaused.Finalizers).To(ContainElement(clusterv1.MachineFinalizer))
+	g.Expect(conditions.Has(unpaused.Status.Conditions, clusterv1.PausedCondition)).To(BeFalse())
+}
+
+// patchCountingClient counts the patches issued.
+type patchCountingClient struct {
+	client.Client
+	patches int
+}
+
+func (c *patchCountingClient) Patch(ctx context.Context, obj runtime.Object, patch client.Patch, opts ...client.PatchOption) error {
+	c.patches++
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func TestReconcileLastSuccessfulReconcileTime(t *testing.T) {
+	testCases := []struct {
+		name         string
+		objects      []runtime.Object
+		expectErr    bool
+		expectUpdate bool
+	}{
+		{
+			name:         "updated when the reconcile succeeds",
+			objects:      []runtime.Object{external.TestGenericBootstrapCRD, external.TestGenericInfrastructureCRD},
+			expectUpdate: true,
+		},
+		{
+			name:      "unchanged when the reconcile returns an error",
+			objects:   []runtime.Object{external.TestGenericBootstrapCRD},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			bootstrapConfig := &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"kind":       "BootstrapMachine",
+					"apiVersion": "bootstrap.cluster.x-k8s.io/v1alpha3",
+					"metadata": map[string]interface{}{
+						"name":      "bootstrap-config1",
+						"namespace": "default",
+					},
+				},
+			}
+			infraConfig := &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"kind":       "InfrastructureMachine",
+					"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
+					"metadata": map[string]interface{}{
+						"name":      "infra-config1",
+						"namespace": "default",
+					},
+				},
+			}
+			testCluster := &clusterv1.Cluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "default"},
+			}
+			lastReconcile := metav1.NewTime(time.Now().Add(-time.Hour).Truncate(time.Second))
+			machine := &clusterv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "machine",
+					Namespace: "default",
+				},
+				Spec: clusterv1.MachineSpec{
+					ClusterName: "test-cluster",
+					Bootstrap: clusterv1.Bootstrap{
+						ConfigRef: &corev1.ObjectReference{
+							APIVersion: "bootstrap.cluster.x-k8s.io/v1alpha3",
+							Kind:       "BootstrapMachine",
+							Name:       "bootstrap-config1",
+						},
+					},
+					InfrastructureRef: corev1.ObjectReference{
+						APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+						Kind:       "InfrastructureMachine",
+						Name:       "infra-config1",
+					},
+				},
+				Status: clusterv1.MachineStatus{
+					LastSuccessfulReconcileTime: &lastReconcile,
+				},
+			}
+
+			objects := append([]runtime.Object{testCluster, machine, bootstrapConfig, infraConfig}, tc.objects...)
+			c := fake.NewFakeClientWithScheme(scheme.Scheme, objects...)
+			r := &MachineReconciler{
+				Client: c,
+				Log:    log.Log,
+				scheme: scheme.Scheme,
+			}
+
+			_, err := r.Reconcile(reconcile.Request{NamespacedName: util.ObjectKey(machine)})
+			if tc.expectErr {
+				g.Expect(err).To(HaveOccurred())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+
+			got := &clusterv1.Machine{}
+			g.Expect(c.Get(ctx, util.ObjectKey(machine), got)).To(Succeed())
+			g.Expect(got.Status.LastSuccessfulReconcileTime).NotTo(BeNil())
+			if tc.expectUpdate {
+				g.Expect(got.Status.LastSuccessfulReconcileTime.After(lastReconcile.Time)).To(BeTrue())
+			} else {
+				g.Expect(got.Status.LastSuccessfulReconcileTime.Equal(&lastReconcile)).To(BeTrue())
+			}
+		})
+	}
+}
+
+// newStatusCacheTestObjects returns the objects of a Machine, named after the given index, that reconciles successfully.
+func newStatusCacheTestObjects(i int) []runtime.Object {
+	bootstrapConfig := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"kind":       "BootstrapMachine",
+			"apiVersion": "bootstrap.cluster.x-k8s.io/v1alpha3",
+			"metadata": map[string]interface{}{
+				"name":      fmt.Sprintf("bootstrap-config-%d", i),
+				"namespace": "default",
+			},
+		},
+	}
+	infraConfig := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"kind":       "InfrastructureMachine",
+			"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
+			"metadata": map[string]interface{}{
+				"name":      fmt.Sprintf("infra-config-%d", i),
+				"namespace": "default",
+			},
+		},
+	}
+	machine := &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("machine-%d", i),
+			Namespace: "default",
+		},
+		Spec: clusterv1.MachineSpec{
+			ClusterName: "test-cluster",
+			Bootstrap: clusterv1.Bootstrap{
+				ConfigRef: &corev1.ObjectReference{
+					APIVersion: "bootstrap.cluster.x-k8s.io/v1alpha3",
+					Kind:       "BootstrapMachine",
+					Name:       fmt.Sprintf("bootstrap-config-%d", i),
+				},
+			},
+			InfrastructureRef: corev1.ObjectReference{
+				APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+				Kind:       "InfrastructureMachine",
+				Name:       fmt.Sprintf("infra-config-%d", i),
+			},
+		},
+	}
+	return []runtime.Object{machine, bootstrapConfig, infraConfig}
+}
+
+func TestReconcileTracing(t *testing.T) {
+	g := NewWithT(t)
+
+	objects := append([]runtime.Object{
+		&clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "default"}},
+		external.TestGenericBootstrapCRD,
+		external.TestGenericInfrastructureCRD,
+	}, newStatusCacheTestObjects(0)...)
+	exporter := tracetest.NewInMemoryExporter()
+	r := &MachineReconciler{
+		Client:         fake.NewFakeClientWithScheme(scheme.Scheme, objects...),
+		Log:            log.Log,
+		scheme:         scheme.Scheme,
+		TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sdktrace.NewSimpleSpanProcessor(exporter))),
+	}
+
+	_, err := r.Reconcile(reconcile.Request{NamespacedName: client.ObjectKey{Namespace: "default", Name: "machine-0"}})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	spans := map[string]tracetest.SpanStub{}
+	for _, span := range exporter.GetSpans() {
+		spans[span.Name] = span
+	}
+	g.Expect(spans).To(HaveLen(5))
+
+	root, ok := spans["machine.reconcile"]
+	g.Expect(ok).To(BeTrue())
+	g.Expect(root.Parent.IsValid()).To(BeFalse())
+	g.Expect(root.Attributes).To(ContainElement(attribute.String("machine.phase", string(clusterv1.MachinePhasePending))))
+
+	for _, name := range []string{
+		"machine.reconcile.bootstrap",
+		"machine.reconcile.infrastructure",
+		"machine.reconcile.noderef",
+		"machine.reconcile.status",
+	} {
+		span, ok := spans[name]
+		g.Expect(ok).To(BeTrue(), "missing span %q", name)
+		g.Expect(span.Parent.SpanID()).To(Equal(root.SpanContext.SpanID()), "span %q isn't a child of the reconcile span", name)
+		g.Expect(span.SpanContext.TraceID()).To(Equal(root.SpanContext.TraceID()))
+		g.Expect(span.Attributes).To(ContainElement(attribute.String("machine.name", "machine-0")))
+		g.Expect(span.Attributes).To(ContainElement(attribute.String("machine.namespace", "default")))
+	}
+
+	// The bootstrap and infrastructure objects aren't ready, the error is recorded on the span of the step.
+	g.Expect(spans["machine.reconcile.bootstrap"].Status.Code).To(Equal(codes.Error))
+	g.Expect(spans["machine.reconcile.status"].Status.Code).To(Equal(codes.Unset))
+}
+
+func TestReconcileMachineStatusCache(t *testing.T) {
+	g := NewWithT(t)
+
+	objects := append([]runtime.Object{
+		&clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "default"}},
+		external.TestGenericBootstrapCRD,
+		external.TestGenericInfrastructureCRD,
+	}, newStatusCacheTestObjects(0)...)
+	fakeClock := clock.NewFakeClock(time.Date(2020, time.June, 1, 12, 0, 0, 0, time.UTC))
+	r := &MachineReconciler{
+		Client: fake.NewFakeClientWithScheme(scheme.Scheme, objects...),
+		Log:    log.Log,
+		scheme: scheme.Scheme,
+		clock:  fakeClock,
+	}
+	key := client.ObjectKey{Namespace: "default", Name: "machine-0"}
+
+	reconcileAndGetTime := func() time.Time {
+		_, err := r.Reconcile(reconcile.Request{NamespacedName: key})
+		g.Expect(err).NotTo(HaveOccurred())
+		got := &clusterv1.Machine{}
+		g.Expect(r.Client.Get(ctx, key, got)).To(Succeed())
+		g.Expect(got.Status.LastSuccessfulReconcileTime).NotTo(BeNil())
+		return got.Status.LastSuccessfulReconcileTime.Time
+	}
+
+	first := reconcileAndGetTime()
+	g.Expect(first).To(BeTemporally("==", fakeClock.Now()))
+
+	// Nothing changed, the status isn't updated.
+	fakeClock.Step(10 * time.Second)
+	g.Expect(reconcileAndGetTime()).To(BeTemporally("==", first))
+
+	// The last successful reconcile time is refreshed after statusRefreshPeriod.
+	fakeClock.Step(statusRefreshPeriod)
+	g.Expect(reconcileAndGetTime()).To(BeTemporally("==", fakeClock.Now()))
+
+	// The cache entry is removed once the Machine is gone.
+	machine := &clusterv1.Machine{}
+	g.Expect(r.Client.Get(ctx, key, machine)).To(Succeed())
+	g.Expect(r.Client.Delete(ctx, machine)).To(Succeed())
+	_, err := r.Reconcile(reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(HaveOccurred())
+	_, ok := r.machineStatusCache.Load(key)
+	g.Expect(ok).To(BeFalse())
+}
+
+// BenchmarkReconcileMachineStatusWrites reconciles 100 Machines every 10 seconds for a minute,
+// and reports the number of writes to the API server.
+func BenchmarkReconcileMachineStatusWrites(b *testing.B) {
+	for _, withCache := range []bool{false, true} {
+		b.Run(fmt.Sprintf("cache=%t", withCache), func(b *testing.B) {
+			writes := 0
+			for n := 0; n < b.N; n++ {
+				objects := []runtime.Object{
+					&clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "default"}},
+					external.TestGenericBootstrapCRD,
+					external.TestGenericInfrastructureCRD,
+				}
+				for i := 0; i < 100; i++ {
+					objects = append(objects, newStatusCacheTestObjects(i)...)
+				}
+				recorder := &recordingClient{Client: fake.NewFakeClientWithScheme(scheme.Scheme, objects...)}
+				fakeClock := clock.NewFakeClock(time.Date(2020, time.June, 1, 12, 0, 0, 0, time.UTC))
+				r := &MachineReconciler{
+					Client: recorder,
+					Log:    log.Log,
+					scheme: scheme.Scheme,
+					clock:  fakeClock,
+				}
+
+				for step := 0; step < 6; step++ {
+					fakeClock.Step(10 * time.Second)
+					for i := 0; i < 100; i++ {
+						if !withCache {
+							r.machineStatusCache.Range(func(key, _ interface{}) bool {
+								r.machineStatusCache.Delete(key)
+								return true
+							})
+						}
+						key := client.ObjectKey{Namespace: "default", Name: fmt.Sprintf("machine-%d", i)}
+						if _, err := r.Reconcile(reconcile.Request{NamespacedName: key}); err != nil {
+							b.Fatal(err)
+						}
+					}
+				}
+				writes += len(recorder.writes)
+			}
+			b.ReportMetric(float64(writes)/float64(b.N), "writes/op")
+		})
+	}
+}
+
+func TestIgnoreLastSuccessfulReconcileTimeUpdates(t *testing.T) {
+	now := metav1.Now()
+	machine := &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{Name: "machine", Namespace: "default", ResourceVersion: "1"},
+	}
+	withUpdate := func(update func(m *clusterv1.Machine)) *clusterv1.Machine {
+		m := machine.DeepCopy()
+		m.ResourceVersion = "2"
+		update(m)
+		return m
+	}
+
+	testCases := []struct {
+		name     string
+		newObj   *clusterv1.Machine
+		expected bool
+	}{
+		{
+			name:     "resync",
+			newObj:   machine.DeepCopy(),
+			expected: true,
+		},
+		{
+			name:     "only lastSuccessfulReconcileTime changed",
+			newObj:   withUpdate(func(m *clusterv1.Machine) { m.Status.LastSuccessfulReconcileTime = &now }),
+			expected: false,
+		},
+		{
+			name: "other fields changed",
+			newObj: withUpdate(func(m *clusterv1.Machine) {
+				m.Status.LastSuccessfulReconcileTime = &now
+				m.Status.Phase = string(clusterv1.MachinePhaseRunning)
+			}),
+			expected: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			e := event.UpdateEvent{ObjectOld: machine, MetaOld: machine, ObjectNew: tc.newObj, MetaNew: tc.newObj}
+			g.Expect(ignoreLastSuccessfulReconcileTimeUpdates.Update(e)).To(Equal(tc.expected))
+		})
+	}
+}
+
+func TestReconcileDefersMachineUpdatedByOthers(t *testing.T) {
+	g := NewWithT(t)
+
+	infraConfig := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"kind":       "InfrastructureMachine",
+			"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
+			"metadata": map[string]interface{}{
+				"name":      "infra-config1",
+				"namespace": "default",
+			},
+		},
+	}
+	testCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-cluster",
+			Namespace: "default",
+		},
+	}
+	machine := &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "updated-by-others",
+			Namespace:       "default",
+			ResourceVersion: "1",
+		},
+		Spec: clusterv1.MachineSpec{
+			ClusterName: "test-cluster",
+			Bootstrap:   clusterv1.Bootstrap{Data: pointer.StringPtr("data")},
+			InfrastructureRef: corev1.ObjectReference{
+				APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+				Kind:       "InfrastructureMachine",
+				Name:       "infra-config1",
+			},
+		},
+	}
+	key := util.ObjectKey(machine)
+
+	c := &patchCountingClient{
+		Client: fake.NewFakeClientWithScheme(scheme.Scheme, testCluster, machine, external.TestGenericInfrastructureCRD, infraConfig),
+	}
+	r := &MachineReconciler{
+		Client: c,
+		Log:    log.Log,
+		scheme: scheme.Scheme,
+	}
+	reconcileMachine := func() reconcile.Result {
+		result, err := r.Reconcile(reconcile.Request{NamespacedName: key})
+		g.Expect(err).NotTo(HaveOccurred())
+		return result
+	}
+	deferred := reconcile.Result{RequeueAfter: updatedByOthersRequeuePeriod}
+
+	// The first reconciliation updates the Machine, the next one can't be attributed to another controller.
+	g.Expect(reconcileMachine()).NotTo(Equal(deferred))
+	g.Expect(reconcileMachine()).NotTo(Equal(deferred))
+
+	// A status update made by another controller defers the reconciliation, once.
+	updated := &clusterv1.Machine{}
+	g.Expect(c.Get(context.Background(), key, updated)).To(Succeed())
+	conditions.MarkTrue(&updated.Status.Conditions, "Other")
+	g.Expect(c.Status().Update(context.Background(), updated)).To(Succeed())
+	c.patches = 0
+	g.Expect(reconcileMachine()).To(Equal(deferred))
+	g.Expect(c.patches).To(BeZero())
+	g.Expect(reconcileMachine()).NotTo(Equal(deferred))
+
+	// A metadata update made by another controller is reconciled immediately.
+	updated = &clusterv1.Machine{}
+	g.Expect(c.Get(context.Background(), key, updated)).To(Succeed())
+	updated.Annotations = map[string]string{"other": "value"}
+	g.Expect(c.Update(context.Background(), updated)).To(Succeed())
+	g.Expect(reconcileMachine()).NotTo(Equal(deferred))
+
+	// The recorded observation is evicted once the Machine is deleted.
+	g.Expect(c.Delete(context.Background(), updated)).To(Succeed())
+	g.Expect(reconcileMachine()).To(Equal(reconcile.Result{}))
+	_, ok := r.observedMachines.Load(key)
+	g.Expect(ok).To(BeFalse())
+}
+
+func TestIsConflict(t *testing.T) {
+	g := NewWithT(t)
+
+	conflict := apierrors.NewConflict(schema.GroupResource{Resource: "machines"}, "machine", errors.New("the object has been modified"))
+	g.Expect(isConflict(conflict)).To(BeTrue())
+	g.Expect(isConflict(errors.Wrap(conflict, "failed to patch"))).To(BeTrue())
+	g.Expect(isConflict(kerrors.NewAggregate([]error{errors.New("other"), conflict}))).To(BeTrue())
+	g.Expect(isConflict(kerrors.NewAggregate([]error{errors.New("other")}))).To(BeFalse())
+	g.Expect(isConflict(apierrors.NewNotFound(schema.GroupResource{Resource: "machines"}, "machine"))).To(BeFalse())
+}
+
+func TestMachineStatusApplyConfiguration(t *testing.T) {
+	g := NewWithT(t)
+
+	original := &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "machine",
+			Namespace:       "default",
+			Labels:          map[string]string{"foo": "bar"},
+			ResourceVersion: "1",
+			ManagedFields: []metav1.ManagedFieldsEntry{
+				{
+					Manager:   MachineManagedFields,
+					Operation: metav1.ManagedFieldsOperationApply,
+					FieldsV1:  &metav1.FieldsV1{Raw: []byte(`{"f:status":{".":{},"f:phase":{}}}`)},
+				},
+				{
+					Manager:   "other-controller",
+					Operation: metav1.ManagedFieldsOperationUpdate,
+					FieldsV1:  &metav1.FieldsV1{Raw: []byte(`{"f:status":{"f:version":{}}}`)},
+				},
+			},
+		},
+		Spec: clusterv1.MachineSpec{ClusterName: "test-cluster"},
+		Status: clusterv1.MachineStatus{
+			Phase:   string(clusterv1.MachinePhaseRunning),
+			Version: pointer.StringPtr("v1.17.0"),
+		},
+	}
+	m := original.DeepCopy()
+	m.Status.BootstrapReady = true
+	conditions.MarkTrue(&m.Status.Conditions, clusterv1.BootstrapReadyCondition)
+
+	obj, err := machineStatusApplyConfiguration(original, m)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(obj.GetAPIVersion()).To(Equal(clusterv1.GroupVersion.String()))
+	g.Expect(obj.GetKind()).To(Equal("Machine"))
+	g.Expect(obj.GetNamespace()).To(Equal("default"))
+	g.Expect(obj.GetName()).To(Equal("machine"))
+
+	// Only the status is applied, without preconditions on the resourceVersion.
+	g.Expect(obj.GetLabels()).To(BeEmpty())
+	g.Expect(obj.GetResourceVersion()).To(BeEmpty())
+	g.Expect(obj.Object).NotTo(HaveKey("spec"))
+
+	// The phase is applied because it is owned by the controller, bootstrapReady because it changed.
+	// The version set by another controller, the unchanged infrastructureReady and the conditions are not applied.
+	g.Expect(obj.Object["status"]).To(Equal(map[string]interface{}{
+		"phase":          "Running",
+		"bootstrapReady": true,
+	}))
+}
+
 func TestReconcileDeleteExternal(t *testing.T) {
 	testCluster := &clusterv1.Cluster{
 		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
@@ -550,17 +1169,245 @@ func TestReconcileDeleteExternal(t *testing.T) {
 				scheme: scheme.Scheme,
 			}
 
-			ok, err := r.reconcileDeleteExternal(ctx, machine)
-			g.Expect(ok).To(Equal(tc.expected))
-			if tc.expectError {
-				g.Expect(err).To(HaveOccurred())
-			} else {
-				g.Expect(err).NotTo(HaveOccurred())
+			ok, err := r.reconcileDeleteExternal(ctx, machine)
+			g.Expect(ok).To(Equal(tc.expected))
+			if tc.expectError {
+				g.Expect(err).To(HaveOccurred())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestReconcileDeleteExternalCrossNamespace(t *testing.T) {
+	g := NewWithT(t)
+
+	infraConfig := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"kind":       "InfrastructureMachine",
+			"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
+			"metadata": map[string]interface{}{
+				"name":      "delete-infra",
+				"namespace": "infra",
+			},
+		},
+	}
+
+	machine := &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "delete",
+			Namespace: "default",
+		},
+		Spec: clusterv1.MachineSpec{
+			ClusterName: "test-cluster",
+			InfrastructureRef: corev1.ObjectReference{
+				APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+				Kind:       "InfrastructureMachine",
+				Name:       "delete-infra",
+				Namespace:  "infra",
+			},
+		},
+	}
+
+	r := &MachineReconciler{
+		Client: fake.NewFakeClientWithScheme(scheme.Scheme, machine, infraConfig),
+		Log:    log.Log,
+		scheme: scheme.Scheme,
+	}
+
+	// The infrastructure object in the other namespace is found and deleted.
+	ok, err := r.reconcileDeleteExternal(ctx, machine)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ok).To(BeFalse())
+
+	ok, err = r.reconcileDeleteExternal(ctx, machine)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ok).To(BeTrue())
+}
+
+func TestReconcileDeleteExternalSkipInfraGC(t *testing.T) {
+	g := NewWithT(t)
+
+	infraConfig := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"kind":       "InfrastructureMachine",
+			"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
+			"metadata": map[string]interface{}{
+				"name":      "delete-infra",
+				"namespace": "default",
+			},
+		},
+	}
+
+	machine := &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "delete",
+			Namespace:   "default",
+			Annotations: map[string]string{clusterv1.SkipInfraGCAnnotation: "true"},
+		},
+		Spec: clusterv1.MachineSpec{
+			ClusterName: "test-cluster",
+			InfrastructureRef: corev1.ObjectReference{
+				APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+				Kind:       "InfrastructureMachine",
+				Name:       "delete-infra",
+			},
+		},
+	}
+
+	r := &MachineReconciler{
+		Client: fake.NewFakeClientWithScheme(scheme.Scheme, machine, infraConfig),
+		Log:    log.Log,
+		scheme: scheme.Scheme,
+	}
+
+	// The infrastructure object is neither deleted nor waited for.
+	ok, err := r.reconcileDeleteExternal(ctx, machine)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ok).To(BeTrue())
+
+	_, err = external.Get(ctx, r.Client, &machine.Spec.InfrastructureRef, machine.Namespace)
+	g.Expect(err).NotTo(HaveOccurred())
+}
+
+func TestReconcileInfrastructureRefNamespace(t *testing.T) {
+	testCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+	}
+
+	infraConfig := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"kind":       "InfrastructureMachine",
+			"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
+			"metadata": map[string]interface{}{
+				"name":      "infra-config1",
+				"namespace": "infra",
+			},
+		},
+	}
+
+	tests := []struct {
+		name          string
+		allowed       clusterv1.CrossNamespaceInfrastructureAllowlist
+		expectFailure bool
+	}{
+		{
+			name:          "cross-namespace infrastructure reference is not allowed",
+			expectFailure: true,
+		},
+		{
+			name:          "cross-namespace infrastructure reference is allowed",
+			allowed:       clusterv1.CrossNamespaceInfrastructureAllowlist{"default": {"infra"}},
+			expectFailure: false,
+		},
+		{
+			name:          "cross-namespace infrastructure reference is only allowed from another namespace",
+			allowed:       clusterv1.CrossNamespaceInfrastructureAllowlist{"other": {"infra"}},
+			expectFailure: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			machine := &clusterv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{Name: "machine-test", Namespace: "default"},
+				Spec: clusterv1.MachineSpec{
+					ClusterName: "test-cluster",
+					InfrastructureRef: corev1.ObjectReference{
+						APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+						Kind:       "InfrastructureMachine",
+						Name:       "infra-config1",
+						Namespace:  "infra",
+					},
+					Bootstrap: clusterv1.Bootstrap{DataSecretName: pointer.StringPtr("data")},
+				},
+			}
+			recorder := record.NewFakeRecorder(10)
+			r := &MachineReconciler{
+				Client: fake.NewFakeClientWithScheme(scheme.Scheme,
+					testCluster, machine,
+					external.TestGenericInfrastructureCRD,
+					infraConfig,
+				),
+				Log:                                 log.Log,
+				scheme:                              scheme.Scheme,
+				recorder:                            recorder,
+				AllowedCrossNamespaceInfrastructure: tt.allowed,
+			}
+
+			_, _ = r.reconcile(ctx, testCluster, machine)
+			r.reconcilePhase(ctx, machine)
+
+			if !tt.expectFailure {
+				g.Expect(machine.Status.FailureReason).To(BeNil())
+				g.Expect(recorder.Events).To(BeEmpty())
+				return
 			}
+			g.Expect(machine.Status.FailureReason).To(Equal(capierrors.MachineStatusErrorPtr(capierrors.InvalidConfigurationMachineError)))
+			g.Expect(machine.Status.FailureMessage).NotTo(BeNil())
+			g.Expect(machine.Status.GetTypedPhase()).To(Equal(clusterv1.MachinePhaseFailed))
+			g.Expect(recorder.Events).To(Receive(ContainSubstring("InvalidInfrastructureRef")))
+
+			// The infrastructure object wasn't adopted.
+			got := &unstructured.Unstructured{}
+			got.SetGroupVersionKind(infraConfig.GroupVersionKind())
+			g.Expect(r.Client.Get(ctx, client.ObjectKey{Namespace: "infra", Name: "infra-config1"}, got)).To(Succeed())
+			g.Expect(got.GetLabels()).To(BeEmpty())
 		})
 	}
 }
 
+func TestCrossNamespaceInfrastructureToMachines(t *testing.T) {
+	g := NewWithT(t)
+
+	infraConfig := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"kind":       "InfrastructureMachine",
+			"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
+			"metadata": map[string]interface{}{
+				"name":      "infra-config1",
+				"namespace": "infra",
+			},
+		},
+	}
+	machine := func(namespace, infraName string) *clusterv1.Machine {
+		return &clusterv1.Machine{
+			ObjectMeta: metav1.ObjectMeta{Name: "machine-" + infraName, Namespace: namespace},
+			Spec: clusterv1.MachineSpec{
+				ClusterName: "test-cluster",
+				InfrastructureRef: corev1.ObjectReference{
+					APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+					Kind:       "InfrastructureMachine",
+					Name:       infraName,
+					Namespace:  "infra",
+				},
+			},
+		}
+	}
+
+	r := &MachineReconciler{
+		Client: fake.NewFakeClientWithScheme(scheme.Scheme,
+			machine("tenant-a", "infra-config1"),
+			machine("tenant-a", "infra-config2"),
+			// Not allowed to reference the infra namespace.
+			machine("tenant-b", "infra-config1"),
+		),
+		Log: log.Log,
+		AllowedCrossNamespaceInfrastructure: clusterv1.CrossNamespaceInfrastructureAllowlist{
+			"tenant-a": {"infra"},
+			"tenant-c": {"other"},
+		},
+	}
+
+	requests := r.crossNamespaceInfrastructureToMachines(handler.MapObject{Meta: infraConfig, Object: infraConfig})
+	g.Expect(requests).To(ConsistOf(reconcile.Request{
+		NamespacedName: client.ObjectKey{Namespace: "tenant-a", Name: "machine-infra-config1"},
+	}))
+}
+
 func TestRemoveMachineFinalizerAfterDeleteReconcile(t *testing.T) {
 	g := NewWithT(t)
 
@@ -600,6 +1447,304 @@ func TestRemoveMachineFinalizerAfterDeleteReconcile(t *testing.T) {
 	g.Expect(m.ObjectMeta.Finalizers).To(Equal([]string{metav1.FinalizerDeleteDependents}))
 }
 
+// conflictingMachinePatchClient fails the first patches of Machines, up to conflicts, with a conflict error.
+type conflictingMachinePatchClient struct {
+	client.Client
+	conflicts int
+	patches   int
+}
+
+func (c *conflictingMachinePatchClient) Patch(ctx context.Context, obj runtime.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if _, ok := obj.(*clusterv1.Machine); ok {
+		c.patches++
+		if c.patches <= c.conflicts {
+			return apierrors.NewConflict(clusterv1.GroupVersion.WithResource("machines").GroupResource(), "delete123", errors.New("conflict"))
+		}
+	}
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func TestRemoveMachineFinalizer(t *testing.T) {
+	tests := []struct {
+		name          string
+		finalizers    []string
+		conflicts     int
+		expectErr     bool
+		expectPatches int
+	}{
+		{
+			name:          "finalizer is removed",
+			finalizers:    []string{clusterv1.MachineFinalizer, metav1.FinalizerDeleteDependents},
+			expectPatches: 1,
+		},
+		{
+			name:          "finalizer is removed after a conflict",
+			finalizers:    []string{clusterv1.MachineFinalizer, metav1.FinalizerDeleteDependents},
+			conflicts:     1,
+			expectPatches: 2,
+		},
+		{
+			name:          "conflict is returned once the retries are exhausted",
+			finalizers:    []string{clusterv1.MachineFinalizer, metav1.FinalizerDeleteDependents},
+			conflicts:     100,
+			expectErr:     true,
+			expectPatches: retry.DefaultRetry.Steps,
+		},
+		{
+			name:          "nothing is patched if the finalizer is already gone",
+			finalizers:    []string{metav1.FinalizerDeleteDependents},
+			expectPatches: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			m := &clusterv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:       "delete123",
+					Namespace:  "default",
+					Finalizers: tt.finalizers,
+				},
+			}
+			c := &conflictingMachinePatchClient{
+				Client:    fake.NewFakeClientWithScheme(scheme.Scheme, m),
+				conflicts: tt.conflicts,
+			}
+			r := &MachineReconciler{
+				Client: c,
+				Log:    log.Log,
+				scheme: scheme.Scheme,
+			}
+
+			err := r.removeMachineFinalizer(ctx, m)
+			g.Expect(c.patches).To(Equal(tt.expectPatches))
+			if tt.expectErr {
+				g.Expect(apierrors.IsConflict(err)).To(BeTrue())
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+
+			got := &clusterv1.Machine{}
+			g.Expect(c.Get(ctx, util.ObjectKey(m), got)).To(Succeed())
+			g.Expect(got.Finalizers).To(Equal([]string{metav1.FinalizerDeleteDependents}))
+		})
+	}
+
+	t.Run("machine is already gone", func(t *testing.T) {
+		g := NewWithT(t)
+
+		r := &MachineReconciler{
+			Client: fake.NewFakeClientWithScheme(scheme.Scheme),
+			Log:    log.Log,
+			scheme: scheme.Scheme,
+		}
+		g.Expect(r.removeMachineFinalizer(ctx, &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "gone", Namespace: "default"}})).To(Succeed())
+	})
+}
+
+// failingNodeDeleteClient fails the deletion of Nodes.
+type failingNodeDeleteClient struct {
+	client.Client
+}
+
+func (c failingNodeDeleteClient) Delete(ctx context.Context, obj runtime.Object, opts ...client.DeleteOption) error {
+	if _, ok := obj.(*corev1.Node); ok {
+		return errors.New("connection refused")
+	}
+	return c.Client.Delete(ctx, obj, opts...)
+}
+
+func TestReconcileDeleteNodeDeletionTimeout(t *testing.T) {
+	now := time.Now()
+	infraDeletedAgo := func(ago time.Duration) *metav1.Time {
+		dt := metav1.NewTime(now.Add(-ago))
+		return &dt
+	}
+
+	testCases := []struct {
+		name                string
+		failNodeDeletion    bool
+		nodeGone            bool
+		nodeDeletionTimeout *metav1.Duration
+		infraDeleted        *metav1.Time
+		expectFinalizer     bool
+		expectNodeDeleted   bool
+	}{
+		{
+			name:            "node is not deleted when the infrastructure is deleted",
+			expectFinalizer: true,
+		},
+		{
+			name:            "node is not deleted right before the default timeout",
+			infraDeleted:    infraDeletedAgo(9 * time.Minute),
+			expectFinalizer: true,
+		},
+		{
+			name:              "node is deleted at the default timeout",
+			infraDeleted:      infraDeletedAgo(10 * time.Minute),
+			expectNodeDeleted: true,
+		},
+		{
+			name:              "node is deleted after the default timeout",
+			infraDeleted:      infraDeletedAgo(11 * time.Minute),
+			expectNodeDeleted: true,
+		},
+		{
+			name:             "node deletion is retried after the default timeout",
+			failNodeDeletion: true,
+			infraDeleted:     infraDeletedAgo(11 * time.Minute),
+			expectFinalizer:  true,
+		},
+		{
+			name:                "node is not deleted before the configured timeout",
+			nodeDeletionTimeout: &metav1.Duration{Duration: time.Hour},
+			infraDeleted:        infraDeletedAgo(11 * time.Minute),
+			expectFinalizer:     true,
+		},
+		{
+			name:                "node is deleted at the configured timeout",
+			nodeDeletionTimeout: &metav1.Duration{Duration: time.Minute},
+			infraDeleted:        infraDeletedAgo(time.Minute),
+			expectNodeDeleted:   true,
+		},
+		{
+			name:              "machine is not held back by a node that is already gone",
+			nodeGone:          true,
+			infraDeleted:      infraDeletedAgo(time.Minute),
+			expectNodeDeleted: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			testCluster := &clusterv1.Cluster{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+			}
+			controlPlane := &clusterv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "control-plane",
+					Namespace: "default",
+					Labels: map[string]string{
+						clusterv1.ClusterLabelName:             "test-cluster",
+						clusterv1.MachineControlPlaneLabelName: "",
+					},
+				},
+				Spec: clusterv1.MachineSpec{ClusterName: "test-cluster"},
+			}
+			m := &clusterv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "delete123",
+					Namespace:         "default",
+					Labels:            map[string]string{clusterv1.ClusterLabelName: "test-cluster"},
+					Annotations:       map[string]string{clusterv1.ExcludeNodeDrainingAnnotation: ""},
+					Finalizers:        []string{clusterv1.MachineFinalizer},
+					DeletionTimestamp: &metav1.Time{Time: now.Add(-time.Hour)},
+				},
+				Spec: clusterv1.MachineSpec{
+					ClusterName: "test-cluster",
+					InfrastructureRef: corev1.ObjectReference{
+						APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+						Kind:       "InfrastructureMachine",
+						Name:       "infra-config1",
+					},
+					Bootstrap:           clusterv1.Bootstrap{Data: pointer.StringPtr("data")},
+					NodeDeletionTimeout: tc.nodeDeletionTimeout,
+				},
+				Status: clusterv1.MachineStatus{
+					NodeRef: &corev1.ObjectReference{Name: "test-node"},
+				},
+			}
+			if tc.infraDeleted != nil {
+				m.Status.Conditions = clusterv1.Conditions{{
+					Type:               clusterv1.InfrastructureReadyCondition,
+					Status:             corev1.ConditionFalse,
+					Severity:           clusterv1.ConditionSeverityInfo,
+					Reason:             clusterv1.DeletedReason,
+					LastTransitionTime: *tc.infraDeleted,
+				}}
+			}
+			objs := []runtime.Object{testCluster, controlPlane, m}
+			if !tc.nodeGone {
+				objs = append(objs, &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "test-node"}})
+			}
+
+			var c client.Client = fake.NewFakeClientWithScheme(scheme.Scheme, objs...)
+			if tc.failNodeDeletion {
+				c = failingNodeDeleteClient{Client: c}
+			}
+			mr := &MachineReconciler{
+				Client:             c,
+				Log:                log.Log,
+				scheme:             scheme.Scheme,
+				recorder:           record.NewFakeRecorder(10),
+				remoteClientGetter: fakeremote.NewClusterClient,
+				clock:              clock.NewFakeClock(now),
+			}
+
+			res, err := mr.reconcileDelete(ctx, testCluster, m)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(conditions.Get(m.Status.Conditions, clusterv1.InfrastructureReadyCondition).Reason).To(Equal(clusterv1.DeletedReason))
+			if tc.infraDeleted == nil {
+				g.Expect(conditions.Get(m.Status.Conditions, clusterv1.InfrastructureReadyCondition).LastTransitionTime.Time).To(Equal(now))
+			}
+
+			err = c.Get(ctx, client.ObjectKey{Name: "test-node"}, &corev1.Node{})
+			if tc.expectNodeDeleted {
+				g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+
+			if tc.expectFinalizer {
+				g.Expect(m.Finalizers).To(ContainElement(clusterv1.MachineFinalizer))
+				g.Expect(res.RequeueAfter).To(BeNumerically(">", 0))
+			} else {
+				g.Expect(m.Finalizers).NotTo(ContainElement(clusterv1.MachineFinalizer))
+				g.Expect(res.RequeueAfter).To(BeZero())
+			}
+		})
+	}
+}
+
+func TestIsNodeDrainSkipped(t *testing.T) {
+	tests := []struct {
+		name     string
+		machine  *clusterv1.Machine
+		expected bool
+	}{
+		{
+			name:     "drain by default",
+			machine:  &clusterv1.Machine{},
+			expected: false,
+		},
+		{
+			name: "skip with the exclude node draining annotation",
+			machine: &clusterv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{clusterv1.ExcludeNodeDrainingAnnotation: ""}},
+			},
+			expected: true,
+		},
+		{
+			name: "skip with spec.ignoreNodeDrain",
+			machine: &clusterv1.Machine{
+				Spec: clusterv1.MachineSpec{IgnoreNodeDrain: true},
+			},
+			expected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(isNodeDrainSkipped(tt.machine)).To(Equal(tt.expected))
+		})
+	}
+}
+
 func TestReconcileMetrics(t *testing.T) {
 	tests := []struct {
 		name            string
@@ -969,3 +2114,247 @@ func TestIsDeleteNodeAllowed(t *testing.T) {
 		})
 	}
 }
+
+func TestReconcileDeleteNoDrainTaints(t *testing.T) {
+	testCases := []struct {
+		name        string
+		taints      []corev1.Taint
+		expectDrain bool
+	}{
+		{
+			name:        "node without taints is drained",
+			expectDrain: true,
+		},
+		{
+			name: "node with a taint of another key and the same effect is drained",
+			taints: []corev1.Taint{
+				{Key: "node.kubernetes.io/unreachable", Effect: corev1.TaintEffectNoExecute},
+			},
+			expectDrain: true,
+		},
+		{
+			name: "node with a taint of the same key and another effect is drained",
+			taints: []corev1.Taint{
+				{Key: "node-role.kubernetes.io/etcd", Effect: corev1.TaintEffectNoSchedule},
+			},
+			expectDrain: true,
+		},
+		{
+			name: "node with a matching taint is not drained",
+			taints: []corev1.Taint{
+				{Key: "node-role.kubernetes.io/master", Effect: corev1.TaintEffectNoSchedule},
+				{Key: "node-role.kubernetes.io/etcd", Value: "true", Effect: corev1.TaintEffectNoExecute},
+			},
+			expectDrain: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			testCluster := &clusterv1.Cluster{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cluster"},
+			}
+			controlPlane := &clusterv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "control-plane",
+					Namespace: "default",
+					Labels: map[string]string{
+						clusterv1.ClusterLabelName:             "test-cluster",
+						clusterv1.MachineControlPlaneLabelName: "",
+					},
+				},
+				Spec: clusterv1.MachineSpec{ClusterName: "test-cluster"},
+			}
+			m := &clusterv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:       "delete123",
+					Namespace:  "default",
+					Labels:     map[string]string{clusterv1.ClusterLabelName: "test-cluster"},
+					Finalizers: []string{clusterv1.MachineFinalizer},
+				},
+				Spec: clusterv1.MachineSpec{
+					ClusterName: "test-cluster",
+					InfrastructureRef: corev1.ObjectReference{
+						APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+						Kind:       "InfrastructureMachine",
+						Name:       "infra-config1",
+					},
+					Bootstrap:           clusterv1.Bootstrap{Data: pointer.StringPtr("data")},
+					NodeDeletionTimeout: &metav1.Duration{},
+				},
+				Status: clusterv1.MachineStatus{
+					NodeRef: &corev1.ObjectReference{Name: "test-node"},
+				},
+			}
+			node := &corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-node"},
+				Spec:       corev1.NodeSpec{Taints: tc.taints},
+			}
+
+			recorder := record.NewFakeRecorder(10)
+			mr := &MachineReconciler{
+				Client:             fake.NewFakeClientWithScheme(scheme.Scheme, testCluster, controlPlane, m, node),
+				Log:                log.Log,
+				scheme:             scheme.Scheme,
+				recorder:           recorder,
+				remoteClientGetter: fakeremote.NewClusterClient,
+				NoDrainTaints: []corev1.Taint{
+					{Key: "node-role.kubernetes.io/etcd", Effect: corev1.TaintEffectNoExecute},
+				},
+			}
+
+			_, err := mr.reconcileDelete(ctx, testCluster, m)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(m.Finalizers).NotTo(ContainElement(clusterv1.MachineFinalizer))
+
+			close(recorder.Events)
+			drained := false
+			for event := range recorder.Events {
+				if strings.Contains(event, "SuccessfulDrainNode") {
+					drained = true
+				}
+			}
+			g.Expect(drained).To(Equal(tc.expectDrain))
+		})
+	}
+}
+
+func TestMachineReconcilerControllerOptions(t *testing.T) {
+	g := NewWithT(t)
+
+	r := &MachineReconciler{ConcurrentReconciles: 10}
+	g.Expect(r.controllerOptions(controller.Options{MaxConcurrentReconciles: 1}).MaxConcurrentReconciles).To(Equal(10))
+
+	r = &MachineReconciler{}
+	g.Expect(r.controllerOptions(controller.Options{MaxConcurrentReconciles: 1}).MaxConcurrentReconciles).To(Equal(1))
+}
+
+func TestMachineReconcilerLogFields(t *testing.T) {
+	g := NewWithT(t)
+
+	machine := &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-machine",
+			Namespace: "default",
+		},
+		Spec: clusterv1.MachineSpec{
+			ClusterName: "test-cluster",
+		},
+		Status: clusterv1.MachineStatus{
+			Phase: string(clusterv1.MachinePhaseProvisioning),
+		},
+	}
+	cluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-cluster",
+			Namespace: "default",
+		},
+	}
+
+	g.Expect(LogFields(machine)).To(Equal([]interface{}{
+		"machine", "test-machine",
+		"namespace", "default",
+		"cluster", "test-cluster",
+		"phase", "Provisioning",
+	}))
+
+	// reconcileNodeRef logs and returns early when the Machine has no ProviderID.
+	buf := &bytes.Buffer{}
+	r := &MachineReconciler{
+		Client: fake.NewFakeClientWithScheme(scheme.Scheme),
+		Log:    zap.New(zap.WriteTo(buf), zap.UseDevMode(false)),
+	}
+	g.Expect(r.reconcileNodeRef(context.Background(), cluster, machine)).To(Succeed())
+
+	entry := map[string]interface{}{}
+	g.Expect(json.Unmarshal(buf.Bytes(), &entry)).To(Succeed())
+	g.Expect(entry).To(HaveKeyWithValue("machine", "test-machine"))
+	g.Expect(entry).To(HaveKeyWithValue("namespace", "default"))
+	g.Expect(entry).To(HaveKeyWithValue("cluster", "test-cluster"))
+	g.Expect(entry).To(HaveKeyWithValue("phase", "Provisioning"))
+}
+
+var _ = Describe("Machine Reconciler with server-side apply of the status", func() {
+	It("Should merge the status with the concurrent updates of other field managers", func() {
+		Expect(feature.MutableGates.Set(fmt.Sprintf("%s=true", feature.MachineStatusServerSideApply))).To(Succeed())
+		defer func() {
+			Expect(feature.MutableGates.Set(fmt.Sprintf("%s=false", feature.MachineStatusServerSideApply))).To(Succeed())
+		}()
+
+		// The cache of the shared manager could return stale Machines, read them from the API server.
+		c, err := client.New(cfg, client.Options{Scheme: scheme.Scheme})
+		Expect(err).ToNot(HaveOccurred())
+
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{GenerateName: "machine-ssa-"}}
+		Expect(c.Create(ctx, ns)).To(Succeed())
+
+		// The Machine has no Cluster, so the controllers of the suite don't update it.
+		machine := &clusterv1.Machine{
+			ObjectMeta: metav1.ObjectMeta{Name: "machine", Namespace: ns.Name},
+			Spec: clusterv1.MachineSpec{
+				ClusterName: "missing-cluster",
+				Bootstrap:   clusterv1.Bootstrap{Data: pointer.StringPtr("data")},
+				InfrastructureRef: corev1.ObjectReference{
+					APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+					Kind:       "InfrastructureMachine",
+					Name:       "infra-config1",
+				},
+			},
+		}
+		Expect(c.Create(ctx, machine)).To(Succeed())
+		defer func() {
+			Expect(c.Delete(ctx, machine)).To(Succeed())
+		}()
+
+		r := &MachineReconciler{
+			Client: c,
+			Log:    log.Log,
+		}
+
+		// The controller reads the Machine.
+		m := &clusterv1.Machine{}
+		Expect(c.Get(ctx, util.ObjectKey(machine), m)).To(Succeed())
+		original := m.DeepCopy()
+		patchHelper, err := patch.NewHelper(m, c)
+		Expect(err).ToNot(HaveOccurred())
+
+		// Another field manager updates the labels and the status concurrently.
+		concurrent := m.DeepCopy()
+		concurrent.Labels = map[string]string{"foo": "bar"}
+		Expect(c.Patch(ctx, concurrent, client.MergeFrom(m), client.FieldOwner("other-controller"))).To(Succeed())
+		concurrentStatus := concurrent.DeepCopy()
+		concurrentStatus.Status.Version = pointer.StringPtr("v1.17.0")
+		Expect(c.Status().Patch(ctx, concurrentStatus, client.MergeFrom(concurrent), client.FieldOwner("other-controller"))).To(Succeed())
+
+		// The controller updates the status from its stale copy without conflicting.
+		m.Status.SetTypedPhase(clusterv1.MachinePhasePending)
+		m.Status.BootstrapReady = true
+		conditions.MarkTrue(&m.Status.Conditions, clusterv1.BootstrapReadyCondition)
+		Expect(r.patchMachine(ctx, patchHelper, original, m)).To(Succeed())
+
+		got := &clusterv1.Machine{}
+		Expect(c.Get(ctx, util.ObjectKey(machine), got)).To(Succeed())
+		Expect(got.Status.GetTypedPhase()).To(Equal(clusterv1.MachinePhasePending))
+		Expect(got.Status.BootstrapReady).To(BeTrue())
+		Expect(got.Status.Version).To(Equal(pointer.StringPtr("v1.17.0")))
+		Expect(conditions.IsTrue(got.Status.Conditions, clusterv1.BootstrapReadyCondition)).To(BeTrue())
+		Expect(got.Labels).To(HaveKeyWithValue("foo", "bar"))
+
+		managers := []string{}
+		for _, f := range got.ManagedFields {
+			managers = append(managers, f.Manager)
+		}
+		Expect(managers).To(ContainElement(MachineManagedFields))
+		Expect(managers).To(ContainElement("other-controller"))
+
+		// The fields set by other managers are not taken over: changing one of them fails with a conflict.
+		original = got.DeepCopy()
+		patchHelper, err = patch.NewHelper(got, c)
+		Expect(err).ToNot(HaveOccurred())
+		got.Status.Version = pointer.StringPtr("v1.18.0")
+		err = r.patchMachine(ctx, patchHelper, original, got)
+		Expect(apierrors.IsConflict(errors.Cause(err))).To(BeTrue())
+	})
+})
@@ -0,0 +1,137 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/util/conditions"
+)
+
+const (
+	// preProvisionValidationTimeout is the timeout of the requests to the pre-provision validation URL.
+	// It is kept short as the request holds one of the workers of the Machine controller.
+	preProvisionValidationTimeout = 2 * time.Second
+
+	// preProvisionValidationRetryPeriod is how long the Machine controller waits before validating
+	// again a Machine that failed its pre-provision validation.
+	preProvisionValidationRetryPeriod = time.Minute
+
+	// preProvisionValidationMaxMessage is the maximum number of bytes of a validation failure response
+	// included in the returned error.
+	preProvisionValidationMaxMessage = 1024
+)
+
+// preProvisionValidationClient is the HTTP client used to call the pre-provision validation URL.
+var preProvisionValidationClient = &http.Client{Timeout: preProvisionValidationTimeout}
+
+// reconcilePreProvisionValidation validates the Machine against the external policy, if any, until it passes the
+// validation once, and records the result in its PreProvisionValidatedCondition. It returns false while the
+// Machine is not validated, in which case its bootstrap and infrastructure must not be reconciled.
+// Machines whose provisioning already started are never validated.
+func (r *MachineReconciler) reconcilePreProvisionValidation(ctx context.Context, m *clusterv1.Machine) bool {
+	if (r.PreProvisionValidationURL == "" && m.Annotations[clusterv1.PreProvisionValidationURLAnnotation] == "") ||
+		conditions.IsTrue(m.Status.Conditions, clusterv1.PreProvisionValidatedCondition) {
+		return true
+	}
+	if !conditions.Has(m.Status.Conditions, clusterv1.PreProvisionValidatedCondition) && machineProvisioningStarted(m) {
+		return true
+	}
+
+	if err := r.PreProvisionValidation(ctx, m); err != nil {
+		previous := conditions.Get(m.Status.Conditions, clusterv1.PreProvisionValidatedCondition)
+		conditions.MarkFalse(&m.Status.Conditions, clusterv1.PreProvisionValidatedCondition, clusterv1.PreProvisionValidationFailedReason,
+			clusterv1.ConditionSeverityError, "%v", err)
+		if previous == nil || previous.Status != corev1.ConditionFalse || previous.Message != err.Error() {
+			r.recorder.Event(m, corev1.EventTypeWarning, "FailedPreProvisionValidation", err.Error())
+		}
+		return false
+	}
+
+	conditions.MarkTrue(&m.Status.Conditions, clusterv1.PreProvisionValidatedCondition)
+	return true
+}
+
+// preProvisionValidationURL returns the URL the Machine is validated against: the URL of its
+// PreProvisionValidationURLAnnotation if it is allowed, or the PreProvisionValidationURL.
+func (r *MachineReconciler) preProvisionValidationURL(machine *clusterv1.Machine) (string, error) {
+	url := machine.Annotations[clusterv1.PreProvisionValidationURLAnnotation]
+	if url == "" || url == r.PreProvisionValidationURL {
+		return r.PreProvisionValidationURL, nil
+	}
+	for _, allowed := range r.AllowedPreProvisionValidationURLs {
+		if url == allowed {
+			return url, nil
+		}
+	}
+	return "", errors.Errorf("pre-provision validation URL %q of Machine %q in namespace %q is not allowed", url, machine.Name, machine.Namespace)
+}
+
+// machineProvisioningStarted returns true if the bootstrap data or the infrastructure of the Machine
+// is already available to the providers, i.e. if the Machine may be provisioning already.
+func machineProvisioningStarted(m *clusterv1.Machine) bool {
+	return m.Status.BootstrapReady || m.Status.InfrastructureReady || m.Status.NodeRef != nil ||
+		m.Spec.Bootstrap.DataSecretName != nil || m.Spec.Bootstrap.Data != nil
+}
+
+// PreProvisionValidation posts the spec of the Machine as JSON to the URL of its PreProvisionValidationURLAnnotation,
+// or to the PreProvisionValidationURL, and returns an error including the response body if the response status
+// is not 2xx. All Machines are valid if there is no URL to post to. An annotation set to a URL that is not allowed
+// is an error.
+func (r *MachineReconciler) PreProvisionValidation(ctx context.Context, machine *clusterv1.Machine) error {
+	url, err := r.preProvisionValidationURL(machine)
+	if err != nil || url == "" {
+		return err
+	}
+
+	body, err := json.Marshal(machine.Spec)
+	if err != nil {
+		return errors.Wrapf(err, "failed to marshal the spec of Machine %q in namespace %q", machine.Name, machine.Namespace)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrapf(err, "failed to create the pre-provision validation request for Machine %q in namespace %q", machine.Name, machine.Namespace)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := preProvisionValidationClient.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "failed to call the pre-provision validation URL for Machine %q in namespace %q", machine.Name, machine.Namespace)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		// Drain the body so the connection can be reused.
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		return nil
+	}
+
+	message, _ := ioutil.ReadAll(io.LimitReader(resp.Body, preProvisionValidationMaxMessage))
+	return errors.Errorf("pre-provision validation of Machine %q in namespace %q failed with status %q: %s",
+		machine.Name, machine.Namespace, resp.Status, strings.TrimSpace(string(message)))
+}
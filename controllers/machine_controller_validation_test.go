@@ -0,0 +1,371 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/feature"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// validationServer is a pre-provision validation endpoint answering with the given status and message,
+// and recording the Machine specs it receives.
+type validationServer struct {
+	status   int
+	message  string
+	received []clusterv1.MachineSpec
+}
+
+func (s *validationServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	spec := clusterv1.MachineSpec{}
+	if err := json.NewDecoder(req.Body).Decode(&spec); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.received = append(s.received, spec)
+	w.WriteHeader(s.status)
+	fmt.Fprint(w, s.message)
+}
+
+func newValidationMachine() *clusterv1.Machine {
+	return &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "validated",
+			Namespace: "default",
+		},
+		Spec: clusterv1.MachineSpec{
+			ClusterName: "test-cluster",
+			Version:     pointer.StringPtr("v1.17.0"),
+			InfrastructureRef: corev1.ObjectReference{
+				APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+				Kind:       "InfrastructureMachine",
+				Name:       "infra-config1",
+			},
+		},
+	}
+}
+
+func TestPreProvisionValidation(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        int
+		message       string
+		noURL         bool
+		expectErr     string
+		expectRequest bool
+	}{
+		{
+			name:          "accepted by the validator",
+			status:        http.StatusOK,
+			expectRequest: true,
+		},
+		{
+			name:          "rejected by the validator",
+			status:        http.StatusForbidden,
+			message:       "version v1.17.0 is not allowed\n",
+			expectErr:     "failed with status \"403 Forbidden\": version v1.17.0 is not allowed",
+			expectRequest: true,
+		},
+		{
+			name:          "validator error",
+			status:        http.StatusInternalServerError,
+			expectErr:     "failed with status \"500 Internal Server Error\"",
+			expectRequest: true,
+		},
+		{
+			name:  "no validation URL",
+			noURL: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			validator := &validationServer{status: tt.status, message: tt.message}
+			server := httptest.NewServer(validator)
+			defer server.Close()
+
+			machine := newValidationMachine()
+			r := &MachineReconciler{PreProvisionValidationURL: server.URL}
+			if tt.noURL {
+				r.PreProvisionValidationURL = ""
+			}
+
+			err := r.PreProvisionValidation(context.Background(), machine)
+			if tt.expectErr != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tt.expectErr))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+
+			if tt.expectRequest {
+				g.Expect(validator.received).To(Equal([]clusterv1.MachineSpec{machine.Spec}))
+			} else {
+				g.Expect(validator.received).To(BeEmpty())
+			}
+		})
+	}
+}
+
+func TestPreProvisionValidationURLAnnotation(t *testing.T) {
+	tests := []struct {
+		name                  string
+		defaultURL            bool
+		allowed               bool
+		expectErr             string
+		expectAnnotationCalls bool
+	}{
+		{
+			name:                  "allowed annotation overrides the default URL",
+			defaultURL:            true,
+			allowed:               true,
+			expectAnnotationCalls: true,
+		},
+		{
+			name:                  "allowed annotation without a default URL",
+			allowed:               true,
+			expectAnnotationCalls: true,
+		},
+		{
+			// The users creating Machines must not be able to make the controller call any endpoint.
+			name:       "annotation that is not allowed is not called",
+			defaultURL: true,
+			expectErr:  "is not allowed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			defaultValidator := &validationServer{status: http.StatusOK}
+			defaultServer := httptest.NewServer(defaultValidator)
+			defer defaultServer.Close()
+			annotationValidator := &validationServer{status: http.StatusOK}
+			annotationServer := httptest.NewServer(annotationValidator)
+			defer annotationServer.Close()
+
+			machine := newValidationMachine()
+			machine.Annotations = map[string]string{clusterv1.PreProvisionValidationURLAnnotation: annotationServer.URL}
+			r := &MachineReconciler{}
+			if tt.defaultURL {
+				r.PreProvisionValidationURL = defaultServer.URL
+			}
+			if tt.allowed {
+				r.AllowedPreProvisionValidationURLs = []string{annotationServer.URL}
+			}
+
+			err := r.PreProvisionValidation(context.Background(), machine)
+			if tt.expectErr != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tt.expectErr))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+
+			g.Expect(defaultValidator.received).To(BeEmpty())
+			if tt.expectAnnotationCalls {
+				g.Expect(annotationValidator.received).To(Equal([]clusterv1.MachineSpec{machine.Spec}))
+			} else {
+				g.Expect(annotationValidator.received).To(BeEmpty())
+			}
+		})
+	}
+}
+
+func TestReconcilePreProvisionValidation(t *testing.T) {
+	tests := []struct {
+		name            string
+		status          int
+		machine         func(m *clusterv1.Machine)
+		expectValidated bool
+		expectRequests  int
+		expectCondition *clusterv1.Condition
+		expectNoEvent   bool
+	}{
+		{
+			name:            "new Machine accepted",
+			status:          http.StatusOK,
+			expectValidated: true,
+			expectRequests:  1,
+			expectCondition: conditions.TrueCondition(clusterv1.PreProvisionValidatedCondition),
+			expectNoEvent:   true,
+		},
+		{
+			name:           "new Machine rejected",
+			status:         http.StatusForbidden,
+			expectRequests: 1,
+			expectCondition: &clusterv1.Condition{
+				Type:     clusterv1.PreProvisionValidatedCondition,
+				Status:   corev1.ConditionFalse,
+				Severity: clusterv1.ConditionSeverityError,
+				Reason:   clusterv1.PreProvisionValidationFailedReason,
+			},
+		},
+		{
+			name:   "Machine already validated",
+			status: http.StatusForbidden,
+			machine: func(m *clusterv1.Machine) {
+				conditions.MarkTrue(&m.Status.Conditions, clusterv1.PreProvisionValidatedCondition)
+			},
+			expectValidated: true,
+			expectCondition: conditions.TrueCondition(clusterv1.PreProvisionValidatedCondition),
+			expectNoEvent:   true,
+		},
+		{
+			name:   "Machine rejected before is validated again",
+			status: http.StatusOK,
+			machine: func(m *clusterv1.Machine) {
+				m.Status.BootstrapReady = true
+				conditions.MarkFalse(&m.Status.Conditions, clusterv1.PreProvisionValidatedCondition, clusterv1.PreProvisionValidationFailedReason,
+					clusterv1.ConditionSeverityError, "rejected")
+			},
+			expectValidated: true,
+			expectRequests:  1,
+			expectCondition: conditions.TrueCondition(clusterv1.PreProvisionValidatedCondition),
+			expectNoEvent:   true,
+		},
+		{
+			name:   "Machine already provisioning",
+			status: http.StatusForbidden,
+			machine: func(m *clusterv1.Machine) {
+				m.Spec.Bootstrap.DataSecretName = pointer.StringPtr("bootstrap-data")
+				m.Status.BootstrapReady = true
+			},
+			expectValidated: true,
+			expectNoEvent:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			validator := &validationServer{status: tt.status, message: "denied"}
+			server := httptest.NewServer(validator)
+			defer server.Close()
+
+			machine := newValidationMachine()
+			if tt.machine != nil {
+				tt.machine(machine)
+			}
+			recorder := record.NewFakeRecorder(10)
+			r := &MachineReconciler{
+				PreProvisionValidationURL: server.URL,
+				recorder:                  recorder,
+			}
+
+			g.Expect(r.reconcilePreProvisionValidation(context.Background(), machine)).To(Equal(tt.expectValidated))
+			g.Expect(validator.received).To(HaveLen(tt.expectRequests))
+
+			condition := conditions.Get(machine.Status.Conditions, clusterv1.PreProvisionValidatedCondition)
+			if tt.expectCondition == nil {
+				g.Expect(condition).To(BeNil())
+			} else {
+				g.Expect(condition).NotTo(BeNil())
+				g.Expect(condition.Status).To(Equal(tt.expectCondition.Status))
+				g.Expect(condition.Severity).To(Equal(tt.expectCondition.Severity))
+				g.Expect(condition.Reason).To(Equal(tt.expectCondition.Reason))
+			}
+
+			if tt.expectNoEvent {
+				g.Expect(recorder.Events).To(BeEmpty())
+			} else {
+				g.Expect(recorder.Events).To(Receive(ContainSubstring("FailedPreProvisionValidation")))
+			}
+		})
+	}
+}
+
+func TestReconcilePreProvisionValidationEventOnTransition(t *testing.T) {
+	g := NewWithT(t)
+
+	validator := &validationServer{status: http.StatusForbidden, message: "denied"}
+	server := httptest.NewServer(validator)
+	defer server.Close()
+
+	machine := newValidationMachine()
+	recorder := record.NewFakeRecorder(10)
+	r := &MachineReconciler{
+		PreProvisionValidationURL: server.URL,
+		recorder:                  recorder,
+	}
+
+	// Rejecting the Machine twice for the same reason emits a single event.
+	g.Expect(r.reconcilePreProvisionValidation(context.Background(), machine)).To(BeFalse())
+	g.Expect(r.reconcilePreProvisionValidation(context.Background(), machine)).To(BeFalse())
+	g.Expect(validator.received).To(HaveLen(2))
+	g.Expect(recorder.Events).To(HaveLen(1))
+}
+
+func TestReconcilePreProvisionValidationFeatureGate(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		t.Run(fmt.Sprintf("feature gate enabled: %t", enabled), func(t *testing.T) {
+			g := NewWithT(t)
+
+			g.Expect(feature.MutableGates.Set(fmt.Sprintf("%s=%t", feature.PreProvisionValidation, enabled))).To(Succeed())
+			defer func() {
+				g.Expect(feature.MutableGates.Set(fmt.Sprintf("%s=false", feature.PreProvisionValidation))).To(Succeed())
+			}()
+
+			validator := &validationServer{status: http.StatusForbidden, message: "denied"}
+			server := httptest.NewServer(validator)
+			defer server.Close()
+
+			machine := newValidationMachine()
+			cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "default"}}
+			r := &MachineReconciler{
+				Client:                    fake.NewFakeClientWithScheme(scheme.Scheme, cluster, machine),
+				Log:                       log.Log,
+				PreProvisionValidationURL: server.URL,
+				scheme:                    scheme.Scheme,
+				recorder:                  record.NewFakeRecorder(10),
+			}
+
+			res, err := r.reconcile(context.Background(), cluster, machine)
+			if enabled {
+				// The Machine is rejected, and validated again later, without reconciling its bootstrap and infrastructure.
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(res.RequeueAfter).To(Equal(preProvisionValidationRetryPeriod))
+				g.Expect(validator.received).To(HaveLen(1))
+				g.Expect(conditions.IsFalse(machine.Status.Conditions, clusterv1.PreProvisionValidatedCondition)).To(BeTrue())
+			} else {
+				// The Machine is reconciled, failing on its missing infrastructure object, without being validated.
+				g.Expect(validator.received).To(BeEmpty())
+				g.Expect(conditions.Has(machine.Status.Conditions, clusterv1.PreProvisionValidatedCondition)).To(BeFalse())
+			}
+		})
+	}
+}
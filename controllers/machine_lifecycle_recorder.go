@@ -0,0 +1,181 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+)
+
+// MachineLifecycleEventType is the type of a MachineLifecycleEvent.
+type MachineLifecycleEventType string
+
+const (
+	// MachineCreatedEvent is recorded when a Machine is reconciled for the first time.
+	MachineCreatedEvent = MachineLifecycleEventType("Created")
+
+	// MachinePhaseChangedEvent is recorded when the phase of a Machine changes.
+	MachinePhaseChangedEvent = MachineLifecycleEventType("PhaseChanged")
+
+	// MachineBootstrapStartedEvent is recorded when a new Machine waits for its bootstrap data.
+	MachineBootstrapStartedEvent = MachineLifecycleEventType("BootstrapStarted")
+
+	// MachineBootstrapCompletedEvent is recorded when the bootstrap data of a Machine is ready.
+	MachineBootstrapCompletedEvent = MachineLifecycleEventType("BootstrapCompleted")
+
+	// MachineInfrastructureReadyEvent is recorded when the infrastructure of a Machine is ready.
+	MachineInfrastructureReadyEvent = MachineLifecycleEventType("InfrastructureReady")
+
+	// MachineNodeAssociatedEvent is recorded when a Machine is associated with its Node.
+	MachineNodeAssociatedEvent = MachineLifecycleEventType("NodeAssociated")
+
+	// MachineDrainStartedEvent is recorded each time the drain of the Node of a Machine being deleted starts.
+	MachineDrainStartedEvent = MachineLifecycleEventType("DrainStarted")
+
+	// MachineDeletedEvent is recorded when the finalizer of a Machine being deleted is removed.
+	MachineDeletedEvent = MachineLifecycleEventType("Deleted")
+)
+
+// MachineLifecycleEvent is a step of the lifecycle of a Machine, recorded for audit purposes.
+type MachineLifecycleEvent struct {
+	Type      MachineLifecycleEventType
+	Name      string
+	Namespace string
+	Message   string
+	Timestamp time.Time
+}
+
+// MachineLifecycleSink stores the events recorded by a MachineEventRecorder.
+type MachineLifecycleSink interface {
+	// Record stores the event.
+	Record(event MachineLifecycleEvent)
+
+	// Events returns the stored events, oldest first. Sinks not keeping the events return nil.
+	Events() []MachineLifecycleEvent
+}
+
+// MachineEventRecorder records the lifecycle events of the Machines to a sink.
+type MachineEventRecorder struct {
+	sink MachineLifecycleSink
+	now  func() time.Time
+}
+
+// NewMachineEventRecorder returns a MachineEventRecorder writing to the given sink.
+func NewMachineEventRecorder(sink MachineLifecycleSink) *MachineEventRecorder {
+	return &MachineEventRecorder{sink: sink, now: time.Now}
+}
+
+// Record records an event of the given type for the Machine.
+// It is a no-op on a nil recorder, so the Machine controller can call it unconditionally.
+func (r *MachineEventRecorder) Record(m *clusterv1.Machine, eventType MachineLifecycleEventType, messageFmt string, args ...interface{}) {
+	if r == nil {
+		return
+	}
+	r.sink.Record(MachineLifecycleEvent{
+		Type:      eventType,
+		Name:      m.Name,
+		Namespace: m.Namespace,
+		Message:   fmt.Sprintf(messageFmt, args...),
+		Timestamp: r.now(),
+	})
+}
+
+// RecordTransitions records the lifecycle events corresponding to the changes made to the Machine
+// during a reconciliation, original being the Machine as read before reconciling it.
+func (r *MachineEventRecorder) RecordTransitions(original, m *clusterv1.Machine) {
+	if r == nil {
+		return
+	}
+	if !hasMachineFinalizer(original) && hasMachineFinalizer(m) && m.DeletionTimestamp.IsZero() {
+		r.Record(m, MachineCreatedEvent, "Machine created")
+		if !m.Status.BootstrapReady {
+			r.Record(m, MachineBootstrapStartedEvent, "Waiting for the bootstrap data")
+		}
+	}
+	if original.Status.Phase != m.Status.Phase {
+		r.Record(m, MachinePhaseChangedEvent, "Phase changed from %q to %q", original.Status.Phase, m.Status.Phase)
+	}
+	if !original.Status.BootstrapReady && m.Status.BootstrapReady {
+		r.Record(m, MachineBootstrapCompletedEvent, "Bootstrap data is ready")
+	}
+	if !original.Status.InfrastructureReady && m.Status.InfrastructureReady {
+		r.Record(m, MachineInfrastructureReadyEvent, "Infrastructure is ready")
+	}
+	if original.Status.NodeRef == nil && m.Status.NodeRef != nil {
+		r.Record(m, MachineNodeAssociatedEvent, "Associated with Node %q", m.Status.NodeRef.Name)
+	}
+	if hasMachineFinalizer(original) && !hasMachineFinalizer(m) {
+		r.Record(m, MachineDeletedEvent, "Machine deleted")
+	}
+}
+
+// GetMachineEvents returns the events recorded for the Machine with the given name and namespace, oldest first.
+// It returns nil if the sink doesn't keep the events.
+func (r *MachineEventRecorder) GetMachineEvents(name, namespace string) []MachineLifecycleEvent {
+	if r == nil {
+		return nil
+	}
+	var events []MachineLifecycleEvent
+	for _, event := range r.sink.Events() {
+		if event.Name == name && event.Namespace == namespace {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// RingBufferSink is a MachineLifecycleSink keeping the last events in memory.
+type RingBufferSink struct {
+	lock   sync.Mutex
+	events []MachineLifecycleEvent
+	next   int
+	full   bool
+}
+
+// NewRingBufferSink returns a RingBufferSink keeping the last size events.
+func NewRingBufferSink(size int) *RingBufferSink {
+	return &RingBufferSink{events: make([]MachineLifecycleEvent, size)}
+}
+
+// Record implements MachineLifecycleSink, overwriting the oldest event once the buffer is full.
+func (s *RingBufferSink) Record(event MachineLifecycleEvent) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if len(s.events) == 0 {
+		return
+	}
+	s.events[s.next] = event
+	s.next = (s.next + 1) % len(s.events)
+	if s.next == 0 {
+		s.full = true
+	}
+}
+
+// Events implements MachineLifecycleSink, returning the events in the buffer.
+func (s *RingBufferSink) Events() []MachineLifecycleEvent {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if !s.full {
+		return append([]MachineLifecycleEvent(nil), s.events[:s.next]...)
+	}
+	return append(append([]MachineLifecycleEvent(nil), s.events[s.next:]...), s.events[:s.next]...)
+}
@@ -0,0 +1,195 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func eventTypes(events []MachineLifecycleEvent) []MachineLifecycleEventType {
+	types := []MachineLifecycleEventType{}
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+	return types
+}
+
+func TestRingBufferSink(t *testing.T) {
+	g := NewWithT(t)
+
+	sink := NewRingBufferSink(3)
+	g.Expect(sink.Events()).To(BeEmpty())
+
+	for _, name := range []string{"a", "b"} {
+		sink.Record(MachineLifecycleEvent{Name: name})
+	}
+	g.Expect(sink.Events()).To(Equal([]MachineLifecycleEvent{{Name: "a"}, {Name: "b"}}))
+
+	// The oldest events are overwritten once the buffer is full.
+	for _, name := range []string{"c", "d", "e"} {
+		sink.Record(MachineLifecycleEvent{Name: name})
+	}
+	g.Expect(sink.Events()).To(Equal([]MachineLifecycleEvent{{Name: "c"}, {Name: "d"}, {Name: "e"}}))
+
+	// A nil recorder, as used when the recording is disabled, doesn't record nor return any event.
+	var recorder *MachineEventRecorder
+	recorder.Record(&clusterv1.Machine{}, MachineCreatedEvent, "Machine created")
+	g.Expect(recorder.GetMachineEvents("", "")).To(BeNil())
+}
+
+func TestMachineEventRecorderRecordTransitions(t *testing.T) {
+	newMachine := func() *clusterv1.Machine {
+		return &clusterv1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       "machine",
+				Namespace:  "default",
+				Finalizers: []string{clusterv1.MachineFinalizer},
+			},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		original func(m *clusterv1.Machine)
+		modified func(m *clusterv1.Machine)
+		expected []MachineLifecycleEventType
+	}{
+		{
+			name:     "new machine",
+			original: func(m *clusterv1.Machine) { m.Finalizers = nil },
+			modified: func(m *clusterv1.Machine) {
+				m.Finalizers = []string{clusterv1.MachineFinalizer}
+				m.Status.SetTypedPhase(clusterv1.MachinePhasePending)
+			},
+			expected: []MachineLifecycleEventType{MachineCreatedEvent, MachineBootstrapStartedEvent, MachinePhaseChangedEvent},
+		},
+		{
+			name: "bootstrap and infrastructure ready",
+			modified: func(m *clusterv1.Machine) {
+				m.Status.BootstrapReady = true
+				m.Status.InfrastructureReady = true
+			},
+			expected: []MachineLifecycleEventType{MachineBootstrapCompletedEvent, MachineInfrastructureReadyEvent},
+		},
+		{
+			name: "node associated",
+			original: func(m *clusterv1.Machine) {
+				m.Status.SetTypedPhase(clusterv1.MachinePhaseProvisioned)
+			},
+			modified: func(m *clusterv1.Machine) {
+				m.Status.SetTypedPhase(clusterv1.MachinePhaseRunning)
+				m.Status.NodeRef = &corev1.ObjectReference{Name: "node-1"}
+			},
+			expected: []MachineLifecycleEventType{MachinePhaseChangedEvent, MachineNodeAssociatedEvent},
+		},
+		{
+			name:     "machine deleted",
+			original: func(m *clusterv1.Machine) { m.DeletionTimestamp = &metav1.Time{Time: time.Now()} },
+			modified: func(m *clusterv1.Machine) {
+				m.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+				m.Finalizers = nil
+			},
+			expected: []MachineLifecycleEventType{MachineDeletedEvent},
+		},
+		{
+			name:     "no changes",
+			expected: []MachineLifecycleEventType{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			original := newMachine()
+			if tt.original != nil {
+				tt.original(original)
+			}
+			modified := newMachine()
+			if tt.original != nil {
+				tt.original(modified)
+			}
+			if tt.modified != nil {
+				tt.modified(modified)
+			}
+
+			recorder := NewMachineEventRecorder(NewRingBufferSink(10))
+			recorder.RecordTransitions(original, modified)
+
+			g.Expect(eventTypes(recorder.GetMachineEvents("machine", "default"))).To(Equal(tt.expected))
+			g.Expect(recorder.GetMachineEvents("machine", "other")).To(BeEmpty())
+		})
+	}
+}
+
+func TestMachineReconcilerRecordsLifecycleEvents(t *testing.T) {
+	g := NewWithT(t)
+
+	cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "default"}}
+	machine := &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "new-machine",
+			Namespace: "default",
+		},
+		Spec: clusterv1.MachineSpec{
+			ClusterName: "test-cluster",
+			Bootstrap: clusterv1.Bootstrap{
+				ConfigRef: &corev1.ObjectReference{
+					APIVersion: "bootstrap.cluster.x-k8s.io/v1alpha3",
+					Kind:       "BootstrapMachine",
+					Name:       "bootstrap-config1",
+				},
+			},
+			InfrastructureRef: corev1.ObjectReference{
+				APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+				Kind:       "InfrastructureMachine",
+				Name:       "infra-config1",
+			},
+		},
+	}
+
+	lifecycleRecorder := NewMachineEventRecorder(NewRingBufferSink(10))
+	r := &MachineReconciler{
+		Client:            fake.NewFakeClientWithScheme(scheme.Scheme, cluster, machine),
+		Log:               log.Log,
+		LifecycleRecorder: lifecycleRecorder,
+		scheme:            scheme.Scheme,
+		recorder:          record.NewFakeRecorder(10),
+	}
+
+	// The bootstrap and infrastructure objects are missing, but the Machine is still patched.
+	_, _ = r.Reconcile(reconcile.Request{NamespacedName: util.ObjectKey(machine)})
+
+	events := lifecycleRecorder.GetMachineEvents("new-machine", "default")
+	g.Expect(eventTypes(events)).To(Equal([]MachineLifecycleEventType{
+		MachineCreatedEvent, MachineBootstrapStartedEvent, MachinePhaseChangedEvent,
+	}))
+	g.Expect(events[2].Message).To(Equal(`Phase changed from "" to "Pending"`))
+}
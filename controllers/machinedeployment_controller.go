@@ -19,6 +19,8 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
@@ -28,7 +30,9 @@ import (
 	"k8s.io/apimachinery/pkg/labels"
 	kerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	capierrors "sigs.k8s.io/cluster-api/errors"
 	"sigs.k8s.io/cluster-api/util"
 	"sigs.k8s.io/cluster-api/util/patch"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -43,6 +47,14 @@ var (
 	machineDeploymentKind = clusterv1.GroupVersion.WithKind("MachineDeployment")
 )
 
+const (
+	// quotaExceededBaseDelay is the delay before retrying the first MachineSet creation rejected because of a ResourceQuota.
+	quotaExceededBaseDelay = 5 * time.Second
+
+	// quotaExceededMaxDelay is the maximum delay between retries of MachineSet creations rejected because of a ResourceQuota.
+	quotaExceededMaxDelay = 5 * time.Minute
+)
+
 // +kubebuilder:rbac:groups=core,resources=events,verbs=get;list;watch;create;patch
 // +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch
 // +kubebuilder:rbac:groups=core,resources=nodes,verbs=get;list;watch;create;update;patch;delete
@@ -55,6 +67,9 @@ type MachineDeploymentReconciler struct {
 	Log    logr.Logger
 
 	recorder record.EventRecorder
+
+	quotaBackoffOnce sync.Once
+	quotaBackoff     workqueue.RateLimiter
 }
 
 func (r *MachineDeploymentReconciler) SetupWithManager(mgr ctrl.Manager, options controller.Options) error {
@@ -132,6 +147,10 @@ func (r *MachineDeploymentReconciler) Reconcile(req ctrl.Request) (_ ctrl.Result
 	}
 
 	result, err := r.reconcile(ctx, cluster, deployment)
+	if requeueErr, ok := errors.Cause(err).(capierrors.HasRequeueAfterError); ok {
+		logger.Error(err, "Reconciliation for MachineDeployment asked to requeue")
+		return ctrl.Result{Requeue: true, RequeueAfter: requeueErr.GetRequeueAfter()}, nil
+	}
 	if err != nil {
 		logger.Error(err, "Failed to reconcile MachineDeployment")
 		r.recorder.Eventf(deployment, corev1.EventTypeWarning, "ReconcileError", "%v", err)
@@ -322,3 +341,15 @@ func (r *MachineDeploymentReconciler) MachineSetToDeployments(o handler.MapObjec
 func (r *MachineDeploymentReconciler) shouldAdopt(md *clusterv1.MachineDeployment) bool {
 	return !util.HasOwner(md.OwnerReferences, clusterv1.GroupVersion.String(), []string{"Cluster"})
 }
+
+// quotaExceededBackoff returns the rate limiter used to compute the delay before retrying
+// the creation of MachineSets rejected because of a ResourceQuota. The delay grows exponentially
+// for each MachineDeployment until a MachineSet is created successfully.
+func (r *MachineDeploymentReconciler) quotaExceededBackoff() workqueue.RateLimiter {
+	r.quotaBackoffOnce.Do(func() {
+		if r.quotaBackoff == nil {
+			r.quotaBackoff = workqueue.NewItemExponentialFailureRateLimiter(quotaExceededBaseDelay, quotaExceededMaxDelay)
+		}
+	})
+	return r.quotaBackoff
+}
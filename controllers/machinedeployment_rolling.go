@@ -23,6 +23,7 @@ import (
 	"k8s.io/utils/integer"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
 	"sigs.k8s.io/cluster-api/controllers/mdutil"
+	"sigs.k8s.io/cluster-api/util/conditions"
 )
 
 // rolloutRolling implements the logic for rolling a new machine set.
@@ -41,6 +42,21 @@ func (r *MachineDeploymentReconciler) rolloutRolling(d *clusterv1.MachineDeploym
 
 	allMSs := append(oldMSs, newMS)
 
+	// Gate the rollout on the canary machines being available, if any.
+	canaryReady, err := r.reconcileCanary(allMSs, oldMSs, newMS, d)
+	if err != nil {
+		return err
+	}
+	if !canaryReady {
+		// Without enough surge for the canary machines, scale down the old MachineSets to make room for them.
+		if *(newMS.Spec.Replicas) < mdutil.CanaryReplicas(*d) {
+			if err := r.reconcileOldMachineSets(allMSs, oldMSs, newMS, d); err != nil {
+				return err
+			}
+		}
+		return r.syncDeploymentStatus(allMSs, newMS, d)
+	}
+
 	// Scale up, if we can.
 	if err := r.reconcileNewMachineSet(allMSs, newMS, d); err != nil {
 		return err
@@ -68,6 +84,39 @@ func (r *MachineDeploymentReconciler) rolloutRolling(d *clusterv1.MachineDeploym
 	return nil
 }
 
+// reconcileCanary scales up the new MachineSet to the canary replicas of the deployment, within its max surge,
+// while the old MachineSets still have replicas, and returns true once the canary machines are available.
+// It always returns true if the deployment doesn't define canary replicas.
+func (r *MachineDeploymentReconciler) reconcileCanary(allMSs []*clusterv1.MachineSet, oldMSs []*clusterv1.MachineSet, newMS *clusterv1.MachineSet, deployment *clusterv1.MachineDeployment) (bool, error) {
+	canaryReplicas := mdutil.CanaryReplicas(*deployment)
+	if canaryReplicas == 0 {
+		return true, nil
+	}
+
+	if newMS.Spec.Replicas == nil {
+		return false, errors.Errorf("spec replicas for machine set %v is nil, this is unexpected", newMS.Name)
+	}
+
+	// There is no rollout in progress if the old MachineSets don't have any replica left.
+	if mdutil.GetReplicaCountForMachineSets(oldMSs) == 0 || newMS.Status.AvailableReplicas >= canaryReplicas {
+		conditions.MarkTrue(&deployment.Status.Conditions, clusterv1.MachineDeploymentCanaryReadyCondition)
+		return true, nil
+	}
+
+	conditions.MarkFalse(&deployment.Status.Conditions, clusterv1.MachineDeploymentCanaryReadyCondition, clusterv1.WaitingForCanaryReason, clusterv1.ConditionSeverityInfo,
+		"%d of %d canary machines are available", newMS.Status.AvailableReplicas, canaryReplicas)
+
+	maxTotalMachines := *(deployment.Spec.Replicas) + mdutil.MaxSurge(*deployment)
+	scaleUpCount := maxTotalMachines - mdutil.GetReplicaCountForMachineSets(allMSs)
+	if *(newMS.Spec.Replicas) < canaryReplicas && scaleUpCount > 0 {
+		newReplicas := integer.Int32Min(canaryReplicas, *(newMS.Spec.Replicas)+scaleUpCount)
+		if err := r.scaleMachineSet(newMS, newReplicas, deployment); err != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
 func (r *MachineDeploymentReconciler) reconcileNewMachineSet(allMSs []*clusterv1.MachineSet, newMS *clusterv1.MachineSet, deployment *clusterv1.MachineDeployment) error {
 	if deployment.Spec.Replicas == nil {
 		return errors.Errorf("spec replicas for deployment set %v is nil, this is unexpected", deployment.Name)
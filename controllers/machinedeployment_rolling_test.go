@@ -0,0 +1,152 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestReconcileCanary(t *testing.T) {
+	newMachineSet := func(name string, replicas, availableReplicas int32) *clusterv1.MachineSet {
+		return &clusterv1.MachineSet{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       clusterv1.MachineSetSpec{Replicas: pointer.Int32Ptr(replicas)},
+			Status:     clusterv1.MachineSetStatus{AvailableReplicas: availableReplicas},
+		}
+	}
+	newDeployment := func(canaryReplicas *int32, maxSurge int) *clusterv1.MachineDeployment {
+		surge, unavailable := intstr.FromInt(maxSurge), intstr.FromInt(1)
+		return &clusterv1.MachineDeployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "md", Namespace: "default"},
+			Spec: clusterv1.MachineDeploymentSpec{
+				Replicas: pointer.Int32Ptr(5),
+				Strategy: &clusterv1.MachineDeploymentStrategy{
+					Type: clusterv1.RollingUpdateMachineDeploymentStrategyType,
+					RollingUpdate: &clusterv1.MachineRollingUpdateDeployment{
+						MaxSurge:       &surge,
+						MaxUnavailable: &unavailable,
+						CanaryReplicas: canaryReplicas,
+					},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name                string
+		deployment          *clusterv1.MachineDeployment
+		oldMS               *clusterv1.MachineSet
+		newMS               *clusterv1.MachineSet
+		expectReady         bool
+		expectCondition     bool
+		expectNewMSReplicas int32
+	}{
+		{
+			name:                "no canary replicas, rollout proceeds",
+			deployment:          newDeployment(nil, 1),
+			oldMS:               newMachineSet("old", 5, 5),
+			newMS:               newMachineSet("new", 0, 0),
+			expectReady:         true,
+			expectNewMSReplicas: 0,
+		},
+		{
+			name:                "canary machines not created yet, scale up the new MachineSet to the canary replicas",
+			deployment:          newDeployment(pointer.Int32Ptr(2), 2),
+			oldMS:               newMachineSet("old", 5, 5),
+			newMS:               newMachineSet("new", 0, 0),
+			expectReady:         false,
+			expectCondition:     true,
+			expectNewMSReplicas: 2,
+		},
+		{
+			name:                "canary machines not created yet, scale up the new MachineSet within the max surge",
+			deployment:          newDeployment(pointer.Int32Ptr(2), 1),
+			oldMS:               newMachineSet("old", 5, 5),
+			newMS:               newMachineSet("new", 0, 0),
+			expectReady:         false,
+			expectCondition:     true,
+			expectNewMSReplicas: 1,
+		},
+		{
+			name:                "canary machines not created yet, no max surge left",
+			deployment:          newDeployment(pointer.Int32Ptr(2), 0),
+			oldMS:               newMachineSet("old", 5, 5),
+			newMS:               newMachineSet("new", 0, 0),
+			expectReady:         false,
+			expectCondition:     true,
+			expectNewMSReplicas: 0,
+		},
+		{
+			name:                "canary machines are available, rollout proceeds",
+			deployment:          newDeployment(pointer.Int32Ptr(2), 1),
+			oldMS:               newMachineSet("old", 5, 5),
+			newMS:               newMachineSet("new", 2, 2),
+			expectReady:         true,
+			expectCondition:     true,
+			expectNewMSReplicas: 2,
+		},
+		{
+			name:                "old MachineSets have no replicas, rollout proceeds",
+			deployment:          newDeployment(pointer.Int32Ptr(2), 1),
+			oldMS:               newMachineSet("old", 0, 0),
+			newMS:               newMachineSet("new", 0, 0),
+			expectReady:         true,
+			expectCondition:     true,
+			expectNewMSReplicas: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(clusterv1.AddToScheme(scheme.Scheme)).To(Succeed())
+
+			r := &MachineDeploymentReconciler{
+				Client:   fake.NewFakeClientWithScheme(scheme.Scheme, tt.oldMS, tt.newMS),
+				Log:      log.Log,
+				recorder: record.NewFakeRecorder(10),
+			}
+
+			ready, err := r.reconcileCanary([]*clusterv1.MachineSet{tt.oldMS, tt.newMS}, []*clusterv1.MachineSet{tt.oldMS}, tt.newMS, tt.deployment)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(ready).To(Equal(tt.expectReady))
+
+			g.Expect(conditions.Has(tt.deployment.Status.Conditions, clusterv1.MachineDeploymentCanaryReadyCondition)).To(Equal(tt.expectCondition))
+			if tt.expectCondition {
+				g.Expect(conditions.IsTrue(tt.deployment.Status.Conditions, clusterv1.MachineDeploymentCanaryReadyCondition)).To(Equal(tt.expectReady))
+			}
+
+			newMS := &clusterv1.MachineSet{}
+			g.Expect(r.Client.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: "new"}, newMS)).To(Succeed())
+			g.Expect(*newMS.Spec.Replicas).To(Equal(tt.expectNewMSReplicas))
+		})
+	}
+}
@@ -18,12 +18,12 @@ package controllers
 
 import (
 	"context"
-	"fmt"
 	"sort"
 	"strconv"
 
 	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	apirand "k8s.io/apimachinery/pkg/util/rand"
@@ -31,6 +31,8 @@ import (
 	"k8s.io/client-go/util/retry"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
 	"sigs.k8s.io/cluster-api/controllers/mdutil"
+	"sigs.k8s.io/cluster-api/controllers/metrics"
+	capierrors "sigs.k8s.io/cluster-api/errors"
 	"sigs.k8s.io/cluster-api/util"
 	"sigs.k8s.io/cluster-api/util/patch"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -112,8 +114,10 @@ func (r *MachineDeploymentReconciler) getNewMachineSet(d *clusterv1.MachineDeplo
 		annotationsUpdated := mdutil.SetNewMachineSetAnnotations(d, msCopy, newRevision, true, logger)
 
 		minReadySecondsNeedsUpdate := msCopy.Spec.MinReadySeconds != *d.Spec.MinReadySeconds
-		if annotationsUpdated || minReadySecondsNeedsUpdate {
+		topologySpreadConstraintsNeedUpdate := !equality.Semantic.DeepEqual(msCopy.Spec.TopologySpreadConstraints, d.Spec.TopologySpreadConstraints)
+		if annotationsUpdated || minReadySecondsNeedsUpdate || topologySpreadConstraintsNeedUpdate {
 			msCopy.Spec.MinReadySeconds = *d.Spec.MinReadySeconds
+			msCopy.Spec.TopologySpreadConstraints = d.Spec.TopologySpreadConstraints
 			return nil, patchHelper.Patch(context.Background(), msCopy)
 		}
 
@@ -130,7 +134,10 @@ func (r *MachineDeploymentReconciler) getNewMachineSet(d *clusterv1.MachineDeplo
 
 	// new MachineSet does not exist, create one.
 	newMSTemplate := *d.Spec.Template.DeepCopy()
-	machineTemplateSpecHash := fmt.Sprintf("%d", mdutil.ComputeHash(&newMSTemplate))
+	machineTemplateSpecHash, err := util.ComputeMachineTemplateHash(&newMSTemplate)
+	if err != nil {
+		return nil, err
+	}
 	newMSTemplate.Labels = mdutil.CloneAndAddLabel(d.Spec.Template.Labels,
 		mdutil.DefaultMachineDeploymentUniqueLabelKey, machineTemplateSpecHash)
 
@@ -153,11 +160,12 @@ func (r *MachineDeploymentReconciler) getNewMachineSet(d *clusterv1.MachineDeplo
 			OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(d, machineDeploymentKind)},
 		},
 		Spec: clusterv1.MachineSetSpec{
-			ClusterName:     d.Spec.ClusterName,
-			Replicas:        new(int32),
-			MinReadySeconds: minReadySeconds,
-			Selector:        *newMSSelector,
-			Template:        newMSTemplate,
+			ClusterName:               d.Spec.ClusterName,
+			Replicas:                  new(int32),
+			MinReadySeconds:           minReadySeconds,
+			Selector:                  *newMSSelector,
+			Template:                  newMSTemplate,
+			TopologySpreadConstraints: d.Spec.TopologySpreadConstraints,
 		},
 	}
 
@@ -204,12 +212,21 @@ func (r *MachineDeploymentReconciler) getNewMachineSet(d *clusterv1.MachineDeplo
 		}
 
 		return nil, err
+	case util.IsQuotaExceeded(err):
+		quotaName := util.ExceededQuotaName(err)
+		delay := r.quotaExceededBackoff().When(util.ObjectKey(d))
+		logger.Info("Failed to create new machine set, resource quota exceeded", "machineset", newMS.Name, "quota", quotaName, "retryAfter", delay)
+		r.recorder.Eventf(d, corev1.EventTypeWarning, "QuotaExceeded", "Failed to create MachineSet %q: exceeded quota %q", newMS.Name, quotaName)
+		return nil, errors.Wrapf(&capierrors.RequeueAfterError{RequeueAfter: delay},
+			"failed to create MachineSet %q: exceeded quota %q", newMS.Name, quotaName)
 	case err != nil:
 		logger.Error(err, "Failed to create new machine set", "machineset", newMS.Name)
 		r.recorder.Eventf(d, corev1.EventTypeWarning, "FailedCreate", "Failed to create MachineSet %q: %v", newMS.Name, err)
 		return nil, err
 	}
 
+	r.quotaExceededBackoff().Forget(util.ObjectKey(d))
+
 	if !alreadyExists {
 		logger.V(4).Info("Created new machine set", "machineset", createdMS.Name)
 		r.recorder.Eventf(d, corev1.EventTypeNormal, "SuccessfulCreate", "Created MachineSet %q", newMS.Name)
@@ -328,6 +345,13 @@ func (r *MachineDeploymentReconciler) scale(deployment *clusterv1.MachineDeploym
 				}
 			}
 
+			// Don't scale up the new machine set of a paused deployment, so that a rollout in flight
+			// stays frozen until the deployment is resumed.
+			if deployment.Spec.Paused && newMS != nil && ms.Name == newMS.Name && nameToSize[ms.Name] > *(ms.Spec.Replicas) {
+				logger.V(4).Info("Deployment is paused, skipping the scale up of the new machine set", "machineset", ms.Name)
+				continue
+			}
+
 			// TODO: Use transactions when we have them.
 			if err := r.scaleMachineSetOperation(ms, nameToSize[ms.Name], deployment, scalingOperation); err != nil {
 				// Return as soon as we fail, the deployment is requeued
@@ -342,6 +366,7 @@ func (r *MachineDeploymentReconciler) scale(deployment *clusterv1.MachineDeploym
 // syncDeploymentStatus checks if the status is up-to-date and sync it if necessary
 func (r *MachineDeploymentReconciler) syncDeploymentStatus(allMSs []*clusterv1.MachineSet, newMS *clusterv1.MachineSet, d *clusterv1.MachineDeployment) error {
 	d.Status = calculateStatus(allMSs, newMS, d)
+	metrics.MachineDeploymentGenerationLag.WithLabelValues(d.Name, d.Namespace, d.Spec.ClusterName).Set(float64(d.Status.GenerationLag))
 	return nil
 }
 
@@ -349,7 +374,7 @@ func (r *MachineDeploymentReconciler) syncDeploymentStatus(allMSs []*clusterv1.M
 func calculateStatus(allMSs []*clusterv1.MachineSet, newMS *clusterv1.MachineSet, deployment *clusterv1.MachineDeployment) clusterv1.MachineDeploymentStatus {
 	availableReplicas := mdutil.GetAvailableReplicaCountForMachineSets(allMSs)
 	totalReplicas := mdutil.GetReplicaCountForMachineSets(allMSs)
-	unavailableReplicas := totalReplicas - availableReplicas
+	unavailableReplicas := *deployment.Spec.Replicas - availableReplicas
 
 	// If unavailableReplicas is negative, then that means the Deployment has more available replicas running than
 	// desired, e.g. whenever it scales down. In such a case we should simply default unavailableReplicas to zero.
@@ -369,6 +394,7 @@ func calculateStatus(allMSs []*clusterv1.MachineSet, newMS *clusterv1.MachineSet
 		ReadyReplicas:       mdutil.GetReadyReplicaCountForMachineSets(allMSs),
 		AvailableReplicas:   availableReplicas,
 		UnavailableReplicas: unavailableReplicas,
+		Conditions:          deployment.Status.Conditions,
 	}
 
 	if *deployment.Spec.Replicas == status.ReadyReplicas {
@@ -390,6 +416,13 @@ func calculateStatus(allMSs []*clusterv1.MachineSet, newMS *clusterv1.MachineSet
 			}
 		}
 	}
+
+	// The last completely rolled out generation is derived from the previous status,
+	// so the lag keeps growing with the spec changes made during a rollout.
+	if !mdutil.DeploymentComplete(deployment, &status) {
+		lastCompleteGeneration := deployment.Status.ObservedGeneration - deployment.Status.GenerationLag
+		status.GenerationLag = deployment.Generation - lastCompleteGeneration
+	}
 	return status
 }
 
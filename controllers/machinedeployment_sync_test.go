@@ -17,14 +17,30 @@ limitations under the License.
 package controllers
 
 import (
+	"context"
+	"fmt"
 	"testing"
+	"time"
 
 	. "github.com/onsi/gomega"
 
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/utils/pointer"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
 	capierrors "sigs.k8s.io/cluster-api/errors"
+	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
 func TestMachineDeploymentSyncStatus(t *testing.T) {
@@ -119,6 +135,7 @@ func TestMachineDeploymentSyncStatus(t *testing.T) {
 				ReadyReplicas:       1,
 				AvailableReplicas:   1,
 				UnavailableReplicas: 1,
+				GenerationLag:       2,
 				Phase:               "ScalingUp",
 			},
 		},
@@ -162,6 +179,7 @@ func TestMachineDeploymentSyncStatus(t *testing.T) {
 				ReadyReplicas:       2,
 				AvailableReplicas:   3,
 				UnavailableReplicas: 0,
+				GenerationLag:       2,
 				Phase:               "ScalingDown",
 			},
 		},
@@ -206,9 +224,157 @@ func TestMachineDeploymentSyncStatus(t *testing.T) {
 				ReadyReplicas:       0,
 				AvailableReplicas:   0,
 				UnavailableReplicas: 2,
+				GenerationLag:       2,
 				Phase:               "Failed",
 			},
 		},
+		"rolling out with some replicas unavailable": {
+			machineSets: []*clusterv1.MachineSet{
+				{
+					Spec: clusterv1.MachineSetSpec{
+						Replicas: pointer.Int32Ptr(1),
+					},
+					Status: clusterv1.MachineSetStatus{
+						AvailableReplicas:  1,
+						ReadyReplicas:      1,
+						Replicas:           1,
+						ObservedGeneration: 1,
+					},
+				},
+				{
+					Spec: clusterv1.MachineSetSpec{
+						Replicas: pointer.Int32Ptr(3),
+					},
+					Status: clusterv1.MachineSetStatus{
+						AvailableReplicas:  1,
+						ReadyReplicas:      1,
+						Replicas:           3,
+						ObservedGeneration: 1,
+					},
+				},
+			},
+			newMachineSet: &clusterv1.MachineSet{
+				Spec: clusterv1.MachineSetSpec{
+					Replicas: pointer.Int32Ptr(3),
+				},
+				Status: clusterv1.MachineSetStatus{
+					AvailableReplicas:  1,
+					ReadyReplicas:      1,
+					Replicas:           3,
+					ObservedGeneration: 1,
+				},
+			},
+			deployment: &clusterv1.MachineDeployment{
+				ObjectMeta: metav1.ObjectMeta{
+					Generation: 2,
+				},
+				Spec: clusterv1.MachineDeploymentSpec{
+					Replicas: pointer.Int32Ptr(3),
+				},
+			},
+			expectedStatus: clusterv1.MachineDeploymentStatus{
+				ObservedGeneration:  2,
+				Replicas:            4,
+				UpdatedReplicas:     3,
+				ReadyReplicas:       2,
+				AvailableReplicas:   2,
+				UnavailableReplicas: 1,
+				GenerationLag:       2,
+				Phase:               "ScalingUp",
+			},
+		},
+		"rollout completed after several spec changes": {
+			machineSets: []*clusterv1.MachineSet{{
+				Spec: clusterv1.MachineSetSpec{
+					Replicas: pointer.Int32Ptr(2),
+				},
+				Status: clusterv1.MachineSetStatus{
+					AvailableReplicas:  2,
+					ReadyReplicas:      2,
+					Replicas:           2,
+					ObservedGeneration: 1,
+				},
+			}},
+			newMachineSet: &clusterv1.MachineSet{
+				Spec: clusterv1.MachineSetSpec{
+					Replicas: pointer.Int32Ptr(2),
+				},
+				Status: clusterv1.MachineSetStatus{
+					AvailableReplicas:  2,
+					ReadyReplicas:      2,
+					Replicas:           2,
+					ObservedGeneration: 1,
+				},
+			},
+			deployment: &clusterv1.MachineDeployment{
+				ObjectMeta: metav1.ObjectMeta{
+					Generation: 4,
+				},
+				Spec: clusterv1.MachineDeploymentSpec{
+					Replicas: pointer.Int32Ptr(2),
+				},
+				Status: clusterv1.MachineDeploymentStatus{
+					ObservedGeneration: 4,
+					GenerationLag:      2,
+				},
+			},
+			expectedStatus: clusterv1.MachineDeploymentStatus{
+				ObservedGeneration:  4,
+				Replicas:            2,
+				UpdatedReplicas:     2,
+				ReadyReplicas:       2,
+				AvailableReplicas:   2,
+				UnavailableReplicas: 0,
+				GenerationLag:       0,
+				Phase:               "Running",
+			},
+		},
+		"spec changed again during a rollout": {
+			machineSets: []*clusterv1.MachineSet{{
+				Spec: clusterv1.MachineSetSpec{
+					Replicas: pointer.Int32Ptr(2),
+				},
+				Status: clusterv1.MachineSetStatus{
+					AvailableReplicas:  1,
+					ReadyReplicas:      1,
+					Replicas:           2,
+					ObservedGeneration: 1,
+				},
+			}},
+			newMachineSet: &clusterv1.MachineSet{
+				Spec: clusterv1.MachineSetSpec{
+					Replicas: pointer.Int32Ptr(2),
+				},
+				Status: clusterv1.MachineSetStatus{
+					AvailableReplicas:  1,
+					ReadyReplicas:      1,
+					Replicas:           2,
+					ObservedGeneration: 1,
+				},
+			},
+			deployment: &clusterv1.MachineDeployment{
+				ObjectMeta: metav1.ObjectMeta{
+					Generation: 4,
+				},
+				Spec: clusterv1.MachineDeploymentSpec{
+					Replicas: pointer.Int32Ptr(2),
+				},
+				Status: clusterv1.MachineDeploymentStatus{
+					ObservedGeneration: 3,
+					GenerationLag:      1,
+				},
+			},
+			expectedStatus: clusterv1.MachineDeploymentStatus{
+				ObservedGeneration:  4,
+				Replicas:            2,
+				UpdatedReplicas:     2,
+				ReadyReplicas:       1,
+				AvailableReplicas:   1,
+				UnavailableReplicas: 1,
+				GenerationLag:       2,
+				Phase:               "ScalingUp",
+			},
+		},
 	}
 
 	for name, test := range tests {
@@ -220,3 +386,218 @@ func TestMachineDeploymentSyncStatus(t *testing.T) {
 		})
 	}
 }
+
+func TestScalePausedRollout(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(clusterv1.AddToScheme(scheme.Scheme)).To(Succeed())
+
+	maxSurge := intstr.FromInt(1)
+	maxUnavailable := intstr.FromInt(0)
+	deployment := &clusterv1.MachineDeployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "md", Namespace: "default"},
+		Spec: clusterv1.MachineDeploymentSpec{
+			Replicas: pointer.Int32Ptr(4),
+			Paused:   true,
+			Strategy: &clusterv1.MachineDeploymentStrategy{
+				Type: clusterv1.RollingUpdateMachineDeploymentStrategyType,
+				RollingUpdate: &clusterv1.MachineRollingUpdateDeployment{
+					MaxSurge:       &maxSurge,
+					MaxUnavailable: &maxUnavailable,
+				},
+			},
+		},
+	}
+
+	// The rollout is paused half-way, with half of the replicas in each MachineSet.
+	now := time.Now()
+	oldMS := &clusterv1.MachineSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "old", Namespace: "default", CreationTimestamp: metav1.NewTime(now.Add(-time.Hour))},
+		Spec:       clusterv1.MachineSetSpec{Replicas: pointer.Int32Ptr(2)},
+	}
+	newMS := &clusterv1.MachineSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "new", Namespace: "default", CreationTimestamp: metav1.NewTime(now)},
+		Spec:       clusterv1.MachineSetSpec{Replicas: pointer.Int32Ptr(2)},
+	}
+
+	r := &MachineDeploymentReconciler{
+		Client:   fake.NewFakeClientWithScheme(scheme.Scheme, oldMS.DeepCopy(), newMS.DeepCopy()),
+		Log:      log.Log,
+		recorder: record.NewFakeRecorder(32),
+	}
+
+	getReplicas := func(name string) int32 {
+		ms := &clusterv1.MachineSet{}
+		g.Expect(r.Client.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: name}, ms)).To(Succeed())
+		return *ms.Spec.Replicas
+	}
+
+	// While paused, the new MachineSet isn't scaled up.
+	g.Expect(r.scale(deployment, newMS.DeepCopy(), []*clusterv1.MachineSet{oldMS.DeepCopy()})).To(Succeed())
+	g.Expect(getReplicas("new")).To(Equal(int32(2)))
+	g.Expect(getReplicas("old")).To(Equal(int32(2)))
+
+	// Once resumed, the rollout continues from where it was paused.
+	deployment.Spec.Paused = false
+	g.Expect(r.scale(deployment, newMS.DeepCopy(), []*clusterv1.MachineSet{oldMS.DeepCopy()})).To(Succeed())
+	g.Expect(getReplicas("new")).To(Equal(int32(3)))
+	g.Expect(getReplicas("old")).To(Equal(int32(2)))
+}
+
+func TestCleanupDeployment(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(clusterv1.AddToScheme(scheme.Scheme)).To(Succeed())
+
+	deployment := &clusterv1.MachineDeployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "md", Namespace: "default"},
+		Spec: clusterv1.MachineDeploymentSpec{
+			RevisionHistoryLimit: pointer.Int32Ptr(10),
+		},
+	}
+
+	// Create 15 old MachineSets scaled to zero, one per revision.
+	now := time.Now()
+	oldMSs := make([]*clusterv1.MachineSet, 0, 15)
+	objs := make([]runtime.Object, 0, 15)
+	for i := 0; i < 15; i++ {
+		ms := &clusterv1.MachineSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              fmt.Sprintf("ms-%d", i),
+				Namespace:         "default",
+				CreationTimestamp: metav1.NewTime(now.Add(time.Duration(i) * time.Minute)),
+			},
+			Spec: clusterv1.MachineSetSpec{
+				Replicas: pointer.Int32Ptr(0),
+			},
+		}
+		oldMSs = append(oldMSs, ms)
+		objs = append(objs, ms.DeepCopy())
+	}
+
+	r := &MachineDeploymentReconciler{
+		Client:   fake.NewFakeClientWithScheme(scheme.Scheme, objs...),
+		Log:      log.Log,
+		recorder: record.NewFakeRecorder(32),
+	}
+	g.Expect(r.cleanupDeployment(oldMSs, deployment)).To(Succeed())
+
+	for i := 0; i < 15; i++ {
+		err := r.Client.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: fmt.Sprintf("ms-%d", i)}, &clusterv1.MachineSet{})
+		if i < 5 {
+			g.Expect(apierrors.IsNotFound(err)).To(BeTrue(), "expected MachineSet ms-%d to be deleted", i)
+		} else {
+			g.Expect(err).NotTo(HaveOccurred(), "expected MachineSet ms-%d to be retained", i)
+		}
+	}
+}
+
+// quotaExceededClient is a client rejecting the creation of any object with a ResourceQuota error.
+type quotaExceededClient struct {
+	client.Client
+}
+
+func (c *quotaExceededClient) Create(_ context.Context, obj runtime.Object, _ ...client.CreateOption) error {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return err
+	}
+	return apierrors.NewForbidden(schema.GroupResource{Group: clusterv1.GroupVersion.Group, Resource: "machinesets"}, accessor.GetName(),
+		errors.New("exceeded quota: md-quota, requested: count/machinesets.cluster.x-k8s.io=1, used: count/machinesets.cluster.x-k8s.io=1, limited: count/machinesets.cluster.x-k8s.io=1"))
+}
+
+func TestGetNewMachineSetQuotaExceeded(t *testing.T) {
+	g := NewWithT(t)
+
+	deployment := &clusterv1.MachineDeployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "md",
+			Namespace: "default",
+		},
+		Spec: clusterv1.MachineDeploymentSpec{
+			ClusterName: "test-cluster",
+			Replicas:    pointer.Int32Ptr(1),
+			Selector: metav1.LabelSelector{
+				MatchLabels: map[string]string{"foo": "bar"},
+			},
+			Template: clusterv1.MachineTemplateSpec{
+				ObjectMeta: clusterv1.ObjectMeta{
+					Labels: map[string]string{"foo": "bar"},
+				},
+			},
+		},
+	}
+	clusterv1.PopulateDefaultsMachineDeployment(deployment)
+
+	fakeClient := fake.NewFakeClientWithScheme(scheme.Scheme, deployment.DeepCopy())
+	recorder := record.NewFakeRecorder(32)
+	r := &MachineDeploymentReconciler{
+		Client:   &quotaExceededClient{Client: fakeClient},
+		Log:      log.Log,
+		recorder: recorder,
+	}
+
+	// Creation attempts rejected by the quota are retried with an increasing delay.
+	for _, expectedDelay := range []time.Duration{quotaExceededBaseDelay, 2 * quotaExceededBaseDelay} {
+		ms, err := r.getNewMachineSet(deployment, nil, nil, true)
+		g.Expect(ms).To(BeNil())
+		g.Expect(err).To(HaveOccurred())
+
+		requeueErr, ok := errors.Cause(err).(capierrors.HasRequeueAfterError)
+		g.Expect(ok).To(BeTrue())
+		g.Expect(requeueErr.GetRequeueAfter()).To(Equal(expectedDelay))
+		g.Expect(recorder.Events).To(Receive(ContainSubstring("QuotaExceeded")))
+	}
+
+	// A successful creation resets the delay.
+	r.Client = fakeClient
+	ms, err := r.getNewMachineSet(deployment, nil, nil, true)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ms).NotTo(BeNil())
+	g.Expect(r.quotaExceededBackoff().NumRequeues(util.ObjectKey(deployment))).To(BeZero())
+}
+
+func TestGetNewMachineSetTopologySpreadConstraints(t *testing.T) {
+	g := NewWithT(t)
+
+	deployment := &clusterv1.MachineDeployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "md",
+			Namespace: "default",
+		},
+		Spec: clusterv1.MachineDeploymentSpec{
+			ClusterName: "test-cluster",
+			Replicas:    pointer.Int32Ptr(1),
+			Selector: metav1.LabelSelector{
+				MatchLabels: map[string]string{"foo": "bar"},
+			},
+			Template: clusterv1.MachineTemplateSpec{
+				ObjectMeta: clusterv1.ObjectMeta{
+					Labels: map[string]string{"foo": "bar"},
+				},
+			},
+			TopologySpreadConstraints: []clusterv1.TopologySpreadConstraint{
+				{MaxSkew: 1, WhenUnsatisfiable: corev1.DoNotSchedule},
+			},
+		},
+	}
+	clusterv1.PopulateDefaultsMachineDeployment(deployment)
+
+	r := &MachineDeploymentReconciler{
+		Client:   fake.NewFakeClientWithScheme(scheme.Scheme, deployment.DeepCopy()),
+		Log:      log.Log,
+		recorder: record.NewFakeRecorder(32),
+	}
+
+	// The new MachineSet is created with the constraints of the deployment.
+	ms, err := r.getNewMachineSet(deployment, nil, nil, true)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ms.Spec.TopologySpreadConstraints).To(Equal(deployment.Spec.TopologySpreadConstraints))
+
+	// The existing new MachineSet is updated when the constraints of the deployment change.
+	deployment.Spec.TopologySpreadConstraints[0].MaxSkew = 2
+	_, err = r.getNewMachineSet(deployment, []*clusterv1.MachineSet{ms}, nil, true)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	updated := &clusterv1.MachineSet{}
+	g.Expect(r.Client.Get(context.Background(), util.ObjectKey(ms), updated)).To(Succeed())
+	g.Expect(updated.Spec.TopologySpreadConstraints).To(Equal(deployment.Spec.TopologySpreadConstraints))
+}
@@ -121,25 +121,25 @@ func (t *healthCheckTarget) needsRemediation(logger logr.Logger, timeoutForMachi
 
 	// check conditions
 	for _, c := range t.MHC.Spec.UnhealthyConditions {
-		nodeCondition := getNodeCondition(t.Node, c.Type)
-
-		// Skip when current node condition is different from the one reported
-		// in the MachineHealthCheck.
-		if nodeCondition == nil || nodeCondition.Status != c.Status {
-			continue
-		}
+		for _, nodeCondition := range getNodeConditions(t.Node, c.Type, t.MHC.Spec.ConditionAliases) {
+			// Skip when current node condition is different from the one reported
+			// in the MachineHealthCheck.
+			if nodeCondition.Status != c.Status {
+				continue
+			}
 
-		// If the condition has been in the unhealthy state for longer than the
-		// timeout, return true with no requeue time.
-		if nodeCondition.LastTransitionTime.Add(c.Timeout.Duration).Before(now) {
-			logger.V(3).Info("Target is unhealthy: condition is in state longer than allowed timeout", "condition", c.Type, "state", c.Status, "timeout", c.Timeout.Duration.String())
-			return true, time.Duration(0)
-		}
+			// If the condition has been in the unhealthy state for longer than the
+			// timeout, return true with no requeue time.
+			if nodeCondition.LastTransitionTime.Add(c.Timeout.Duration).Before(now) {
+				logger.V(3).Info("Target is unhealthy: condition is in state longer than allowed timeout", "condition", nodeCondition.Type, "state", c.Status, "timeout", c.Timeout.Duration.String())
+				return true, time.Duration(0)
+			}
 
-		durationUnhealthy := now.Sub(nodeCondition.LastTransitionTime.Time)
-		nextCheck := c.Timeout.Duration - durationUnhealthy + time.Second
-		if nextCheck > 0 {
-			nextCheckTimes = append(nextCheckTimes, nextCheck)
+			durationUnhealthy := now.Sub(nodeCondition.LastTransitionTime.Time)
+			nextCheck := c.Timeout.Duration - durationUnhealthy + time.Second
+			if nextCheck > 0 {
+				nextCheckTimes = append(nextCheckTimes, nextCheck)
+			}
 		}
 	}
 	return false, minDuration(nextCheckTimes)
@@ -249,14 +249,16 @@ func (r *MachineHealthCheckReconciler) healthCheckTargets(targets []healthCheckT
 	return currentHealthy, needRemediationTargets, nextCheckTimes
 }
 
-// getNodeCondition returns node condition by type
-func getNodeCondition(node *corev1.Node, conditionType corev1.NodeConditionType) *corev1.NodeCondition {
+// getNodeConditions returns the node conditions of the given type, including
+// the conditions whose type is mapped to it in aliases.
+func getNodeConditions(node *corev1.Node, conditionType corev1.NodeConditionType, aliases map[string]string) []corev1.NodeCondition {
+	var conditions []corev1.NodeCondition
 	for _, cond := range node.Status.Conditions {
-		if cond.Type == conditionType {
-			return &cond
+		if cond.Type == conditionType || corev1.NodeConditionType(aliases[string(cond.Type)]) == conditionType {
+			conditions = append(conditions, cond)
 		}
 	}
-	return nil
+	return conditions
 }
 
 func minDuration(durations []time.Duration) time.Duration {
@@ -248,6 +248,27 @@ func TestHealthCheckTargets(t *testing.T) {
 		nodeMissing: false,
 	}
 
+	// Target for when a provider specific condition aliased to Ready has been false for longer than the timeout
+	testMHCWithAliases := testMHC.DeepCopy()
+	testMHCWithAliases.Spec.ConditionAliases = map[string]string{"CustomReady": string(corev1.NodeReady)}
+	testNodeCustomFalse400 := newTestUnhealthyNode("node1", "CustomReady", corev1.ConditionFalse, 400*time.Second)
+	testNodeCustomFalse400.Status.Conditions = append(testNodeCustomFalse400.Status.Conditions, corev1.NodeCondition{
+		Type:   corev1.NodeReady,
+		Status: corev1.ConditionTrue,
+	})
+	nodeCustomFalse400 := healthCheckTarget{
+		MHC:         testMHCWithAliases,
+		Machine:     testMachine,
+		Node:        testNodeCustomFalse400,
+		nodeMissing: false,
+	}
+	nodeCustomFalse400NoAliases := healthCheckTarget{
+		MHC:         testMHC,
+		Machine:     testMachine,
+		Node:        testNodeCustomFalse400,
+		nodeMissing: false,
+	}
+
 	testCases := []struct {
 		desc                     string
 		targets                  []healthCheckTarget
@@ -297,6 +318,20 @@ func TestHealthCheckTargets(t *testing.T) {
 			expectedNeedsRemediation: []healthCheckTarget{nodeUnknown400},
 			expectedNextCheckTimes:   []time.Duration{200 * time.Second, 100 * time.Second},
 		},
+		{
+			desc:                     "when an aliased node condition has been unhealthy for longer than the timeout",
+			targets:                  []healthCheckTarget{nodeCustomFalse400},
+			expectedHealthy:          0,
+			expectedNeedsRemediation: []healthCheckTarget{nodeCustomFalse400},
+			expectedNextCheckTimes:   []time.Duration{},
+		},
+		{
+			desc:                     "when a provider specific node condition is not aliased",
+			targets:                  []healthCheckTarget{nodeCustomFalse400NoAliases},
+			expectedHealthy:          1,
+			expectedNeedsRemediation: []healthCheckTarget{},
+			expectedNextCheckTimes:   []time.Duration{},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -19,6 +19,8 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -26,17 +28,24 @@ import (
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	apirand "k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
 	"sigs.k8s.io/cluster-api/controllers/external"
+	"sigs.k8s.io/cluster-api/controllers/mdutil"
 	"sigs.k8s.io/cluster-api/controllers/noderefutil"
 	"sigs.k8s.io/cluster-api/controllers/remote"
+	capierrors "sigs.k8s.io/cluster-api/errors"
 	"sigs.k8s.io/cluster-api/util"
 	utilconversion "sigs.k8s.io/cluster-api/util/conversion"
 	"sigs.k8s.io/cluster-api/util/patch"
@@ -59,6 +68,12 @@ var (
 	stateConfirmationInterval = 100 * time.Millisecond
 )
 
+// maxFailedMachinesInStatus is the maximum number of Machine names listed in status.failedMachines.
+const maxFailedMachinesInStatus = 10
+
+// defaultStatusConcurrency is used when MachineSetReconciler.StatusConcurrency is not set.
+const defaultStatusConcurrency = 10
+
 // +kubebuilder:rbac:groups=core,resources=events,verbs=get;list;watch;create;patch
 // +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch
 // +kubebuilder:rbac:groups=core,resources=nodes,verbs=get;list;watch;create;update;patch;delete
@@ -70,6 +85,10 @@ type MachineSetReconciler struct {
 	Client client.Client
 	Log    logr.Logger
 
+	// StatusConcurrency is the maximum number of Nodes read in parallel when computing the status
+	// of a MachineSet. Defaults to 10.
+	StatusConcurrency int
+
 	recorder record.EventRecorder
 	scheme   *runtime.Scheme
 }
@@ -205,8 +224,12 @@ func (r *MachineSetReconciler) reconcile(ctx context.Context, cluster *clusterv1
 
 	// Filter out irrelevant machines (deleting/mismatch labels) and claim orphaned machines.
 	filteredMachines := make([]*clusterv1.Machine, 0, len(allMachines.Items))
+	deletingMachines := 0
 	for idx := range allMachines.Items {
 		machine := &allMachines.Items[idx]
+		if metav1.IsControlledBy(machine, machineSet) && !machine.DeletionTimestamp.IsZero() {
+			deletingMachines++
+		}
 		if shouldExcludeMachine(machineSet, machine, logger) {
 			continue
 		}
@@ -225,7 +248,7 @@ func (r *MachineSetReconciler) reconcile(ctx context.Context, cluster *clusterv1
 		filteredMachines = append(filteredMachines, machine)
 	}
 
-	syncErr := r.syncReplicas(ctx, machineSet, filteredMachines)
+	syncErr := r.syncReplicas(ctx, cluster, machineSet, filteredMachines, deletingMachines)
 
 	ms := machineSet.DeepCopy()
 	newStatus, err := r.calculateStatus(ctx, cluster, ms, filteredMachines)
@@ -242,7 +265,15 @@ func (r *MachineSetReconciler) reconcile(ctx context.Context, cluster *clusterv1
 		return ctrl.Result{}, errors.Wrap(err, "failed to patch MachineSet's Status")
 	}
 
+	if err := r.patchFailedMachinesCount(ctx, updatedMS, len(failedMachineNames(filteredMachines))); err != nil {
+		return ctrl.Result{}, err
+	}
+
 	if syncErr != nil {
+		if requeueErr, ok := errors.Cause(syncErr).(capierrors.HasRequeueAfterError); ok {
+			logger.Info("Failed to sync MachineSet replicas, requeuing", "reason", syncErr.Error())
+			return ctrl.Result{RequeueAfter: requeueErr.GetRequeueAfter()}, nil
+		}
 		return ctrl.Result{}, errors.Wrapf(syncErr, "failed to sync MachineSet replicas")
 	}
 
@@ -274,8 +305,9 @@ func (r *MachineSetReconciler) reconcile(ctx context.Context, cluster *clusterv1
 	return ctrl.Result{}, nil
 }
 
-// syncReplicas scales Machine resources up or down.
-func (r *MachineSetReconciler) syncReplicas(ctx context.Context, ms *clusterv1.MachineSet, machines []*clusterv1.Machine) error {
+// syncReplicas scales Machine resources up or down. The number of Machines of the MachineSet being deleted
+// is used to bound the number of replacements created according to the MaxSurge of the update strategy.
+func (r *MachineSetReconciler) syncReplicas(ctx context.Context, cluster *clusterv1.Cluster, ms *clusterv1.MachineSet, machines []*clusterv1.Machine, deleting int) error {
 	logger := r.Log.WithValues("machineset", ms.Name, "namespace", ms.Namespace)
 	if ms.Spec.Replicas == nil {
 		return errors.Errorf("the Replicas field in Spec for machineset %v is nil, this should not be allowed", ms.Name)
@@ -285,15 +317,49 @@ func (r *MachineSetReconciler) syncReplicas(ctx context.Context, ms *clusterv1.M
 
 	if diff < 0 {
 		diff *= -1
+		if ms.Spec.UpdateStrategy != nil && deleting > 0 {
+			surge, _, err := mdutil.ComputeDesiredReplicas(*ms.Spec.Replicas, *ms.Spec.UpdateStrategy)
+			if err != nil {
+				return errors.Wrapf(err, "failed to resolve the update strategy of MachineSet %q in namespace %q", ms.Name, ms.Namespace)
+			}
+			// The Machines being deleted are counted until they are gone, so that their replacements
+			// don't exceed the desired replicas by more than MaxSurge.
+			if allowed := int(*ms.Spec.Replicas+surge) - len(machines) - deleting; allowed < diff {
+				logger.Info("Waiting for Machines being deleted to be gone, they count towards maxSurge",
+					"deleting", deleting, "maxSurge", surge, "creating", allowed)
+				if allowed <= 0 {
+					return nil
+				}
+				diff = allowed
+			}
+		}
 		logger.Info("Too few replicas", "need", *(ms.Spec.Replicas), "creating", diff)
 
 		var machineList []*clusterv1.Machine
 		var errstrings []string
+
+		// Machines counted to spread the new Machines across failure domains, and names not to give to the new Machines.
+		existing := make([]clusterv1.Machine, 0, len(machines)+diff)
+		takenNames := sets.NewString()
+		for _, m := range machines {
+			existing = append(existing, *m)
+			takenNames.Insert(m.Name)
+		}
+
 		for i := 0; i < diff; i++ {
 			logger.Info(fmt.Sprintf("Creating machine %d of %d, ( spec.replicas(%d) > currentMachineCount(%d) )",
 				i+1, diff, *(ms.Spec.Replicas), len(machines)))
 
-			machine := r.getNewMachine(ms)
+			machine := r.getNewMachine(ms, takenNames)
+
+			if len(ms.Spec.TopologySpreadConstraints) > 0 && len(cluster.Status.FailureDomains) > 0 {
+				failureDomain, err := SelectFailureDomainForNewMachine(existing, cluster.Status.FailureDomains, ms.Spec.TopologySpreadConstraints)
+				if err != nil {
+					r.recorder.Eventf(ms, corev1.EventTypeWarning, "FailedCreate", "Failed to select a failure domain for a new machine: %v", err)
+					return errors.Wrapf(err, "failed to select a failure domain for a new Machine of MachineSet %q in namespace %q", ms.Name, ms.Namespace)
+				}
+				machine.Spec.FailureDomain = pointer.StringPtr(failureDomain)
+			}
 
 			// Clone and set the infrastructure and bootstrap references.
 			var (
@@ -327,7 +393,14 @@ func (r *MachineSetReconciler) syncReplicas(ctx context.Context, ms *clusterv1.M
 			}
 			machine.Spec.InfrastructureRef = *infraRef
 
-			if err := r.Client.Create(ctx, machine); err != nil {
+			err = r.Client.Create(ctx, machine)
+			for ms.Spec.MachineNamingStrategy != nil && apierrors.IsAlreadyExists(err) {
+				// The name is used by a Machine that isn't part of the MachineSet anymore, e.g. one being deleted.
+				takenNames.Insert(machine.Name)
+				machine.Name = newMachineName(ms, takenNames)
+				err = r.Client.Create(ctx, machine)
+			}
+			if err != nil {
 				logger.Error(err, "Unable to create Machine", "machine", machine.Name)
 				r.recorder.Eventf(ms, corev1.EventTypeWarning, "FailedCreate", "Failed to create machine %q: %v", machine.Name, err)
 				errstrings = append(errstrings, err.Error())
@@ -355,6 +428,8 @@ func (r *MachineSetReconciler) syncReplicas(ctx context.Context, ms *clusterv1.M
 			r.recorder.Eventf(ms, corev1.EventTypeNormal, "SuccessfulCreate", "Created machine %q", machine.Name)
 
 			machineList = append(machineList, machine)
+			existing = append(existing, *machine)
+			takenNames.Insert(machine.Name)
 		}
 
 		if len(errstrings) > 0 {
@@ -370,12 +445,33 @@ func (r *MachineSetReconciler) syncReplicas(ctx context.Context, ms *clusterv1.M
 			return err
 		}
 		logger.Info("Found delete policy", "delete-policy", ms.Spec.DeletePolicy)
-		// Choose which Machines to delete.
-		machinesToDelete := getMachinesToDeletePrioritized(machines, diff, deletePriorityFunc)
+		// Choose which Machines to delete, skipping the ones that can't be deleted
+		// without violating a PodDisruptionBudget in favor of the next candidates.
+		// The scale down isn't blocked by an unreachable workload cluster: its PodDisruptionBudgets are not checked.
+		var remoteClient client.Client
+		for _, m := range machines {
+			if m.Status.NodeRef != nil {
+				remoteClient, err = remote.NewClusterClient(ctx, r.Client, util.ObjectKey(cluster), r.scheme)
+				if err != nil {
+					logger.Error(err, "Failed to create a remote client for the cluster, deleting Machines without checking PodDisruptionBudgets")
+					remoteClient = nil
+				}
+				break
+			}
+		}
+		machinesToDelete, skipped, err := selectMachinesToDelete(ctx, logger, remoteClient, machines, diff, deletePriorityFunc)
+		if err != nil {
+			return err
+		}
+		for _, machine := range skipped {
+			logger.Info("Skipping deletion of Machine, deleting its Node would violate a PodDisruptionBudget", "machine", machine.Name)
+			r.recorder.Eventf(ms, corev1.EventTypeWarning, "PodDisruptionBudgetViolation",
+				"Skipped deletion of machine %q, deleting its node would violate a PodDisruptionBudget", machine.Name)
+		}
 
-		errCh := make(chan error, diff)
+		errCh := make(chan error, len(machinesToDelete))
 		var wg sync.WaitGroup
-		wg.Add(diff)
+		wg.Add(len(machinesToDelete))
 		for _, machine := range machinesToDelete {
 			go func(targetMachine *clusterv1.Machine) {
 				defer wg.Done()
@@ -400,15 +496,75 @@ func (r *MachineSetReconciler) syncReplicas(ctx context.Context, ms *clusterv1.M
 			return kerrors.NewAggregate(errs)
 		}
 
-		return r.waitForMachineDeletion(machinesToDelete)
+		if err := r.waitForMachineDeletion(machinesToDelete); err != nil {
+			return err
+		}
+
+		if len(machinesToDelete) < diff {
+			return errors.Wrapf(&capierrors.RequeueAfterError{RequeueAfter: 30 * time.Second},
+				"deleted %d of %d machines, the others would violate a PodDisruptionBudget", len(machinesToDelete), diff)
+		}
 	}
 
 	return nil
 }
 
-// getNewMachine creates a new Machine object. The name of the newly created resource is going
-// to be created by the API server, we set the generateName field.
-func (r *MachineSetReconciler) getNewMachine(machineSet *clusterv1.MachineSet) *clusterv1.Machine {
+// selectMachinesToDelete returns up to diff Machines, chosen by getMachinesToDeletePrioritized, that can be deleted
+// together without violating a PodDisruptionBudget targeting the pods on their Nodes; the budgets are checked against
+// the evictions of all the selected Machines. The Machines that were skipped because of a PodDisruptionBudget are
+// replaced by the next candidates, and returned as well. The remote client is used to retrieve the Nodes; without it,
+// the Machines are selected without checking PodDisruptionBudgets.
+func selectMachinesToDelete(ctx context.Context, logger logr.Logger, remoteClient client.Client, machines []*clusterv1.Machine, diff int, priority deletePriorityFunc) ([]*clusterv1.Machine, []*clusterv1.Machine, error) {
+	var selected, skipped []*clusterv1.Machine
+	tracker := util.NewPDBEvictionTracker(remoteClient)
+
+	// getMachinesToDeletePrioritized sorts the machines it's given, don't reorder the caller's slice.
+	remaining := append([]*clusterv1.Machine{}, machines...)
+	for len(selected) < diff && len(remaining) > 0 {
+		candidates := getMachinesToDeletePrioritized(remaining, diff-len(selected), priority)
+		remaining = remaining[len(candidates):]
+
+		for _, machine := range candidates {
+			ok, err := canDeleteMachine(ctx, logger, remoteClient, tracker, machine)
+			if err != nil {
+				return nil, nil, err
+			}
+			if !ok {
+				skipped = append(skipped, machine)
+				continue
+			}
+			selected = append(selected, machine)
+		}
+	}
+	return selected, skipped, nil
+}
+
+// canDeleteMachine returns false if draining the Node of the Machine, in addition to the Nodes already counted by
+// the tracker, would violate a PodDisruptionBudget. The Machine can be deleted if its Node can't be retrieved.
+func canDeleteMachine(ctx context.Context, logger logr.Logger, remoteClient client.Client, tracker *util.PDBEvictionTracker, machine *clusterv1.Machine) (bool, error) {
+	if machine.Status.NodeRef == nil || !machine.DeletionTimestamp.IsZero() || remoteClient == nil {
+		return true, nil
+	}
+
+	node := &corev1.Node{}
+	if err := remoteClient.Get(ctx, client.ObjectKey{Name: machine.Status.NodeRef.Name}, node); err != nil {
+		if !apierrors.IsNotFound(err) {
+			logger.Error(err, "Failed to retrieve the node of the Machine, deleting it without checking PodDisruptionBudgets",
+				"machine", machine.Name, "node", machine.Status.NodeRef.Name)
+		}
+		return true, nil
+	}
+
+	violated, err := tracker.Evict(ctx, node)
+	if err != nil {
+		return false, err
+	}
+	return !violated, nil
+}
+
+// getNewMachine creates a new Machine object. Unless the MachineSet has a MachineNamingStrategy,
+// the name of the newly created resource is going to be created by the API server, we set the generateName field.
+func (r *MachineSetReconciler) getNewMachine(machineSet *clusterv1.MachineSet, takenNames sets.String) *clusterv1.Machine {
 	gv := clusterv1.GroupVersion
 	machine := &clusterv1.Machine{
 		TypeMeta: metav1.TypeMeta{
@@ -416,21 +572,44 @@ func (r *MachineSetReconciler) getNewMachine(machineSet *clusterv1.MachineSet) *
 			APIVersion: gv.String(),
 		},
 		ObjectMeta: metav1.ObjectMeta{
-			Labels:      machineSet.Spec.Template.Labels,
+			Labels:      make(map[string]string, len(machineSet.Spec.Template.Labels)+1),
 			Annotations: machineSet.Spec.Template.Annotations,
 		},
 		Spec: machineSet.Spec.Template.Spec,
 	}
-	machine.ObjectMeta.GenerateName = fmt.Sprintf("%s-", machineSet.Name)
-	machine.ObjectMeta.OwnerReferences = []metav1.OwnerReference{*metav1.NewControllerRef(machineSet, machineSetKind)}
+	// Merge the template labels with the cluster label, without modifying the template.
+	for k, v := range machineSet.Spec.Template.Labels {
+		machine.Labels[k] = v
+	}
+	machine.Labels[clusterv1.ClusterLabelName] = machineSet.Spec.ClusterName
+	if machineSet.Spec.MachineNamingStrategy != nil {
+		machine.Name = newMachineName(machineSet, takenNames)
+	} else {
+		machine.ObjectMeta.GenerateName = fmt.Sprintf("%s-", machineSet.Name)
+	}
+	// Block the deletion of the MachineSet by the garbage collector until its Machines are gone.
+	machine.ObjectMeta.OwnerReferences = util.EnsureOwnerRefWithOptions(nil, metav1.OwnerReference{
+		APIVersion: machineSetKind.GroupVersion().String(),
+		Kind:       machineSetKind.Kind,
+		Name:       machineSet.Name,
+		UID:        machineSet.UID,
+	}, true, true)
 	machine.Namespace = machineSet.Namespace
 	machine.Spec.ClusterName = machineSet.Spec.ClusterName
-	if machine.Labels == nil {
-		machine.Labels = make(map[string]string)
-	}
 	return machine
 }
 
+// newMachineName returns a name generated from the MachineNamingStrategy of the MachineSet
+// that isn't one of the taken names, trying the indexes in increasing order.
+func newMachineName(machineSet *clusterv1.MachineSet, takenNames sets.String) string {
+	for index := 0; ; index++ {
+		name := machineSet.Spec.MachineNamingStrategy.MachineName(machineSet.Name, index, apirand.String(clusterv1.MachineNameRandomLength))
+		if !takenNames.Has(name) {
+			return name
+		}
+	}
+}
+
 // shouldExcludeMachine returns true if the machine should be filtered out, false otherwise.
 func shouldExcludeMachine(machineSet *clusterv1.MachineSet, machine *clusterv1.Machine, logger logr.Logger) bool {
 	if metav1.GetControllerOf(machine) != nil && !metav1.IsControlledBy(machine, machineSet) {
@@ -585,6 +764,53 @@ func (r *MachineSetReconciler) shouldAdopt(ms *clusterv1.MachineSet) bool {
 
 func (r *MachineSetReconciler) calculateStatus(ctx context.Context, cluster *clusterv1.Cluster, ms *clusterv1.MachineSet, filteredMachines []*clusterv1.Machine) (*clusterv1.MachineSetStatus, error) {
 	logger := r.Log.WithValues("machineset", ms.Name, "namespace", ms.Namespace)
+	newRemoteClient := func() (client.Client, error) {
+		return remote.NewClusterClient(ctx, r.Client, util.ObjectKey(cluster), r.scheme)
+	}
+
+	concurrency := r.StatusConcurrency
+	if concurrency <= 0 {
+		concurrency = defaultStatusConcurrency
+	}
+	return computeMachineSetStatus(ctx, logger, newRemoteClient, ms, filteredMachines, concurrency)
+}
+
+// ComputeMachineSetStatus computes the status of the MachineSet from the Machines it controls, read with the client c,
+// and from the Nodes of these Machines, read from the workload cluster with up to 10 reads in parallel.
+// The Nodes that can't be read are not counted as ready.
+func ComputeMachineSetStatus(ctx context.Context, c client.Client, ms *clusterv1.MachineSet) (clusterv1.MachineSetStatus, error) {
+	logger := ctrl.Log.WithName("controllers").WithName("MachineSet").WithValues("machineset", ms.Name, "namespace", ms.Namespace)
+
+	selector, err := metav1.LabelSelectorAsSelector(&ms.Spec.Selector)
+	if err != nil {
+		return clusterv1.MachineSetStatus{}, errors.Wrapf(err, "failed to calculate status for MachineSet %s/%s", ms.Namespace, ms.Name)
+	}
+	machineList := &clusterv1.MachineList{}
+	if err := c.List(ctx, machineList, client.InNamespace(ms.Namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return clusterv1.MachineSetStatus{}, errors.Wrapf(err, "failed to list machines of MachineSet %s/%s", ms.Namespace, ms.Name)
+	}
+	machines := make([]*clusterv1.Machine, 0, len(machineList.Items))
+	for i := range machineList.Items {
+		machine := &machineList.Items[i]
+		if !metav1.IsControlledBy(machine, ms) || !machine.DeletionTimestamp.IsZero() {
+			continue
+		}
+		machines = append(machines, machine)
+	}
+
+	newRemoteClient := func() (client.Client, error) {
+		return remote.NewClusterClient(ctx, c, client.ObjectKey{Namespace: ms.Namespace, Name: ms.Spec.ClusterName}, scheme.Scheme)
+	}
+	status, err := computeMachineSetStatus(ctx, logger, newRemoteClient, ms, machines, defaultStatusConcurrency)
+	if err != nil {
+		return clusterv1.MachineSetStatus{}, err
+	}
+	return *status, nil
+}
+
+// computeMachineSetStatus computes the status of the MachineSet from the given Machines. The Nodes of the Machines
+// are read with the client returned by newRemoteClient, running up to concurrency reads at the same time.
+func computeMachineSetStatus(ctx context.Context, logger logr.Logger, newRemoteClient func() (client.Client, error), ms *clusterv1.MachineSet, machines []*clusterv1.Machine, concurrency int) (*clusterv1.MachineSetStatus, error) {
 	newStatus := ms.Status.DeepCopy()
 
 	// Copy label selector to its status counterpart in string format.
@@ -605,7 +831,8 @@ func (r *MachineSetReconciler) calculateStatus(ctx context.Context, cluster *clu
 	availableReplicasCount := 0
 	templateLabel := labels.Set(ms.Spec.Template.Labels).AsSelectorPreValidated()
 
-	for _, machine := range filteredMachines {
+	var machinesWithNodeRef []*clusterv1.Machine
+	for _, machine := range machines {
 		if templateLabel.Matches(labels.Set(machine.Labels)) {
 			fullyLabeledReplicasCount++
 		}
@@ -614,28 +841,84 @@ func (r *MachineSetReconciler) calculateStatus(ctx context.Context, cluster *clu
 			logger.V(2).Info("Unable to retrieve Node status, missing NodeRef", "machine", machine.Name)
 			continue
 		}
+		machinesWithNodeRef = append(machinesWithNodeRef, machine)
+	}
 
-		node, err := r.getMachineNode(ctx, cluster, machine)
+	if len(machinesWithNodeRef) > 0 {
+		remoteClient, err := newRemoteClient()
 		if err != nil {
-			logger.Error(err, "Unable to retrieve Node status")
-			continue
+			logger.Error(err, "Unable to retrieve Node status, failed to create a remote client")
+			machinesWithNodeRef = nil
 		}
 
-		if noderefutil.IsNodeReady(node) {
-			readyReplicasCount++
-			if noderefutil.IsNodeAvailable(node, ms.Spec.MinReadySeconds, metav1.Now()) {
-				availableReplicasCount++
+		nodes, errs := getMachineNodes(ctx, remoteClient, machinesWithNodeRef, concurrency)
+		for i, node := range nodes {
+			if errs[i] != nil {
+				logger.Error(errs[i], "Unable to retrieve Node status")
+				continue
+			}
+
+			if noderefutil.IsNodeReady(node) {
+				readyReplicasCount++
+				if noderefutil.IsNodeAvailable(node, ms.Spec.MinReadySeconds, metav1.Now()) {
+					availableReplicasCount++
+				}
 			}
 		}
 	}
 
-	newStatus.Replicas = int32(len(filteredMachines))
+	failedMachines := failedMachineNames(machines)
+	if len(failedMachines) > maxFailedMachinesInStatus {
+		failedMachines = failedMachines[:maxFailedMachinesInStatus]
+	}
+
+	newStatus.Replicas = int32(len(machines))
 	newStatus.FullyLabeledReplicas = int32(fullyLabeledReplicasCount)
 	newStatus.ReadyReplicas = int32(readyReplicasCount)
 	newStatus.AvailableReplicas = int32(availableReplicasCount)
+	newStatus.FailedMachines = failedMachines
 	return newStatus, nil
 }
 
+// failedMachineNames returns the sorted names of the machines in the Failed phase.
+func failedMachineNames(machines []*clusterv1.Machine) []string {
+	var names []string
+	for _, m := range machines {
+		if m.Status.GetTypedPhase() == clusterv1.MachinePhaseFailed {
+			names = append(names, m.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// patchFailedMachinesCount records the number of failed Machines in the FailedMachinesCountAnnotation
+// annotation of the MachineSet, removing it if there are none.
+func (r *MachineSetReconciler) patchFailedMachinesCount(ctx context.Context, ms *clusterv1.MachineSet, count int) error {
+	value, ok := ms.Annotations[clusterv1.FailedMachinesCountAnnotation]
+	switch {
+	case count == 0 && !ok:
+		return nil
+	case count != 0 && value == strconv.Itoa(count):
+		return nil
+	}
+
+	patch := client.MergeFrom(ms.DeepCopy())
+	if count == 0 {
+		delete(ms.Annotations, clusterv1.FailedMachinesCountAnnotation)
+	} else {
+		if ms.Annotations == nil {
+			ms.Annotations = map[string]string{}
+		}
+		ms.Annotations[clusterv1.FailedMachinesCountAnnotation] = strconv.Itoa(count)
+	}
+	// Patch using a deep copy to avoid overwriting any unexpected Status changes from the returned result
+	if err := r.Client.Patch(ctx, ms.DeepCopy(), patch); err != nil {
+		return errors.Wrapf(err, "failed to patch the %s annotation of MachineSet %s/%s", clusterv1.FailedMachinesCountAnnotation, ms.Namespace, ms.Name)
+	}
+	return nil
+}
+
 // patchMachineSetStatus attempts to update the Status.Replicas of the given MachineSet.
 func (r *MachineSetReconciler) patchMachineSetStatus(ctx context.Context, ms *clusterv1.MachineSet, newStatus *clusterv1.MachineSetStatus) (*clusterv1.MachineSet, error) {
 	logger := r.Log.WithValues("machineset", ms.Name, "namespace", ms.Namespace)
@@ -647,6 +930,7 @@ func (r *MachineSetReconciler) patchMachineSetStatus(ctx context.Context, ms *cl
 		ms.Status.FullyLabeledReplicas == newStatus.FullyLabeledReplicas &&
 		ms.Status.ReadyReplicas == newStatus.ReadyReplicas &&
 		ms.Status.AvailableReplicas == newStatus.AvailableReplicas &&
+		equality.Semantic.DeepEqual(ms.Status.FailedMachines, newStatus.FailedMachines) &&
 		ms.Generation == ms.Status.ObservedGeneration {
 		return ms, nil
 	}
@@ -686,11 +970,30 @@ func (r *MachineSetReconciler) patchMachineSetStatus(ctx context.Context, ms *cl
 	return ms, nil
 }
 
-func (r *MachineSetReconciler) getMachineNode(ctx context.Context, cluster *clusterv1.Cluster, machine *clusterv1.Machine) (*corev1.Node, error) {
-	c, err := remote.NewClusterClient(ctx, r.Client, util.ObjectKey(cluster), r.scheme)
-	if err != nil {
-		return nil, err
+// getMachineNodes reads the Nodes referenced by the given Machines with the client c, running up to
+// concurrency reads at the same time. The returned Nodes and errors are at the index of their Machine.
+func getMachineNodes(ctx context.Context, c client.Client, machines []*clusterv1.Machine, concurrency int) ([]*corev1.Node, []error) {
+	nodes := make([]*corev1.Node, len(machines))
+	errs := make([]error, len(machines))
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+	for i := range machines {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			nodes[i], errs[i] = getMachineNode(ctx, c, machines[i])
+		}(i)
 	}
+	wg.Wait()
+	return nodes, errs
+}
+
+func getMachineNode(ctx context.Context, c client.Client, machine *clusterv1.Machine) (*corev1.Node, error) {
 	node := &corev1.Node{}
 	if err := c.Get(ctx, client.ObjectKey{Name: machine.Status.NodeRef.Name}, node); err != nil {
 		return nil, errors.Wrapf(err, "error retrieving node %s for machine %s/%s", machine.Status.NodeRef.Name, machine.Namespace, machine.Name)
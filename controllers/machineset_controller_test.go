@@ -18,19 +18,27 @@ package controllers
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"testing"
 	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
 
 	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/klog/klogr"
+	"k8s.io/utils/pointer"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
@@ -667,6 +675,300 @@ func TestAdoptOrphan(t *testing.T) {
 	}
 }
 
+func TestMachineSetCalculateStatusFailedMachines(t *testing.T) {
+	machinesInPhase := func(phase clusterv1.MachinePhase, count int) []*clusterv1.Machine {
+		machines := make([]*clusterv1.Machine, 0, count)
+		for i := 0; i < count; i++ {
+			m := &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("%s-%02d", strings.ToLower(string(phase)), i)}}
+			m.Status.SetTypedPhase(phase)
+			machines = append(machines, m)
+		}
+		return machines
+	}
+
+	testCases := []struct {
+		name     string
+		machines []*clusterv1.Machine
+		expected []string
+	}{
+		{
+			name:     "no failed machines",
+			machines: machinesInPhase(clusterv1.MachinePhaseRunning, 2),
+		},
+		{
+			name:     "all the failed machines are listed",
+			machines: append(machinesInPhase(clusterv1.MachinePhaseRunning, 2), machinesInPhase(clusterv1.MachinePhaseFailed, 5)...),
+			expected: []string{"failed-00", "failed-01", "failed-02", "failed-03", "failed-04"},
+		},
+		{
+			name:     "failed machines are limited to 10 entries",
+			machines: machinesInPhase(clusterv1.MachinePhaseFailed, 12),
+			expected: []string{"failed-00", "failed-01", "failed-02", "failed-03", "failed-04", "failed-05", "failed-06", "failed-07", "failed-08", "failed-09"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			ms := &clusterv1.MachineSet{ObjectMeta: metav1.ObjectMeta{Name: "ms", Namespace: "default"}}
+			r := &MachineSetReconciler{
+				Client: fake.NewFakeClientWithScheme(scheme.Scheme),
+				Log:    log.Log,
+			}
+
+			status, err := r.calculateStatus(context.Background(), &clusterv1.Cluster{}, ms, tc.machines)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(status.FailedMachines).To(Equal(tc.expected))
+		})
+	}
+}
+
+func TestGetMachineNodes(t *testing.T) {
+	g := NewWithT(t)
+
+	c := fake.NewFakeClientWithScheme(scheme.Scheme,
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-0"}},
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-2"}},
+	)
+	machines := make([]*clusterv1.Machine, 3)
+	for i := range machines {
+		machines[i] = &clusterv1.Machine{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("machine-%d", i), Namespace: "default"},
+			Status:     clusterv1.MachineStatus{NodeRef: &corev1.ObjectReference{Name: fmt.Sprintf("node-%d", i)}},
+		}
+	}
+
+	nodes, errs := getMachineNodes(context.Background(), c, machines, 2)
+	g.Expect(nodes).To(HaveLen(3))
+	g.Expect(errs).To(HaveLen(3))
+
+	g.Expect(errs[0]).NotTo(HaveOccurred())
+	g.Expect(nodes[0].Name).To(Equal("node-0"))
+	g.Expect(errs[1]).To(HaveOccurred())
+	g.Expect(nodes[1]).To(BeNil())
+	g.Expect(errs[2]).NotTo(HaveOccurred())
+	g.Expect(nodes[2].Name).To(Equal("node-2"))
+}
+
+// slowClient is a client whose Get calls take at least delay, like the ones to a remote workload cluster.
+type slowClient struct {
+	client.Client
+	delay time.Duration
+}
+
+func (c *slowClient) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	time.Sleep(c.delay)
+	return c.Client.Get(ctx, key, obj)
+}
+
+func BenchmarkComputeMachineSetStatus(b *testing.B) {
+	objs := []runtime.Object{}
+	machines := []*clusterv1.Machine{}
+	for i := 0; i < 100; i++ {
+		objs = append(objs, &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("node-%d", i)}})
+		machines = append(machines, &clusterv1.Machine{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("machine-%d", i), Namespace: "default"},
+			Status:     clusterv1.MachineStatus{NodeRef: &corev1.ObjectReference{Name: fmt.Sprintf("node-%d", i)}},
+		})
+	}
+	c := &slowClient{Client: fake.NewFakeClientWithScheme(scheme.Scheme, objs...), delay: time.Millisecond}
+	newRemoteClient := func() (client.Client, error) { return c, nil }
+	ms := &clusterv1.MachineSet{ObjectMeta: metav1.ObjectMeta{Name: "ms", Namespace: "default"}}
+
+	// A concurrency of 1 reads the Nodes sequentially.
+	for _, concurrency := range []int{1, defaultStatusConcurrency} {
+		b.Run(fmt.Sprintf("concurrency=%d", concurrency), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := computeMachineSetStatus(context.Background(), log.Log, newRemoteClient, ms, machines, concurrency); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestComputeMachineSetStatus(t *testing.T) {
+	g := NewWithT(t)
+
+	ms := &clusterv1.MachineSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "ms", Namespace: "default", UID: "ms-uid"},
+		Spec: clusterv1.MachineSetSpec{
+			ClusterName: "test-cluster",
+			Selector:    metav1.LabelSelector{MatchLabels: map[string]string{"set": "ms"}},
+			Template: clusterv1.MachineTemplateSpec{
+				ObjectMeta: clusterv1.ObjectMeta{Labels: map[string]string{"set": "ms", "template": "ms"}},
+			},
+		},
+	}
+	newMachine := func(name string, labels map[string]string, owned bool) *clusterv1.Machine {
+		m := &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: labels}}
+		if owned {
+			m.OwnerReferences = []metav1.OwnerReference{*metav1.NewControllerRef(ms, machineSetKind)}
+		}
+		return m
+	}
+	fullyLabeled := newMachine("fully-labeled", map[string]string{"set": "ms", "template": "ms"}, true)
+	failed := newMachine("failed", map[string]string{"set": "ms"}, true)
+	failed.Status.SetTypedPhase(clusterv1.MachinePhaseFailed)
+	notOwned := newMachine("not-owned", map[string]string{"set": "ms", "template": "ms"}, false)
+	notSelected := newMachine("not-selected", map[string]string{"template": "ms"}, true)
+
+	c := fake.NewFakeClientWithScheme(scheme.Scheme, ms, fullyLabeled, failed, notOwned, notSelected)
+
+	status, err := ComputeMachineSetStatus(context.Background(), c, ms)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(status.Selector).To(Equal("set=ms"))
+	g.Expect(status.Replicas).To(Equal(int32(2)))
+	g.Expect(status.FullyLabeledReplicas).To(Equal(int32(1)))
+	g.Expect(status.ReadyReplicas).To(BeZero())
+	g.Expect(status.FailedMachines).To(Equal([]string{"failed"}))
+}
+
+func TestMachineSetPatchFailedMachinesCount(t *testing.T) {
+	g := NewWithT(t)
+
+	ctx := context.Background()
+	ms := &clusterv1.MachineSet{ObjectMeta: metav1.ObjectMeta{Name: "ms", Namespace: "default"}}
+	r := &MachineSetReconciler{
+		Client: fake.NewFakeClientWithScheme(scheme.Scheme, ms),
+		Log:    log.Log,
+	}
+
+	g.Expect(r.patchFailedMachinesCount(ctx, ms, 12)).To(Succeed())
+	got := &clusterv1.MachineSet{}
+	g.Expect(r.Client.Get(ctx, util.ObjectKey(ms), got)).To(Succeed())
+	g.Expect(got.Annotations).To(HaveKeyWithValue(clusterv1.FailedMachinesCountAnnotation, "12"))
+
+	g.Expect(r.patchFailedMachinesCount(ctx, got, 0)).To(Succeed())
+	g.Expect(r.Client.Get(ctx, util.ObjectKey(ms), got)).To(Succeed())
+	g.Expect(got.Annotations).NotTo(HaveKey(clusterv1.FailedMachinesCountAnnotation))
+}
+
+func TestSelectMachinesToDelete(t *testing.T) {
+	g := NewWithT(t)
+
+	newMachine := func(name, nodeName string) *clusterv1.Machine {
+		m := &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}}
+		if nodeName != "" {
+			m.Status.NodeRef = &corev1.ObjectReference{Name: nodeName}
+		}
+		return m
+	}
+	protected := newMachine("protected", "node-1")
+	withoutNode := newMachine("without-node", "")
+	unprotected := newMachine("unprotected", "node-2")
+	nodeGone := newMachine("node-gone", "node-3")
+
+	remoteClient := fake.NewFakeClientWithScheme(scheme.Scheme,
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}},
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-2"}},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", Labels: map[string]string{"app": "web"}},
+			Spec:       corev1.PodSpec{NodeName: "node-1"},
+		},
+		&policyv1beta1.PodDisruptionBudget{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+			Spec: policyv1beta1.PodDisruptionBudgetSpec{
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+			},
+		},
+	)
+
+	// The machines are deleted in this order.
+	candidates := []*clusterv1.Machine{protected, withoutNode, unprotected, nodeGone}
+	priority := func(m *clusterv1.Machine) deletePriority {
+		for i := range candidates {
+			if candidates[i] == m {
+				return deletePriority(len(candidates) - i)
+			}
+		}
+		return mustNotDelete
+	}
+
+	selected, skipped, err := selectMachinesToDelete(context.Background(), log.Log, remoteClient, candidates, 2, priority)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(selected).To(ConsistOf(withoutNode, unprotected))
+	g.Expect(skipped).To(ConsistOf(protected))
+
+	selected, skipped, err = selectMachinesToDelete(context.Background(), log.Log, remoteClient, candidates, 4, priority)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(selected).To(ConsistOf(withoutNode, unprotected, nodeGone))
+	g.Expect(skipped).To(ConsistOf(protected))
+
+	// The machines to delete are left in their order.
+	g.Expect(candidates).To(Equal([]*clusterv1.Machine{protected, withoutNode, unprotected, nodeGone}))
+}
+
+func TestSelectMachinesToDeleteChecksBudgetsForAllMachines(t *testing.T) {
+	g := NewWithT(t)
+
+	var objs []runtime.Object
+	var machines []*clusterv1.Machine
+	for i := 1; i <= 3; i++ {
+		nodeName := fmt.Sprintf("node-%d", i)
+		machines = append(machines, &clusterv1.Machine{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("machine-%d", i), Namespace: "default"},
+			Status:     clusterv1.MachineStatus{NodeRef: &corev1.ObjectReference{Name: nodeName}},
+		})
+		objs = append(objs,
+			&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: nodeName}},
+			&corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("web-%d", i), Namespace: "default", Labels: map[string]string{"app": "web"}},
+				Spec:       corev1.PodSpec{NodeName: nodeName},
+			},
+		)
+	}
+	// Each Node can be drained on its own, but not more than two of them together.
+	objs = append(objs, &policyv1beta1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec: policyv1beta1.PodDisruptionBudgetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+		},
+		Status: policyv1beta1.PodDisruptionBudgetStatus{PodDisruptionsAllowed: 2},
+	})
+	remoteClient := fake.NewFakeClientWithScheme(scheme.Scheme, objs...)
+
+	selected, skipped, err := selectMachinesToDelete(context.Background(), log.Log, remoteClient, machines, 3, randomDeletePolicy)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(selected).To(HaveLen(2))
+	g.Expect(skipped).To(HaveLen(1))
+}
+
+// unreachableClient fails to get any object, as a client of an unreachable workload cluster.
+type unreachableClient struct {
+	client.Client
+}
+
+func (c unreachableClient) Get(_ context.Context, _ client.ObjectKey, _ runtime.Object) error {
+	return errors.New("connection refused")
+}
+
+func TestSelectMachinesToDeleteUnreachableCluster(t *testing.T) {
+	g := NewWithT(t)
+
+	var machines []*clusterv1.Machine
+	for i := 1; i <= 3; i++ {
+		machines = append(machines, &clusterv1.Machine{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("machine-%d", i), Namespace: "default"},
+			Status:     clusterv1.MachineStatus{NodeRef: &corev1.ObjectReference{Name: fmt.Sprintf("node-%d", i)}},
+		})
+	}
+
+	// The Nodes can't be retrieved, the Machines are deleted without checking PodDisruptionBudgets.
+	selected, skipped, err := selectMachinesToDelete(context.Background(), log.Log,
+		unreachableClient{Client: fake.NewFakeClientWithScheme(scheme.Scheme)}, machines, 2, randomDeletePolicy)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(selected).To(HaveLen(2))
+	g.Expect(skipped).To(BeEmpty())
+
+	// Neither can they without a remote client.
+	selected, skipped, err = selectMachinesToDelete(context.Background(), log.Log, nil, machines, 3, randomDeletePolicy)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(selected).To(HaveLen(3))
+	g.Expect(skipped).To(BeEmpty())
+}
+
 func TestHasMatchingLabels(t *testing.T) {
 	r := &MachineSetReconciler{
 		Log: klogr.New(),
@@ -799,3 +1101,289 @@ func newMachineSet(name, cluster string) *clusterv1.MachineSet {
 		},
 	}
 }
+
+func TestMachineSetGetNewMachineLabels(t *testing.T) {
+	g := NewWithT(t)
+
+	ms := &clusterv1.MachineSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "ms", Namespace: "default"},
+		Spec: clusterv1.MachineSetSpec{
+			ClusterName: "test-cluster",
+			Template: clusterv1.MachineTemplateSpec{
+				ObjectMeta: clusterv1.ObjectMeta{Labels: map[string]string{"node-role": "worker"}},
+			},
+		},
+	}
+	r := &MachineSetReconciler{}
+
+	machine := r.getNewMachine(ms, nil)
+	g.Expect(machine.Labels).To(HaveKeyWithValue("node-role", "worker"))
+	g.Expect(machine.Labels).To(HaveKeyWithValue(clusterv1.ClusterLabelName, "test-cluster"))
+
+	// The labels of the Machine don't alias the ones of the template.
+	machine.Labels["other"] = "value"
+	g.Expect(ms.Spec.Template.Labels).To(Equal(map[string]string{"node-role": "worker"}))
+}
+
+func TestMachineSetSyncReplicasOwnerReference(t *testing.T) {
+	g := NewWithT(t)
+
+	infraTemplate := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"kind":       "InfrastructureMachineTemplate",
+			"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
+			"metadata": map[string]interface{}{
+				"name":      "ms-template",
+				"namespace": "default",
+			},
+			"spec": map[string]interface{}{
+				"template": map[string]interface{}{},
+			},
+		},
+	}
+	cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "default"}}
+	ms := &clusterv1.MachineSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "ms", Namespace: "default", UID: "ms-uid"},
+		Spec: clusterv1.MachineSetSpec{
+			ClusterName: "test-cluster",
+			Replicas:    pointer.Int32Ptr(1),
+			Template: clusterv1.MachineTemplateSpec{
+				Spec: clusterv1.MachineSpec{
+					ClusterName: "test-cluster",
+					InfrastructureRef: corev1.ObjectReference{
+						APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+						Kind:       "InfrastructureMachineTemplate",
+						Name:       "ms-template",
+					},
+				},
+			},
+		},
+	}
+
+	c := fake.NewFakeClientWithScheme(scheme.Scheme, cluster, ms, infraTemplate)
+	r := &MachineSetReconciler{
+		Client:   c,
+		Log:      log.Log,
+		recorder: record.NewFakeRecorder(32),
+	}
+	g.Expect(r.syncReplicas(context.Background(), cluster, ms, nil, 0)).To(Succeed())
+
+	machines := &clusterv1.MachineList{}
+	g.Expect(c.List(context.Background(), machines, client.InNamespace("default"))).To(Succeed())
+	g.Expect(machines.Items).To(HaveLen(1))
+
+	refs := machines.Items[0].OwnerReferences
+	g.Expect(refs).To(HaveLen(1))
+	g.Expect(refs[0].Kind).To(Equal("MachineSet"))
+	g.Expect(refs[0].Name).To(Equal("ms"))
+	g.Expect(refs[0].UID).To(Equal(ms.UID))
+	g.Expect(refs[0].Controller).To(Equal(pointer.BoolPtr(true)))
+	g.Expect(refs[0].BlockOwnerDeletion).To(Equal(pointer.BoolPtr(true)))
+}
+
+func TestMachineSetSyncReplicasUpdateStrategyMaxSurge(t *testing.T) {
+	infraTemplate := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"kind":       "InfrastructureMachineTemplate",
+			"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
+			"metadata": map[string]interface{}{
+				"name":      "ms-template",
+				"namespace": "default",
+			},
+			"spec": map[string]interface{}{
+				"template": map[string]interface{}{},
+			},
+		},
+	}
+	cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "default"}}
+	newMachineSet := func(strategy *clusterv1.MachineSetUpdateStrategy) *clusterv1.MachineSet {
+		return &clusterv1.MachineSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "ms", Namespace: "default", UID: "ms-uid"},
+			Spec: clusterv1.MachineSetSpec{
+				ClusterName:    "test-cluster",
+				Replicas:       pointer.Int32Ptr(4),
+				UpdateStrategy: strategy,
+				Template: clusterv1.MachineTemplateSpec{
+					Spec: clusterv1.MachineSpec{
+						ClusterName: "test-cluster",
+						InfrastructureRef: corev1.ObjectReference{
+							APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+							Kind:       "InfrastructureMachineTemplate",
+							Name:       "ms-template",
+						},
+					},
+				},
+			},
+		}
+	}
+	intOrString := func(v intstr.IntOrString) *intstr.IntOrString { return &v }
+
+	testCases := []struct {
+		name           string
+		strategy       *clusterv1.MachineSetUpdateStrategy
+		deleting       int
+		expectMachines int
+	}{
+		{
+			name:           "without update strategy, all the replacements are created",
+			deleting:       2,
+			expectMachines: 2,
+		},
+		{
+			name:           "without Machines being deleted, all the missing Machines are created",
+			strategy:       &clusterv1.MachineSetUpdateStrategy{MaxSurge: intOrString(intstr.FromInt(0))},
+			expectMachines: 2,
+		},
+		{
+			name:           "the replacements are bounded by maxSurge",
+			strategy:       &clusterv1.MachineSetUpdateStrategy{MaxSurge: intOrString(intstr.FromInt(1))},
+			deleting:       2,
+			expectMachines: 1,
+		},
+		{
+			name:           "the replacements wait for the deletions without surge",
+			strategy:       &clusterv1.MachineSetUpdateStrategy{MaxSurge: intOrString(intstr.FromInt(0))},
+			deleting:       2,
+			expectMachines: 0,
+		},
+		{
+			name:           "maxSurge is resolved against the desired replicas",
+			strategy:       &clusterv1.MachineSetUpdateStrategy{MaxSurge: intOrString(intstr.FromString("50%"))},
+			deleting:       2,
+			expectMachines: 2,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			ms := newMachineSet(tc.strategy)
+			c := fake.NewFakeClientWithScheme(scheme.Scheme, cluster, ms, infraTemplate)
+			r := &MachineSetReconciler{
+				Client:   c,
+				Log:      log.Log,
+				recorder: record.NewFakeRecorder(32),
+			}
+			// Two Machines are available, and tc.deleting others are being replaced.
+			existing := []*clusterv1.Machine{
+				{ObjectMeta: metav1.ObjectMeta{Name: "existing-1", Namespace: "default"}},
+				{ObjectMeta: metav1.ObjectMeta{Name: "existing-2", Namespace: "default"}},
+			}
+			g.Expect(r.syncReplicas(context.Background(), cluster, ms, existing, tc.deleting)).To(Succeed())
+
+			machines := &clusterv1.MachineList{}
+			g.Expect(c.List(context.Background(), machines, client.InNamespace("default"))).To(Succeed())
+			g.Expect(machines.Items).To(HaveLen(tc.expectMachines))
+		})
+	}
+}
+
+func TestMachineSetSyncReplicasNamingStrategyNameAlreadyExists(t *testing.T) {
+	g := NewWithT(t)
+
+	infraTemplate := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"kind":       "InfrastructureMachineTemplate",
+			"apiVersion": "infrastructure.cluster.x-k8s.io/v1alpha3",
+			"metadata": map[string]interface{}{
+				"name":      "ms-template",
+				"namespace": "default",
+			},
+			"spec": map[string]interface{}{
+				"template": map[string]interface{}{},
+			},
+		},
+	}
+	cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "default"}}
+	ms := &clusterv1.MachineSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "ms", Namespace: "default", UID: "ms-uid"},
+		Spec: clusterv1.MachineSetSpec{
+			ClusterName:           "test-cluster",
+			Replicas:              pointer.Int32Ptr(1),
+			MachineNamingStrategy: &clusterv1.MachineNamingStrategy{Template: "{machineSet}-worker-{index}"},
+			Template: clusterv1.MachineTemplateSpec{
+				Spec: clusterv1.MachineSpec{
+					ClusterName: "test-cluster",
+					InfrastructureRef: corev1.ObjectReference{
+						APIVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+						Kind:       "InfrastructureMachineTemplate",
+						Name:       "ms-template",
+					},
+				},
+			},
+		},
+	}
+	// A Machine being deleted isn't part of the MachineSet anymore, but still holds its name.
+	now := metav1.Now()
+	deleting := &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{Name: "ms-worker-0", Namespace: "default", DeletionTimestamp: &now},
+	}
+
+	c := fake.NewFakeClientWithScheme(scheme.Scheme, cluster, ms, infraTemplate, deleting)
+	r := &MachineSetReconciler{
+		Client:   c,
+		Log:      log.Log,
+		recorder: record.NewFakeRecorder(32),
+	}
+	g.Expect(r.syncReplicas(context.Background(), cluster, ms, nil, 0)).To(Succeed())
+
+	machines := &clusterv1.MachineList{}
+	g.Expect(c.List(context.Background(), machines, client.InNamespace("default"))).To(Succeed())
+	g.Expect(machines.Items).To(HaveLen(2))
+	g.Expect(c.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: "ms-worker-1"}, &clusterv1.Machine{})).To(Succeed())
+}
+
+func TestMachineSetGetNewMachineNamingStrategy(t *testing.T) {
+	newMachineSet := func(template string) *clusterv1.MachineSet {
+		return &clusterv1.MachineSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "ms", Namespace: "default"},
+			Spec: clusterv1.MachineSetSpec{
+				ClusterName:           "test-cluster",
+				MachineNamingStrategy: &clusterv1.MachineNamingStrategy{Template: template},
+			},
+		}
+	}
+	r := &MachineSetReconciler{}
+
+	t.Run("without naming strategy", func(t *testing.T) {
+		g := NewWithT(t)
+
+		ms := newMachineSet("")
+		ms.Spec.MachineNamingStrategy = nil
+
+		machine := r.getNewMachine(ms, nil)
+		g.Expect(machine.Name).To(BeEmpty())
+		g.Expect(machine.GenerateName).To(Equal("ms-"))
+	})
+
+	t.Run("with an index, the lowest free index is used", func(t *testing.T) {
+		g := NewWithT(t)
+
+		ms := newMachineSet("{machineSet}-worker-{index}")
+		taken := sets.NewString("ms-worker-1")
+
+		var names []string
+		for i := 0; i < 3; i++ {
+			machine := r.getNewMachine(ms, taken)
+			g.Expect(machine.GenerateName).To(BeEmpty())
+			names = append(names, machine.Name)
+			taken.Insert(machine.Name)
+		}
+		g.Expect(names).To(Equal([]string{"ms-worker-0", "ms-worker-2", "ms-worker-3"}))
+	})
+
+	t.Run("with a random suffix, all the names are different", func(t *testing.T) {
+		g := NewWithT(t)
+
+		ms := newMachineSet("{machineSet}-{random}")
+		taken := sets.NewString()
+		for i := 0; i < 50; i++ {
+			machine := r.getNewMachine(ms, taken)
+			g.Expect(machine.Name).To(HavePrefix("ms-"))
+			g.Expect(machine.Name).To(HaveLen(len("ms-") + clusterv1.MachineNameRandomLength))
+			g.Expect(taken.Has(machine.Name)).To(BeFalse())
+			taken.Insert(machine.Name)
+		}
+	})
+}
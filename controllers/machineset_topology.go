@@ -0,0 +1,90 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+)
+
+// SelectFailureDomainForNewMachine returns the failure domain a new Machine should be created in to minimize
+// the skew of the existing Machines across the given failure domains. Machines being deleted or in a failure domain
+// not in domains are not counted. The skew of a failure domain is the number of Machines it would contain
+// with the new Machine, minus the minimum number of Machines in any failure domain.
+//
+// Failure domains exceeding the MaxSkew of a DoNotSchedule constraint are not eligible, and an error is returned
+// if no failure domain is eligible. Ties are broken by failure domain name. An empty string is returned if there
+// are no failure domains.
+func SelectFailureDomainForNewMachine(existing []clusterv1.Machine, domains clusterv1.FailureDomains, constraints []clusterv1.TopologySpreadConstraint) (string, error) {
+	if len(domains) == 0 {
+		return "", nil
+	}
+
+	counts := make(map[string]int32, len(domains))
+	for id := range domains {
+		counts[id] = 0
+	}
+	for i := range existing {
+		m := &existing[i]
+		if !m.DeletionTimestamp.IsZero() || m.Spec.FailureDomain == nil {
+			continue
+		}
+		if _, ok := counts[*m.Spec.FailureDomain]; ok {
+			counts[*m.Spec.FailureDomain]++
+		}
+	}
+
+	ids := make([]string, 0, len(counts))
+	minCount := int32(-1)
+	for id, count := range counts {
+		ids = append(ids, id)
+		if minCount < 0 || count < minCount {
+			minCount = count
+		}
+	}
+	sort.Strings(ids)
+
+	selected := ""
+	var selectedSkew int32
+	for _, id := range ids {
+		skew := counts[id] + 1 - minCount
+		if !satisfiesTopologySpreadConstraints(skew, constraints) {
+			continue
+		}
+		if selected == "" || skew < selectedSkew {
+			selected, selectedSkew = id, skew
+		}
+	}
+
+	if selected == "" {
+		return "", errors.New("no failure domain satisfies the topology spread constraints")
+	}
+	return selected, nil
+}
+
+// satisfiesTopologySpreadConstraints returns true if the skew doesn't exceed the MaxSkew of any DoNotSchedule constraint.
+func satisfiesTopologySpreadConstraints(skew int32, constraints []clusterv1.TopologySpreadConstraint) bool {
+	for _, c := range constraints {
+		if c.WhenUnsatisfiable == corev1.DoNotSchedule && skew > c.MaxSkew {
+			return false
+		}
+	}
+	return true
+}
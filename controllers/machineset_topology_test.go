@@ -0,0 +1,126 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+)
+
+func TestSelectFailureDomainForNewMachine(t *testing.T) {
+	now := metav1.Now()
+	machineIn := func(failureDomain string) clusterv1.Machine {
+		return clusterv1.Machine{Spec: clusterv1.MachineSpec{FailureDomain: pointer.StringPtr(failureDomain)}}
+	}
+	deletingMachineIn := func(failureDomain string) clusterv1.Machine {
+		m := machineIn(failureDomain)
+		m.DeletionTimestamp = &now
+		return m
+	}
+
+	domains := clusterv1.FailureDomains{
+		"fd-a": clusterv1.FailureDomainSpec{},
+		"fd-b": clusterv1.FailureDomainSpec{},
+		"fd-c": clusterv1.FailureDomainSpec{},
+	}
+	doNotSchedule := []clusterv1.TopologySpreadConstraint{{MaxSkew: 1, WhenUnsatisfiable: corev1.DoNotSchedule}}
+
+	tests := []struct {
+		desc        string
+		existing    []clusterv1.Machine
+		domains     clusterv1.FailureDomains
+		constraints []clusterv1.TopologySpreadConstraint
+		expect      string
+	}{
+		{
+			desc:        "no failure domains",
+			existing:    []clusterv1.Machine{machineIn("fd-a")},
+			constraints: doNotSchedule,
+			expect:      "",
+		},
+		{
+			desc:        "no machines, picks the first failure domain by name",
+			domains:     domains,
+			constraints: doNotSchedule,
+			expect:      "fd-a",
+		},
+		{
+			desc:        "picks the failure domain with no machines",
+			existing:    []clusterv1.Machine{machineIn("fd-a"), machineIn("fd-c")},
+			domains:     domains,
+			constraints: doNotSchedule,
+			expect:      "fd-b",
+		},
+		{
+			desc:        "picks the failure domain with the fewest machines in a skewed topology",
+			existing:    []clusterv1.Machine{machineIn("fd-a"), machineIn("fd-a"), machineIn("fd-a"), machineIn("fd-b"), machineIn("fd-c"), machineIn("fd-c")},
+			domains:     domains,
+			constraints: doNotSchedule,
+			expect:      "fd-b",
+		},
+		{
+			desc:        "ignores machines being deleted",
+			existing:    []clusterv1.Machine{machineIn("fd-a"), deletingMachineIn("fd-b"), deletingMachineIn("fd-b"), machineIn("fd-c")},
+			domains:     domains,
+			constraints: doNotSchedule,
+			expect:      "fd-b",
+		},
+		{
+			desc:        "ignores machines without a failure domain or in an unknown failure domain",
+			existing:    []clusterv1.Machine{machineIn("fd-a"), machineIn("fd-b"), machineIn("fd-z"), {}},
+			domains:     domains,
+			constraints: doNotSchedule,
+			expect:      "fd-c",
+		},
+		{
+			desc:     "spreads machines without constraints",
+			existing: []clusterv1.Machine{machineIn("fd-a"), machineIn("fd-b")},
+			domains:  domains,
+			expect:   "fd-c",
+		},
+		{
+			desc:        "the most restrictive DoNotSchedule constraint applies",
+			existing:    []clusterv1.Machine{machineIn("fd-a"), machineIn("fd-a"), machineIn("fd-b")},
+			domains:     domains,
+			constraints: []clusterv1.TopologySpreadConstraint{{MaxSkew: 3, WhenUnsatisfiable: corev1.DoNotSchedule}, doNotSchedule[0]},
+			expect:      "fd-c",
+		},
+		{
+			desc:        "ScheduleAnyway constraints don't exclude failure domains",
+			existing:    []clusterv1.Machine{machineIn("fd-a"), machineIn("fd-c")},
+			domains:     domains,
+			constraints: []clusterv1.TopologySpreadConstraint{{MaxSkew: 1, WhenUnsatisfiable: corev1.ScheduleAnyway}},
+			expect:      "fd-b",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			g := NewWithT(t)
+
+			result, err := SelectFailureDomainForNewMachine(test.existing, test.domains, test.constraints)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(result).To(Equal(test.expect))
+		})
+	}
+}
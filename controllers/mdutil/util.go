@@ -32,6 +32,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	intstrutil "k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/utils/integer"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
 )
@@ -174,6 +175,28 @@ func copyDeploymentAnnotationsToMachineSet(deployment *clusterv1.MachineDeployme
 	return msAnnotationsChanged
 }
 
+// copyTemplateAnnotationsToMachineSet copies the annotations of the deployment's machine template listed in
+// Spec.PropagatedAnnotations (or all of them, if the list is empty) to machine set's annotations,
+// and returns true if machine set's annotation is changed.
+func copyTemplateAnnotationsToMachineSet(deployment *clusterv1.MachineDeployment, ms *clusterv1.MachineSet) bool {
+	msAnnotationsChanged := false
+	if ms.Annotations == nil {
+		ms.Annotations = make(map[string]string)
+	}
+	propagated := sets.NewString(deployment.Spec.PropagatedAnnotations...)
+	for k, v := range deployment.Spec.Template.Annotations {
+		if propagated.Len() > 0 && !propagated.Has(k) {
+			continue
+		}
+		if skipCopyAnnotation(k) || ms.Annotations[k] == v {
+			continue
+		}
+		ms.Annotations[k] = v
+		msAnnotationsChanged = true
+	}
+	return msAnnotationsChanged
+}
+
 func getMaxReplicasAnnotation(ms *clusterv1.MachineSet, logger logr.Logger) (int32, bool) {
 	return getIntFromAnnotation(ms, clusterv1.MaxReplicasAnnotation, logger)
 }
@@ -200,6 +223,10 @@ func SetNewMachineSetAnnotations(deployment *clusterv1.MachineDeployment, newMS
 
 	// First, copy deployment's annotations (except for apply and revision annotations)
 	annotationChanged := copyDeploymentAnnotationsToMachineSet(deployment, newMS)
+	// Then, propagate the machine template annotations to the machine set's own metadata
+	if copyTemplateAnnotationsToMachineSet(deployment, newMS) {
+		annotationChanged = true
+	}
 	// Then, update machine set's revision annotation
 	if newMS.Annotations == nil {
 		newMS.Annotations = make(map[string]string)
@@ -331,6 +358,14 @@ func MaxSurge(deployment clusterv1.MachineDeployment) int32 {
 	return maxSurge
 }
 
+// CanaryReplicas returns the number of canary machines of a rolling deployment, capped to the desired replicas.
+func CanaryReplicas(deployment clusterv1.MachineDeployment) int32 {
+	if !IsRollingUpdate(&deployment) || deployment.Spec.Strategy.RollingUpdate.CanaryReplicas == nil {
+		return int32(0)
+	}
+	return integer.Int32Min(*deployment.Spec.Strategy.RollingUpdate.CanaryReplicas, *deployment.Spec.Replicas)
+}
+
 // GetProportion will estimate the proportion for the provided machine set using 1. the current size
 // of the parent deployment, 2. the replica count that needs be added on the machine sets of the
 // deployment, and 3. the total replicas added in the machine sets of the deployment so far.
@@ -594,6 +629,24 @@ func ResolveFenceposts(maxSurge, maxUnavailable *intstrutil.IntOrString, desired
 	return int32(surge), int32(unavailable), nil
 }
 
+// ComputeDesiredReplicas resolves the MaxSurge and MaxUnavailable of the update strategy of a MachineSet
+// with the given number of desired replicas into absolute numbers of Machines, MaxSurge being rounded up
+// and MaxUnavailable rounded down. Unset values default to 1 for MaxSurge and 0 for MaxUnavailable.
+// As with ResolveFenceposts, MaxUnavailable is set to 1 if both resolve to zero.
+func ComputeDesiredReplicas(desired int32, strategy clusterv1.MachineSetUpdateStrategy) (int32, int32, error) {
+	maxSurge := strategy.MaxSurge
+	if maxSurge == nil {
+		ios1 := intstrutil.FromInt(1)
+		maxSurge = &ios1
+	}
+	maxUnavailable := strategy.MaxUnavailable
+	if maxUnavailable == nil {
+		ios0 := intstrutil.FromInt(0)
+		maxUnavailable = &ios0
+	}
+	return ResolveFenceposts(maxSurge, maxUnavailable, desired)
+}
+
 // FilterActiveMachineSets returns machine sets that have (or at least ought to have) machines.
 func FilterActiveMachineSets(machineSets []*clusterv1.MachineSet) []*clusterv1.MachineSet {
 	activeFilter := func(ms *clusterv1.MachineSet) bool {
@@ -31,7 +31,9 @@ import (
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/apiserver/pkg/storage/names"
 	"k8s.io/klog/klogr"
+	"k8s.io/utils/pointer"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/util"
 )
 
 func newDControllerRef(d *clusterv1.MachineDeployment) *metav1.OwnerReference {
@@ -505,6 +507,100 @@ func TestResolveFenceposts(t *testing.T) {
 	}
 }
 
+func TestComputeDesiredReplicas(t *testing.T) {
+	intOrStrPtr := func(v intstr.IntOrString) *intstr.IntOrString { return &v }
+
+	tests := []struct {
+		name              string
+		strategy          clusterv1.MachineSetUpdateStrategy
+		expectSurge       int32
+		expectUnavailable int32
+		expectError       bool
+	}{
+		{
+			name:              "defaults",
+			expectSurge:       1,
+			expectUnavailable: 0,
+		},
+		{
+			name: "absolute numbers",
+			strategy: clusterv1.MachineSetUpdateStrategy{
+				MaxSurge:       intOrStrPtr(intstr.FromInt(2)),
+				MaxUnavailable: intOrStrPtr(intstr.FromInt(3)),
+			},
+			expectSurge:       2,
+			expectUnavailable: 3,
+		},
+		{
+			name: "10%",
+			strategy: clusterv1.MachineSetUpdateStrategy{
+				MaxSurge:       intOrStrPtr(intstr.FromString("10%")),
+				MaxUnavailable: intOrStrPtr(intstr.FromString("10%")),
+			},
+			expectSurge:       1,
+			expectUnavailable: 1,
+		},
+		{
+			name: "25% rounds surge up and unavailable down",
+			strategy: clusterv1.MachineSetUpdateStrategy{
+				MaxSurge:       intOrStrPtr(intstr.FromString("25%")),
+				MaxUnavailable: intOrStrPtr(intstr.FromString("25%")),
+			},
+			expectSurge:       3,
+			expectUnavailable: 2,
+		},
+		{
+			name: "50%",
+			strategy: clusterv1.MachineSetUpdateStrategy{
+				MaxSurge:       intOrStrPtr(intstr.FromString("50%")),
+				MaxUnavailable: intOrStrPtr(intstr.FromString("50%")),
+			},
+			expectSurge:       5,
+			expectUnavailable: 5,
+		},
+		{
+			name: "100%",
+			strategy: clusterv1.MachineSetUpdateStrategy{
+				MaxSurge:       intOrStrPtr(intstr.FromString("100%")),
+				MaxUnavailable: intOrStrPtr(intstr.FromString("100%")),
+			},
+			expectSurge:       10,
+			expectUnavailable: 10,
+		},
+		{
+			name: "unavailable is 1 when both resolve to zero",
+			strategy: clusterv1.MachineSetUpdateStrategy{
+				MaxSurge:       intOrStrPtr(intstr.FromString("0%")),
+				MaxUnavailable: intOrStrPtr(intstr.FromString("5%")),
+			},
+			expectSurge:       0,
+			expectUnavailable: 1,
+		},
+		{
+			name: "invalid percentage",
+			strategy: clusterv1.MachineSetUpdateStrategy{
+				MaxSurge: intOrStrPtr(intstr.FromString("oops")),
+			},
+			expectError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			surge, unavail, err := ComputeDesiredReplicas(10, test.strategy)
+			if test.expectError {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(surge).To(Equal(test.expectSurge))
+			g.Expect(unavail).To(Equal(test.expectUnavailable))
+		})
+	}
+}
+
 func TestNewMSNewReplicas(t *testing.T) {
 	tests := []struct {
 		Name          string
@@ -719,7 +815,28 @@ func TestAnnotationUtils(t *testing.T) {
 		}
 	})
 
-	//Test Case 2:  Check if annotations are set properly
+	//Test Case 2: Check if template annotations are propagated to the MS
+	t.Run("SetNewMachineSetAnnotations propagates template annotations", func(t *testing.T) {
+		g := NewWithT(t)
+
+		deployment := generateDeployment("nginx")
+		ms := generateMS(deployment)
+		deployment.Spec.Template.Annotations = map[string]string{"foo": "bar", "baz": "qux"}
+
+		g.Expect(SetNewMachineSetAnnotations(&deployment, &ms, "1", true, logger)).To(BeTrue())
+		g.Expect(ms.Annotations).To(HaveKeyWithValue("foo", "bar"))
+		g.Expect(ms.Annotations).To(HaveKeyWithValue("baz", "qux"))
+
+		// Update the template annotations, restricting the propagated keys.
+		deployment.Spec.Template.Annotations = map[string]string{"foo": "updated", "baz": "ignored"}
+		deployment.Spec.PropagatedAnnotations = []string{"foo"}
+
+		g.Expect(SetNewMachineSetAnnotations(&deployment, &ms, "1", true, logger)).To(BeTrue())
+		g.Expect(ms.Annotations).To(HaveKeyWithValue("foo", "updated"))
+		g.Expect(ms.Annotations).To(HaveKeyWithValue("baz", "qux"))
+	})
+
+	//Test Case 3:  Check if annotations are set properly
 	t.Run("SetReplicasAnnotations", func(t *testing.T) {
 		g := NewWithT(t)
 
@@ -728,7 +845,7 @@ func TestAnnotationUtils(t *testing.T) {
 		g.Expect(tMS.Annotations).To(HaveKeyWithValue(clusterv1.MaxReplicasAnnotation, "11"))
 	})
 
-	//Test Case 3:  Check if annotations reflect deployments state
+	//Test Case 4:  Check if annotations reflect deployments state
 	tMS.Annotations[clusterv1.DesiredReplicasAnnotation] = "1"
 	tMS.Status.AvailableReplicas = 1
 	tMS.Spec.Replicas = new(int32)
@@ -813,3 +930,19 @@ func TestReplicasAnnotationsNeedUpdate(t *testing.T) {
 		})
 	}
 }
+
+func TestComputeHashMatchesUtil(t *testing.T) {
+	g := NewWithT(t)
+
+	template := &clusterv1.MachineTemplateSpec{
+		ObjectMeta: clusterv1.ObjectMeta{Labels: map[string]string{"foo": "bar"}},
+		Spec: clusterv1.MachineSpec{
+			ClusterName: "test-cluster",
+			Version:     pointer.StringPtr("v1.17.3"),
+		},
+	}
+
+	hash, err := util.ComputeMachineTemplateHash(template)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(hash).To(Equal(fmt.Sprintf("%d", ComputeHash(template))))
+}
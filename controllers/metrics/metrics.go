@@ -64,6 +64,26 @@ var (
 		[]string{"cluster", "namespace"},
 	)
 
+	// ClusterStuckTotal is a metric counting the number of times a cluster
+	// has been detected as stuck.
+	ClusterStuckTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "capi_cluster_stuck_total",
+			Help: "Number of times the cluster has been detected as stuck.",
+		},
+		[]string{"cluster", "namespace"},
+	)
+
+	// MachineDeploymentGenerationLag is a metric that is set to the number of
+	// generations of the machine deployment spec not completely rolled out yet.
+	MachineDeploymentGenerationLag = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "capi_machinedeployment_generation_lag",
+			Help: "Number of generations of the MachineDeployment spec observed since the last one completely rolled out.",
+		},
+		[]string{"machinedeployment", "namespace", "cluster"},
+	)
+
 	// MachineBootstrapReady is a metric that is set to 1 if machine bootstrap
 	// is ready and 0 if it is not.
 	MachineBootstrapReady = prometheus.NewGaugeVec(
@@ -101,6 +121,8 @@ func init() {
 		ClusterInfrastructureReady,
 		ClusterKubeconfigReady,
 		ClusterFailureSet,
+		ClusterStuckTotal,
+		MachineDeploymentGenerationLag,
 		MachineBootstrapReady,
 		MachineInfrastructureReady,
 		MachineNodeReady,
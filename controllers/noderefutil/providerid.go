@@ -17,82 +17,18 @@ limitations under the License.
 package noderefutil
 
 import (
-	"errors"
-	"regexp"
-	"strings"
+	"sigs.k8s.io/cluster-api/util"
 )
 
 var (
-	ErrEmptyProviderID   = errors.New("providerID is empty")
-	ErrInvalidProviderID = errors.New("providerID must be of the form <cloudProvider>://<optional>/<segments>/<provider id>")
+	ErrEmptyProviderID   = util.ErrEmptyProviderID
+	ErrInvalidProviderID = util.ErrInvalidProviderID
 )
 
-// ProviderID is a struct representation of a Kubernetes ProviderID.
-// Format: cloudProvider://optional/segments/etc/id
-type ProviderID struct {
-	original      string
-	cloudProvider string
-	id            string
-}
+// ProviderID is a struct representation of a Kubernetes ProviderID, see util.ProviderID.
+type ProviderID = util.ProviderID
 
-/*
-	- must start with at least one non-colon
-	- followed by ://
-	- followed by any number of characters
-	- must end with a non-slash
-*/
-var providerIDRegex = regexp.MustCompile("^[^:]+://.*[^/]$")
-
-// NewProviderID parses the input string and returns a new ProviderID.
+// NewProviderID parses the input string and returns a new ProviderID, see util.NewProviderID.
 func NewProviderID(id string) (*ProviderID, error) {
-	if id == "" {
-		return nil, ErrEmptyProviderID
-	}
-
-	if !providerIDRegex.MatchString(id) {
-		return nil, ErrInvalidProviderID
-	}
-
-	colonIndex := strings.Index(id, ":")
-	cloudProvider := id[0:colonIndex]
-
-	lastSlashIndex := strings.LastIndex(id, "/")
-	instance := id[lastSlashIndex+1:]
-
-	res := &ProviderID{
-		original:      id,
-		cloudProvider: cloudProvider,
-		id:            instance,
-	}
-
-	if !res.Validate() {
-		return nil, ErrInvalidProviderID
-	}
-
-	return res, nil
-}
-
-// CloudProvider returns the cloud provider portion of the ProviderID.
-func (p *ProviderID) CloudProvider() string {
-	return p.cloudProvider
-}
-
-// ID returns the identifier portion of the ProviderID.
-func (p *ProviderID) ID() string {
-	return p.id
-}
-
-// Equals returns true if both the CloudProvider and ID match.
-func (p *ProviderID) Equals(o *ProviderID) bool {
-	return p.CloudProvider() == o.CloudProvider() && p.ID() == o.ID()
-}
-
-// String returns the string representation of this object.
-func (p *ProviderID) String() string {
-	return p.original
-}
-
-// Validate returns true if the provider id is valid.
-func (p *ProviderID) Validate() bool {
-	return p.CloudProvider() != "" && p.ID() != ""
+	return util.NewProviderID(id)
 }
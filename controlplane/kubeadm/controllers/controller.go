@@ -107,6 +107,9 @@ func (r *KubeadmControlPlaneReconciler) Reconcile(req ctrl.Request) (res ctrl.Re
 	// Fetch the Cluster.
 	cluster, err := util.GetOwnerCluster(ctx, r.Client, kcp.ObjectMeta)
 	if err != nil {
+		if errors.Cause(err) == util.ErrMultipleClusterOwners {
+			r.recorder.Eventf(kcp, corev1.EventTypeWarning, "MultipleClusterOwners", "%v", err)
+		}
 		logger.Error(err, "Failed to retrieve owner Cluster from the API Server")
 		return ctrl.Result{}, err
 	}
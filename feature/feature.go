@@ -31,6 +31,30 @@ const (
 	// owner: @
 	// alpha: v0.3
 	MachinePool featuregate.Feature = "MachinePool"
+
+	// owner: @
+	// alpha: v0.3
+	// ClusterTopology makes the cluster controller reconcile the control plane and the worker
+	// MachineSets of the Clusters with a Spec.Topology.
+	ClusterTopology featuregate.Feature = "ClusterTopology"
+
+	// owner: @
+	// alpha: v0.3
+	// MachineStatusServerSideApply makes the machine controller update the status of Machines, except their
+	// conditions, with server-side apply. It requires a management cluster with server-side apply enabled.
+	MachineStatusServerSideApply featuregate.Feature = "MachineStatusServerSideApply"
+
+	// owner: @
+	// alpha: v0.3
+	// ReProvisionOnBootstrapChange makes the machine controller re-provision the Machines setting
+	// Spec.Bootstrap.ReProvisionOnChange when their bootstrap data changes.
+	ReProvisionOnBootstrapChange featuregate.Feature = "ReProvisionOnBootstrapChange"
+
+	// owner: @
+	// alpha: v0.3
+	// PreProvisionValidation makes the machine controller validate new Machines against the
+	// endpoint set with the --pre-provision-validation-url flag before provisioning them.
+	PreProvisionValidation featuregate.Feature = "PreProvisionValidation"
 )
 
 func init() {
@@ -41,5 +65,9 @@ func init() {
 // To add a new feature, define a key for it above and add it here.
 var defaultClusterAPIFeatureGates = map[featuregate.Feature]featuregate.FeatureSpec{
 	// Every feature should be initiated here:
-	MachinePool: {Default: false, PreRelease: featuregate.Alpha},
+	MachinePool:                  {Default: false, PreRelease: featuregate.Alpha},
+	ClusterTopology:              {Default: false, PreRelease: featuregate.Alpha},
+	MachineStatusServerSideApply: {Default: false, PreRelease: featuregate.Alpha},
+	ReProvisionOnBootstrapChange: {Default: false, PreRelease: featuregate.Alpha},
+	PreProvisionValidation:       {Default: false, PreRelease: featuregate.Alpha},
 }
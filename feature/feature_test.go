@@ -0,0 +1,85 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package feature
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/spf13/pflag"
+	"k8s.io/component-base/featuregate"
+)
+
+func TestDefaultGates(t *testing.T) {
+	g := NewWithT(t)
+
+	for _, f := range []featuregate.Feature{MachinePool, ClusterTopology, MachineStatusServerSideApply, ReProvisionOnBootstrapChange, PreProvisionValidation} {
+		g.Expect(Gates.Enabled(f)).To(BeFalse(), "%s should be disabled by default", f)
+	}
+}
+
+func TestFeatureGatesFlag(t *testing.T) {
+	tests := []struct {
+		name      string
+		value     string
+		expected  map[featuregate.Feature]bool
+		expectErr bool
+	}{
+		{
+			name:  "enable MachinePool",
+			value: "MachinePool=true",
+			expected: map[featuregate.Feature]bool{
+				MachinePool:     true,
+				ClusterTopology: false,
+			},
+		},
+		{
+			name:  "enable MachinePool and ClusterTopology",
+			value: "MachinePool=true,ClusterTopology=true",
+			expected: map[featuregate.Feature]bool{
+				MachinePool:     true,
+				ClusterTopology: true,
+			},
+		},
+		{
+			name:      "unknown gate",
+			value:     "Unknown=true",
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			gates := featuregate.NewFeatureGate()
+			g.Expect(gates.Add(defaultClusterAPIFeatureGates)).To(Succeed())
+			fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+			gates.AddFlag(fs)
+
+			err := fs.Parse([]string{"--feature-gates=" + tt.value})
+			if tt.expectErr {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			for f, enabled := range tt.expected {
+				g.Expect(gates.Enabled(f)).To(Equal(enabled), "unexpected state for %s", f)
+			}
+		})
+	}
+}
@@ -16,16 +16,22 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	"math/rand"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/spf13/pflag"
+	corev1 "k8s.io/api/core/v1"
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
 	"k8s.io/klog"
@@ -40,6 +46,7 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	// +kubebuilder:scaffold:imports
@@ -52,20 +59,32 @@ var (
 	// flags
 	metricsAddr                   string
 	enableLeaderElection          bool
+	leaderElectionNamespace       string
 	leaderElectionLeaseDuration   time.Duration
 	leaderElectionRenewDeadline   time.Duration
 	leaderElectionRetryPeriod     time.Duration
 	watchNamespace                string
+	watchNamespaces               []string
 	profilerAddress               string
 	clusterConcurrency            int
 	machineConcurrency            int
 	machineSetConcurrency         int
+	machineSetStatusConcurrency   int
 	machineDeploymentConcurrency  int
 	machinePoolConcurrency        int
 	machineHealthCheckConcurrency int
+	clusterHealthCheckConcurrency int
+	nodeLabelPrefix               string
 	syncPeriod                    time.Duration
 	webhookPort                   int
 	healthAddr                    string
+	dryRun                        bool
+	crossNamespaceInfraNamespaces []string
+	clusterStuckDetectionInterval time.Duration
+	preProvisionValidationURL     string
+	preProvisionValidationURLs    []string
+	noDrainTaints                 []string
+	machineLifecycleEventsSize    int
 )
 
 func init() {
@@ -87,6 +106,9 @@ func InitFlags(fs *pflag.FlagSet) {
 	fs.BoolVar(&enableLeaderElection, "enable-leader-election", false,
 		"Enable leader election for controller manager. Enabling this will ensure there is only one active controller manager.")
 
+	fs.StringVar(&leaderElectionNamespace, "leader-election-namespace", "",
+		"Namespace that the controller manager uses to hold its leader election lock. If unspecified, the namespace the controller manager is running in is used.")
+
 	fs.DurationVar(&leaderElectionLeaseDuration, "leader-election-lease-duration", 15*time.Second,
 		"Interval at which non-leader candidates will wait to force acquire leadership (duration string)")
 
@@ -99,18 +121,24 @@ func InitFlags(fs *pflag.FlagSet) {
 	fs.StringVar(&watchNamespace, "namespace", "",
 		"Namespace that the controller watches to reconcile cluster-api objects. If unspecified, the controller watches for cluster-api objects across all namespaces.")
 
+	fs.StringSliceVar(&watchNamespaces, "watch-namespace", nil,
+		"Comma-separated list of namespaces that the controller watches to reconcile cluster-api objects. If unspecified, the controller watches for cluster-api objects across all namespaces. Can't be used together with --namespace.")
+
 	fs.StringVar(&profilerAddress, "profiler-address", "",
 		"Bind address to expose the pprof profiler (e.g. localhost:6060)")
 
 	fs.IntVar(&clusterConcurrency, "cluster-concurrency", 10,
-		"Number of clusters to process simultaneously")
+		"Number of clusters to process simultaneously. Higher values reduce reconciliation latency for large numbers of clusters, at the cost of more load on the API server and the workload clusters")
 
 	fs.IntVar(&machineConcurrency, "machine-concurrency", 10,
-		"Number of machines to process simultaneously")
+		"Number of machines to process simultaneously. Higher values reduce reconciliation latency for large numbers of machines, at the cost of more load on the API server and the workload clusters")
 
 	fs.IntVar(&machineSetConcurrency, "machineset-concurrency", 10,
 		"Number of machine sets to process simultaneously")
 
+	fs.IntVar(&machineSetStatusConcurrency, "machineset-status-concurrency", 10,
+		"Number of Nodes read simultaneously when computing the status of a machine set. Higher values reduce the status latency of large machine sets, at the cost of more load on the workload clusters")
+
 	fs.IntVar(&machineDeploymentConcurrency, "machinedeployment-concurrency", 10,
 		"Number of machine deployments to process simultaneously")
 
@@ -120,6 +148,12 @@ func InitFlags(fs *pflag.FlagSet) {
 	fs.IntVar(&machineHealthCheckConcurrency, "machinehealthcheck-concurrency", 10,
 		"Number of machine health checks to process simultaneously")
 
+	fs.IntVar(&clusterHealthCheckConcurrency, "clusterhealthcheck-concurrency", 10,
+		"Number of cluster health checks to process simultaneously")
+
+	fs.StringVar(&nodeLabelPrefix, "node-label-prefix", controllers.DefaultNodeLabelPrefix,
+		"Prefix of the Machine labels that are copied to the corresponding Node")
+
 	fs.DurationVar(&syncPeriod, "sync-period", 10*time.Minute,
 		"The minimum interval at which watched resources are reconciled (e.g. 15m)")
 
@@ -129,6 +163,27 @@ func InitFlags(fs *pflag.FlagSet) {
 	fs.StringVar(&healthAddr, "health-addr", ":9440",
 		"The address the health endpoint binds to.")
 
+	fs.BoolVar(&dryRun, "dry-run", false,
+		"If true, the cluster controller logs the changes it would make to the API server, without persisting them")
+
+	fs.DurationVar(&clusterStuckDetectionInterval, "stuck-detection-interval", 30*time.Minute,
+		"How long a Cluster can stay in a non-terminal phase without any condition transition before it is reported as stuck. Set to 0 to disable the detection.")
+
+	fs.StringSliceVar(&crossNamespaceInfraNamespaces, "allowed-cross-namespace-infra-namespaces", nil,
+		"Comma-separated list of namespace:infra-namespace pairs, e.g. tenant-a:infra-a. The infrastructure reference of a Machine in namespace can point to infra-namespace, in addition to the namespace of the Machine. Machines referencing any other namespace are rejected by the webhook and failed by the Machine controller.")
+
+	fs.StringVar(&preProvisionValidationURL, "pre-provision-validation-url", "",
+		"URL the spec of each new Machine is posted to before the Machine is provisioned, when the PreProvisionValidation feature gate is enabled. Machines are rejected if the response status is not 2xx. The cluster.x-k8s.io/pre-provision-validation-url annotation of a Machine overrides it.")
+
+	fs.StringSliceVar(&preProvisionValidationURLs, "allowed-pre-provision-validation-urls", nil,
+		"Comma-separated list of the URLs the cluster.x-k8s.io/pre-provision-validation-url annotation of a Machine can be set to, in addition to --pre-provision-validation-url. Machines annotated with any other URL are rejected without calling it.")
+
+	fs.StringSliceVar(&noDrainTaints, "no-drain-taints", nil,
+		"Comma-separated list of taints, as key:effect, e.g. node-role.kubernetes.io/etcd:NoExecute. The Nodes of Machines being deleted carrying any of them are not drained.")
+
+	fs.IntVar(&machineLifecycleEventsSize, "machine-lifecycle-events-buffer-size", 1000,
+		"Number of Machine lifecycle events kept in memory for audit purposes, the oldest events being discarded first. Set to 0 to disable the recording.")
+
 	feature.MutableGates.AddFlag(fs)
 }
 
@@ -148,19 +203,49 @@ func main() {
 		}()
 	}
 
+	allowlist, err := parseCrossNamespaceInfrastructureAllowlist(crossNamespaceInfraNamespaces)
+	if err != nil {
+		setupLog.Error(err, "invalid --allowed-cross-namespace-infra-namespaces")
+		os.Exit(1)
+	}
+	if watchNamespace != "" && len(allowlist) > 0 {
+		setupLog.Error(nil, "--namespace and --allowed-cross-namespace-infra-namespaces can't be used together, use --watch-namespace instead")
+		os.Exit(1)
+	}
+
+	// Restrict the cache, and thus the controllers, to the watched namespaces, if any.
+	// The namespaces cross-namespace infrastructure references are allowed to point to are watched too.
+	newCache := cache.New
+	if len(watchNamespaces) > 0 {
+		if watchNamespace != "" {
+			setupLog.Error(nil, "--namespace and --watch-namespace can't be used together")
+			os.Exit(1)
+		}
+		namespaces := sets.NewString(watchNamespaces...)
+		for namespace, infraNamespaces := range allowlist {
+			if namespaces.Has(namespace) {
+				namespaces.Insert(infraNamespaces...)
+			}
+		}
+		setupLog.Info("Watching cluster-api objects only in namespaces", "namespaces", namespaces.List())
+		newCache = cache.MultiNamespacedCacheBuilder(namespaces.List())
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		Scheme:                 scheme,
-		MetricsBindAddress:     metricsAddr,
-		LeaderElection:         enableLeaderElection,
-		LeaderElectionID:       "controller-leader-election-capi",
-		LeaseDuration:          &leaderElectionLeaseDuration,
-		RenewDeadline:          &leaderElectionRenewDeadline,
-		RetryPeriod:            &leaderElectionRetryPeriod,
-		Namespace:              watchNamespace,
-		SyncPeriod:             &syncPeriod,
-		NewClient:              newClientFunc,
-		Port:                   webhookPort,
-		HealthProbeBindAddress: healthAddr,
+		Scheme:                  scheme,
+		MetricsBindAddress:      metricsAddr,
+		LeaderElection:          enableLeaderElection,
+		LeaderElectionNamespace: leaderElectionNamespace,
+		LeaderElectionID:        "controller-leader-election-capi",
+		LeaseDuration:           &leaderElectionLeaseDuration,
+		RenewDeadline:           &leaderElectionRenewDeadline,
+		RetryPeriod:             &leaderElectionRetryPeriod,
+		Namespace:               watchNamespace,
+		SyncPeriod:              &syncPeriod,
+		NewCache:                newCache,
+		NewClient:               newClientFunc,
+		Port:                    webhookPort,
+		HealthProbeBindAddress:  healthAddr,
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
@@ -168,8 +253,8 @@ func main() {
 	}
 
 	setupChecks(mgr)
-	setupReconcilers(mgr)
-	setupWebhooks(mgr)
+	setupReconcilers(mgr, allowlist)
+	setupWebhooks(mgr, allowlist)
 
 	// +kubebuilder:scaffold:builder
 	setupLog.Info("starting manager", "version", version.Get().String())
@@ -191,28 +276,48 @@ func setupChecks(mgr ctrl.Manager) {
 	}
 }
 
-func setupReconcilers(mgr ctrl.Manager) {
+func setupReconcilers(mgr ctrl.Manager, allowlist clusterv1alpha3.CrossNamespaceInfrastructureAllowlist) {
 	if webhookPort != 0 {
 		return
 	}
 
 	if err := (&controllers.ClusterReconciler{
-		Client: mgr.GetClient(),
-		Log:    ctrl.Log.WithName("controllers").WithName("Cluster"),
-	}).SetupWithManager(mgr, concurrency(clusterConcurrency)); err != nil {
+		Client:                 mgr.GetClient(),
+		Log:                    ctrl.Log.WithName("controllers").WithName("Cluster"),
+		ConcurrentReconciles:   clusterConcurrency,
+		DryRun:                 dryRun,
+		StuckDetectionInterval: clusterStuckDetectionInterval,
+	}).SetupWithManager(mgr, controller.Options{}); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Cluster")
 		os.Exit(1)
 	}
+	taints, err := parseTaints(noDrainTaints)
+	if err != nil {
+		setupLog.Error(err, "invalid --no-drain-taints")
+		os.Exit(1)
+	}
+	var lifecycleRecorder *controllers.MachineEventRecorder
+	if machineLifecycleEventsSize > 0 {
+		lifecycleRecorder = controllers.NewMachineEventRecorder(controllers.NewRingBufferSink(machineLifecycleEventsSize))
+	}
 	if err := (&controllers.MachineReconciler{
-		Client: mgr.GetClient(),
-		Log:    ctrl.Log.WithName("controllers").WithName("Machine"),
-	}).SetupWithManager(mgr, concurrency(machineConcurrency)); err != nil {
+		Client:                              mgr.GetClient(),
+		Log:                                 ctrl.Log.WithName("controllers").WithName("Machine"),
+		ConcurrentReconciles:                machineConcurrency,
+		NodeLabelPrefix:                     nodeLabelPrefix,
+		AllowedCrossNamespaceInfrastructure: allowlist,
+		PreProvisionValidationURL:           preProvisionValidationURL,
+		AllowedPreProvisionValidationURLs:   preProvisionValidationURLs,
+		NoDrainTaints:                       taints,
+		LifecycleRecorder:                   lifecycleRecorder,
+	}).SetupWithManager(mgr, controller.Options{}); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Machine")
 		os.Exit(1)
 	}
 	if err := (&controllers.MachineSetReconciler{
-		Client: mgr.GetClient(),
-		Log:    ctrl.Log.WithName("controllers").WithName("MachineSet"),
+		Client:            mgr.GetClient(),
+		Log:               ctrl.Log.WithName("controllers").WithName("MachineSet"),
+		StatusConcurrency: machineSetStatusConcurrency,
 	}).SetupWithManager(mgr, concurrency(machineSetConcurrency)); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "MachineSet")
 		os.Exit(1)
@@ -241,9 +346,16 @@ func setupReconcilers(mgr ctrl.Manager) {
 		setupLog.Error(err, "unable to create controller", "controller", "MachineHealthCheck")
 		os.Exit(1)
 	}
+	if err := (&controllers.ClusterHealthCheckReconciler{
+		Client: mgr.GetClient(),
+		Log:    ctrl.Log.WithName("controllers").WithName("ClusterHealthCheck"),
+	}).SetupWithManager(mgr, concurrency(clusterHealthCheckConcurrency)); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ClusterHealthCheck")
+		os.Exit(1)
+	}
 }
 
-func setupWebhooks(mgr ctrl.Manager) {
+func setupWebhooks(mgr ctrl.Manager, allowlist clusterv1alpha3.CrossNamespaceInfrastructureAllowlist) {
 	if webhookPort == 0 {
 		return
 	}
@@ -266,7 +378,7 @@ func setupWebhooks(mgr ctrl.Manager) {
 		setupLog.Error(err, "unable to create webhook", "webhook", "Machine")
 		os.Exit(1)
 	}
-	if err := (&clusterv1alpha3.Machine{}).SetupWebhookWithManager(mgr); err != nil {
+	if err := (&clusterv1alpha3.MachineWebhook{AllowedCrossNamespaceInfrastructure: allowlist}).SetupWebhookWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create webhook", "webhook", "Machine")
 		os.Exit(1)
 	}
@@ -317,6 +429,38 @@ func setupWebhooks(mgr ctrl.Manager) {
 	}
 }
 
+// parseTaints parses taints formatted as key:effect.
+func parseTaints(specs []string) ([]corev1.Taint, error) {
+	taints := make([]corev1.Taint, 0, len(specs))
+	for _, spec := range specs {
+		i := strings.LastIndex(spec, ":")
+		if i <= 0 || i == len(spec)-1 {
+			return nil, fmt.Errorf("taint %q is not formatted as key:effect", spec)
+		}
+		taint := corev1.Taint{Key: spec[:i], Effect: corev1.TaintEffect(spec[i+1:])}
+		switch taint.Effect {
+		case corev1.TaintEffectNoSchedule, corev1.TaintEffectPreferNoSchedule, corev1.TaintEffectNoExecute:
+		default:
+			return nil, fmt.Errorf("taint %q has an invalid effect %q", spec, taint.Effect)
+		}
+		taints = append(taints, taint)
+	}
+	return taints, nil
+}
+
+// parseCrossNamespaceInfrastructureAllowlist parses namespace pairs formatted as namespace:infra-namespace.
+func parseCrossNamespaceInfrastructureAllowlist(specs []string) (clusterv1alpha3.CrossNamespaceInfrastructureAllowlist, error) {
+	allowlist := clusterv1alpha3.CrossNamespaceInfrastructureAllowlist{}
+	for _, spec := range specs {
+		parts := strings.Split(spec, ":")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("%q is not formatted as namespace:infra-namespace", spec)
+		}
+		allowlist[parts[0]] = append(allowlist[parts[0]], parts[1])
+	}
+	return allowlist, nil
+}
+
 func concurrency(c int) controller.Options {
 	return controller.Options{MaxConcurrentReconciles: c}
 }
@@ -331,9 +475,54 @@ func newClientFunc(cache cache.Cache, config *rest.Config, options client.Option
 		return nil, err
 	}
 
+	var reader client.Reader = cache
+	if len(watchNamespaces) > 0 {
+		reader = &clusterScopedReader{Reader: cache, direct: c, scheme: options.Scheme, mapper: options.Mapper}
+	}
+
 	return &client.DelegatingClient{
-		Reader:       cache,
+		Reader:       reader,
 		Writer:       c,
 		StatusClient: c,
 	}, nil
 }
+
+// clusterScopedReader reads cluster wide objects, e.g. CustomResourceDefinitions, directly from the server,
+// given that they can't be read from the cache restricted to the namespaces set with --watch-namespace.
+type clusterScopedReader struct {
+	client.Reader
+	direct client.Reader
+	scheme *runtime.Scheme
+	mapper meta.RESTMapper
+}
+
+func (r *clusterScopedReader) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	if r.isClusterScoped(obj) {
+		return r.direct.Get(ctx, key, obj)
+	}
+	return r.Reader.Get(ctx, key, obj)
+}
+
+func (r *clusterScopedReader) List(ctx context.Context, list runtime.Object, opts ...client.ListOption) error {
+	if r.isClusterScoped(list) {
+		return r.direct.List(ctx, list, opts...)
+	}
+	return r.Reader.List(ctx, list, opts...)
+}
+
+// isClusterScoped returns true if the kind of the object, or of the items of the list, is cluster wide.
+// Kinds unknown to the RESTMapper are read from the cache.
+func (r *clusterScopedReader) isClusterScoped(obj runtime.Object) bool {
+	gvk, err := apiutil.GVKForObject(obj, r.scheme)
+	if err != nil {
+		return false
+	}
+	if meta.IsListType(obj) {
+		gvk.Kind = strings.TrimSuffix(gvk.Kind, "List")
+	}
+	mapping, err := r.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return false
+	}
+	return mapping.Scope.Name() == meta.RESTScopeNameRoot
+}
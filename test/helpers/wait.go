@@ -0,0 +1,82 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package helpers contains utilities shared by the integration tests.
+package helpers
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// pollInterval is the interval between two reads of the object being waited for.
+var pollInterval = time.Second
+
+// WaitForMachinePhase waits until the Machine with the given key is in the given phase,
+// and returns an error including the last observed phase if it isn't after timeout.
+func WaitForMachinePhase(ctx context.Context, c client.Client, key client.ObjectKey, phase clusterv1.MachinePhase, timeout time.Duration) error {
+	machine := &clusterv1.Machine{}
+	err := pollUntil(ctx, timeout, func() (bool, error) {
+		if err := c.Get(ctx, key, machine); err != nil {
+			return false, ignoreNotFound(err)
+		}
+		return machine.Status.GetTypedPhase() == phase, nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "Machine %q in namespace %q is in phase %q, not %q",
+			key.Name, key.Namespace, machine.Status.Phase, phase)
+	}
+	return nil
+}
+
+// WaitForClusterReady waits until both the infrastructure and the control plane of the Cluster
+// with the given key are ready, and returns an error including the last observed state if they aren't after timeout.
+func WaitForClusterReady(ctx context.Context, c client.Client, key client.ObjectKey, timeout time.Duration) error {
+	cluster := &clusterv1.Cluster{}
+	err := pollUntil(ctx, timeout, func() (bool, error) {
+		if err := c.Get(ctx, key, cluster); err != nil {
+			return false, ignoreNotFound(err)
+		}
+		return cluster.Status.InfrastructureReady && cluster.Status.ControlPlaneReady, nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "Cluster %q in namespace %q is not ready (phase %q, infrastructureReady %t, controlPlaneReady %t)",
+			key.Name, key.Namespace, cluster.Status.Phase, cluster.Status.InfrastructureReady, cluster.Status.ControlPlaneReady)
+	}
+	return nil
+}
+
+// pollUntil runs condition every pollInterval until it returns true or an error,
+// timeout expires or ctx is done.
+func pollUntil(ctx context.Context, timeout time.Duration, condition wait.ConditionFunc) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return wait.PollImmediateUntil(pollInterval, condition, ctx.Done())
+}
+
+// ignoreNotFound returns nil for NotFound errors, so the polling goes on until the object is created.
+func ignoreNotFound(err error) error {
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,148 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helpers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func init() {
+	pollInterval = 10 * time.Millisecond
+}
+
+func newScheme(g *WithT) *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	g.Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+	return scheme
+}
+
+func TestWaitForMachinePhase(t *testing.T) {
+	tests := []struct {
+		name      string
+		objs      []runtime.Object
+		expectErr bool
+	}{
+		{
+			name: "machine in the expected phase",
+			objs: []runtime.Object{&clusterv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "machine"},
+				Status:     clusterv1.MachineStatus{Phase: string(clusterv1.MachinePhaseRunning)},
+			}},
+		},
+		{
+			name: "machine in another phase",
+			objs: []runtime.Object{&clusterv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "machine"},
+				Status:     clusterv1.MachineStatus{Phase: string(clusterv1.MachinePhaseProvisioning)},
+			}},
+			expectErr: true,
+		},
+		{
+			name:      "machine not found",
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			c := fake.NewFakeClientWithScheme(newScheme(g), tt.objs...)
+			key := client.ObjectKey{Namespace: "default", Name: "machine"}
+
+			err := WaitForMachinePhase(context.Background(), c, key, clusterv1.MachinePhaseRunning, 100*time.Millisecond)
+			if tt.expectErr {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(`not "Running"`))
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+		})
+	}
+}
+
+func TestWaitForClusterReady(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    clusterv1.ClusterStatus
+		expectErr bool
+	}{
+		{
+			name:   "cluster ready",
+			status: clusterv1.ClusterStatus{InfrastructureReady: true, ControlPlaneReady: true},
+		},
+		{
+			name:      "control plane not ready",
+			status:    clusterv1.ClusterStatus{InfrastructureReady: true},
+			expectErr: true,
+		},
+		{
+			name:      "infrastructure not ready",
+			status:    clusterv1.ClusterStatus{ControlPlaneReady: true},
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			cluster := &clusterv1.Cluster{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "cluster"},
+				Status:     tt.status,
+			}
+			c := fake.NewFakeClientWithScheme(newScheme(g), cluster)
+
+			err := WaitForClusterReady(context.Background(), c, client.ObjectKey{Namespace: "default", Name: "cluster"}, 100*time.Millisecond)
+			if tt.expectErr {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+		})
+	}
+}
+
+func TestWaitForMachinePhaseEventuallyReached(t *testing.T) {
+	g := NewWithT(t)
+
+	machine := &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "machine"},
+		Status:     clusterv1.MachineStatus{Phase: string(clusterv1.MachinePhaseProvisioning)},
+	}
+	c := fake.NewFakeClientWithScheme(newScheme(g), machine)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		machine.Status.Phase = string(clusterv1.MachinePhaseRunning)
+		_ = c.Update(context.Background(), machine)
+	}()
+
+	g.Expect(WaitForMachinePhase(context.Background(), c, client.ObjectKey{Namespace: "default", Name: "machine"}, clusterv1.MachinePhaseRunning, 5*time.Second)).To(Succeed())
+}
@@ -0,0 +1,151 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package conditions implements utilities for reading and writing Cluster API conditions.
+package conditions
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+)
+
+// Get returns the condition with the given type, if the condition does not exists,
+// it returns nil.
+func Get(conditions clusterv1.Conditions, t clusterv1.ConditionType) *clusterv1.Condition {
+	for i := range conditions {
+		if conditions[i].Type == t {
+			return &conditions[i]
+		}
+	}
+	return nil
+}
+
+// Has returns true if a condition with the given type exists.
+func Has(conditions clusterv1.Conditions, t clusterv1.ConditionType) bool {
+	return Get(conditions, t) != nil
+}
+
+// IsTrue is true if the condition with the given type is True, otherwise it return false
+// if the condition is not True or if the condition does not exist (is nil).
+func IsTrue(conditions clusterv1.Conditions, t clusterv1.ConditionType) bool {
+	if c := Get(conditions, t); c != nil {
+		return c.Status == corev1.ConditionTrue
+	}
+	return false
+}
+
+// IsFalse is true if the condition with the given type is False, otherwise it return false
+// if the condition is not False or if the condition does not exist (is nil).
+func IsFalse(conditions clusterv1.Conditions, t clusterv1.ConditionType) bool {
+	if c := Get(conditions, t); c != nil {
+		return c.Status == corev1.ConditionFalse
+	}
+	return false
+}
+
+// Set sets the given condition.
+//
+// NOTE: If a condition already exists, the LastTransitionTime is updated only if a change is detected
+// in any of the following fields: Status, Reason, Severity and Message.
+func Set(conditions *clusterv1.Conditions, condition *clusterv1.Condition) {
+	if conditions == nil || condition == nil {
+		return
+	}
+
+	if existing := Get(*conditions, condition.Type); existing != nil {
+		if hasSameState(existing, condition) {
+			return
+		}
+		condition.LastTransitionTime = metav1.Now()
+		*existing = *condition
+		return
+	}
+
+	condition.LastTransitionTime = metav1.Now()
+	*conditions = append(*conditions, *condition)
+}
+
+// TrueCondition returns a condition with Status=True and the given type.
+func TrueCondition(t clusterv1.ConditionType) *clusterv1.Condition {
+	return &clusterv1.Condition{
+		Type:   t,
+		Status: corev1.ConditionTrue,
+	}
+}
+
+// FalseCondition returns a condition with Status=False and the given type.
+func FalseCondition(t clusterv1.ConditionType, reason string, severity clusterv1.ConditionSeverity, messageFormat string, messageArgs ...interface{}) *clusterv1.Condition {
+	return &clusterv1.Condition{
+		Type:     t,
+		Status:   corev1.ConditionFalse,
+		Reason:   reason,
+		Severity: severity,
+		Message:  fmt.Sprintf(messageFormat, messageArgs...),
+	}
+}
+
+// UnknownCondition returns a condition with Status=Unknown and the given type.
+func UnknownCondition(t clusterv1.ConditionType, reason string, messageFormat string, messageArgs ...interface{}) *clusterv1.Condition {
+	return &clusterv1.Condition{
+		Type:    t,
+		Status:  corev1.ConditionUnknown,
+		Reason:  reason,
+		Message: fmt.Sprintf(messageFormat, messageArgs...),
+	}
+}
+
+// MarkTrue sets Status=True for the condition with the given type.
+func MarkTrue(conditions *clusterv1.Conditions, t clusterv1.ConditionType) {
+	Set(conditions, TrueCondition(t))
+}
+
+// MarkFalse sets Status=False for the condition with the given type.
+func MarkFalse(conditions *clusterv1.Conditions, t clusterv1.ConditionType, reason string, severity clusterv1.ConditionSeverity, messageFormat string, messageArgs ...interface{}) {
+	Set(conditions, FalseCondition(t, reason, severity, messageFormat, messageArgs...))
+}
+
+// MarkUnknown sets Status=Unknown for the condition with the given type.
+func MarkUnknown(conditions *clusterv1.Conditions, t clusterv1.ConditionType, reason string, messageFormat string, messageArgs ...interface{}) {
+	Set(conditions, UnknownCondition(t, reason, messageFormat, messageArgs...))
+}
+
+// Delete deletes the condition with the given type.
+func Delete(conditions *clusterv1.Conditions, t clusterv1.ConditionType) {
+	if conditions == nil {
+		return
+	}
+
+	newConditions := make(clusterv1.Conditions, 0, len(*conditions))
+	for _, condition := range *conditions {
+		if condition.Type != t {
+			newConditions = append(newConditions, condition)
+		}
+	}
+	*conditions = newConditions
+}
+
+// hasSameState returns true if a condition has the same state of another; state is defined
+// by the union of following fields: Type, Status, Reason, Severity and Message (it excludes LastTransitionTime).
+func hasSameState(i, j *clusterv1.Condition) bool {
+	return i.Type == j.Type &&
+		i.Status == j.Status &&
+		i.Reason == j.Reason &&
+		i.Severity == j.Severity &&
+		i.Message == j.Message
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package conditions
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+)
+
+const testConditionType clusterv1.ConditionType = "Test"
+
+func TestGetAndHas(t *testing.T) {
+	g := NewWithT(t)
+
+	conditions := clusterv1.Conditions{}
+	g.Expect(Has(conditions, testConditionType)).To(BeFalse())
+	g.Expect(Get(conditions, testConditionType)).To(BeNil())
+
+	MarkTrue(&conditions, testConditionType)
+	g.Expect(Has(conditions, testConditionType)).To(BeTrue())
+	g.Expect(Get(conditions, testConditionType)).ToNot(BeNil())
+	g.Expect(IsTrue(conditions, testConditionType)).To(BeTrue())
+	g.Expect(IsFalse(conditions, testConditionType)).To(BeFalse())
+}
+
+func TestSet(t *testing.T) {
+	t.Run("should add a condition if it does not exist", func(t *testing.T) {
+		g := NewWithT(t)
+
+		conditions := clusterv1.Conditions{}
+		Set(&conditions, FalseCondition(testConditionType, "Reason", clusterv1.ConditionSeverityWarning, "message %d", 1))
+
+		g.Expect(conditions).To(HaveLen(1))
+		g.Expect(conditions[0].Status).To(Equal(corev1.ConditionFalse))
+		g.Expect(conditions[0].Reason).To(Equal("Reason"))
+		g.Expect(conditions[0].Severity).To(Equal(clusterv1.ConditionSeverityWarning))
+		g.Expect(conditions[0].Message).To(Equal("message 1"))
+		g.Expect(conditions[0].LastTransitionTime.IsZero()).To(BeFalse())
+	})
+
+	t.Run("should not update LastTransitionTime if the state did not change", func(t *testing.T) {
+		g := NewWithT(t)
+
+		before := metav1.NewTime(time.Now().Add(-time.Hour).Truncate(time.Second))
+		conditions := clusterv1.Conditions{
+			{Type: testConditionType, Status: corev1.ConditionTrue, LastTransitionTime: before},
+		}
+		MarkTrue(&conditions, testConditionType)
+
+		g.Expect(conditions).To(HaveLen(1))
+		g.Expect(conditions[0].LastTransitionTime).To(Equal(before))
+	})
+
+	t.Run("should update the condition and LastTransitionTime if the state changed", func(t *testing.T) {
+		g := NewWithT(t)
+
+		before := metav1.NewTime(time.Now().Add(-time.Hour).Truncate(time.Second))
+		conditions := clusterv1.Conditions{
+			{Type: testConditionType, Status: corev1.ConditionTrue, LastTransitionTime: before},
+		}
+		MarkUnknown(&conditions, testConditionType, "Reason", "")
+
+		g.Expect(conditions).To(HaveLen(1))
+		g.Expect(conditions[0].Status).To(Equal(corev1.ConditionUnknown))
+		g.Expect(conditions[0].LastTransitionTime).ToNot(Equal(before))
+	})
+}
+
+func TestDelete(t *testing.T) {
+	g := NewWithT(t)
+
+	conditions := clusterv1.Conditions{}
+	MarkTrue(&conditions, testConditionType)
+	MarkTrue(&conditions, "Other")
+
+	Delete(&conditions, testConditionType)
+	g.Expect(Has(conditions, testConditionType)).To(BeFalse())
+	g.Expect(Has(conditions, "Other")).To(BeTrue())
+
+	// Deleting a condition that does not exist is a no-op.
+	Delete(&conditions, testConditionType)
+	g.Expect(conditions).To(HaveLen(1))
+}
@@ -17,8 +17,11 @@ limitations under the License.
 package util
 
 import (
+	"context"
 	"time"
 
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/util/wait"
 )
 
@@ -29,6 +32,9 @@ const (
 	backoffJitter   = 1.0
 )
 
+// transientErrorBackoff is the initial interval between two attempts of RetryWithBackoff.
+var transientErrorBackoff = 100 * time.Millisecond
+
 func Retry(fn wait.ConditionFunc, initialBackoffSec int) error {
 	if initialBackoffSec <= 0 {
 		initialBackoffSec = backoffDuration
@@ -53,3 +59,34 @@ func Poll(interval, timeout time.Duration, condition wait.ConditionFunc) error {
 func PollImmediate(interval, timeout time.Duration, condition wait.ConditionFunc) error {
 	return wait.PollImmediate(interval, timeout, condition)
 }
+
+// RetryWithBackoff calls fn up to maxAttempts times, with an exponential backoff starting at 100ms,
+// as long as it returns a transient API error: a server timeout, a throttled request, or an unavailable service.
+// It returns the last error returned by fn, or the context error if ctx is done while waiting.
+func RetryWithBackoff(ctx context.Context, maxAttempts int, fn func() error) error {
+	backoff := wait.Backoff{
+		Steps:    maxAttempts,
+		Duration: transientErrorBackoff,
+		Factor:   2.0,
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = fn()
+		if err == nil || !isTransientAPIError(err) || attempt >= maxAttempts {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.Wrapf(ctx.Err(), "gave up retrying after %d attempts, last error: %v", attempt, err)
+		case <-time.After(backoff.Step()):
+		}
+	}
+}
+
+// isTransientAPIError returns true if the request that returned err can be retried as is.
+func isTransientAPIError(err error) bool {
+	err = errors.Cause(err)
+	return apierrors.IsServerTimeout(err) || apierrors.IsTooManyRequests(err) || apierrors.IsServiceUnavailable(err)
+}
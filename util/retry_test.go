@@ -0,0 +1,112 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestRetryWithBackoff(t *testing.T) {
+	defer func(d time.Duration) { transientErrorBackoff = d }(transientErrorBackoff)
+	transientErrorBackoff = time.Millisecond
+
+	machines := schema.GroupResource{Group: "cluster.x-k8s.io", Resource: "machines"}
+	tooManyRequests := apierrors.NewTooManyRequests("slow down", 1)
+	serverTimeout := apierrors.NewServerTimeout(machines, "get", 1)
+	serviceUnavailable := apierrors.NewServiceUnavailable("unavailable")
+	notFound := apierrors.NewNotFound(machines, "machine")
+
+	tests := []struct {
+		name             string
+		maxAttempts      int
+		errs             []error
+		expectedAttempts int
+		expectedErr      error
+	}{
+		{
+			name:             "succeeds on the first attempt",
+			maxAttempts:      3,
+			expectedAttempts: 1,
+		},
+		{
+			name:             "succeeds on the third attempt after transient errors",
+			maxAttempts:      3,
+			errs:             []error{tooManyRequests, errors.Wrap(serverTimeout, "failed to get Machine")},
+			expectedAttempts: 3,
+		},
+		{
+			name:             "returns the last error after exhausting the attempts",
+			maxAttempts:      3,
+			errs:             []error{tooManyRequests, serverTimeout, serviceUnavailable, tooManyRequests},
+			expectedAttempts: 3,
+			expectedErr:      serviceUnavailable,
+		},
+		{
+			name:             "doesn't retry other errors",
+			maxAttempts:      3,
+			errs:             []error{notFound},
+			expectedAttempts: 1,
+			expectedErr:      notFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			attempts := 0
+			err := RetryWithBackoff(context.Background(), tt.maxAttempts, func() error {
+				attempts++
+				if attempts <= len(tt.errs) {
+					return tt.errs[attempts-1]
+				}
+				return nil
+			})
+
+			g.Expect(attempts).To(Equal(tt.expectedAttempts))
+			if tt.expectedErr == nil {
+				g.Expect(err).NotTo(HaveOccurred())
+			} else {
+				g.Expect(err).To(Equal(tt.expectedErr))
+			}
+		})
+	}
+}
+
+func TestRetryWithBackoffContextDone(t *testing.T) {
+	g := NewWithT(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	attempts := 0
+	err := RetryWithBackoff(ctx, 5, func() error {
+		attempts++
+		return apierrors.NewTooManyRequests("slow down", 1)
+	})
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(errors.Cause(err)).To(Equal(context.Canceled))
+	g.Expect(attempts).To(Equal(1))
+}
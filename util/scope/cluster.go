@@ -0,0 +1,102 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package scope contains the context shared by the controllers operating on a Cluster.
+package scope
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/patch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// ClusterScope groups a Cluster with the client and the logger used to reconcile it and the
+// objects belonging to it.
+type ClusterScope struct {
+	Cluster *clusterv1.Cluster
+	Client  client.Client
+	Logger  logr.Logger
+
+	// original is the Cluster as it was when the scope was created, PatchCluster
+	// sends the changes made to Cluster since.
+	original *clusterv1.Cluster
+}
+
+// NewClusterScope returns a ClusterScope for the given Cluster, with a logger including
+// the name and namespace of the Cluster.
+func NewClusterScope(cluster *clusterv1.Cluster, c client.Client) *ClusterScope {
+	return &ClusterScope{
+		Cluster:  cluster,
+		Client:   c,
+		Logger:   log.Log.WithValues("cluster", cluster.Name, "namespace", cluster.Namespace),
+		original: cluster.DeepCopy(),
+	}
+}
+
+// GetMachines returns the Machines of the Cluster, sorted by name.
+func (s *ClusterScope) GetMachines(ctx context.Context) ([]clusterv1.Machine, error) {
+	machines, err := util.GetMachinesForCluster(ctx, s.Client, s.Cluster)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list the Machines of Cluster %q in namespace %q", s.Cluster.Name, s.Cluster.Namespace)
+	}
+	return machines.Items, nil
+}
+
+// GetMachineSets returns the MachineSets of the Cluster.
+func (s *ClusterScope) GetMachineSets(ctx context.Context) ([]clusterv1.MachineSet, error) {
+	machineSets := &clusterv1.MachineSetList{}
+	if err := s.Client.List(ctx, machineSets, s.listOptions()...); err != nil {
+		return nil, errors.Wrapf(err, "failed to list the MachineSets of Cluster %q in namespace %q", s.Cluster.Name, s.Cluster.Namespace)
+	}
+	return machineSets.Items, nil
+}
+
+// GetMachineDeployments returns the MachineDeployments of the Cluster.
+func (s *ClusterScope) GetMachineDeployments(ctx context.Context) ([]clusterv1.MachineDeployment, error) {
+	machineDeployments := &clusterv1.MachineDeploymentList{}
+	if err := s.Client.List(ctx, machineDeployments, s.listOptions()...); err != nil {
+		return nil, errors.Wrapf(err, "failed to list the MachineDeployments of Cluster %q in namespace %q", s.Cluster.Name, s.Cluster.Namespace)
+	}
+	return machineDeployments.Items, nil
+}
+
+// PatchCluster patches the Cluster and its status with the changes made to Cluster since the
+// scope was created, or since the last successful call to PatchCluster.
+func (s *ClusterScope) PatchCluster(ctx context.Context) error {
+	patchHelper, err := patch.NewHelper(s.original, s.Client)
+	if err != nil {
+		return err
+	}
+	if err := patchHelper.Patch(ctx, s.Cluster); err != nil {
+		return errors.Wrapf(err, "failed to patch Cluster %q in namespace %q", s.Cluster.Name, s.Cluster.Namespace)
+	}
+	s.original = s.Cluster.DeepCopy()
+	return nil
+}
+
+// listOptions returns the options selecting the objects of the Cluster.
+func (s *ClusterScope) listOptions() []client.ListOption {
+	return []client.ListOption{
+		client.InNamespace(s.Cluster.Namespace),
+		client.MatchingLabels{clusterv1.ClusterLabelName: s.Cluster.Name},
+	}
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scope
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestClusterScope(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+
+	cluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-cluster", Namespace: "my-ns"},
+	}
+	meta := func(name, clusterName string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "my-ns",
+			Labels:    map[string]string{clusterv1.ClusterLabelName: clusterName},
+		}
+	}
+	c := fake.NewFakeClientWithScheme(scheme,
+		cluster,
+		&clusterv1.Machine{ObjectMeta: meta("machine-b", "my-cluster")},
+		&clusterv1.Machine{ObjectMeta: meta("machine-a", "my-cluster")},
+		&clusterv1.Machine{ObjectMeta: meta("other-machine", "other-cluster")},
+		&clusterv1.MachineSet{ObjectMeta: meta("machineset", "my-cluster")},
+		&clusterv1.MachineSet{ObjectMeta: meta("other-machineset", "other-cluster")},
+		&clusterv1.MachineDeployment{ObjectMeta: meta("machinedeployment", "my-cluster")},
+		&clusterv1.MachineDeployment{ObjectMeta: meta("other-machinedeployment", "other-cluster")},
+	)
+
+	s := NewClusterScope(cluster, c)
+
+	machines, err := s.GetMachines(context.Background())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(machines).To(HaveLen(2))
+	g.Expect(machines[0].Name).To(Equal("machine-a"))
+	g.Expect(machines[1].Name).To(Equal("machine-b"))
+
+	machineSets, err := s.GetMachineSets(context.Background())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(machineSets).To(HaveLen(1))
+	g.Expect(machineSets[0].Name).To(Equal("machineset"))
+
+	machineDeployments, err := s.GetMachineDeployments(context.Background())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(machineDeployments).To(HaveLen(1))
+	g.Expect(machineDeployments[0].Name).To(Equal("machinedeployment"))
+
+	// The changes made to the Cluster are patched, including its status.
+	s.Cluster.Labels = map[string]string{"foo": "bar"}
+	conditions.MarkTrue(&s.Cluster.Status.Conditions, clusterv1.ControlPlaneQuorumLostCondition)
+	g.Expect(s.PatchCluster(context.Background())).To(Succeed())
+
+	got := &clusterv1.Cluster{}
+	g.Expect(c.Get(context.Background(), util.ObjectKey(cluster), got)).To(Succeed())
+	g.Expect(got.Labels).To(HaveKeyWithValue("foo", "bar"))
+	g.Expect(conditions.IsTrue(got.Status.Conditions, clusterv1.ControlPlaneQuorumLostCondition)).To(BeTrue())
+
+	// Later changes are patched relative to the last patch.
+	conditions.Delete(&s.Cluster.Status.Conditions, clusterv1.ControlPlaneQuorumLostCondition)
+	g.Expect(s.PatchCluster(context.Background())).To(Succeed())
+	got = &clusterv1.Cluster{}
+	g.Expect(c.Get(context.Background(), util.ObjectKey(cluster), got)).To(Succeed())
+	g.Expect(got.Labels).To(HaveKeyWithValue("foo", "bar"))
+	g.Expect(got.Status.Conditions).To(BeEmpty())
+}
@@ -18,28 +18,43 @@ package util
 
 import (
 	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
+	"hash/fnv"
 	"math"
 	"math/rand"
 	"path"
+	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/blang/semver"
+	"github.com/davecgh/go-spew/spew"
 	"github.com/docker/distribution/reference"
 	"github.com/pkg/errors"
 	v1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/discovery"
+	"k8s.io/utils/pointer"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/secret"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
@@ -54,6 +69,10 @@ import (
 const (
 	// CharSet defines the alphanumeric set for random string generation
 	CharSet = "0123456789abcdefghijklmnopqrstuvwxyz"
+	// DefaultOwnerLookupTimeout is the timeout of the API calls made by GetOwnerCluster.
+	DefaultOwnerLookupTimeout = 30 * time.Second
+	// MachineProviderIDIndex is the field index of Machines by Spec.ProviderID, see IndexMachineByProviderID.
+	MachineProviderIDIndex = "spec.providerID"
 	// MachineListFormatDeprecationMessage notifies the user that the old
 	// MachineList format is no longer supported
 	MachineListFormatDeprecationMessage = "Your MachineList items must include Kind and APIVersion"
@@ -63,28 +82,96 @@ var (
 	rnd                          = rand.New(rand.NewSource(time.Now().UnixNano()))
 	ErrNoCluster                 = fmt.Errorf("no %q label present", clusterv1.ClusterLabelName)
 	ErrUnstructuredFieldNotFound = fmt.Errorf("field not found")
+	ErrMultipleClusterOwners     = fmt.Errorf("multiple %q owner references present", "Cluster")
+	ErrKubeconfigNotFound        = fmt.Errorf("kubeconfig secret not found")
 	ociTagAllowedChars           = regexp.MustCompile(`[^-a-zA-Z0-9_\.]`)
 	kubeSemver                   = regexp.MustCompile(`^v?(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)([-0-9a-zA-Z_\.+]*)?$`)
+	kubeSemverMajorMinor         = regexp.MustCompile(`^v?(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)([-+_].*)?$`)
+	kubeSemverMajor              = regexp.MustCompile(`^v?(0|[1-9][0-9]*)([-+_].*)?$`)
 )
 
+// apiResourcesCacheTTL is how long the APIResources returned by GVKToAPIResource are cached.
+const apiResourcesCacheTTL = 10 * time.Minute
+
+var (
+	// apiResourcesCache caches the APIResources returned by GVKToAPIResource, for each discovery client.
+	apiResourcesCache     = map[discovery.DiscoveryInterface]map[schema.GroupVersionKind]cachedAPIResource{}
+	apiResourcesCacheLock sync.RWMutex
+)
+
+// cachedAPIResource is an APIResource cached by GVKToAPIResource, and when it expires.
+type cachedAPIResource struct {
+	resource metav1.APIResource
+	expires  time.Time
+}
+
+// VersionParseErrorReason describes why a version string could not be parsed.
+type VersionParseErrorReason string
+
+const (
+	// VersionParseErrorMissingPatch is used when the version has a major and minor but no patch version.
+	VersionParseErrorMissingPatch VersionParseErrorReason = "MissingPatch"
+
+	// VersionParseErrorMissingMinor is used when the version has a major but no minor version.
+	VersionParseErrorMissingMinor VersionParseErrorReason = "MissingMinor"
+
+	// VersionParseErrorInvalidFormat is used when the version is not in a known format.
+	VersionParseErrorInvalidFormat VersionParseErrorReason = "InvalidFormat"
+)
+
+// VersionParseError is returned by ParseMajorMinorPatch and ParseMajorMinorPatchWithMetadata
+// when the version string provided can not be parsed.
+type VersionParseError struct {
+	// Input is the version string that failed to parse.
+	Input string
+
+	// Reason describes why the version string could not be parsed.
+	Reason VersionParseErrorReason
+}
+
+func (e *VersionParseError) Error() string {
+	switch e.Reason {
+	case VersionParseErrorMissingPatch:
+		return fmt.Sprintf("failed to parse major.minor.patch from %q: missing patch version", e.Input)
+	case VersionParseErrorMissingMinor:
+		return fmt.Sprintf("failed to parse major.minor.patch from %q: missing minor version", e.Input)
+	default:
+		return fmt.Sprintf("failed to parse major.minor.patch from %q: invalid format", e.Input)
+	}
+}
+
+// newVersionParseError returns a VersionParseError for the given version,
+// detecting whether the minor or patch version is missing.
+func newVersionParseError(version string) *VersionParseError {
+	reason := VersionParseErrorInvalidFormat
+	switch {
+	case kubeSemverMajorMinor.MatchString(version):
+		reason = VersionParseErrorMissingPatch
+	case kubeSemverMajor.MatchString(version):
+		reason = VersionParseErrorMissingMinor
+	}
+	return &VersionParseError{Input: version, Reason: reason}
+}
+
 // ParseMajorMinorPatch returns a semver.Version from the string provided
 // by looking only at major.minor.patch and stripping everything else out.
+// If the version can not be parsed, the error returned is a *VersionParseError.
 func ParseMajorMinorPatch(version string) (semver.Version, error) {
 	groups := kubeSemver.FindStringSubmatch(version)
 	if len(groups) < 4 {
-		return semver.Version{}, errors.Errorf("failed to parse major.minor.patch from %q", version)
+		return semver.Version{}, newVersionParseError(version)
 	}
 	major, err := strconv.ParseUint(groups[1], 10, 64)
 	if err != nil {
-		return semver.Version{}, errors.Wrapf(err, "failed to parse major version from %q", version)
+		return semver.Version{}, &VersionParseError{Input: version, Reason: VersionParseErrorInvalidFormat}
 	}
 	minor, err := strconv.ParseUint(groups[2], 10, 64)
 	if err != nil {
-		return semver.Version{}, errors.Wrapf(err, "failed to parse minor version from %q", version)
+		return semver.Version{}, &VersionParseError{Input: version, Reason: VersionParseErrorInvalidFormat}
 	}
 	patch, err := strconv.ParseUint(groups[3], 10, 64)
 	if err != nil {
-		return semver.Version{}, errors.Wrapf(err, "failed to parse patch version from %q", version)
+		return semver.Version{}, &VersionParseError{Input: version, Reason: VersionParseErrorInvalidFormat}
 	}
 	return semver.Version{
 		Major: major,
@@ -93,6 +180,159 @@ func ParseMajorMinorPatch(version string) (semver.Version, error) {
 	}, nil
 }
 
+// ParseMajorMinorPatchWithMetadata returns a semver.Version from the string provided,
+// including the pre-release and build metadata, if any. A leading "v" is ignored.
+// If the version can not be parsed, the error returned is a *VersionParseError.
+func ParseMajorMinorPatchWithMetadata(version string) (semver.Version, error) {
+	v, err := semver.ParseTolerant(version)
+	if err != nil {
+		return semver.Version{}, newVersionParseError(version)
+	}
+	return v, nil
+}
+
+// IsVersionNewer returns true if version is newer than other. Versions with equal
+// major.minor.patch are compared by pre-release, as defined by the semver spec,
+// e.g. v1.21.0 is newer than v1.21.0-rc.1, which is newer than v1.21.0-beta.2.
+func IsVersionNewer(version, other string) (bool, error) {
+	v, err := ParseMajorMinorPatchWithMetadata(version)
+	if err != nil {
+		return false, err
+	}
+	o, err := ParseMajorMinorPatchWithMetadata(other)
+	if err != nil {
+		return false, err
+	}
+	return v.GT(o), nil
+}
+
+// VersionRange is a range of versions, as parsed by ParseVersionRange.
+type VersionRange struct {
+	// Min is the lower bound of the range. The zero value, with MinExclusive unset,
+	// means the range has no lower bound.
+	Min semver.Version
+
+	// MinExclusive is true if Min is not part of the range.
+	MinExclusive bool
+
+	// Max is the upper bound of the range. The zero value means the range has no upper bound.
+	Max semver.Version
+
+	// MaxExclusive is true if Max is not part of the range.
+	MaxExclusive bool
+}
+
+// ParseVersionRange parses a comma-separated list of constraints, e.g. ">= 1.20, < 1.22", into a VersionRange
+// containing the versions satisfying all of them. Each constraint is made of one of the >=, >, <=, < and =
+// operators followed by a version, whose minor and patch versions can be omitted.
+func ParseVersionRange(s string) (VersionRange, error) {
+	r := VersionRange{}
+	if strings.TrimSpace(s) == "" {
+		return r, errors.New("failed to parse version range: no constraints")
+	}
+
+	for _, constraint := range strings.Split(s, ",") {
+		constraint = strings.TrimSpace(constraint)
+
+		var op string
+		for _, o := range []string{">=", "<=", ">", "<", "="} {
+			if strings.HasPrefix(constraint, o) {
+				op = o
+				break
+			}
+		}
+		if op == "" {
+			return VersionRange{}, errors.Errorf("failed to parse version range %q: constraint %q has no operator", s, constraint)
+		}
+
+		v, err := ParseMajorMinorPatchWithMetadata(strings.TrimSpace(strings.TrimPrefix(constraint, op)))
+		if err != nil {
+			return VersionRange{}, errors.Wrapf(err, "failed to parse version range %q", s)
+		}
+
+		switch op {
+		case ">=", ">":
+			r.setMin(v, op == ">")
+		case "<=", "<":
+			if err := r.setMax(v, op == "<"); err != nil {
+				return VersionRange{}, errors.Wrapf(err, "failed to parse version range %q", s)
+			}
+		case "=":
+			r.setMin(v, false)
+			if err := r.setMax(v, false); err != nil {
+				return VersionRange{}, errors.Wrapf(err, "failed to parse version range %q", s)
+			}
+		}
+	}
+
+	if r.hasMax() && (r.Min.GT(r.Max) || (r.Min.EQ(r.Max) && (r.MinExclusive || r.MaxExclusive))) {
+		return VersionRange{}, errors.Errorf("failed to parse version range %q: no version satisfies all the constraints", s)
+	}
+	return r, nil
+}
+
+// Contains returns true if the version is part of the range.
+func (r VersionRange) Contains(v semver.Version) bool {
+	if v.LT(r.Min) || (r.MinExclusive && v.EQ(r.Min)) {
+		return false
+	}
+	if r.hasMax() && (v.GT(r.Max) || (r.MaxExclusive && v.EQ(r.Max))) {
+		return false
+	}
+	return true
+}
+
+// setMin sets the lower bound of the range, if it is stricter than the current one.
+func (r *VersionRange) setMin(v semver.Version, exclusive bool) {
+	if v.GT(r.Min) || (v.EQ(r.Min) && exclusive) {
+		r.Min, r.MinExclusive = v, exclusive
+	}
+}
+
+// setMax sets the upper bound of the range, if it is stricter than the current one.
+func (r *VersionRange) setMax(v semver.Version, exclusive bool) error {
+	if v.EQ(semver.Version{}) {
+		return errors.New("upper bound must be greater than 0.0.0")
+	}
+	if !r.hasMax() || v.LT(r.Max) || (v.EQ(r.Max) && exclusive) {
+		r.Max, r.MaxExclusive = v, exclusive
+	}
+	return nil
+}
+
+func (r VersionRange) hasMax() bool {
+	return !r.Max.EQ(semver.Version{})
+}
+
+// SortMachinesByVersion sorts the machines in place by Spec.Version, oldest first if ascending is true,
+// newest first otherwise. Machines without a version are placed at the end of the list.
+// It returns an error, leaving the list untouched, if any version can not be parsed.
+func SortMachinesByVersion(machines []clusterv1.Machine, ascending bool) error {
+	versions := make(map[string]semver.Version, len(machines))
+	for _, m := range machines {
+		if m.Spec.Version == nil {
+			continue
+		}
+		v, err := ParseMajorMinorPatch(*m.Spec.Version)
+		if err != nil {
+			return errors.Wrapf(err, "failed to parse version of Machine %q", m.Name)
+		}
+		versions[*m.Spec.Version] = v
+	}
+
+	sort.SliceStable(machines, func(i, j int) bool {
+		vi, vj := machines[i].Spec.Version, machines[j].Spec.Version
+		if vi == nil || vj == nil {
+			return vi != nil && vj == nil
+		}
+		if ascending {
+			return versions[*vi].LT(versions[*vj])
+		}
+		return versions[*vi].GT(versions[*vj])
+	})
+	return nil
+}
+
 // RandomString returns a random alphanumeric string.
 func RandomString(n int) string {
 	result := make([]byte, n)
@@ -147,6 +387,33 @@ func ModifyImageTag(imageName, tagName string) (string, error) {
 	return reference.FamiliarString(reference.TagNameOnly(namedTagged)), nil
 }
 
+// ExtractImageTag takes an imageName (e.g., repository/image:tag), and returns its tag
+func ExtractImageTag(imageName string) (string, error) {
+	namedRef, err := reference.ParseNormalizedNamed(imageName)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to parse image name")
+	}
+	tagged, ok := namedRef.(reference.NamedTagged)
+	if !ok {
+		return "", errors.Errorf("image %q must be tagged", imageName)
+	}
+	return tagged.Tag(), nil
+}
+
+// VersionFromImageTag takes an imageName (e.g., repository/image:tag), and returns the version
+// parsed from its tag. Tags normalised with SemverToOCIImageTag (e.g., v1.17.4_build1) are supported.
+func VersionFromImageTag(imageName string) (semver.Version, error) {
+	tag, err := ExtractImageTag(imageName)
+	if err != nil {
+		return semver.Version{}, err
+	}
+	version, err := ParseMajorMinorPatch(tag)
+	if err != nil {
+		return semver.Version{}, errors.Wrapf(err, "image %q tag is not a valid version", imageName)
+	}
+	return version, nil
+}
+
 // ModifyImageRepository takes an imageName (e.g., repository/image:tag), and returns an image name with updated repository
 func ModifyImageRepository(imageName, repositoryName string) (string, error) {
 	namedRef, err := reference.ParseNamed(imageName)
@@ -175,7 +442,7 @@ func ImageTagIsValid(tagName string) bool {
 	return !ociTagAllowedChars.MatchString(tagName)
 }
 
-// GetMachinesForCluster returns a list of machines associated with the cluster.
+// GetMachinesForCluster returns a list of machines associated with the cluster, sorted by name.
 func GetMachinesForCluster(ctx context.Context, c client.Client, cluster *clusterv1.Cluster) (*clusterv1.MachineList, error) {
 	var machines clusterv1.MachineList
 	if err := c.List(
@@ -188,9 +455,87 @@ func GetMachinesForCluster(ctx context.Context, c client.Client, cluster *cluste
 	); err != nil {
 		return nil, err
 	}
+	// Sort the Machines so that callers picking e.g. the first one behave consistently.
+	sort.Slice(machines.Items, func(i, j int) bool {
+		return machines.Items[i].Name < machines.Items[j].Name
+	})
 	return &machines, nil
 }
 
+// IsControlPlaneQuorumLost returns true if more than half of the control plane Machines of the
+// cluster are failed or being deleted, i.e. the remaining ones can't form a majority.
+// A cluster without control plane Machines never loses quorum.
+func IsControlPlaneQuorumLost(ctx context.Context, c client.Client, cluster *clusterv1.Cluster) (bool, error) {
+	machines, err := GetMachinesForCluster(ctx, c, cluster)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to list the Machines of Cluster %q in namespace %q", cluster.Name, cluster.Namespace)
+	}
+
+	controlPlaneMachines := GetControlPlaneMachinesFromList(machines)
+	healthy := 0
+	for _, m := range controlPlaneMachines {
+		if !IsMachineFailed(m) && m.DeletionTimestamp.IsZero() {
+			healthy++
+		}
+	}
+	return len(controlPlaneMachines) > 0 && healthy <= len(controlPlaneMachines)/2, nil
+}
+
+// IsMachineFailed returns true if the Machine reports a failure.
+func IsMachineFailed(m *clusterv1.Machine) bool {
+	return m.Status.FailureReason != nil || m.Status.FailureMessage != nil ||
+		m.Status.GetTypedPhase() == clusterv1.MachinePhaseFailed
+}
+
+// GetMachinesForClusterPaged returns a list of machines associated with the cluster,
+// retrieving them from the API server in batches of at most pageSize items.
+func GetMachinesForClusterPaged(ctx context.Context, c client.Client, cluster *clusterv1.Cluster, pageSize int64) (*clusterv1.MachineList, error) {
+	machines := &clusterv1.MachineList{}
+	continueToken := ""
+	for {
+		page := &clusterv1.MachineList{}
+		if err := c.List(
+			ctx,
+			page,
+			client.InNamespace(cluster.Namespace),
+			client.MatchingLabels{
+				clusterv1.ClusterLabelName: cluster.Name,
+			},
+			client.Limit(pageSize),
+			client.Continue(continueToken),
+		); err != nil {
+			return nil, errors.Wrapf(err, "failed to list Machines for Cluster %s/%s", cluster.Namespace, cluster.Name)
+		}
+
+		machines.Items = append(machines.Items, page.Items...)
+
+		continueToken = page.Continue
+		if continueToken == "" {
+			break
+		}
+		// Don't request the next page if the caller has given up in the meantime.
+		if err := ctx.Err(); err != nil {
+			return nil, errors.Wrapf(err, "failed to list Machines for Cluster %s/%s", cluster.Namespace, cluster.Name)
+		}
+	}
+	return machines, nil
+}
+
+// GetMachineDeploymentRevision returns the revision of the current template of the MachineDeployment,
+// read from its RevisionAnnotation. It returns 0 if the annotation is not set yet, and an error if its
+// value is not a valid integer.
+func GetMachineDeploymentRevision(md *clusterv1.MachineDeployment) (int64, error) {
+	v, ok := md.Annotations[clusterv1.RevisionAnnotation]
+	if !ok {
+		return 0, nil
+	}
+	revision, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid revision %q for MachineDeployment %s/%s", v, md.Namespace, md.Name)
+	}
+	return revision, nil
+}
+
 // SemVerToOCIImageTag is a helper function that replaces all
 // non-allowed symbols in tag strings with underscores.
 // Image tag can only contain lowercase and uppercase letters, digits,
@@ -269,13 +614,34 @@ func GetClusterFromMetadata(ctx context.Context, c client.Client, obj metav1.Obj
 }
 
 // GetOwnerCluster returns the Cluster object owning the current resource.
+// If more than one Cluster owner reference is found, an error wrapping ErrMultipleClusterOwners
+// is returned; callers should surface it to the user with a Warning event on the resource.
+// The Cluster is retrieved with a timeout of DefaultOwnerLookupTimeout.
 func GetOwnerCluster(ctx context.Context, c client.Client, obj metav1.ObjectMeta) (*clusterv1.Cluster, error) {
-	for _, ref := range obj.OwnerReferences {
-		if ref.Kind == "Cluster" && ref.APIVersion == clusterv1.GroupVersion.String() {
-			return GetClusterByName(ctx, c, obj.Namespace, ref.Name)
+	return GetOwnerClusterWithTimeout(ctx, DefaultOwnerLookupTimeout, c, obj)
+}
+
+// GetOwnerClusterWithTimeout is GetOwnerCluster with the retrieval of the Cluster bounded by timeout,
+// so an unresponsive API server can't block the caller indefinitely.
+func GetOwnerClusterWithTimeout(ctx context.Context, timeout time.Duration, c client.Client, obj metav1.ObjectMeta) (*clusterv1.Cluster, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var owner *metav1.OwnerReference
+	for i := range obj.OwnerReferences {
+		ref := &obj.OwnerReferences[i]
+		if ref.Kind != "Cluster" || ref.APIVersion != clusterv1.GroupVersion.String() {
+			continue
+		}
+		if owner != nil {
+			return nil, errors.Wrapf(ErrMultipleClusterOwners, "%q and %q own %q", owner.Name, ref.Name, obj.Name)
 		}
+		owner = ref
 	}
-	return nil, nil
+	if owner == nil {
+		return nil, nil
+	}
+	return GetClusterByName(ctx, c, obj.Namespace, owner.Name)
 }
 
 // GetClusterByName finds and return a Cluster object using the specified params.
@@ -293,6 +659,43 @@ func GetClusterByName(ctx context.Context, c client.Client, namespace, name stri
 	return cluster, nil
 }
 
+// GetClusterKubeconfigSecret returns the Secret storing the kubeconfig of the Cluster, validating that it
+// contains a non-empty kubeconfig. If the Secret doesn't exist, an error wrapping ErrKubeconfigNotFound is returned.
+func GetClusterKubeconfigSecret(ctx context.Context, c client.Client, cluster *clusterv1.Cluster) (*v1.Secret, error) {
+	s, err := secret.Get(ctx, c, ObjectKey(cluster), secret.Kubeconfig)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, errors.Wrapf(ErrKubeconfigNotFound, "secret %q for cluster %q in namespace %q",
+				secret.Name(cluster.Name, secret.Kubeconfig), cluster.Name, cluster.Namespace)
+		}
+		return nil, errors.Wrapf(err, "failed to get kubeconfig secret for cluster %q in namespace %q", cluster.Name, cluster.Namespace)
+	}
+	if len(s.Data[secret.KubeconfigDataName]) == 0 {
+		return nil, errors.Errorf("kubeconfig secret %q in namespace %q has no %q key or it is empty", s.Name, s.Namespace, secret.KubeconfigDataName)
+	}
+	return s, nil
+}
+
+// GetClusterCondition returns the condition of the Cluster with the given type, or nil if it isn't set.
+func GetClusterCondition(cluster *clusterv1.Cluster, condType clusterv1.ConditionType) *clusterv1.Condition {
+	return conditions.Get(cluster.Status.Conditions, condType)
+}
+
+// SetClusterCondition sets the given condition on the Cluster, replacing the existing condition of the same type,
+// with conditions.Set. The LastTransitionTime of the existing condition is kept unless its Status changes,
+// even if its Reason or Message do.
+func SetClusterCondition(cluster *clusterv1.Cluster, cond clusterv1.Condition) {
+	existing := conditions.Get(cluster.Status.Conditions, cond.Type)
+	if existing == nil || existing.Status != cond.Status {
+		conditions.Set(&cluster.Status.Conditions, &cond)
+		return
+	}
+
+	lastTransitionTime := existing.LastTransitionTime
+	conditions.Set(&cluster.Status.Conditions, &cond)
+	conditions.Get(cluster.Status.Conditions, cond.Type).LastTransitionTime = lastTransitionTime
+}
+
 // ObjectKey returns client.ObjectKey for the object
 func ObjectKey(object metav1.Object) client.ObjectKey {
 	return client.ObjectKey{
@@ -352,6 +755,446 @@ func GetMachineByName(ctx context.Context, c client.Client, namespace, name stri
 	return m, nil
 }
 
+// IndexMachineByProviderID is the client.IndexerFunc for MachineProviderIDIndex. Provider IDs are indexed
+// by cloud provider and ID, so Machines are found regardless of the optional segments of their provider ID.
+func IndexMachineByProviderID(o runtime.Object) []string {
+	machine, ok := o.(*clusterv1.Machine)
+	if !ok || machine.Spec.ProviderID == nil {
+		return nil
+	}
+	id, err := NewProviderID(*machine.Spec.ProviderID)
+	if err != nil {
+		return nil
+	}
+	return []string{providerIDIndexValue(id)}
+}
+
+func providerIDIndexValue(id *ProviderID) string {
+	return id.CloudProvider() + "://" + id.ID()
+}
+
+// GetMachineByProviderID returns the Machine in the namespace whose Spec.ProviderID matches providerID,
+// or nil if there is none. Provider IDs are compared by cloud provider and ID, like Machines and Nodes are
+// matched by the Machine controller. The Machines are listed with a MachineProviderIDIndex field selector,
+// so a cached client needs the index registered with IndexMachineByProviderID.
+func GetMachineByProviderID(ctx context.Context, c client.Client, namespace, providerID string) (*clusterv1.Machine, error) {
+	id, err := NewProviderID(providerID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid provider ID %q", providerID)
+	}
+
+	machines := &clusterv1.MachineList{}
+	if err := c.List(ctx, machines,
+		client.InNamespace(namespace),
+		client.MatchingFields{MachineProviderIDIndex: providerIDIndexValue(id)},
+	); err != nil {
+		return nil, errors.Wrapf(err, "failed to list Machines in namespace %q", namespace)
+	}
+
+	var found *clusterv1.Machine
+	for i := range machines.Items {
+		m := &machines.Items[i]
+		if m.Spec.ProviderID == nil {
+			continue
+		}
+		machineID, err := NewProviderID(*m.Spec.ProviderID)
+		if err != nil || !machineID.Equals(id) {
+			continue
+		}
+		if found != nil {
+			return nil, errors.Errorf("multiple Machines in namespace %q have provider ID %q: %q and %q",
+				namespace, providerID, found.Name, m.Name)
+		}
+		found = m
+	}
+	return found, nil
+}
+
+// GetOwnerMachineSet returns the MachineSet object owning the current resource.
+func GetOwnerMachineSet(ctx context.Context, c client.Client, obj metav1.ObjectMeta) (*clusterv1.MachineSet, error) {
+	for _, ref := range obj.OwnerReferences {
+		if ref.Kind == "MachineSet" && ref.APIVersion == clusterv1.GroupVersion.String() {
+			return GetMachineSetByName(ctx, c, obj.Namespace, ref.Name)
+		}
+	}
+	return nil, nil
+}
+
+// GetMachineSetByName finds and return a MachineSet object using the specified params.
+func GetMachineSetByName(ctx context.Context, c client.Client, namespace, name string) (*clusterv1.MachineSet, error) {
+	ms := &clusterv1.MachineSet{}
+	key := client.ObjectKey{Name: name, Namespace: namespace}
+	if err := c.Get(ctx, key, ms); err != nil {
+		return nil, err
+	}
+	return ms, nil
+}
+
+// GetMachineSetForMachine returns the MachineSet the Machine belongs to.
+// If the Machine is owned directly by a MachineDeployment, the MachineSet owned by the same
+// MachineDeployment and whose selector matches the Machine labels is returned instead.
+// It returns nil if no such MachineSet exists.
+func GetMachineSetForMachine(ctx context.Context, c client.Client, machine *clusterv1.Machine) (*clusterv1.MachineSet, error) {
+	ms, err := GetOwnerMachineSet(ctx, c, machine.ObjectMeta)
+	if err != nil || ms != nil {
+		return ms, err
+	}
+
+	for _, ref := range machine.OwnerReferences {
+		if ref.Kind != "MachineDeployment" || ref.APIVersion != clusterv1.GroupVersion.String() {
+			continue
+		}
+
+		machineSets := &clusterv1.MachineSetList{}
+		if err := c.List(ctx, machineSets, client.InNamespace(machine.Namespace)); err != nil {
+			return nil, errors.Wrapf(err, "failed to list MachineSets for Machine %s/%s", machine.Namespace, machine.Name)
+		}
+
+		for i := range machineSets.Items {
+			ms := &machineSets.Items[i]
+			if !HasOwnerRef(ms.OwnerReferences, ref) {
+				continue
+			}
+			selector, err := metav1.LabelSelectorAsSelector(&ms.Spec.Selector)
+			if err != nil || selector.Empty() {
+				continue
+			}
+			if selector.Matches(labels.Set(machine.Labels)) {
+				return ms, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// ValidateMachineOwnerReferences checks that the Machine is owned by at most one Cluster and at most one
+// MachineSet and, if it is owned by both, that they belong to the same cluster. The cluster of the MachineSet
+// is the one of the Machine, since the MachineSet webhook enforces its template to have the same cluster name.
+// All the violations found are returned as an aggregate error.
+func ValidateMachineOwnerReferences(machine *clusterv1.Machine) error {
+	var clusterOwners, machineSetOwners []string
+	for _, ref := range machine.OwnerReferences {
+		if ref.APIVersion != clusterv1.GroupVersion.String() {
+			continue
+		}
+		switch ref.Kind {
+		case "Cluster":
+			clusterOwners = append(clusterOwners, ref.Name)
+		case "MachineSet":
+			machineSetOwners = append(machineSetOwners, ref.Name)
+		}
+	}
+
+	var errs []error
+	if len(clusterOwners) > 1 {
+		errs = append(errs, errors.Errorf("Machine %q in namespace %q is owned by multiple Clusters: %s",
+			machine.Name, machine.Namespace, strings.Join(clusterOwners, ", ")))
+	}
+	if len(machineSetOwners) > 1 {
+		errs = append(errs, errors.Errorf("Machine %q in namespace %q is owned by multiple MachineSets: %s",
+			machine.Name, machine.Namespace, strings.Join(machineSetOwners, ", ")))
+	}
+	if len(machineSetOwners) > 0 {
+		for _, name := range clusterOwners {
+			if name != machine.Spec.ClusterName {
+				errs = append(errs, errors.Errorf("Machine %q in namespace %q is owned by Cluster %q but its MachineSet %q belongs to Cluster %q",
+					machine.Name, machine.Namespace, name, machineSetOwners[0], machine.Spec.ClusterName))
+			}
+		}
+	}
+	return kerrors.NewAggregate(errs)
+}
+
+// PatchIfChanged patches modified with a merge patch computed against original, skipping the
+// call to the API server if the patch is empty. It returns true if the object has been patched.
+func PatchIfChanged(ctx context.Context, c client.Client, original, modified runtime.Object) (bool, error) {
+	patch := client.MergeFrom(original)
+	data, err := patch.Data(modified)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to compute patch")
+	}
+	if string(data) == "{}" {
+		return false, nil
+	}
+
+	if err := c.Patch(ctx, modified, patch); err != nil {
+		return false, errors.Wrapf(err, "failed to patch object")
+	}
+	return true, nil
+}
+
+// AnnotateObject merges the given annotations into the annotations of obj, overwriting the values of existing keys,
+// and patches it with a single call to the API server, skipped if the annotations are already set.
+// It returns an error without patching the object if any of the keys is empty.
+func AnnotateObject(ctx context.Context, c client.Client, obj runtime.Object, annotations map[string]string) error {
+	for key := range annotations {
+		if key == "" {
+			return errors.New("annotation keys must not be empty")
+		}
+	}
+
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return errors.Wrapf(err, "failed to annotate %T", obj)
+	}
+
+	original := obj.DeepCopyObject()
+	merged := accessor.GetAnnotations()
+	if merged == nil {
+		merged = make(map[string]string, len(annotations))
+	}
+	for key, value := range annotations {
+		merged[key] = value
+	}
+	accessor.SetAnnotations(merged)
+
+	if _, err := PatchIfChanged(ctx, c, original, obj); err != nil {
+		return errors.Wrapf(err, "failed to annotate %s %q in namespace %q",
+			obj.GetObjectKind().GroupVersionKind().Kind, accessor.GetName(), accessor.GetNamespace())
+	}
+	return nil
+}
+
+// GetClusterForMachineSet returns the Cluster owning the MachineSet, either directly or through
+// the MachineDeployment owning the MachineSet.
+// It returns nil if neither the MachineSet nor its MachineDeployment is owned by a Cluster.
+func GetClusterForMachineSet(ctx context.Context, c client.Client, ms *clusterv1.MachineSet) (*clusterv1.Cluster, error) {
+	cluster, err := GetOwnerCluster(ctx, c, ms.ObjectMeta)
+	if err != nil || cluster != nil {
+		return cluster, err
+	}
+
+	for _, ref := range ms.OwnerReferences {
+		if ref.Kind != "MachineDeployment" || ref.APIVersion != clusterv1.GroupVersion.String() {
+			continue
+		}
+
+		md := &clusterv1.MachineDeployment{}
+		if err := c.Get(ctx, client.ObjectKey{Namespace: ms.Namespace, Name: ref.Name}, md); err != nil {
+			return nil, errors.Wrapf(err, "failed to get MachineDeployment %q owning MachineSet %s/%s", ref.Name, ms.Namespace, ms.Name)
+		}
+		return GetOwnerCluster(ctx, c, md.ObjectMeta)
+	}
+	return nil, nil
+}
+
+// exceededQuotaRegex matches the message of the errors returned by the ResourceQuota admission plugin,
+// e.g. "exceeded quota: compute-resources, requested: pods=1, used: pods=10, limited: pods=10".
+var exceededQuotaRegex = regexp.MustCompile(`exceeded quota: ([^,]+)`)
+
+// IsQuotaExceeded returns true if the error was returned by the API server because
+// the request would exceed a ResourceQuota in the namespace.
+func IsQuotaExceeded(err error) bool {
+	err = errors.Cause(err)
+	return apierrors.IsForbidden(err) && exceededQuotaRegex.MatchString(err.Error())
+}
+
+// ExceededQuotaName returns the name of the ResourceQuota reported as exceeded by the error,
+// or an empty string if the error is not a quota exceeded error.
+func ExceededQuotaName(err error) string {
+	if !IsQuotaExceeded(err) {
+		return ""
+	}
+	matches := exceededQuotaRegex.FindStringSubmatch(errors.Cause(err).Error())
+	return strings.TrimSpace(matches[1])
+}
+
+// BootstrapDataHash returns the hex encoded SHA256 checksum of the given bootstrap data,
+// as stored in Machine.Status.BootstrapDataHash.
+func BootstrapDataHash(data []byte) string {
+	return fmt.Sprintf("%x", sha256.Sum256(data))
+}
+
+// ComputeMachineTemplateHash returns the hash of the given Machine template, as used by the MachineDeployment
+// controller for the machine-template-hash label of the MachineSets it creates.
+func ComputeMachineTemplateHash(template *clusterv1.MachineTemplateSpec) (string, error) {
+	if template == nil {
+		return "", errors.New("cannot compute the hash of a nil machine template")
+	}
+	hasher := fnv.New32a()
+	printer := spew.ConfigState{
+		Indent:         " ",
+		SortKeys:       true,
+		DisableMethods: true,
+		SpewKeys:       true,
+	}
+	printer.Fprintf(hasher, "%#v", *template)
+	return fmt.Sprintf("%d", hasher.Sum32()), nil
+}
+
+// BootstrapDataChanged returns true if the content of the bootstrap data secret referenced by
+// machine.Spec.Bootstrap.DataSecretName no longer matches machine.Status.BootstrapDataHash.
+// It returns false if the Machine has no data secret or no hash has been recorded yet.
+func BootstrapDataChanged(ctx context.Context, c client.Reader, machine *clusterv1.Machine) (bool, error) {
+	if machine.Spec.Bootstrap.DataSecretName == nil || machine.Status.BootstrapDataHash == "" {
+		return false, nil
+	}
+
+	secret := &v1.Secret{}
+	key := client.ObjectKey{Namespace: machine.Namespace, Name: *machine.Spec.Bootstrap.DataSecretName}
+	if err := c.Get(ctx, key, secret); err != nil {
+		return false, errors.Wrapf(err, "failed to retrieve bootstrap data secret for Machine %q in namespace %q", machine.Name, machine.Namespace)
+	}
+
+	value, ok := secret.Data["value"]
+	if !ok {
+		return false, errors.Errorf("bootstrap data secret %q for Machine %q in namespace %q is missing the value key", key.Name, machine.Name, machine.Namespace)
+	}
+
+	return BootstrapDataHash(value) != machine.Status.BootstrapDataHash, nil
+}
+
+// WouldViolatePDB returns true if evicting all the pods scheduled on the given node
+// would violate at least one of the PodDisruptionBudgets targeting them.
+// Terminated pods and pods managed by a DaemonSet are ignored, as they are not evicted when draining a node.
+func WouldViolatePDB(ctx context.Context, c client.Client, node *v1.Node) (bool, error) {
+	return NewPDBEvictionTracker(c).Evict(ctx, node)
+}
+
+// PDBEvictionTracker counts the pods evicted by draining several Nodes, e.g. the Nodes of the Machines deleted
+// together, so that each PodDisruptionBudget is checked against all of their evictions rather than one Node at a time.
+type PDBEvictionTracker struct {
+	client          client.Client
+	pdbsByNamespace map[string][]policyv1beta1.PodDisruptionBudget
+	// evictions counts the pods evicted so far for each PodDisruptionBudget.
+	evictions map[types.NamespacedName]int32
+}
+
+// NewPDBEvictionTracker returns a PDBEvictionTracker reading the pods and PodDisruptionBudgets with c.
+func NewPDBEvictionTracker(c client.Client) *PDBEvictionTracker {
+	return &PDBEvictionTracker{
+		client:          c,
+		pdbsByNamespace: map[string][]policyv1beta1.PodDisruptionBudget{},
+		evictions:       map[types.NamespacedName]int32{},
+	}
+}
+
+// Evict returns true if evicting all the pods scheduled on the given node, in addition to the pods of the Nodes
+// evicted before, would violate at least one of the PodDisruptionBudgets targeting them. Otherwise, the pods of
+// the node are counted as evicted. Pods are ignored like in WouldViolatePDB.
+func (t *PDBEvictionTracker) Evict(ctx context.Context, node *v1.Node) (bool, error) {
+	pods := &v1.PodList{}
+	if err := t.client.List(ctx, pods, client.MatchingFields{"spec.nodeName": node.Name}); err != nil {
+		return false, errors.Wrapf(err, "failed to list pods on Node %q", node.Name)
+	}
+
+	// evictions counts the pods of the node that would be evicted for each PodDisruptionBudget.
+	evictions := map[types.NamespacedName]int32{}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Spec.NodeName != node.Name || !pod.DeletionTimestamp.IsZero() ||
+			pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+			continue
+		}
+		if controllerRef := metav1.GetControllerOf(pod); controllerRef != nil && controllerRef.Kind == "DaemonSet" {
+			continue
+		}
+
+		pdbs, ok := t.pdbsByNamespace[pod.Namespace]
+		if !ok {
+			pdbList := &policyv1beta1.PodDisruptionBudgetList{}
+			if err := t.client.List(ctx, pdbList, client.InNamespace(pod.Namespace)); err != nil {
+				return false, errors.Wrapf(err, "failed to list PodDisruptionBudgets in namespace %q", pod.Namespace)
+			}
+			pdbs = pdbList.Items
+			t.pdbsByNamespace[pod.Namespace] = pdbs
+		}
+
+		for j := range pdbs {
+			pdb := &pdbs[j]
+			selector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+			if err != nil || selector.Empty() {
+				continue
+			}
+			if !selector.Matches(labels.Set(pod.Labels)) {
+				continue
+			}
+			key := types.NamespacedName{Namespace: pdb.Namespace, Name: pdb.Name}
+			evictions[key]++
+			if t.evictions[key]+evictions[key] > pdb.Status.PodDisruptionsAllowed {
+				return true, nil
+			}
+		}
+	}
+
+	for key, count := range evictions {
+		t.evictions[key] += count
+	}
+	return false, nil
+}
+
+// GVKToAPIResource returns the APIResource served by the API server for the given GroupVersionKind,
+// e.g. to get the plural resource name of a kind. Results are cached for each discovery client, for
+// apiResourcesCacheTTL, to avoid repeated discovery calls; InvalidateAPIResourceCache drops them earlier.
+// It returns a NotFound error if the kind is not served by the API server.
+func GVKToAPIResource(_ context.Context, discoveryClient discovery.DiscoveryInterface, gvk schema.GroupVersionKind) (*metav1.APIResource, error) {
+	// Discovery clients that can't be used as map keys aren't cached.
+	cacheable := discoveryClient != nil && reflect.TypeOf(discoveryClient).Comparable()
+	if cacheable {
+		apiResourcesCacheLock.RLock()
+		cached, ok := apiResourcesCache[discoveryClient][gvk]
+		apiResourcesCacheLock.RUnlock()
+		if ok && time.Now().Before(cached.expires) {
+			return &cached.resource, nil
+		}
+	}
+
+	resources, err := discoveryClient.ServerResourcesForGroupVersion(gvk.GroupVersion().String())
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, errors.Wrapf(err, "failed to discover resources for %s", gvk.GroupVersion())
+	}
+	if resources != nil {
+		for i := range resources.APIResources {
+			resource := resources.APIResources[i]
+			// Skip subresources, they share the kind of the parent resource.
+			if resource.Kind != gvk.Kind || strings.Contains(resource.Name, "/") {
+				continue
+			}
+			resource.Group = gvk.Group
+			resource.Version = gvk.Version
+
+			if cacheable {
+				apiResourcesCacheLock.Lock()
+				if apiResourcesCache[discoveryClient] == nil {
+					apiResourcesCache[discoveryClient] = map[schema.GroupVersionKind]cachedAPIResource{}
+				}
+				apiResourcesCache[discoveryClient][gvk] = cachedAPIResource{
+					resource: resource,
+					expires:  time.Now().Add(apiResourcesCacheTTL),
+				}
+				apiResourcesCacheLock.Unlock()
+			}
+			return &resource, nil
+		}
+	}
+
+	// Don't keep serving a kind that is no longer served.
+	InvalidateAPIResourceCache(discoveryClient, gvk)
+	return nil, apierrors.NewNotFound(schema.GroupResource{Group: gvk.Group, Resource: gvk.Kind}, "")
+}
+
+// InvalidateAPIResourceCache drops the APIResources cached by GVKToAPIResource for the discovery client,
+// e.g. once the CustomResourceDefinitions served by the API server changed. If GroupVersionKinds are given,
+// only their APIResources are dropped.
+func InvalidateAPIResourceCache(discoveryClient discovery.DiscoveryInterface, gvks ...schema.GroupVersionKind) {
+	if discoveryClient == nil || !reflect.TypeOf(discoveryClient).Comparable() {
+		return
+	}
+
+	apiResourcesCacheLock.Lock()
+	defer apiResourcesCacheLock.Unlock()
+	if len(gvks) == 0 {
+		delete(apiResourcesCache, discoveryClient)
+		return
+	}
+	for _, gvk := range gvks {
+		delete(apiResourcesCache[discoveryClient], gvk)
+	}
+	if len(apiResourcesCache[discoveryClient]) == 0 {
+		delete(apiResourcesCache, discoveryClient)
+	}
+}
+
 // MachineToInfrastructureMapFunc returns a handler.ToRequestsFunc that watches for
 // Machine events and returns reconciliation requests for an infrastructure provider object.
 func MachineToInfrastructureMapFunc(gvk schema.GroupVersionKind) handler.ToRequestsFunc {
@@ -394,6 +1237,14 @@ func EnsureOwnerRef(ownerReferences []metav1.OwnerReference, ref metav1.OwnerRef
 	return ownerReferences
 }
 
+// EnsureOwnerRefWithOptions makes sure the slice contains the OwnerReference,
+// setting its Controller and BlockOwnerDeletion fields to the given values.
+func EnsureOwnerRefWithOptions(ownerReferences []metav1.OwnerReference, ref metav1.OwnerReference, controller, blockDeletion bool) []metav1.OwnerReference {
+	ref.Controller = pointer.BoolPtr(controller)
+	ref.BlockOwnerDeletion = pointer.BoolPtr(blockDeletion)
+	return EnsureOwnerRef(ownerReferences, ref)
+}
+
 // indexOwnerRef returns the index of the owner reference in the slice if found, or -1.
 func indexOwnerRef(ownerReferences []metav1.OwnerReference, ref metav1.OwnerReference) int {
 	for index, r := range ownerReferences {
@@ -430,6 +1281,38 @@ func PointsTo(refs []metav1.OwnerReference, target *metav1.ObjectMeta) bool {
 	return false
 }
 
+// GetObjectGeneration returns the metadata.generation of the given unstructured object,
+// and false if it isn't set.
+func GetObjectGeneration(obj *unstructured.Unstructured) (int64, bool) {
+	return unstructuredInt64(obj, "metadata", "generation")
+}
+
+// GetObservedGeneration returns the status.observedGeneration of the given unstructured object,
+// and false if the provider doesn't report it.
+func GetObservedGeneration(obj *unstructured.Unstructured) (int64, bool) {
+	return unstructuredInt64(obj, "status", "observedGeneration")
+}
+
+// unstructuredInt64 returns the integer value of the given field, falling back to the float
+// representation used when the object was decoded by a plain JSON decoder.
+func unstructuredInt64(obj *unstructured.Unstructured, fields ...string) (int64, bool) {
+	value, found, err := unstructured.NestedFieldNoCopy(obj.Object, fields...)
+	if err != nil || !found {
+		return 0, false
+	}
+	switch v := value.(type) {
+	case int64:
+		return v, true
+	case int32:
+		return int64(v), true
+	case int:
+		return int64(v), true
+	case float64:
+		return int64(v), true
+	}
+	return 0, false
+}
+
 // UnstructuredUnmarshalField is a wrapper around json and unstructured objects to decode and copy a specific field
 // value into an object.
 func UnstructuredUnmarshalField(obj *unstructured.Unstructured, v interface{}, fields ...string) error {
@@ -466,6 +1349,17 @@ func HasOwner(refList []metav1.OwnerReference, apiVersion string, kinds []string
 	return false
 }
 
+// IsMachineSetOwnedByMachineDeployment returns true if the MachineSet has a MachineDeployment owner,
+// i.e. it is scaled by the MachineDeployment controller.
+func IsMachineSetOwnedByMachineDeployment(ms *clusterv1.MachineSet) bool {
+	return HasOwner(ms.OwnerReferences, clusterv1.GroupVersion.String(), []string{"MachineDeployment"})
+}
+
+// IsMachineOwnedByMachineSet returns true if the Machine has a MachineSet owner.
+func IsMachineOwnedByMachineSet(machine *clusterv1.Machine) bool {
+	return HasOwner(machine.OwnerReferences, clusterv1.GroupVersion.String(), []string{"MachineSet"})
+}
+
 // IsPaused returns true if the Cluster is paused or the object has the `paused` annotation.
 func IsPaused(cluster *clusterv1.Cluster, o metav1.Object) bool {
 	if cluster.Spec.Paused {
@@ -573,6 +1467,11 @@ func ClusterToObjectsMapper(c client.Client, ro runtime.Object, scheme *runtime.
 			return nil
 		}
 
+		// The objects of a Cluster being deleted are deleted too, there is no need to reconcile them on Cluster changes.
+		if !cluster.DeletionTimestamp.IsZero() {
+			return nil
+		}
+
 		list := &unstructured.UnstructuredList{}
 		list.SetGroupVersionKind(gvk)
 		if err := c.List(context.Background(), list, client.MatchingLabels{clusterv1.ClusterLabelName: cluster.Name}); err != nil {
@@ -589,3 +1488,33 @@ func ClusterToObjectsMapper(c client.Client, ro runtime.Object, scheme *runtime.
 
 	}), nil
 }
+
+// ClusterToMultipleObjectsMapper is ClusterToObjectsMapper for several object types at once: the returned mapper lists
+// the objects of each of the list types passed in and aggregates their requests, skipping duplicates.
+// Requests don't carry the type of the object, so it is meant for controllers reconciling all the given types together.
+// NB: The objects are required to have `clusterv1.ClusterLabelName` applied.
+func ClusterToMultipleObjectsMapper(c client.Client, objs []runtime.Object, scheme *runtime.Scheme) (handler.Mapper, error) {
+	mappers := make([]handler.Mapper, 0, len(objs))
+	for _, ro := range objs {
+		mapper, err := ClusterToObjectsMapper(c, ro, scheme)
+		if err != nil {
+			return nil, err
+		}
+		mappers = append(mappers, mapper)
+	}
+
+	return handler.ToRequestsFunc(func(o handler.MapObject) []ctrl.Request {
+		seen := map[ctrl.Request]bool{}
+		results := []ctrl.Request{}
+		for _, mapper := range mappers {
+			for _, req := range mapper.Map(o) {
+				if seen[req] {
+					continue
+				}
+				seen[req] = true
+				results = append(results, req)
+			}
+		}
+		return results
+	}), nil
+}
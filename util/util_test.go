@@ -19,18 +19,29 @@ package util
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/blang/semver"
 	. "github.com/onsi/gomega"
 
 	"github.com/docker/distribution/reference"
+	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/utils/pointer"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -43,10 +54,10 @@ func TestParseMajorMinorPatch(t *testing.T) {
 	g := NewWithT(t)
 
 	var testcases = []struct {
-		name        string
-		input       string
-		output      semver.Version
-		expectError bool
+		name          string
+		input         string
+		output        semver.Version
+		expectedError *VersionParseError
 	}{
 		{
 			name:  "should parse an OCI compliant string",
@@ -67,22 +78,270 @@ func TestParseMajorMinorPatch(t *testing.T) {
 			},
 		},
 		{
-			name:        "should error if there is no patch version",
-			input:       "v1.16+foobar-0",
+			name:          "should error if there is no patch version",
+			input:         "v1.16+foobar-0",
+			expectedError: &VersionParseError{Input: "v1.16+foobar-0", Reason: VersionParseErrorMissingPatch},
+		},
+		{
+			name:          "should error if there is no minor and patch",
+			input:         "v1+foobar-0",
+			expectedError: &VersionParseError{Input: "v1+foobar-0", Reason: VersionParseErrorMissingMinor},
+		},
+		{
+			name:          "should error if the version is not in a known format",
+			input:         "latest",
+			expectedError: &VersionParseError{Input: "latest", Reason: VersionParseErrorInvalidFormat},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			out, err := ParseMajorMinorPatch(tc.input)
+			if tc.expectedError != nil {
+				g.Expect(err).To(HaveOccurred())
+				parseErr, ok := err.(*VersionParseError)
+				g.Expect(ok).To(BeTrue())
+				g.Expect(parseErr).To(Equal(tc.expectedError))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+			g.Expect(out).To(Equal(tc.output))
+		})
+	}
+}
+
+func TestIsVersionNewer(t *testing.T) {
+	var testcases = []struct {
+		name        string
+		version     string
+		other       string
+		expected    bool
+		expectError bool
+	}{
+		{
+			name:     "should return true for a newer minor version",
+			version:  "v1.21.0",
+			other:    "v1.20.5",
+			expected: true,
+		},
+		{
+			name:     "should return false for an older patch version",
+			version:  "v1.21.0",
+			other:    "v1.21.1",
+			expected: false,
+		},
+		{
+			name:     "should return false for equal versions",
+			version:  "v1.21.0",
+			other:    "v1.21.0",
+			expected: false,
+		},
+		{
+			name:     "should return true for a release compared to a release candidate",
+			version:  "v1.21.0",
+			other:    "v1.21.0-rc.1",
+			expected: true,
+		},
+		{
+			name:     "should return true for a release candidate compared to a beta",
+			version:  "v1.21.0-rc.1",
+			other:    "v1.21.0-beta.1",
+			expected: true,
+		},
+		{
+			name:     "should return true for a beta compared to an alpha",
+			version:  "v1.21.0-beta.1",
+			other:    "v1.21.0-alpha.1",
+			expected: true,
+		},
+		{
+			name:     "should return false for an alpha compared to a release",
+			version:  "v1.21.0-alpha.1",
+			other:    "v1.21.0",
+			expected: false,
+		},
+		{
+			name:        "should error on an invalid version",
+			version:     "v1.x",
+			other:       "v1.21.0",
 			expectError: true,
 		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			newer, err := IsVersionNewer(tc.version, tc.other)
+			g.Expect(err != nil).To(Equal(tc.expectError))
+			g.Expect(newer).To(Equal(tc.expected))
+		})
+	}
+}
+
+func TestParseVersionRange(t *testing.T) {
+	var testcases = []struct {
+		name         string
+		versionRange string
+		contains     []string
+		notContains  []string
+		expectError  bool
+	}{
+		{
+			name:         "should include the lower and exclude the upper bound",
+			versionRange: ">= 1.20, < 1.22",
+			contains:     []string{"v1.20.0", "v1.21.5"},
+			notContains:  []string{"v1.19.9", "v1.22.0", "v1.22.1"},
+		},
+		{
+			name:         "should exclude the lower and include the upper bound",
+			versionRange: "> v1.20.1,<= v1.21.0",
+			contains:     []string{"v1.20.2", "v1.21.0"},
+			notContains:  []string{"v1.20.1", "v1.21.1"},
+		},
+		{
+			name:         "should support ranges without an upper bound",
+			versionRange: ">= 1.20",
+			contains:     []string{"v1.20.0", "v2.0.0"},
+			notContains:  []string{"v1.19.16"},
+		},
+		{
+			name:         "should support ranges without a lower bound",
+			versionRange: "< 1.20",
+			contains:     []string{"v0.0.0", "v1.19.16"},
+			notContains:  []string{"v1.20.0"},
+		},
+		{
+			name:         "should support exact versions",
+			versionRange: "= 1.21.2",
+			contains:     []string{"v1.21.2"},
+			notContains:  []string{"v1.21.1", "v1.21.3"},
+		},
 		{
-			name:        "should error if there is no minor and patch",
-			input:       "v1+foobar-0",
+			name:         "should keep the strictest bounds",
+			versionRange: ">= 1.19, > 1.20, <= 1.23, < 1.22",
+			contains:     []string{"v1.20.1", "v1.21.0"},
+			notContains:  []string{"v1.20.0", "v1.22.0"},
+		},
+		{
+			name:         "should error on an empty range",
+			versionRange: " ",
+			expectError:  true,
+		},
+		{
+			name:         "should error on a constraint without an operator",
+			versionRange: ">= 1.20, 1.22",
+			expectError:  true,
+		},
+		{
+			name:         "should error on an invalid version",
+			versionRange: ">= 1.x",
+			expectError:  true,
+		},
+		{
+			name:         "should error on constraints no version satisfies",
+			versionRange: ">= 1.22, < 1.20",
+			expectError:  true,
+		},
+		{
+			name:         "should error on an empty interval",
+			versionRange: "> 1.20, <= 1.20",
+			expectError:  true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			r, err := ParseVersionRange(tc.versionRange)
+			if tc.expectError {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+
+			for _, v := range tc.contains {
+				g.Expect(r.Contains(semver.MustParse(strings.TrimPrefix(v, "v")))).To(BeTrue(), "expected %s to be in range %q", v, tc.versionRange)
+			}
+			for _, v := range tc.notContains {
+				g.Expect(r.Contains(semver.MustParse(strings.TrimPrefix(v, "v")))).To(BeFalse(), "expected %s not to be in range %q", v, tc.versionRange)
+			}
+		})
+	}
+}
+
+func TestSortMachinesByVersion(t *testing.T) {
+	newMachine := func(name string, version *string) clusterv1.Machine {
+		return clusterv1.Machine{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       clusterv1.MachineSpec{Version: version},
+		}
+	}
+	names := func(machines []clusterv1.Machine) []string {
+		out := make([]string, len(machines))
+		for i := range machines {
+			out[i] = machines[i].Name
+		}
+		return out
+	}
+
+	var testcases = []struct {
+		name        string
+		machines    []clusterv1.Machine
+		ascending   bool
+		expected    []string
+		expectError bool
+	}{
+		{
+			name: "should sort machines by ascending version",
+			machines: []clusterv1.Machine{
+				newMachine("v1.22", pointer.StringPtr("v1.22.0")),
+				newMachine("v1.20", pointer.StringPtr("v1.20.2")),
+				newMachine("v1.21", pointer.StringPtr("v1.21.1")),
+			},
+			ascending: true,
+			expected:  []string{"v1.20", "v1.21", "v1.22"},
+		},
+		{
+			name: "should sort machines by descending version",
+			machines: []clusterv1.Machine{
+				newMachine("v1.21", pointer.StringPtr("v1.21.1")),
+				newMachine("v1.20", pointer.StringPtr("v1.20.2")),
+				newMachine("v1.22", pointer.StringPtr("v1.22.0")),
+			},
+			ascending: false,
+			expected:  []string{"v1.22", "v1.21", "v1.20"},
+		},
+		{
+			name: "should place machines without a version at the end",
+			machines: []clusterv1.Machine{
+				newMachine("none", nil),
+				newMachine("v1.22", pointer.StringPtr("v1.22.0")),
+				newMachine("v1.20", pointer.StringPtr("v1.20.2")),
+			},
+			ascending: true,
+			expected:  []string{"v1.20", "v1.22", "none"},
+		},
+		{
+			name: "should return an error if a version can not be parsed",
+			machines: []clusterv1.Machine{
+				newMachine("v1.22", pointer.StringPtr("v1.22.0")),
+				newMachine("invalid", pointer.StringPtr("v1.20")),
+			},
+			ascending:   true,
+			expected:    []string{"v1.22", "invalid"},
 			expectError: true,
 		},
 	}
 
 	for _, tc := range testcases {
 		t.Run(tc.name, func(t *testing.T) {
-			out, err := ParseMajorMinorPatch(tc.input)
+			g := NewWithT(t)
+
+			err := SortMachinesByVersion(tc.machines, tc.ascending)
 			g.Expect(err != nil).To(Equal(tc.expectError))
-			g.Expect(out).To(Equal(tc.output))
+			g.Expect(names(tc.machines)).To(Equal(tc.expected))
 		})
 	}
 }
@@ -313,6 +572,60 @@ func TestHasOwner(t *testing.T) {
 	}
 }
 
+func TestIsOwnedByMachineDeploymentOrMachineSet(t *testing.T) {
+	machineDeploymentRef := metav1.OwnerReference{APIVersion: clusterv1.GroupVersion.String(), Kind: "MachineDeployment", Name: "md"}
+	machineSetRef := metav1.OwnerReference{APIVersion: clusterv1.GroupVersion.String(), Kind: "MachineSet", Name: "ms"}
+	clusterRef := metav1.OwnerReference{APIVersion: clusterv1.GroupVersion.String(), Kind: "Cluster", Name: "cluster"}
+	otherGroupRef := metav1.OwnerReference{APIVersion: "apps/v1", Kind: "MachineSet", Name: "ms"}
+
+	tests := []struct {
+		name                           string
+		refList                        []metav1.OwnerReference
+		expectOwnedByMachineDeployment bool
+		expectOwnedByMachineSet        bool
+	}{
+		{
+			name: "no owner",
+		},
+		{
+			name:    "owned by a Cluster only",
+			refList: []metav1.OwnerReference{clusterRef},
+		},
+		{
+			name:                           "owned by a MachineDeployment",
+			refList:                        []metav1.OwnerReference{clusterRef, machineDeploymentRef},
+			expectOwnedByMachineDeployment: true,
+		},
+		{
+			name:                    "owned by a MachineSet",
+			refList:                 []metav1.OwnerReference{machineSetRef, clusterRef},
+			expectOwnedByMachineSet: true,
+		},
+		{
+			name:                           "owned by both",
+			refList:                        []metav1.OwnerReference{machineSetRef, machineDeploymentRef},
+			expectOwnedByMachineDeployment: true,
+			expectOwnedByMachineSet:        true,
+		},
+		{
+			name:    "owned by a kind of another group",
+			refList: []metav1.OwnerReference{otherGroupRef},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			ms := &clusterv1.MachineSet{ObjectMeta: metav1.ObjectMeta{OwnerReferences: tt.refList}}
+			g.Expect(IsMachineSetOwnedByMachineDeployment(ms)).To(Equal(tt.expectOwnedByMachineDeployment))
+
+			machine := &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{OwnerReferences: tt.refList}}
+			g.Expect(IsMachineOwnedByMachineSet(machine)).To(Equal(tt.expectOwnedByMachineSet))
+		})
+	}
+}
+
 func TestPointsTo(t *testing.T) {
 	g := NewWithT(t)
 
@@ -395,6 +708,193 @@ func TestGetOwnerClusterSuccessByName(t *testing.T) {
 	g.Expect(cluster).NotTo(BeNil())
 }
 
+func TestGetOwnerClusterMultipleOwners(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+
+	c := fake.NewFakeClientWithScheme(scheme,
+		&clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "my-cluster", Namespace: "my-ns"}},
+		&clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "my-other-cluster", Namespace: "my-ns"}},
+	)
+	objm := metav1.ObjectMeta{
+		OwnerReferences: []metav1.OwnerReference{
+			{
+				Kind:       "Cluster",
+				APIVersion: clusterv1.GroupVersion.String(),
+				Name:       "my-cluster",
+			},
+			{
+				Kind:       "Cluster",
+				APIVersion: clusterv1.GroupVersion.String(),
+				Name:       "my-other-cluster",
+			},
+		},
+		Namespace: "my-ns",
+		Name:      "my-resource-owned-by-clusters",
+	}
+	cluster, err := GetOwnerCluster(context.TODO(), c, objm)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(errors.Cause(err)).To(Equal(ErrMultipleClusterOwners))
+	g.Expect(cluster).To(BeNil())
+}
+
+// blockingClient is a client whose Get calls block until their context is done.
+type blockingClient struct {
+	client.Client
+}
+
+func (c *blockingClient) Get(ctx context.Context, _ client.ObjectKey, _ runtime.Object) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (c *blockingClient) List(ctx context.Context, _ runtime.Object, _ ...client.ListOption) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestGetOwnerClusterWithTimeout(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+
+	c := &blockingClient{Client: fake.NewFakeClientWithScheme(scheme)}
+	objm := metav1.ObjectMeta{
+		OwnerReferences: []metav1.OwnerReference{
+			{
+				Kind:       "Cluster",
+				APIVersion: clusterv1.GroupVersion.String(),
+				Name:       "my-cluster",
+			},
+		},
+		Namespace: "my-ns",
+		Name:      "my-resource-owned-by-cluster",
+	}
+
+	start := time.Now()
+	cluster, err := GetOwnerClusterWithTimeout(context.TODO(), 50*time.Millisecond, c, objm)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(errors.Cause(err)).To(Equal(context.DeadlineExceeded))
+	g.Expect(cluster).To(BeNil())
+	g.Expect(time.Since(start)).To(BeNumerically("<", 5*time.Second))
+}
+
+func TestGettersContextCancellation(t *testing.T) {
+	owned := metav1.ObjectMeta{
+		OwnerReferences: []metav1.OwnerReference{
+			{Kind: "Cluster", APIVersion: clusterv1.GroupVersion.String(), Name: "my-cluster"},
+			{Kind: "Machine", APIVersion: clusterv1.GroupVersion.String(), Name: "my-machine"},
+		},
+		Namespace: "my-ns",
+		Name:      "my-resource",
+	}
+	cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "my-cluster", Namespace: "my-ns"}}
+
+	testCases := []struct {
+		name string
+		call func(ctx context.Context, c client.Client) error
+	}{
+		{
+			name: "GetOwnerCluster",
+			call: func(ctx context.Context, c client.Client) error {
+				_, err := GetOwnerCluster(ctx, c, owned)
+				return err
+			},
+		},
+		{
+			name: "GetOwnerMachine",
+			call: func(ctx context.Context, c client.Client) error {
+				_, err := GetOwnerMachine(ctx, c, owned)
+				return err
+			},
+		},
+		{
+			name: "GetMachinesForCluster",
+			call: func(ctx context.Context, c client.Client) error {
+				_, err := GetMachinesForCluster(ctx, c, cluster)
+				return err
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			scheme := runtime.NewScheme()
+			g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+			c := &blockingClient{Client: fake.NewFakeClientWithScheme(scheme)}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(50*time.Millisecond, cancel)
+
+			start := time.Now()
+			err := tc.call(ctx, c)
+			g.Expect(err).To(HaveOccurred())
+			g.Expect(errors.Cause(err)).To(Equal(context.Canceled))
+			g.Expect(time.Since(start)).To(BeNumerically("<", 5*time.Second))
+		})
+	}
+}
+
+func TestGetClusterKubeconfigSecret(t *testing.T) {
+	cluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-cluster", Namespace: "my-ns"},
+	}
+	kubeconfigSecret := func(data []byte) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "my-cluster-kubeconfig", Namespace: "my-ns"},
+			Data:       map[string][]byte{"value": data},
+		}
+	}
+
+	testCases := []struct {
+		name        string
+		objs        []runtime.Object
+		expectErr   bool
+		expectCause error
+	}{
+		{
+			name: "secret exists",
+			objs: []runtime.Object{kubeconfigSecret([]byte("kubeconfig"))},
+		},
+		{
+			name:        "secret is missing",
+			expectErr:   true,
+			expectCause: ErrKubeconfigNotFound,
+		},
+		{
+			name:      "secret has an empty value",
+			objs:      []runtime.Object{kubeconfigSecret(nil)},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			scheme := runtime.NewScheme()
+			g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+			c := fake.NewFakeClientWithScheme(scheme, tc.objs...)
+
+			s, err := GetClusterKubeconfigSecret(context.TODO(), c, cluster)
+			if tc.expectErr {
+				g.Expect(err).To(HaveOccurred())
+				if tc.expectCause != nil {
+					g.Expect(errors.Cause(err)).To(Equal(tc.expectCause))
+				}
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(s.Data["value"]).To(Equal([]byte("kubeconfig")))
+		})
+	}
+}
+
 func TestGetOwnerMachineSuccessByName(t *testing.T) {
 	g := NewWithT(t)
 
@@ -425,60 +925,1163 @@ func TestGetOwnerMachineSuccessByName(t *testing.T) {
 	g.Expect(machine).NotTo(BeNil())
 }
 
-func TestGetMachinesForCluster(t *testing.T) {
+func TestGetMachineSetForMachine(t *testing.T) {
 	g := NewWithT(t)
 
 	scheme := runtime.NewScheme()
 	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
 
-	cluster := &clusterv1.Cluster{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      "my-cluster",
-			Namespace: "my-ns",
-		},
+	deploymentRef := metav1.OwnerReference{
+		Kind:       "MachineDeployment",
+		APIVersion: clusterv1.GroupVersion.String(),
+		Name:       "my-deployment",
 	}
-
-	machine := &clusterv1.Machine{
+	myMachineSet := &clusterv1.MachineSet{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      "my-machine",
-			Namespace: cluster.Namespace,
-			Labels: map[string]string{
-				clusterv1.ClusterLabelName: cluster.Name,
+			Name:            "my-machineset",
+			Namespace:       "my-ns",
+			OwnerReferences: []metav1.OwnerReference{deploymentRef},
+		},
+		Spec: clusterv1.MachineSetSpec{
+			Selector: metav1.LabelSelector{
+				MatchLabels: map[string]string{"set": "my-machineset"},
 			},
 		},
 	}
+	c := fake.NewFakeClientWithScheme(scheme, myMachineSet)
 
-	machineDifferentClusterNameSameNamespace := &clusterv1.Machine{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      "other-machine",
-			Namespace: cluster.Namespace,
-			Labels: map[string]string{
-				clusterv1.ClusterLabelName: "other-cluster",
-			},
+	testCases := []struct {
+		name    string
+		machine *clusterv1.Machine
+		want    string
+	}{
+		{
+			name: "machine owned by a MachineSet",
+			machine: &clusterv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "my-machine",
+					Namespace: "my-ns",
+					OwnerReferences: []metav1.OwnerReference{
+						{
+							Kind:       "MachineSet",
+							APIVersion: clusterv1.GroupVersion.String(),
+							Name:       "my-machineset",
+						},
+					},
+				},
+			},
+			want: "my-machineset",
+		},
+		{
+			name: "machine owned by a MachineDeployment",
+			machine: &clusterv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:            "my-machine",
+					Namespace:       "my-ns",
+					Labels:          map[string]string{"set": "my-machineset"},
+					OwnerReferences: []metav1.OwnerReference{deploymentRef},
+				},
+			},
+			want: "my-machineset",
+		},
+		{
+			name: "standalone machine",
+			machine: &clusterv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "my-machine",
+					Namespace: "my-ns",
+					Labels:    map[string]string{"set": "my-machineset"},
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			ms, err := GetMachineSetForMachine(context.TODO(), c, tc.machine)
+			g.Expect(err).NotTo(HaveOccurred())
+			if tc.want == "" {
+				g.Expect(ms).To(BeNil())
+				return
+			}
+			g.Expect(ms).NotTo(BeNil())
+			g.Expect(ms.Name).To(Equal(tc.want))
+		})
+	}
+}
+
+func TestValidateMachineOwnerReferences(t *testing.T) {
+	clusterOwner := func(name string) metav1.OwnerReference {
+		return metav1.OwnerReference{APIVersion: clusterv1.GroupVersion.String(), Kind: "Cluster", Name: name}
+	}
+	machineSetOwner := func(name string) metav1.OwnerReference {
+		return metav1.OwnerReference{APIVersion: clusterv1.GroupVersion.String(), Kind: "MachineSet", Name: name}
+	}
+
+	testCases := []struct {
+		name         string
+		owners       []metav1.OwnerReference
+		expectErrors int
+	}{
+		{
+			name: "no owners",
+		},
+		{
+			name:   "owned by a Cluster and a MachineSet of the same cluster",
+			owners: []metav1.OwnerReference{clusterOwner("test-cluster"), machineSetOwner("ms")},
+		},
+		{
+			name:         "owned by multiple Clusters",
+			owners:       []metav1.OwnerReference{clusterOwner("test-cluster"), clusterOwner("other-cluster")},
+			expectErrors: 1,
+		},
+		{
+			name:         "owned by multiple MachineSets",
+			owners:       []metav1.OwnerReference{machineSetOwner("ms"), machineSetOwner("other-ms")},
+			expectErrors: 1,
+		},
+		{
+			name:         "owned by a Cluster other than the one of its MachineSet",
+			owners:       []metav1.OwnerReference{clusterOwner("other-cluster"), machineSetOwner("ms")},
+			expectErrors: 1,
+		},
+		{
+			name:         "multiple violations",
+			owners:       []metav1.OwnerReference{clusterOwner("test-cluster"), clusterOwner("other-cluster"), machineSetOwner("ms"), machineSetOwner("other-ms")},
+			expectErrors: 3,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			machine := &clusterv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{Name: "machine", Namespace: "default", OwnerReferences: tc.owners},
+				Spec:       clusterv1.MachineSpec{ClusterName: "test-cluster"},
+			}
+
+			err := ValidateMachineOwnerReferences(machine)
+			if tc.expectErrors == 0 {
+				g.Expect(err).NotTo(HaveOccurred())
+				return
+			}
+			g.Expect(err).To(HaveOccurred())
+			g.Expect(err.(kerrors.Aggregate).Errors()).To(HaveLen(tc.expectErrors))
+		})
+	}
+}
+
+func TestPatchIfChanged(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clusterv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	newMachineSet := func() *clusterv1.MachineSet {
+		return &clusterv1.MachineSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "my-machineset",
+				Namespace: "my-ns",
+				Labels:    map[string]string{"foo": "bar"},
+			},
+			Spec: clusterv1.MachineSetSpec{Replicas: pointer.Int32Ptr(1)},
+		}
+	}
+
+	testCases := []struct {
+		name          string
+		modify        func(ms *clusterv1.MachineSet)
+		expectPatched bool
+	}{
+		{
+			name:          "should not patch an object without changes",
+			modify:        func(ms *clusterv1.MachineSet) {},
+			expectPatched: false,
+		},
+		{
+			name:          "should not patch an object set to equal values",
+			modify:        func(ms *clusterv1.MachineSet) { ms.Labels["foo"] = "bar" },
+			expectPatched: false,
+		},
+		{
+			name:          "should patch a changed metadata field",
+			modify:        func(ms *clusterv1.MachineSet) { ms.Labels["foo"] = "baz" },
+			expectPatched: true,
+		},
+		{
+			name:          "should patch a changed spec field",
+			modify:        func(ms *clusterv1.MachineSet) { ms.Spec.Replicas = pointer.Int32Ptr(3) },
+			expectPatched: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			ms := newMachineSet()
+			c := fake.NewFakeClientWithScheme(scheme, ms)
+			g.Expect(c.Get(context.TODO(), ObjectKey(ms), ms)).To(Succeed())
+			resourceVersion := ms.ResourceVersion
+
+			original := ms.DeepCopy()
+			tc.modify(ms)
+
+			patched, err := PatchIfChanged(context.TODO(), c, original, ms)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(patched).To(Equal(tc.expectPatched))
+
+			got := &clusterv1.MachineSet{}
+			g.Expect(c.Get(context.TODO(), ObjectKey(ms), got)).To(Succeed())
+			g.Expect(got.Labels).To(Equal(ms.Labels))
+			g.Expect(got.Spec.Replicas).To(Equal(ms.Spec.Replicas))
+			if tc.expectPatched {
+				g.Expect(got.ResourceVersion).NotTo(Equal(resourceVersion))
+			} else {
+				g.Expect(got.ResourceVersion).To(Equal(resourceVersion))
+			}
+		})
+	}
+}
+
+// patchCountingClient wraps a client and counts the Patch calls it receives.
+type patchCountingClient struct {
+	client.Client
+	patches int
+}
+
+func (c *patchCountingClient) Patch(ctx context.Context, obj runtime.Object, patch client.Patch, opts ...client.PatchOption) error {
+	c.patches++
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func TestAnnotateObject(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clusterv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name          string
+		existing      map[string]string
+		annotations   map[string]string
+		expected      map[string]string
+		expectPatches int
+		expectErr     bool
+	}{
+		{
+			name:          "should merge the annotations into the existing ones",
+			existing:      map[string]string{"keep": "me", "foo": "bar"},
+			annotations:   map[string]string{"foo": "baz", "new": "value"},
+			expected:      map[string]string{"keep": "me", "foo": "baz", "new": "value"},
+			expectPatches: 1,
+		},
+		{
+			name:          "should annotate an object without annotations",
+			annotations:   map[string]string{"foo": "bar", "new": "value"},
+			expected:      map[string]string{"foo": "bar", "new": "value"},
+			expectPatches: 1,
+		},
+		{
+			name:          "should not patch an object already annotated",
+			existing:      map[string]string{"keep": "me", "foo": "bar"},
+			annotations:   map[string]string{"foo": "bar"},
+			expected:      map[string]string{"keep": "me", "foo": "bar"},
+			expectPatches: 0,
+		},
+		{
+			name:          "should return an error for an empty key",
+			existing:      map[string]string{"keep": "me"},
+			annotations:   map[string]string{"foo": "bar", "": "value"},
+			expected:      map[string]string{"keep": "me"},
+			expectPatches: 0,
+			expectErr:     true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			ms := &clusterv1.MachineSet{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "my-machineset",
+					Namespace:   "my-ns",
+					Annotations: tc.existing,
+				},
+			}
+			c := &patchCountingClient{Client: fake.NewFakeClientWithScheme(scheme, ms.DeepCopy())}
+
+			err := AnnotateObject(context.TODO(), c, ms, tc.annotations)
+			if tc.expectErr {
+				g.Expect(err).To(HaveOccurred())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+			g.Expect(c.patches).To(Equal(tc.expectPatches))
+
+			got := &clusterv1.MachineSet{}
+			g.Expect(c.Get(context.TODO(), ObjectKey(ms), got)).To(Succeed())
+			g.Expect(got.Annotations).To(Equal(tc.expected))
+		})
+	}
+}
+
+func TestGetClusterForMachineSet(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+
+	clusterRef := metav1.OwnerReference{
+		Kind:       "Cluster",
+		APIVersion: clusterv1.GroupVersion.String(),
+		Name:       "my-cluster",
+	}
+	myCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "my-cluster",
+			Namespace: "my-ns",
+		},
+	}
+	myDeployment := &clusterv1.MachineDeployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "my-deployment",
+			Namespace:       "my-ns",
+			OwnerReferences: []metav1.OwnerReference{clusterRef},
+		},
+	}
+	orphanDeployment := &clusterv1.MachineDeployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "orphan-deployment",
+			Namespace: "my-ns",
+		},
+	}
+	c := fake.NewFakeClientWithScheme(scheme, myCluster, myDeployment, orphanDeployment)
+
+	testCases := []struct {
+		name      string
+		owners    []metav1.OwnerReference
+		want      string
+		expectErr bool
+	}{
+		{
+			name:   "MachineSet owned by a Cluster",
+			owners: []metav1.OwnerReference{clusterRef},
+			want:   "my-cluster",
+		},
+		{
+			name: "MachineSet owned by a MachineDeployment owned by a Cluster",
+			owners: []metav1.OwnerReference{
+				{
+					Kind:       "MachineDeployment",
+					APIVersion: clusterv1.GroupVersion.String(),
+					Name:       "my-deployment",
+				},
+			},
+			want: "my-cluster",
+		},
+		{
+			name: "MachineSet owned by a MachineDeployment without a Cluster owner",
+			owners: []metav1.OwnerReference{
+				{
+					Kind:       "MachineDeployment",
+					APIVersion: clusterv1.GroupVersion.String(),
+					Name:       "orphan-deployment",
+				},
+			},
+		},
+		{
+			name: "MachineSet without owners",
+		},
+		{
+			name: "MachineSet owned by a MachineDeployment that does not exist",
+			owners: []metav1.OwnerReference{
+				{
+					Kind:       "MachineDeployment",
+					APIVersion: clusterv1.GroupVersion.String(),
+					Name:       "missing-deployment",
+				},
+			},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			ms := &clusterv1.MachineSet{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:            "my-machineset",
+					Namespace:       "my-ns",
+					OwnerReferences: tc.owners,
+				},
+			}
+
+			cluster, err := GetClusterForMachineSet(context.TODO(), c, ms)
+			if tc.expectErr {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			if tc.want == "" {
+				g.Expect(cluster).To(BeNil())
+				return
+			}
+			g.Expect(cluster).NotTo(BeNil())
+			g.Expect(cluster.Name).To(Equal(tc.want))
+		})
+	}
+}
+
+func TestIsQuotaExceeded(t *testing.T) {
+	quotaErr := apierrors.NewForbidden(schema.GroupResource{Resource: "machinesets"}, "ms-1",
+		errors.New("exceeded quota: compute-resources, requested: count/machinesets.cluster.x-k8s.io=1, used: count/machinesets.cluster.x-k8s.io=5, limited: count/machinesets.cluster.x-k8s.io=5"))
+
+	testCases := []struct {
+		name         string
+		err          error
+		expected     bool
+		expectedName string
+	}{
+		{
+			name:         "quota exceeded error",
+			err:          quotaErr,
+			expected:     true,
+			expectedName: "compute-resources",
+		},
+		{
+			name:         "wrapped quota exceeded error",
+			err:          errors.Wrap(quotaErr, "failed to create MachineSet"),
+			expected:     true,
+			expectedName: "compute-resources",
+		},
+		{
+			name:     "forbidden error not related to quotas",
+			err:      apierrors.NewForbidden(schema.GroupResource{Resource: "machinesets"}, "ms-1", errors.New("not allowed")),
+			expected: false,
+		},
+		{
+			name:     "other error",
+			err:      apierrors.NewNotFound(schema.GroupResource{Resource: "machinesets"}, "ms-1"),
+			expected: false,
+		},
+		{
+			name:     "nil error",
+			err:      nil,
+			expected: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			g.Expect(IsQuotaExceeded(tc.err)).To(Equal(tc.expected))
+			g.Expect(ExceededQuotaName(tc.err)).To(Equal(tc.expectedName))
+		})
+	}
+}
+
+func TestBootstrapDataChanged(t *testing.T) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "bootstrap-data",
+			Namespace: "default",
+		},
+		Data: map[string][]byte{
+			"value": []byte("#cloud-config\n... data"),
+		},
+	}
+
+	testCases := []struct {
+		name           string
+		dataSecretName *string
+		hash           string
+		objs           []runtime.Object
+		expected       bool
+		expectErr      bool
+	}{
+		{
+			name:           "hash matches the secret content",
+			dataSecretName: pointer.StringPtr("bootstrap-data"),
+			hash:           BootstrapDataHash([]byte("#cloud-config\n... data")),
+			objs:           []runtime.Object{secret},
+			expected:       false,
+		},
+		{
+			name:           "hash does not match the secret content",
+			dataSecretName: pointer.StringPtr("bootstrap-data"),
+			hash:           BootstrapDataHash([]byte("#cloud-config\n... old data")),
+			objs:           []runtime.Object{secret},
+			expected:       true,
+		},
+		{
+			name:           "no hash recorded yet",
+			dataSecretName: pointer.StringPtr("bootstrap-data"),
+			objs:           []runtime.Object{secret},
+			expected:       false,
+		},
+		{
+			name:     "no data secret",
+			hash:     BootstrapDataHash([]byte("#cloud-config\n... data")),
+			expected: false,
+		},
+		{
+			name:           "secret not found",
+			dataSecretName: pointer.StringPtr("bootstrap-data"),
+			hash:           BootstrapDataHash([]byte("#cloud-config\n... data")),
+			expectErr:      true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			machine := &clusterv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-machine",
+					Namespace: "default",
+				},
+				Spec: clusterv1.MachineSpec{
+					Bootstrap: clusterv1.Bootstrap{
+						DataSecretName: tc.dataSecretName,
+					},
+				},
+				Status: clusterv1.MachineStatus{
+					BootstrapDataHash: tc.hash,
+				},
+			}
+
+			scheme := runtime.NewScheme()
+			g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+
+			c := fake.NewFakeClientWithScheme(scheme, tc.objs...)
+			changed, err := BootstrapDataChanged(context.Background(), c, machine)
+			if tc.expectErr {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(changed).To(Equal(tc.expected))
+		})
+	}
+}
+
+func TestWouldViolatePDB(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := policyv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "my-node"}}
+	newPod := func(name, nodeName string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "my-ns",
+				Labels:    map[string]string{"app": "web"},
+			},
+			Spec: corev1.PodSpec{NodeName: nodeName},
+		}
+	}
+	newPDB := func(disruptionsAllowed int32) *policyv1beta1.PodDisruptionBudget {
+		return &policyv1beta1.PodDisruptionBudget{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "web",
+				Namespace: "my-ns",
+			},
+			Spec: policyv1beta1.PodDisruptionBudgetSpec{
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+			},
+			Status: policyv1beta1.PodDisruptionBudgetStatus{PodDisruptionsAllowed: disruptionsAllowed},
+		}
+	}
+	succeededPod := newPod("succeeded", node.Name)
+	succeededPod.Status.Phase = corev1.PodSucceeded
+
+	testcases := []struct {
+		name     string
+		objects  []runtime.Object
+		expected bool
+	}{
+		{
+			name:     "no pods on the node",
+			objects:  []runtime.Object{newPod("web-1", "other-node"), newPDB(0)},
+			expected: false,
+		},
+		{
+			name:     "no PodDisruptionBudget targeting the pods",
+			objects:  []runtime.Object{newPod("web-1", node.Name)},
+			expected: false,
+		},
+		{
+			name:     "PodDisruptionBudget allows the disruption",
+			objects:  []runtime.Object{newPod("web-1", node.Name), newPDB(1)},
+			expected: false,
+		},
+		{
+			name:     "PodDisruptionBudget doesn't allow any disruption",
+			objects:  []runtime.Object{newPod("web-1", node.Name), newPDB(0)},
+			expected: true,
+		},
+		{
+			name:     "PodDisruptionBudget doesn't allow the disruption of all the pods on the node",
+			objects:  []runtime.Object{newPod("web-1", node.Name), newPod("web-2", node.Name), newPDB(1)},
+			expected: true,
+		},
+		{
+			name:     "terminated pods are ignored",
+			objects:  []runtime.Object{succeededPod, newPDB(0)},
+			expected: false,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			c := fake.NewFakeClientWithScheme(scheme, tc.objects...)
+			violated, err := WouldViolatePDB(context.Background(), c, node)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(violated).To(Equal(tc.expected))
+		})
+	}
+}
+
+func TestPDBEvictionTracker(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(policyv1beta1.AddToScheme(scheme)).To(Succeed())
+
+	newPod := func(name, nodeName string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "my-ns",
+				Labels:    map[string]string{"app": "web"},
+			},
+			Spec: corev1.PodSpec{NodeName: nodeName},
+		}
+	}
+	c := fake.NewFakeClientWithScheme(scheme,
+		newPod("web-1", "node-1"),
+		newPod("web-2", "node-2"),
+		newPod("web-3", "node-3"),
+		&policyv1beta1.PodDisruptionBudget{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "my-ns"},
+			Spec: policyv1beta1.PodDisruptionBudgetSpec{
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+			},
+			Status: policyv1beta1.PodDisruptionBudgetStatus{PodDisruptionsAllowed: 2},
+		},
+	)
+
+	tracker := NewPDBEvictionTracker(c)
+	for _, nodeName := range []string{"node-1", "node-2"} {
+		violated, err := tracker.Evict(context.Background(), &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: nodeName}})
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(violated).To(BeFalse())
+	}
+
+	// Each Node on its own is fine, but the budget is used up by the Nodes evicted before.
+	violated, err := WouldViolatePDB(context.Background(), c, &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-3"}})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(violated).To(BeFalse())
+	violated, err = tracker.Evict(context.Background(), &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-3"}})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(violated).To(BeTrue())
+}
+
+func TestGVKToAPIResource(t *testing.T) {
+	g := NewWithT(t)
+
+	fakeDiscovery := &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{}}
+	fakeDiscovery.Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+			APIResources: []metav1.APIResource{
+				{Name: "testmachines/status", Kind: "TestMachine"},
+				{Name: "testmachines", Kind: "TestMachine", Namespaced: true},
+			},
+		},
+	}
+	gvk := schema.GroupVersionKind{Group: "infrastructure.cluster.x-k8s.io", Version: "v1alpha3", Kind: "TestMachine"}
+
+	resource, err := GVKToAPIResource(context.Background(), fakeDiscovery, gvk)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(resource.Name).To(Equal("testmachines"))
+	g.Expect(resource.Group).To(Equal(gvk.Group))
+	g.Expect(resource.Version).To(Equal(gvk.Version))
+	g.Expect(resource.Namespaced).To(BeTrue())
+
+	// The result is served from the cache on subsequent calls.
+	fakeDiscovery.Resources = nil
+	resource, err = GVKToAPIResource(context.Background(), fakeDiscovery, gvk)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(resource.Name).To(Equal("testmachines"))
+
+	fakeDiscovery.Resources = []*metav1.APIResourceList{
+		{GroupVersion: "infrastructure.cluster.x-k8s.io/v1alpha3"},
+	}
+	_, err = GVKToAPIResource(context.Background(), fakeDiscovery, gvk.GroupVersion().WithKind("UnknownMachine"))
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+	_, err = GVKToAPIResource(context.Background(), fakeDiscovery, schema.GroupVersionKind{Group: "unknown.x-k8s.io", Version: "v1", Kind: "TestMachine"})
+	g.Expect(err).To(HaveOccurred())
+
+	// The cache isn't shared with other discovery clients.
+	otherDiscovery := &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{}}
+	_, err = GVKToAPIResource(context.Background(), otherDiscovery, gvk)
+	g.Expect(err).To(HaveOccurred())
+
+	// The result is discovered again once expired.
+	apiResourcesCacheLock.Lock()
+	cached := apiResourcesCache[fakeDiscovery][gvk]
+	cached.expires = time.Now().Add(-time.Second)
+	apiResourcesCache[fakeDiscovery][gvk] = cached
+	apiResourcesCacheLock.Unlock()
+	_, err = GVKToAPIResource(context.Background(), fakeDiscovery, gvk)
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+	// The result is discovered again once invalidated.
+	fakeDiscovery.Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: "infrastructure.cluster.x-k8s.io/v1alpha3",
+			APIResources: []metav1.APIResource{{Name: "testmachines", Kind: "TestMachine"}},
+		},
+	}
+	resource, err = GVKToAPIResource(context.Background(), fakeDiscovery, gvk)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(resource.Namespaced).To(BeFalse())
+	InvalidateAPIResourceCache(fakeDiscovery)
+	fakeDiscovery.Resources = nil
+	_, err = GVKToAPIResource(context.Background(), fakeDiscovery, gvk)
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestGetMachinesForCluster(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+
+	cluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "my-cluster",
+			Namespace: "my-ns",
+		},
+	}
+
+	machine := &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "my-machine",
+			Namespace: cluster.Namespace,
+			Labels: map[string]string{
+				clusterv1.ClusterLabelName: cluster.Name,
+			},
+		},
+	}
+
+	machineDifferentClusterNameSameNamespace := &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "other-machine",
+			Namespace: cluster.Namespace,
+			Labels: map[string]string{
+				clusterv1.ClusterLabelName: "other-cluster",
+			},
+		},
+	}
+
+	machineSameClusterNameDifferentNamespace := &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "other-machine",
+			Namespace: "other-ns",
+			Labels: map[string]string{
+				clusterv1.ClusterLabelName: cluster.Name,
+			},
+		},
+	}
+
+	c := fake.NewFakeClientWithScheme(
+		scheme,
+		machine,
+		machineDifferentClusterNameSameNamespace,
+		machineSameClusterNameDifferentNamespace,
+	)
+
+	machines, err := GetMachinesForCluster(context.Background(), c, cluster)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(machines.Items).To(HaveLen(1))
+	g.Expect(machines.Items[0].Labels[clusterv1.ClusterLabelName]).To(Equal(cluster.Name))
+}
+
+// orderedListClient wraps a client and returns the Machines it lists in the given order of names.
+type orderedListClient struct {
+	client.Client
+	order []string
+}
+
+func (c *orderedListClient) List(ctx context.Context, list runtime.Object, opts ...client.ListOption) error {
+	if err := c.Client.List(ctx, list, opts...); err != nil {
+		return err
+	}
+	machines := list.(*clusterv1.MachineList)
+	position := map[string]int{}
+	for i, name := range c.order {
+		position[name] = i
+	}
+	sort.Slice(machines.Items, func(i, j int) bool {
+		return position[machines.Items[i].Name] < position[machines.Items[j].Name]
+	})
+	return nil
+}
+
+func TestGetMachinesForClusterSortedByName(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+
+	cluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "my-cluster",
+			Namespace: "my-ns",
+		},
+	}
+
+	objs := []runtime.Object{}
+	for _, name := range []string{"b", "a", "c"} {
+		objs = append(objs, &clusterv1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: cluster.Namespace,
+				Labels: map[string]string{
+					clusterv1.ClusterLabelName: cluster.Name,
+				},
+			},
+		})
+	}
+	c := &orderedListClient{
+		Client: fake.NewFakeClientWithScheme(scheme, objs...),
+		order:  []string{"b", "a", "c"},
+	}
+
+	machines, err := GetMachinesForCluster(context.Background(), c, cluster)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	names := []string{}
+	for _, m := range machines.Items {
+		names = append(names, m.Name)
+	}
+	g.Expect(names).To(Equal([]string{"a", "b", "c"}))
+}
+
+// paginatingClient wraps a client and paginates MachineList results honoring the Limit and Continue list options.
+type paginatingClient struct {
+	client.Client
+	listCalls int
+}
+
+func (c *paginatingClient) List(ctx context.Context, list runtime.Object, opts ...client.ListOption) error {
+	c.listCalls++
+	if err := c.Client.List(ctx, list, opts...); err != nil {
+		return err
+	}
+
+	listOpts := (&client.ListOptions{}).ApplyOptions(opts)
+	machines := list.(*clusterv1.MachineList)
+
+	start := 0
+	if listOpts.Continue != "" {
+		var err error
+		if start, err = strconv.Atoi(listOpts.Continue); err != nil {
+			return err
+		}
+	}
+	end := len(machines.Items)
+	machines.Continue = ""
+	if listOpts.Limit > 0 && start+int(listOpts.Limit) < end {
+		end = start + int(listOpts.Limit)
+		machines.Continue = strconv.Itoa(end)
+	}
+	machines.Items = machines.Items[start:end]
+	return nil
+}
+
+func TestIsControlPlaneQuorumLost(t *testing.T) {
+	cluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "my-cluster",
+			Namespace: "my-ns",
+		},
+	}
+
+	healthy := func(m *clusterv1.Machine) {}
+	failed := func(m *clusterv1.Machine) {
+		m.Status.FailureMessage = pointer.StringPtr("failed to create the instance")
+	}
+	failedPhase := func(m *clusterv1.Machine) {
+		m.Status.SetTypedPhase(clusterv1.MachinePhaseFailed)
+	}
+	deleting := func(m *clusterv1.Machine) {
+		m.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	}
+
+	tests := []struct {
+		name       string
+		machines   []func(m *clusterv1.Machine)
+		workers    int
+		expectLost bool
+	}{
+		{
+			name: "no control plane machines",
+		},
+		{
+			name:     "single healthy machine",
+			machines: []func(m *clusterv1.Machine){healthy},
+		},
+		{
+			name:       "single failed machine",
+			machines:   []func(m *clusterv1.Machine){failed},
+			expectLost: true,
+		},
+		{
+			name:     "three machines, one failed",
+			machines: []func(m *clusterv1.Machine){healthy, healthy, failed},
+		},
+		{
+			name:       "single deleting machine",
+			machines:   []func(m *clusterv1.Machine){deleting},
+			expectLost: true,
+		},
+		{
+			name:     "three machines, one deleting",
+			machines: []func(m *clusterv1.Machine){healthy, healthy, deleting},
+		},
+		{
+			name:       "three machines, one failed and one deleting",
+			machines:   []func(m *clusterv1.Machine){healthy, deleting, failed},
+			expectLost: true,
+		},
+		{
+			name:       "three machines, two deleting",
+			machines:   []func(m *clusterv1.Machine){healthy, deleting, deleting},
+			expectLost: true,
+		},
+		{
+			name:       "three machines, two failed, one of them deleting",
+			machines:   []func(m *clusterv1.Machine){healthy, failed, func(m *clusterv1.Machine) { failed(m); deleting(m) }},
+			expectLost: true,
+		},
+		{
+			name:     "five machines, two failed",
+			machines: []func(m *clusterv1.Machine){healthy, healthy, healthy, failedPhase, failed},
+		},
+		{
+			name:     "five machines, one failed and one deleting",
+			machines: []func(m *clusterv1.Machine){healthy, healthy, healthy, failed, deleting},
+		},
+		{
+			name:       "five machines, two failed and one deleting",
+			machines:   []func(m *clusterv1.Machine){healthy, healthy, failedPhase, failed, deleting},
+			expectLost: true,
+		},
+		{
+			name:       "five machines, three failed",
+			machines:   []func(m *clusterv1.Machine){healthy, healthy, failedPhase, failed, failed},
+			expectLost: true,
+		},
+		{
+			name:     "failed worker machines are ignored",
+			machines: []func(m *clusterv1.Machine){healthy, healthy, healthy},
+			workers:  5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			scheme := runtime.NewScheme()
+			g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+
+			objs := []runtime.Object{}
+			for i, mutate := range tt.machines {
+				m := &clusterv1.Machine{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      fmt.Sprintf("control-plane-%d", i),
+						Namespace: cluster.Namespace,
+						Labels: map[string]string{
+							clusterv1.ClusterLabelName:             cluster.Name,
+							clusterv1.MachineControlPlaneLabelName: "",
+						},
+					},
+				}
+				mutate(m)
+				objs = append(objs, m)
+			}
+			for i := 0; i < tt.workers; i++ {
+				m := &clusterv1.Machine{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      fmt.Sprintf("worker-%d", i),
+						Namespace: cluster.Namespace,
+						Labels:    map[string]string{clusterv1.ClusterLabelName: cluster.Name},
+					},
+				}
+				failed(m)
+				objs = append(objs, m)
+			}
+
+			lost, err := IsControlPlaneQuorumLost(context.Background(), fake.NewFakeClientWithScheme(scheme, objs...), cluster)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(lost).To(Equal(tt.expectLost))
+		})
+	}
+}
+
+func TestGetMachinesForClusterPaged(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+
+	cluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "my-cluster",
+			Namespace: "my-ns",
+		},
+	}
+
+	objs := []runtime.Object{
+		&clusterv1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "other-machine",
+				Namespace: cluster.Namespace,
+				Labels: map[string]string{
+					clusterv1.ClusterLabelName: "other-cluster",
+				},
+			},
+		},
+	}
+	for i := 0; i < 5; i++ {
+		objs = append(objs, &clusterv1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("my-machine-%d", i),
+				Namespace: cluster.Namespace,
+				Labels: map[string]string{
+					clusterv1.ClusterLabelName: cluster.Name,
+				},
+			},
+		})
+	}
+
+	testCases := []struct {
+		name              string
+		pageSize          int64
+		expectedListCalls int
+	}{
+		{
+			name:              "page size smaller than the number of machines",
+			pageSize:          2,
+			expectedListCalls: 3,
+		},
+		{
+			name:              "page size equal to the number of machines",
+			pageSize:          5,
+			expectedListCalls: 1,
+		},
+		{
+			name:              "no page size",
+			pageSize:          0,
+			expectedListCalls: 1,
 		},
 	}
 
-	machineSameClusterNameDifferentNamespace := &clusterv1.Machine{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      "other-machine",
-			Namespace: "other-ns",
-			Labels: map[string]string{
-				clusterv1.ClusterLabelName: cluster.Name,
-			},
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			c := &paginatingClient{Client: fake.NewFakeClientWithScheme(scheme, objs...)}
+
+			machines, err := GetMachinesForClusterPaged(context.Background(), c, cluster, tc.pageSize)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(c.listCalls).To(Equal(tc.expectedListCalls))
+			g.Expect(machines.Items).To(HaveLen(5))
+			for _, m := range machines.Items {
+				g.Expect(m.Labels[clusterv1.ClusterLabelName]).To(Equal(cluster.Name))
+			}
+		})
+	}
+
+	t.Run("the next page isn't requested once the context is canceled", func(t *testing.T) {
+		g := NewWithT(t)
+
+		c := &paginatingClient{Client: fake.NewFakeClientWithScheme(scheme, objs...)}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := GetMachinesForClusterPaged(ctx, c, cluster, 2)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(errors.Cause(err)).To(Equal(context.Canceled))
+		g.Expect(c.listCalls).To(Equal(1))
+	})
+}
+
+func TestGetMachineDeploymentRevision(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		expected    int64
+		expectErr   bool
+	}{
+		{
+			name:     "no revision annotation",
+			expected: 0,
+		},
+		{
+			name:        "zero revision",
+			annotations: map[string]string{clusterv1.RevisionAnnotation: "0"},
+			expected:    0,
+		},
+		{
+			name:        "positive revision",
+			annotations: map[string]string{clusterv1.RevisionAnnotation: "3"},
+			expected:    3,
+		},
+		{
+			name:        "invalid revision",
+			annotations: map[string]string{clusterv1.RevisionAnnotation: "three"},
+			expectErr:   true,
 		},
 	}
 
-	c := fake.NewFakeClientWithScheme(
-		scheme,
-		machine,
-		machineDifferentClusterNameSameNamespace,
-		machineSameClusterNameDifferentNamespace,
-	)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
 
-	machines, err := GetMachinesForCluster(context.Background(), c, cluster)
-	g.Expect(err).NotTo(HaveOccurred())
-	g.Expect(machines.Items).To(HaveLen(1))
-	g.Expect(machines.Items[0].Labels[clusterv1.ClusterLabelName]).To(Equal(cluster.Name))
+			md := &clusterv1.MachineDeployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "md", Namespace: "default", Annotations: tt.annotations},
+			}
+			revision, err := GetMachineDeploymentRevision(md)
+			if tt.expectErr {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(revision).To(Equal(tt.expected))
+		})
+	}
 }
 
 func TestModifyImageTag(t *testing.T) {
@@ -492,6 +2095,65 @@ func TestModifyImageTag(t *testing.T) {
 	})
 }
 
+func TestVersionFromImageTag(t *testing.T) {
+	testCases := []struct {
+		name        string
+		image       string
+		expected    semver.Version
+		expectError bool
+	}{
+		{
+			name:     "image with a repository and a semver tag",
+			image:    "example.com/image:1.17.3",
+			expected: semver.Version{Major: 1, Minor: 17, Patch: 3},
+		},
+		{
+			name:     "image with a docker compatible tag",
+			image:    "example.com/image:v1.17.4_build1",
+			expected: semver.Version{Major: 1, Minor: 17, Patch: 4},
+		},
+		{
+			name:     "image with subpaths",
+			image:    "example.com/subpaths/are/okay/image:v1.18.0",
+			expected: semver.Version{Major: 1, Minor: 18, Patch: 0},
+		},
+		{
+			name:     "image without a repository",
+			image:    "image:1.17.3",
+			expected: semver.Version{Major: 1, Minor: 17, Patch: 3},
+		},
+		{
+			name:        "image without a tag",
+			image:       "example.com/image",
+			expectError: true,
+		},
+		{
+			name:        "image with a tag that is not a version",
+			image:       "example.com/image:latest",
+			expectError: true,
+		},
+		{
+			name:        "invalid image name",
+			image:       "example.com/image:$@$(*",
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			version, err := VersionFromImageTag(tc.image)
+			if tc.expectError {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(version).To(Equal(tc.expected))
+		})
+	}
+}
+
 func TestModifyImageRepository(t *testing.T) {
 	const testRepository = "example.com/new"
 	g := NewGomegaWithT(t)
@@ -589,6 +2251,60 @@ func TestEnsureOwnerRef(t *testing.T) {
 	})
 }
 
+func TestEnsureOwnerRefWithOptions(t *testing.T) {
+	g := NewWithT(t)
+
+	t.Run("should set Controller and BlockOwnerDeletion on a new owner reference", func(t *testing.T) {
+		obj := &clusterv1.Machine{}
+		ref := metav1.OwnerReference{
+			APIVersion: clusterv1.GroupVersion.String(),
+			Kind:       "Cluster",
+			Name:       "test-cluster",
+		}
+		obj.OwnerReferences = EnsureOwnerRefWithOptions(obj.OwnerReferences, ref, false, true)
+		g.Expect(obj.OwnerReferences).Should(HaveLen(1))
+		g.Expect(obj.OwnerReferences[0].Controller).To(Equal(pointer.BoolPtr(false)))
+		g.Expect(obj.OwnerReferences[0].BlockOwnerDeletion).To(Equal(pointer.BoolPtr(true)))
+	})
+
+	t.Run("should update BlockOwnerDeletion on an existing owner reference", func(t *testing.T) {
+		obj := &clusterv1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				OwnerReferences: []metav1.OwnerReference{
+					{
+						APIVersion:         clusterv1.GroupVersion.String(),
+						Kind:               "Cluster",
+						Name:               "test-cluster",
+						BlockOwnerDeletion: pointer.BoolPtr(false),
+					},
+				},
+			},
+		}
+		ref := metav1.OwnerReference{
+			APIVersion: clusterv1.GroupVersion.String(),
+			Kind:       "Cluster",
+			Name:       "test-cluster",
+		}
+		obj.OwnerReferences = EnsureOwnerRefWithOptions(obj.OwnerReferences, ref, true, true)
+		g.Expect(obj.OwnerReferences).Should(HaveLen(1))
+		g.Expect(obj.OwnerReferences[0].Controller).To(Equal(pointer.BoolPtr(true)))
+		g.Expect(obj.OwnerReferences[0].BlockOwnerDeletion).To(Equal(pointer.BoolPtr(true)))
+	})
+
+	t.Run("should preserve BlockOwnerDeletion through a round-trip", func(t *testing.T) {
+		ref := metav1.OwnerReference{
+			APIVersion: clusterv1.GroupVersion.String(),
+			Kind:       "Cluster",
+			Name:       "test-cluster",
+		}
+		refs := EnsureOwnerRefWithOptions(nil, ref, false, true)
+		refs = EnsureOwnerRefWithOptions(refs, refs[0], false, true)
+		g.Expect(refs).Should(HaveLen(1))
+		g.Expect(refs[0].BlockOwnerDeletion).To(Equal(pointer.BoolPtr(true)))
+		g.Expect(HasOwnerRef(refs, ref)).To(BeTrue())
+	})
+}
+
 func TestClusterToObjectsMapper(t *testing.T) {
 	g := NewWithT(t)
 
@@ -601,8 +2317,12 @@ func TestClusterToObjectsMapper(t *testing.T) {
 		},
 	}
 
+	deletingCluster := cluster.DeepCopy()
+	deletingCluster.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+
 	table := []struct {
 		name        string
+		cluster     *clusterv1.Cluster
 		objects     []runtime.Object
 		input       runtime.Object
 		output      []ctrl.Request
@@ -673,16 +2393,73 @@ func TestClusterToObjectsMapper(t *testing.T) {
 				{NamespacedName: client.ObjectKey{Name: "md3"}},
 			},
 		},
+		{
+			name:    "should not return requests for a cluster being deleted",
+			cluster: deletingCluster,
+			input:   &clusterv1.MachineList{},
+			objects: []runtime.Object{
+				&clusterv1.Machine{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "machine1",
+						Labels: map[string]string{
+							clusterv1.ClusterLabelName: "test1",
+						},
+					},
+				},
+			},
+			output: []ctrl.Request{},
+		},
 	}
 
 	for _, tc := range table {
-		tc.objects = append(tc.objects, cluster)
+		if tc.cluster == nil {
+			tc.cluster = cluster
+		}
+		tc.objects = append(tc.objects, tc.cluster)
 		client := fake.NewFakeClientWithScheme(scheme, tc.objects...)
 
 		f, err := ClusterToObjectsMapper(client, tc.input, scheme)
 		g.Expect(err != nil, err).To(Equal(tc.expectError))
-		g.Expect(f.Map(handler.MapObject{Object: cluster})).To(ConsistOf(tc.output))
+		g.Expect(f.Map(handler.MapObject{Object: tc.cluster})).To(ConsistOf(tc.output))
+	}
+}
+
+func TestClusterToMultipleObjectsMapper(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+
+	cluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test1",
+		},
 	}
+	labels := map[string]string{clusterv1.ClusterLabelName: "test1"}
+
+	client := fake.NewFakeClientWithScheme(scheme,
+		cluster,
+		&clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "machine1", Labels: labels}},
+		&clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "shared", Labels: labels}},
+		&clusterv1.MachineSet{ObjectMeta: metav1.ObjectMeta{Name: "ms1", Labels: labels}},
+		&clusterv1.MachineSet{ObjectMeta: metav1.ObjectMeta{Name: "shared", Labels: labels}},
+		&clusterv1.MachineDeployment{ObjectMeta: metav1.ObjectMeta{Name: "md1", Labels: map[string]string{clusterv1.ClusterLabelName: "test2"}}},
+	)
+
+	f, err := ClusterToMultipleObjectsMapper(client, []runtime.Object{
+		&clusterv1.MachineList{},
+		&clusterv1.MachineSetList{},
+		&clusterv1.MachineDeploymentList{},
+	}, scheme)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(f.Map(handler.MapObject{Object: cluster})).To(ConsistOf(
+		ctrl.Request{NamespacedName: types.NamespacedName{Name: "machine1"}},
+		ctrl.Request{NamespacedName: types.NamespacedName{Name: "shared"}},
+		ctrl.Request{NamespacedName: types.NamespacedName{Name: "ms1"}},
+	))
+
+	_, err = ClusterToMultipleObjectsMapper(client, []runtime.Object{&clusterv1.MachineList{}, &clusterv1.Machine{}}, scheme)
+	g.Expect(err).To(HaveOccurred())
 }
 
 func TestOrdinalize(t *testing.T) {
@@ -710,3 +2487,283 @@ func TestOrdinalize(t *testing.T) {
 	}
 
 }
+
+func TestComputeMachineTemplateHash(t *testing.T) {
+	g := NewWithT(t)
+
+	template := &clusterv1.MachineTemplateSpec{
+		ObjectMeta: clusterv1.ObjectMeta{Labels: map[string]string{"foo": "bar"}},
+		Spec: clusterv1.MachineSpec{
+			ClusterName: "test-cluster",
+			Version:     pointer.StringPtr("v1.17.3"),
+		},
+	}
+
+	hash, err := ComputeMachineTemplateHash(template)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(hash).NotTo(BeEmpty())
+
+	// The same template always has the same hash.
+	again, err := ComputeMachineTemplateHash(template.DeepCopy())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(again).To(Equal(hash))
+
+	// A different template has a different hash.
+	other := template.DeepCopy()
+	other.Spec.Version = pointer.StringPtr("v1.18.0")
+	otherHash, err := ComputeMachineTemplateHash(other)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(otherHash).NotTo(Equal(hash))
+
+	_, err = ComputeMachineTemplateHash(nil)
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestSetClusterCondition(t *testing.T) {
+	past := metav1.NewTime(metav1.Now().Add(-time.Hour).Truncate(time.Second))
+
+	existing := func() *clusterv1.Cluster {
+		return &clusterv1.Cluster{
+			Status: clusterv1.ClusterStatus{
+				Conditions: clusterv1.Conditions{
+					{Type: clusterv1.ClusterHealthyCondition, Status: corev1.ConditionFalse, Reason: "Waiting", LastTransitionTime: past},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name                  string
+		cluster               *clusterv1.Cluster
+		condition             clusterv1.Condition
+		expectTransitionReset bool
+	}{
+		{
+			name:                  "new condition",
+			cluster:               &clusterv1.Cluster{},
+			condition:             clusterv1.Condition{Type: clusterv1.ClusterHealthyCondition, Status: corev1.ConditionTrue},
+			expectTransitionReset: true,
+		},
+		{
+			name:                  "existing condition with a different status",
+			cluster:               existing(),
+			condition:             clusterv1.Condition{Type: clusterv1.ClusterHealthyCondition, Status: corev1.ConditionTrue},
+			expectTransitionReset: true,
+		},
+		{
+			name:                  "existing condition with the same status and a different reason",
+			cluster:               existing(),
+			condition:             clusterv1.Condition{Type: clusterv1.ClusterHealthyCondition, Status: corev1.ConditionFalse, Reason: "StillWaiting"},
+			expectTransitionReset: false,
+		},
+		{
+			name:                  "existing condition with the same status and a different message",
+			cluster:               existing(),
+			condition:             clusterv1.Condition{Type: clusterv1.ClusterHealthyCondition, Status: corev1.ConditionFalse, Reason: "Waiting", Message: "for the control plane"},
+			expectTransitionReset: false,
+		},
+		{
+			name:                  "existing condition with the same state",
+			cluster:               existing(),
+			condition:             clusterv1.Condition{Type: clusterv1.ClusterHealthyCondition, Status: corev1.ConditionFalse, Reason: "Waiting"},
+			expectTransitionReset: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			SetClusterCondition(tt.cluster, tt.condition)
+
+			g.Expect(tt.cluster.Status.Conditions).To(HaveLen(1))
+			got := GetClusterCondition(tt.cluster, tt.condition.Type)
+			g.Expect(got).NotTo(BeNil())
+			g.Expect(got.Status).To(Equal(tt.condition.Status))
+			g.Expect(got.Reason).To(Equal(tt.condition.Reason))
+			if tt.expectTransitionReset {
+				g.Expect(got.LastTransitionTime.After(past.Time)).To(BeTrue())
+			} else {
+				g.Expect(got.LastTransitionTime).To(Equal(past))
+			}
+		})
+	}
+}
+
+func TestGetClusterCondition(t *testing.T) {
+	g := NewWithT(t)
+
+	cluster := &clusterv1.Cluster{
+		Status: clusterv1.ClusterStatus{
+			Conditions: clusterv1.Conditions{{Type: clusterv1.ClusterHealthyCondition, Status: corev1.ConditionTrue}},
+		},
+	}
+	g.Expect(GetClusterCondition(cluster, clusterv1.ClusterHealthyCondition)).NotTo(BeNil())
+	g.Expect(GetClusterCondition(cluster, clusterv1.InfrastructureProviderInstalledCondition)).To(BeNil())
+	g.Expect(GetClusterCondition(&clusterv1.Cluster{}, clusterv1.ClusterHealthyCondition)).To(BeNil())
+}
+
+func TestGetMachineByProviderID(t *testing.T) {
+	scheme := runtime.NewScheme()
+	NewWithT(t).Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+
+	machine := func(namespace, name string, providerID *string) *clusterv1.Machine {
+		return &clusterv1.Machine{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+			Spec:       clusterv1.MachineSpec{ProviderID: providerID},
+		}
+	}
+
+	objs := []runtime.Object{
+		machine("my-ns", "machine-1", pointer.StringPtr("aws:///us-east-1a/i-1")),
+		machine("my-ns", "machine-2", pointer.StringPtr("aws:///us-east-1a/i-2")),
+		machine("my-ns", "machine-3", nil),
+		machine("other-ns", "machine-4", pointer.StringPtr("aws:///us-east-1a/i-4")),
+		machine("dup-ns", "machine-5", pointer.StringPtr("aws:///us-east-1a/i-5")),
+		machine("dup-ns", "machine-6", pointer.StringPtr("aws:///us-east-1b/i-5")),
+	}
+
+	tests := []struct {
+		name       string
+		namespace  string
+		providerID string
+		expected   string
+		expectErr  bool
+	}{
+		{
+			name:       "machine with the provider ID",
+			namespace:  "my-ns",
+			providerID: "aws:///us-east-1a/i-2",
+			expected:   "machine-2",
+		},
+		{
+			name:       "provider ID in another format",
+			namespace:  "my-ns",
+			providerID: "aws:////i-1",
+			expected:   "machine-1",
+		},
+		{
+			name:       "machine with the provider ID in another namespace",
+			namespace:  "my-ns",
+			providerID: "aws:///us-east-1a/i-4",
+		},
+		{
+			name:       "multiple machines with the provider ID",
+			namespace:  "dup-ns",
+			providerID: "aws:///us-east-1a/i-5",
+			expectErr:  true,
+		},
+		{
+			name:       "invalid provider ID",
+			namespace:  "my-ns",
+			providerID: "i-1",
+			expectErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			c := fake.NewFakeClientWithScheme(scheme, objs...)
+			m, err := GetMachineByProviderID(context.Background(), c, tt.namespace, tt.providerID)
+			if tt.expectErr {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			if tt.expected == "" {
+				g.Expect(m).To(BeNil())
+				return
+			}
+			g.Expect(m).NotTo(BeNil())
+			g.Expect(m.Name).To(Equal(tt.expected))
+		})
+	}
+}
+
+func TestIndexMachineByProviderID(t *testing.T) {
+	g := NewWithT(t)
+
+	machine := &clusterv1.Machine{Spec: clusterv1.MachineSpec{ProviderID: pointer.StringPtr("aws:///us-east-1a/i-1")}}
+	g.Expect(IndexMachineByProviderID(machine)).To(ConsistOf("aws://i-1"))
+
+	// The optional segments of the provider ID are not indexed.
+	machine.Spec.ProviderID = pointer.StringPtr("aws:////i-1")
+	g.Expect(IndexMachineByProviderID(machine)).To(ConsistOf("aws://i-1"))
+
+	machine.Spec.ProviderID = pointer.StringPtr("i-1")
+	g.Expect(IndexMachineByProviderID(machine)).To(BeEmpty())
+
+	g.Expect(IndexMachineByProviderID(&clusterv1.Machine{})).To(BeEmpty())
+	g.Expect(IndexMachineByProviderID(&clusterv1.MachineSet{})).To(BeEmpty())
+}
+
+func TestGetObjectGeneration(t *testing.T) {
+	tests := []struct {
+		name                  string
+		obj                   map[string]interface{}
+		expectGeneration      int64
+		expectGenerationFound bool
+		expectObserved        int64
+		expectObservedFound   bool
+	}{
+		{
+			name: "generation and observed generation set",
+			obj: map[string]interface{}{
+				"metadata": map[string]interface{}{"generation": int64(3)},
+				"status":   map[string]interface{}{"observedGeneration": int64(2)},
+			},
+			expectGeneration:      3,
+			expectGenerationFound: true,
+			expectObserved:        2,
+			expectObservedFound:   true,
+		},
+		{
+			name: "values decoded as floats",
+			obj: map[string]interface{}{
+				"metadata": map[string]interface{}{"generation": float64(3)},
+				"status":   map[string]interface{}{"observedGeneration": float64(3)},
+			},
+			expectGeneration:      3,
+			expectGenerationFound: true,
+			expectObserved:        3,
+			expectObservedFound:   true,
+		},
+		{
+			name: "observed generation not reported",
+			obj: map[string]interface{}{
+				"metadata": map[string]interface{}{"generation": int64(1)},
+				"status":   map[string]interface{}{"ready": true},
+			},
+			expectGeneration:      1,
+			expectGenerationFound: true,
+		},
+		{
+			name: "observed generation of the wrong type",
+			obj: map[string]interface{}{
+				"status": map[string]interface{}{"observedGeneration": "1"},
+			},
+		},
+		{
+			name: "empty object",
+			obj:  map[string]interface{}{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			obj := &unstructured.Unstructured{Object: tt.obj}
+
+			generation, found := GetObjectGeneration(obj)
+			g.Expect(found).To(Equal(tt.expectGenerationFound))
+			g.Expect(generation).To(Equal(tt.expectGeneration))
+
+			observed, found := GetObservedGeneration(obj)
+			g.Expect(found).To(Equal(tt.expectObservedFound))
+			g.Expect(observed).To(Equal(tt.expectObserved))
+		})
+	}
+}